@@ -0,0 +1,168 @@
+package mcpfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Approval modes accepted by PathRule.Approval.
+const (
+	ApprovalNone     = "none"
+	ApprovalRequired = "required"
+)
+
+// DefaultApprovalTimeout is how long the user has to answer an approval
+// request when approval_timeout is not configured.
+const DefaultApprovalTimeout = 2 * time.Minute
+
+// maxApprovalDiff caps the diff shown to the user; longer diffs are cut.
+const maxApprovalDiff = 8 << 10
+
+// ApprovalRequest describes an operation waiting for the user's consent.
+type ApprovalRequest struct {
+	Op     Permission
+	Path   string
+	Action string // short description such as "write file" or "delete"
+	Diff   string // optional unified diff of the change
+}
+
+// Message renders the request as the text shown to the user.
+func (r ApprovalRequest) Message() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The agent wants to %s %q (%s permission).", r.Action, r.Path, r.Op)
+	if r.Diff != "" {
+		diff := r.Diff
+		if len(diff) > maxApprovalDiff {
+			diff = diff[:maxApprovalDiff] + "\n... (diff truncated)\n"
+		}
+		sb.WriteString("\n\n")
+		sb.WriteString(diff)
+	}
+	return sb.String()
+}
+
+// Approver asks a human to confirm an operation. It returns nil if the
+// operation was approved and an error wrapping ErrApprovalDenied otherwise.
+type Approver interface {
+	RequestApproval(ctx context.Context, req ApprovalRequest) error
+}
+
+// RequiresApproval reports whether op on path needs the user's approval
+// before it proceeds: whether any rule covering the path requires it, not
+// only the rule granting op. A broader rule listed first thus does not skip
+// the approval of a narrower one. Paths are compared with their symlinks
+// resolved, as Evaluate does.
+func (c *Config) RequiresApproval(op Permission, path string) bool {
+	if c == nil || op == PermNone {
+		return false
+	}
+	loc := resolveParent(path)
+	real := resolvePath(loc)
+	for i := range c.Paths {
+		r := &c.Paths[i]
+		if r.Approval == ApprovalRequired && (r.covers(loc) || r.covers(real)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionApprover asks the user of an MCP session for approval through
+// elicitation. Clients that do not support elicitation cannot approve
+// anything: sampling would let the client's model answer for the user.
+// Requests that are not answered within Timeout are denied.
+type SessionApprover struct {
+	Session *mcp.ServerSession
+	Timeout time.Duration
+}
+
+// NewSessionApprover returns an approver for ss using the configured timeout.
+func NewSessionApprover(cfg *Config, ss *mcp.ServerSession) *SessionApprover {
+	timeout := DefaultApprovalTimeout
+	if cfg != nil && cfg.ApprovalTimeout > 0 {
+		timeout = cfg.ApprovalTimeout
+	}
+	return &SessionApprover{Session: ss, Timeout: timeout}
+}
+
+func (a *SessionApprover) RequestApproval(ctx context.Context, req ApprovalRequest) error {
+	if a.Session == nil {
+		return fmt.Errorf("%w: no client session to ask", ErrApprovalDenied)
+	}
+	timeout := a.Timeout
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var caps *mcp.ClientCapabilities
+	if params := a.Session.InitializeParams(); params != nil {
+		caps = params.Capabilities
+	}
+	if caps == nil || caps.Elicitation == nil {
+		return fmt.Errorf("%w: client does not support elicitation", ErrApprovalDenied)
+	}
+	approved, err := a.elicit(ctx, req)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: no answer within %s", ErrApprovalDenied, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrApprovalDenied, err)
+	}
+	if !approved {
+		return fmt.Errorf("%w: user declined to %s %q", ErrApprovalDenied, req.Action, req.Path)
+	}
+	return nil
+}
+
+func (a *SessionApprover) elicit(ctx context.Context, req ApprovalRequest) (bool, error) {
+	res, err := a.Session.Elicit(ctx, &mcp.ElicitParams{
+		Message: req.Message(),
+		RequestedSchema: &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"approve": {
+					Type:        "boolean",
+					Description: "Allow this operation",
+				},
+			},
+			Required: []string{"approve"},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	if res.Action != "accept" {
+		return false, nil
+	}
+	approve, _ := res.Content["approve"].(bool)
+	return approve, nil
+}
+
+// Confirm asks approver to approve req if a rule covering its path requires
+// it (see RequiresApproval). Tools performing writes, deletes or exec call this right
+// before acting. A nil approver denies operations that need approval.
+func (a *App) Confirm(ctx context.Context, approver Approver, req ApprovalRequest) error {
+	if !a.Cfg.RequiresApproval(req.Op, req.Path) {
+		return nil
+	}
+	if approver == nil {
+		return fmt.Errorf("%w: %q requires approval but no approver is available", ErrApprovalDenied, req.Path)
+	}
+	if err := approver.RequestApproval(ctx, req); err != nil {
+		a.Logger.LogAttrs(ctx, slog.LevelInfo, "operation not approved",
+			slog.String("path", req.Path), slog.String("op", req.Op.String()), slog.Any("error", err))
+		return err
+	}
+	a.Logger.LogAttrs(ctx, slog.LevelInfo, "operation approved",
+		slog.String("path", req.Path), slog.String("op", req.Op.String()))
+	return nil
+}
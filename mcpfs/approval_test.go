@@ -0,0 +1,162 @@
+package mcpfs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// connectApprover connects an in-memory client using the given options and
+// returns an approver bound to the server side of the session.
+func connectApprover(t *testing.T, opts *mcp.ClientOptions, timeout time.Duration) *mcpfs.SessionApprover {
+	t.Helper()
+	ctx := context.Background()
+	ct, st := mcp.NewInMemoryTransports()
+	server := mcp.NewServer(&mcp.Implementation{Name: "mcpfs-test"}, nil)
+	ss, err := server.Connect(ctx, st, nil)
+	if err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	client := mcp.NewClient(&mcp.Implementation{Name: "client"}, opts)
+	cs, err := client.Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return &mcpfs.SessionApprover{Session: ss, Timeout: timeout}
+}
+
+func TestSessionApprover_Elicitation(t *testing.T) {
+	var shown string
+	approver := connectApprover(t, &mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			shown = req.Params.Message
+			return &mcp.ElicitResult{Action: "accept", Content: map[string]any{"approve": true}}, nil
+		},
+	}, time.Second)
+
+	req := mcpfs.ApprovalRequest{
+		Op:     mcpfs.PermWrite,
+		Path:   "/srv/release/notes.md",
+		Action: "write",
+		Diff:   mcpfs.UnifiedDiff("a/notes.md", "b/notes.md", "v1\n", "v2\n"),
+	}
+	if err := approver.RequestApproval(context.Background(), req); err != nil {
+		t.Fatalf("RequestApproval: %v", err)
+	}
+	if shown != req.Message() {
+		t.Fatalf("client was shown %q, want %q", shown, req.Message())
+	}
+}
+
+func TestSessionApprover_DeclineAndTimeout(t *testing.T) {
+	approver := connectApprover(t, &mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			return &mcp.ElicitResult{Action: "decline"}, nil
+		},
+	}, time.Second)
+	req := mcpfs.ApprovalRequest{Op: mcpfs.PermWrite, Path: "/x", Action: "delete"}
+	if err := approver.RequestApproval(context.Background(), req); !errors.Is(err, mcpfs.ErrApprovalDenied) {
+		t.Fatalf("expected denial, got %v", err)
+	}
+
+	approver = connectApprover(t, &mcp.ClientOptions{
+		ElicitationHandler: func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}, 20*time.Millisecond)
+	if err := approver.RequestApproval(context.Background(), req); !errors.Is(err, mcpfs.ErrApprovalDenied) {
+		t.Fatalf("expected denial on timeout, got %v", err)
+	}
+}
+
+func TestSessionApprover_DeniesWithoutElicitation(t *testing.T) {
+	sampled := false
+	approver := connectApprover(t, &mcp.ClientOptions{
+		CreateMessageHandler: func(ctx context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			sampled = true
+			return &mcp.CreateMessageResult{Role: "assistant", Content: &mcp.TextContent{Text: "APPROVE"}}, nil
+		},
+	}, time.Second)
+	req := mcpfs.ApprovalRequest{Op: mcpfs.PermWrite, Path: "/x", Action: "delete"}
+	if err := approver.RequestApproval(context.Background(), req); !errors.Is(err, mcpfs.ErrApprovalDenied) {
+		t.Fatalf("expected denial without elicitation, got %v", err)
+	}
+	if sampled {
+		t.Fatal("approval was asked of the client's model")
+	}
+}
+
+func TestApp_ConfirmOnlyForRulesRequiringApproval(t *testing.T) {
+	cfg, err := mcpfs.ParseConfigData([]byte(`
+paths:
+  - path: /srv/scratch
+    perms: [read, write]
+  - path: /srv/release
+    perms: [read, write]
+    approval: required
+`))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	app, err := mcpfs.NewApp(cfg, nil, nil)
+	if err != nil {
+		t.Fatalf("NewApp: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := app.Confirm(ctx, nil, mcpfs.ApprovalRequest{Op: mcpfs.PermWrite, Path: "/srv/scratch/a"}); err != nil {
+		t.Fatalf("scratch write should not need approval: %v", err)
+	}
+	err = app.Confirm(ctx, nil, mcpfs.ApprovalRequest{Op: mcpfs.PermWrite, Path: "/srv/release/a"})
+	if !errors.Is(err, mcpfs.ErrApprovalDenied) {
+		t.Fatalf("expected release write to be denied without approver, got %v", err)
+	}
+}
+
+func TestApp_CommandRequiresApproval(t *testing.T) {
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("no cat in PATH")
+	}
+	if cat, err = filepath.Abs(cat); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, fmt.Sprintf("paths:\n  - path: %s\n    perms: [exec]\n    approval: required\n", cat))
+
+	_, err = app.Command(context.Background(), nil, "cat")
+	if !errors.Is(err, mcpfs.ErrApprovalDenied) {
+		t.Fatalf("Command without approver = %v, want approval denied", err)
+	}
+}
+
+func TestConfig_RequiresApprovalOfAnyCoveringRule(t *testing.T) {
+	cfg, err := mcpfs.ParseConfigData([]byte(`
+paths:
+  - path: /srv
+    perms: [read, write]
+  - path: /srv/release
+    perms: [read, write]
+    approval: required
+`))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	if got := cfg.MatchRule(mcpfs.PermWrite, "/srv/release/a"); got == nil || got.CleanPath() != "/srv" {
+		t.Fatalf("write is granted by %+v, want the /srv rule", got)
+	}
+	if !cfg.RequiresApproval(mcpfs.PermWrite, "/srv/release/a") {
+		t.Fatal("the broader /srv rule skipped the approval of /srv/release")
+	}
+	if cfg.RequiresApproval(mcpfs.PermWrite, "/srv/scratch/a") {
+		t.Fatal("/srv/scratch requires approval")
+	}
+}
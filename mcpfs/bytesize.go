@@ -0,0 +1,99 @@
+package mcpfs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize is a size in bytes. In YAML it may be written as a plain integer
+// or as a string with a unit suffix:
+//
+//	max_file_size: 1048576
+//	max_file_size: "10MB"   # SI units: KB, MB, GB, TB (powers of 1000)
+//	max_file_size: "10MiB"  # IEC units: KiB, MiB, GiB, TiB (powers of 1024)
+//	max_file_size: "10M"    # single letters are IEC units
+type ByteSize int64
+
+var byteSizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kib": 1 << 10,
+	"kb":  1000,
+	"m":   1 << 20,
+	"mib": 1 << 20,
+	"mb":  1000 * 1000,
+	"g":   1 << 30,
+	"gib": 1 << 30,
+	"gb":  1000 * 1000 * 1000,
+	"t":   1 << 40,
+	"tib": 1 << 40,
+	"tb":  1000 * 1000 * 1000 * 1000,
+}
+
+// ParseByteSize parses a size such as "512", "64KiB" or "1.5GB".
+func ParseByteSize(s string) (ByteSize, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	num, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	mult, ok := byteSizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q in %q", s[i:], s)
+	}
+	if n, err := strconv.ParseInt(num, 10, 64); err == nil {
+		if n < 0 || (mult > 1 && n > (1<<63-1)/mult) {
+			return 0, fmt.Errorf("size %q out of range", s)
+		}
+		return ByteSize(n * mult), nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	// float64(1<<63) is the first value past MaxInt64; converting anything
+	// at or above it to int64 is undefined.
+	if v := f * float64(mult); v < 1<<63 {
+		return ByteSize(v), nil
+	}
+	return 0, fmt.Errorf("size %q out of range", s)
+}
+
+// String formats the size using the largest IEC unit that keeps the value
+// at or above one, e.g. "1.5 GiB".
+func (b ByteSize) String() string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", int64(b))
+	}
+	div, exp := int64(unit), 0
+	for n := int64(b) / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGT"[exp])
+}
+
+func (b *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: size must be a scalar", value.Line)
+	}
+	v, err := ParseByteSize(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*b = v
+	return nil
+}
+
+func (b ByteSize) MarshalYAML() (any, error) {
+	return int64(b), nil
+}
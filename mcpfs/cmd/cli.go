@@ -23,6 +23,7 @@ type globalFlags struct {
 	cfgPath   string
 	logFile   string
 	logLevel  string
+	profile   string
 	flagDebug bool
 }
 
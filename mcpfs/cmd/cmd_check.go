@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/spf13/cobra"
+)
+
+func (s *state) newCheckCmd() *cobra.Command {
+	var perms []string
+	cmd := &cobra.Command{
+		Use:   "check [path...]",
+		Short: "Show which config files are loaded and which rule grants access to paths",
+		Long: `Show which config files are loaded and which rule grants access to paths.
+
+Without paths, check lists the config files in the order they are merged and
+the path rules with the file each came from, noting rules whose schedule
+does not grant anything now, and the sensitive paths that only rules with
+unsafe_allow_sensitive grant access to. With paths, it reports for each permission
+whether the path is allowed, by which rule and from which file.`,
+		PersistentPreRunE:  s.configPreRun,
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := s.loadConfig()
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			if len(args) == 0 {
+				if cfg.Profile() != "" {
+					fmt.Fprintf(w, "profile: %s\n", cfg.Profile())
+				}
+				fmt.Fprintln(w, "config files, in merge order:")
+				for _, f := range cfg.Files() {
+					fmt.Fprintf(w, "  %s\n", f)
+				}
+				fmt.Fprintln(w, "path rules, in match order:")
+				for i := range cfg.Paths {
+					r := &cfg.Paths[i]
+					notes := ""
+					if r.UnsafeAllowSensitive {
+						notes += ", unsafe_allow_sensitive"
+					}
+					if !r.ActiveAt(cfg.Now()) {
+						notes += ", inactive now"
+					}
+					fmt.Fprintf(w, "  %s (%s%s) from %s\n", r.CleanPath(), r.Permissions(), notes, r.Source())
+				}
+				fmt.Fprintln(w, "sensitive paths, granted only by rules with unsafe_allow_sensitive:")
+				for _, p := range cfg.SensitivePaths() {
+					fmt.Fprintf(w, "  %s\n", p)
+				}
+				return nil
+			}
+
+			var ops []mcpfs.Permission
+			for _, p := range perms {
+				op, err := mcpfs.ParsePermission(p)
+				if err != nil {
+					return err
+				}
+				ops = append(ops, op)
+			}
+			for _, target := range args {
+				for _, op := range ops {
+					d := cfg.Evaluate(mcpfs.Access{Op: op, Path: target})
+					r := d.Rule
+					switch {
+					case r == nil && d.Reason == "":
+						fmt.Fprintf(w, "%s %s: denied, no rule grants it\n", op, target)
+						continue
+					case r == nil:
+						fmt.Fprintf(w, "%s %s: denied, %s\n", op, target, d.Reason)
+						continue
+					}
+					fmt.Fprintf(w, "%s %s: allowed by %s (%s) from %s\n", op, target, r.CleanPath(), r.Permissions(), r.Source())
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&perms, "perms", []string{"read", "write"},
+		"permissions to check (read, write, execute, git)")
+	return cmd
+}
@@ -0,0 +1,84 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckCmd_ListsFilesRulesAndSensitivePaths(t *testing.T) {
+	f := NewFixture(t)
+	defer f.Teardown()
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yaml")
+	if err := os.WriteFile(base, []byte("paths:\n  - path: /srv\n    perms: [read]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte(`version: "2"
+include: [base.yaml]
+paths:
+  - path: /srv/mine
+    perms: [read, write]
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, err := f.Run("--config", cfgPath, "check")
+	if err != nil {
+		t.Fatalf("check returned error: %v, stderr=%s", err, stderr)
+	}
+	files, rest, ok := strings.Cut(stdout, "path rules, in match order:\n")
+	if !ok {
+		t.Fatalf("check printed no path rules:\n%s", stdout)
+	}
+	rules, sensitive, ok := strings.Cut(rest, "sensitive paths, granted only by rules with unsafe_allow_sensitive:\n")
+	if !ok {
+		t.Fatalf("check printed no sensitive paths:\n%s", stdout)
+	}
+
+	if want := "config files, in merge order:\n  " + base + "\n  " + cfgPath + "\n"; files != want {
+		t.Errorf("check listed config files\n%s\nwant\n%s", files, want)
+	}
+	if want := "  /srv/mine (read|write) from " + cfgPath + "\n  /srv (read) from " + base + "\n"; rules != want {
+		t.Errorf("check listed rules\n%s\nwant\n%s", rules, want)
+	}
+	home, err := f.Services.Env.GetHome()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		filepath.Join(home, ".ssh"),
+		base,
+		cfgPath,
+		".env*",
+		"*.pem",
+	} {
+		if !strings.Contains(sensitive, "  "+want+"\n") {
+			t.Errorf("check sensitive paths lack %q:\n%s", want, sensitive)
+		}
+	}
+}
+
+func TestCheckCmd_ReportsRuleOfPath(t *testing.T) {
+	f := NewFixture(t)
+	defer f.Teardown()
+
+	cfgPath, err := f.WithConfigFile("version: \"2\"\npaths:\n  - path: /srv\n    perms: [read]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, err := f.Run("--config", cfgPath, "check", "/srv/a", "/opt/b")
+	if err != nil {
+		t.Fatalf("check returned error: %v, stderr=%s", err, stderr)
+	}
+	want := "read /srv/a: allowed by /srv (read) from " + cfgPath + "\n" +
+		"write /srv/a: denied, no rule grants it\n" +
+		"read /opt/b: denied, no rule grants it\n" +
+		"write /opt/b: denied, no rule grants it\n"
+	if stdout != want {
+		t.Fatalf("check printed\n%s\nwant\n%s", stdout, want)
+	}
+}
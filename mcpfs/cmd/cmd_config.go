@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/spf13/cobra"
+)
+
+// newConfigCmd groups the commands that edit the config file. They work on
+// the file named by --config, or the default one, without loading it as the
+// running configuration, so they also fix configs that fail to load.
+func (s *state) newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                "config",
+		Short:              "Edit the config file, keeping its comments and layout",
+		PersistentPreRunE:  s.configPreRun,
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+	cmd.AddCommand(s.newRuleAddCmd("add-rule"))
+	cmd.AddCommand(s.newRuleRemoveCmd("remove-rule"))
+	cmd.AddCommand(s.newConfigSetCmd())
+	cmd.AddCommand(s.newConfigMigrateCmd())
+	cmd.AddCommand(s.newConfigSchemaCmd())
+	cmd.AddCommand(s.newConfigValidateCmd())
+	return cmd
+}
+
+// configPreRun replaces the root hooks for commands that work on the config
+// file rather than with the configured app.
+func (s *state) configPreRun(cmd *cobra.Command, args []string) error {
+	cmd.SetIn(s.InOrStdin())
+	cmd.SetOut(s.OutOrStdout())
+	cmd.SetErr(s.ErrOrStderr())
+	return nil
+}
+
+// configPath returns the config file the config commands edit.
+func (s *state) configPath() (string, error) {
+	if s.flags.cfgPath != "" {
+		return s.flags.cfgPath, nil
+	}
+	return mcpfs.DefaultConfigPath(s.Env())
+}
+
+// profileName returns the profile selected with --profile or, failing that,
+// the environment.
+func (s *state) profileName() string {
+	if s.flags.profile != "" {
+		return s.flags.profile
+	}
+	return s.Env().Get(mcpfs.ProfileEnv)
+}
+
+// parseOptions returns the options configs are parsed with, which resolve
+// environment variables in the injected environment.
+func (s *state) parseOptions() mcpfs.ParseOptions {
+	return mcpfs.ParseOptions{Env: s.Env()}
+}
+
+// loadConfig reads the config file with its includes and selects the
+// profile, as the server does at startup.
+func (s *state) loadConfig() (*mcpfs.Config, error) {
+	path, err := s.configPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := mcpfs.ReadAndParseConfigWith(path, s.parseOptions())
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.SelectProfile(s.profileName()); err != nil {
+		return nil, err
+	}
+	cfg.SetClock(s.Clock())
+	return cfg, nil
+}
+
+// editConfig applies edit to the config file and returns its path.
+func (s *state) editConfig(edit func(cfg *mcpfs.Config) error) (string, error) {
+	path, err := s.configPath()
+	if err != nil {
+		return "", err
+	}
+	return path, mcpfs.EditConfigFile(path, edit)
+}
+
+func (s *state) newConfigSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a setting such as log_level or rate_limits.max_concurrent",
+		Long: `Set a setting in the config file.
+
+The key is a dotted path of YAML keys and the value is parsed as YAML, so
+durations, numbers, booleans and lists are written with their proper types.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := s.editConfig(func(cfg *mcpfs.Config) error { return cfg.Set(args[0], args[1]) })
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "set %s in %s\n", args[0], path)
+			return nil
+		},
+	}
+}
+
+func (s *state) newConfigMigrateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Rewrite the config file in the current config version",
+		Long: `Rewrite the config file in the current config version.
+
+Configs of older versions are migrated each time they are loaded; this makes
+the change permanent. The original file is kept next to it with a .bak suffix.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := s.configPath()
+			if err != nil {
+				return err
+			}
+			from, backup, err := mcpfs.MigrateConfigFile(path)
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			if backup == "" {
+				fmt.Fprintf(w, "%s needs no migration to version %s\n", path, from)
+				return nil
+			}
+			fmt.Fprintf(w, "migrated %s from version %s to %s; the original is in %s\n",
+				path, from, mcpfs.CurrentConfigVersion, backup)
+			return nil
+		},
+	}
+}
+
+func (s *state) newConfigSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the config file",
+		Long: `Print the JSON Schema of the config file.
+
+Save it and point your editor at it for completion and validation, e.g. with
+the YAML language server:
+
+  # yaml-language-server: $schema=./mcpfs.schema.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schema, err := mcpfs.ConfigSchema()
+			if err != nil {
+				return err
+			}
+			data, err := json.MarshalIndent(schema, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return nil
+		},
+	}
+}
+
+func (s *state) newConfigValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file against the config schema",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := s.configPath()
+			if err != nil {
+				return err
+			}
+			data, err := mcpfs.ReadConfigData(path)
+			if err != nil {
+				return err
+			}
+			opts := s.parseOptions()
+			opts.Dir = filepath.Dir(path)
+			if _, err := mcpfs.ValidateConfigDataWith(data, opts); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s is valid\n", path)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,144 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestConfigCmd_Set(t *testing.T) {
+	f := NewFixture(t)
+	defer f.Teardown()
+
+	cfgPath, err := f.WithConfigFile("version: \"2\"\npaths: []\nlog_level: info # default\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, err := f.Run("--config", cfgPath, "config", "set", "log_level", "warn")
+	if err != nil {
+		t.Fatalf("config set returned error: %v, stderr=%s", err, stderr)
+	}
+	if want := "set log_level in " + cfgPath; !strings.Contains(stdout, want) {
+		t.Fatalf("config set printed %q, want it to contain %q", stdout, want)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "log_level: warn # default") {
+		t.Fatalf("config after set:\n%s", data)
+	}
+
+	_, _, err = f.Run("--config", cfgPath, "config", "set", "sandbox.landlock", "sometimes")
+	if err == nil || !strings.Contains(err.Error(), "refusing to write invalid config") {
+		t.Fatalf("setting an invalid landlock mode = %v, want it refused", err)
+	}
+	after, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(data) {
+		t.Fatalf("refused set changed the config:\n%s", after)
+	}
+}
+
+func TestConfigCmd_MigrateCurrentConfig(t *testing.T) {
+	f := NewFixture(t)
+	defer f.Teardown()
+
+	const v1 = "# old config\npaths:\n  - path: /srv\n    perms: [read]\n"
+	cfgPath, err := f.WithConfigFile(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, err := f.Run("--config", cfgPath, "config", "migrate")
+	if err != nil {
+		t.Fatalf("config migrate returned error: %v, stderr=%s", err, stderr)
+	}
+	if want := cfgPath + " needs no migration to version " + mcpfs.CurrentConfigVersion; !strings.Contains(stdout, want) {
+		t.Fatalf("config migrate printed %q, want it to contain %q", stdout, want)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != v1 {
+		t.Fatalf("config migrate rewrote a config needing no migration:\n%s", data)
+	}
+	if backups, _ := filepath.Glob(cfgPath + ".*.bak"); len(backups) != 0 {
+		t.Fatalf("config migrate left backups %v", backups)
+	}
+}
+
+func TestConfigCmd_MigrateRefusesNewerConfig(t *testing.T) {
+	f := NewFixture(t)
+	defer f.Teardown()
+
+	cfgPath, err := f.WithConfigFile("version: \"99\"\npaths: []\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.Run("--config", cfgPath, "config", "migrate"); err == nil || !strings.Contains(err.Error(), "newer than this mcpfs supports") {
+		t.Fatalf("migrating a newer config = %v, want it refused", err)
+	}
+}
+
+func TestConfigCmd_Validate(t *testing.T) {
+	f := NewFixture(t)
+	defer f.Teardown()
+
+	cfgPath, err := f.WithConfigFile("version: \"2\"\npaths:\n  - path: /srv\n    perms: [read, write]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, err := f.Run("--config", cfgPath, "config", "validate")
+	if err != nil {
+		t.Fatalf("config validate returned error: %v, stderr=%s", err, stderr)
+	}
+	if want := cfgPath + " is valid"; !strings.Contains(stdout, want) {
+		t.Fatalf("config validate printed %q, want it to contain %q", stdout, want)
+	}
+
+	if err := os.WriteFile(cfgPath, []byte("version: \"2\"\npaths:\n  - path: /srv\n    perms: [fly]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, err = f.Run("--config", cfgPath, "config", "validate")
+	if err == nil || !strings.HasPrefix(err.Error(), cfgPath+": ") {
+		t.Fatalf("validating a bad config = %v, want an error naming the file", err)
+	}
+	if strings.Contains(stdout, "is valid") {
+		t.Fatalf("config validate printed %q for a bad config", stdout)
+	}
+}
+
+func TestConfigCmd_Schema(t *testing.T) {
+	f := NewFixture(t)
+	defer f.Teardown()
+
+	stdout, stderr, err := f.Run("config", "schema")
+	if err != nil {
+		t.Fatalf("config schema returned error: %v, stderr=%s", err, stderr)
+	}
+	var schema struct {
+		Schema     string                     `json:"$schema"`
+		Type       string                     `json:"type"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &schema); err != nil {
+		t.Fatalf("config schema printed invalid JSON: %v\n%s", err, stdout)
+	}
+	if schema.Schema == "" || schema.Type != "object" {
+		t.Fatalf("config schema has $schema %q and type %q", schema.Schema, schema.Type)
+	}
+	for _, key := range []string{"version", "paths", "include", "profiles", "log_level"} {
+		if _, ok := schema.Properties[key]; !ok {
+			t.Errorf("config schema lacks property %q", key)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/jlrickert/go-std/pkg"
 	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
 
@@ -22,9 +23,8 @@ import (
 //   - The root command's default Out/Err are set to bytes.Buffer so tests can
 //     capture output by default.
 func (cli *Cli) newRootCmd() *cobra.Command {
-	flags := globalFlags{}
-
 	s := &state{cli: cli, teardown: func() error { return nil }}
+	flags := &s.flags
 	root := &cobra.Command{
 		Version: mcpfs.Version,
 		Use:     "mcpfs",
@@ -37,17 +37,23 @@ func (cli *Cli) newRootCmd() *cobra.Command {
 
 			// If a config file path is provided, load it (propagate errors).
 			if flags.cfgPath != "" {
-				loaded, err := mcpfs.ReadAndParseConfig(flags.cfgPath)
+				loaded, err := mcpfs.ReadAndParseConfigWith(flags.cfgPath, s.parseOptions())
 				if err != nil {
 					return err
 				}
 				// Use loaded file as base
 				cfg = loaded
 			}
+			if err := cfg.SelectProfile(s.profileName()); err != nil {
+				return err
+			}
 
+			// Flags override the log settings of the config and its profile.
 			var logfile string
 			if flags.logFile != "" {
 				logfile = flags.logFile
+			} else if cfg.LogPath != "" {
+				logfile = cfg.LogPath
 			} else {
 				path, err := std.UserStatePath(mcpfs.AppName, cli.Services.Env)
 				if err == nil {
@@ -55,6 +61,11 @@ func (cli *Cli) newRootCmd() *cobra.Command {
 				}
 			}
 
+			logLevel := flags.logLevel
+			if !cmd.Flags().Changed("log-level") && cfg.LogLevel != "" {
+				logLevel = cfg.LogLevel
+			}
+
 			// Default to discard; may be replaced with a file or stderr fallback.
 			logR := io.Discard
 			if logfile != "" {
@@ -97,7 +108,7 @@ func (cli *Cli) newRootCmd() *cobra.Command {
 			logger := std.NewLogger(std.LoggerConfig{
 				Version: mcpfs.Version,
 				Out:     logR,
-				Level:   parseLevel(flags.logLevel),
+				Level:   parseLevel(logLevel),
 				JSON:    true,
 			})
 			logger.Info("initialized")
@@ -105,7 +116,11 @@ func (cli *Cli) newRootCmd() *cobra.Command {
 
 			s.logger = logger
 			s.cli = cli
-			s.app = mcpfs.NewApp(cfg, logger, cli.Services)
+			app, err := mcpfs.NewApp(cfg, logger, cli.Services)
+			if err != nil {
+				return err
+			}
+			s.app = app
 			cmd.SetIn(s.InOrStdin())
 			cmd.SetOut(s.OutOrStdout())
 			cmd.SetErr(s.ErrOrStderr())
@@ -144,22 +159,28 @@ func (cli *Cli) newRootCmd() *cobra.Command {
 	root.PersistentFlags().StringVarP(&flags.cfgPath, "config", "c", "", "optional JSON config file path")
 	root.PersistentFlags().StringVar(&flags.logLevel, "log-level", "info", "override log level (debug/info/warn/error)")
 	root.PersistentFlags().StringVar(&flags.logFile, "logfile", "", "description")
+	root.PersistentFlags().StringVar(&flags.profile, "profile", "", "config profile to use (default $"+mcpfs.ProfileEnv+")")
 
 	// Add subcommands
 	root.AddCommand(s.newStdioCmd())
 	root.AddCommand(s.newStdio2Cmd())
+	root.AddCommand(s.newSandboxExecCmd())
+	root.AddCommand(s.newUndoCmd())
+	root.AddCommand(s.newTrashCmd())
+	root.AddCommand(s.newConfigCmd())
+	root.AddCommand(s.newRulesCmd())
+	root.AddCommand(s.newCheckCmd())
 
 	return root
 }
 
 func (s *state) newStdioCmd() *cobra.Command {
 	return &cobra.Command{
-		Use: "stdio",
+		Use:   "stdio",
+		Short: "Serve MCP over stdin/stdout",
 		// Aliases: []string{"-"},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// w := cmd.OutOrStdout()
-			// fmt.Fprintln(w, "hello world")
-			return nil
+			return s.app.Serve(cmd.Context(), &mcp.StdioTransport{})
 		},
 	}
 }
@@ -175,3 +196,21 @@ func (s *state) newStdio2Cmd() *cobra.Command {
 		},
 	}
 }
+
+// newSandboxExecCmd is the hidden helper used by mcpfs.SandboxCommand. It
+// skips the root hooks so no log file is opened inside the sandbox.
+func (s *state) newSandboxExecCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                mcpfs.SandboxExecCommand + " -- command [args...]",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		PersistentPreRunE:  func(cmd *cobra.Command, args []string) error { return nil },
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 && args[0] == "--" {
+				args = args[1:]
+			}
+			return mcpfs.RunSandboxed(args)
+		},
+	}
+}
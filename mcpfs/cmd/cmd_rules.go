@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/spf13/cobra"
+)
+
+func (s *state) newRulesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                "rules",
+		Short:              "List and edit the path rules in the config file",
+		PersistentPreRunE:  s.configPreRun,
+		PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	}
+	cmd.AddCommand(s.newRulesListCmd())
+	cmd.AddCommand(s.newRuleAddCmd("add"))
+	cmd.AddCommand(s.newRuleRemoveCmd("remove"))
+	return cmd
+}
+
+func (s *state) newRulesListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "Show the effective permissions of each path rule",
+		Long: `Show the effective permissions of each path rule, in the order they are
+matched, with environment variables expanded, whether the path exists and
+the config file the rule is from.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := s.loadConfig()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "PATH\tPERMS\tSUBPATHS\tEXISTS\tSOURCE\tDESCRIPTION")
+			for i := range cfg.Paths {
+				r := &cfg.Paths[i]
+				subpaths := r.AllowSubpaths == nil || *r.AllowSubpaths
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.CleanPath(), r.Permissions(),
+					yesNo(subpaths), pathExists(r.CleanPath()), r.Source(), r.Description)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// newRuleAddCmd returns the command adding a path rule, named use.
+func (s *state) newRuleAddCmd(use string) *cobra.Command {
+	var (
+		perms       []string
+		noSubpaths  bool
+		description string
+	)
+	cmd := &cobra.Command{
+		Use:   use + " <path>",
+		Short: "Add a path rule",
+		Long: `Add a path rule to the config file.
+
+The path is written as given, so it may reference environment variables such
+as ${HOME}; quote it to keep the shell from expanding them. A relative path is
+made absolute against the working directory first.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := absRulePath(args[0])
+			if err != nil {
+				return err
+			}
+			rule := mcpfs.PathRule{
+				Path:        p,
+				Perms:       perms,
+				Description: description,
+			}
+			if noSubpaths {
+				allow := false
+				rule.AllowSubpaths = &allow
+			}
+			path, err := s.editConfig(func(cfg *mcpfs.Config) error { return cfg.AddRule(rule) })
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "added rule for %s to %s\n", p, path)
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVar(&perms, "perms", []string{"read"}, "permissions to grant (read, write, execute, git)")
+	cmd.Flags().BoolVar(&noSubpaths, "no-subpaths", false, "grant the permissions on the path itself only")
+	cmd.Flags().StringVar(&description, "description", "", "description of the rule")
+	return cmd
+}
+
+// absRulePath makes a relative path argument absolute against the working
+// directory: in the config file it would be relative to the directory of the
+// file. Paths starting with ~, an environment variable or a placeholder are
+// kept as written.
+func absRulePath(p string) (string, error) {
+	if p == "" || filepath.IsAbs(p) || strings.ContainsAny(p[:1], "~${") {
+		return p, nil
+	}
+	return filepath.Abs(p)
+}
+
+// newRuleRemoveCmd returns the command removing path rules, named use.
+func (s *state) newRuleRemoveCmd(use string) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <path>",
+		Short: "Remove the path rules for a path",
+		Long: `Remove the path rules for a path, given as written in the config file or
+as expanded.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var n int
+			path, err := s.editConfig(func(cfg *mcpfs.Config) error {
+				if n = cfg.RemoveRule(args[0]); n == 0 {
+					return fmt.Errorf("no rule for %s", args[0])
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "removed %d rule(s) for %s from %s\n", n, args[0], path)
+			return nil
+		},
+	}
+}
+
+// pathExists describes whether path exists and what it is.
+func pathExists(path string) string {
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "no"
+	case err != nil:
+		return "unknown"
+	case info.IsDir():
+		return "dir"
+	default:
+		return "file"
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
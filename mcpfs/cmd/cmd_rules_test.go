@@ -0,0 +1,88 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestRulesCmd_AddListRemove(t *testing.T) {
+	f := NewFixture(t)
+	defer f.Teardown()
+
+	cfgPath, err := f.WithConfigFile("# managed by hand\nversion: \"2\"\npaths: []\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(f.TempDir, "project")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, err := f.Run("--config", cfgPath, "rules", "add", dir,
+		"--perms", "read,write", "--no-subpaths", "--description", "the project")
+	if err != nil {
+		t.Fatalf("rules add returned error: %v, stderr=%s", err, stderr)
+	}
+	if want := "added rule for " + dir + " to " + cfgPath; !strings.Contains(stdout, want) {
+		t.Fatalf("rules add printed %q, want it to contain %q", stdout, want)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# managed by hand") {
+		t.Fatalf("rules add dropped the comment:\n%s", data)
+	}
+	cfg, err := mcpfs.ParseConfigData(data)
+	if err != nil {
+		t.Fatalf("parse config after add: %v\n%s", err, data)
+	}
+	if len(cfg.Paths) != 1 {
+		t.Fatalf("config after add has %d rules, want 1:\n%s", len(cfg.Paths), data)
+	}
+	r := cfg.Paths[0]
+	if r.Path != dir || r.Permissions() != mcpfs.PermRead|mcpfs.PermWrite ||
+		r.AllowSubpaths == nil || *r.AllowSubpaths || r.Description != "the project" {
+		t.Fatalf("config after add has rule %+v:\n%s", r, data)
+	}
+
+	if _, _, err := f.Run("--config", cfgPath, "rules", "add", dir); err == nil {
+		t.Fatal("expected adding a second rule for the same path to fail")
+	}
+
+	stdout, stderr, err = f.Run("--config", cfgPath, "rules", "list")
+	if err != nil {
+		t.Fatalf("rules list returned error: %v, stderr=%s", err, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "PATH") {
+		t.Fatalf("rules list printed %q, want a header and one rule", stdout)
+	}
+	fields := strings.Fields(lines[1])
+	if len(fields) < 5 || fields[0] != dir || fields[2] != "no" || fields[3] != "dir" || !strings.HasSuffix(lines[1], "the project") {
+		t.Fatalf("rules list printed rule line %q", lines[1])
+	}
+
+	stdout, stderr, err = f.Run("--config", cfgPath, "rules", "remove", dir)
+	if err != nil {
+		t.Fatalf("rules remove returned error: %v, stderr=%s", err, stderr)
+	}
+	if want := "removed 1 rule(s) for " + dir; !strings.Contains(stdout, want) {
+		t.Fatalf("rules remove printed %q, want it to contain %q", stdout, want)
+	}
+	data, err = os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), dir) || !strings.Contains(string(data), "# managed by hand") {
+		t.Fatalf("config after remove:\n%s", data)
+	}
+
+	if _, _, err := f.Run("--config", cfgPath, "rules", "remove", dir); err == nil || !strings.Contains(err.Error(), "no rule for") {
+		t.Fatalf("removing a missing rule = %v, want a no rule error", err)
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/spf13/cobra"
+)
+
+func (s *state) newTrashCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trash",
+		Short: "Inspect and purge the trash that delete_path moves items to",
+	}
+	cmd.AddCommand(s.newTrashListCmd())
+	cmd.AddCommand(s.newTrashPurgeCmd())
+	return cmd
+}
+
+func (s *state) trash() (*mcpfs.Trash, error) {
+	if s.app.Trash == nil {
+		return nil, fmt.Errorf("%w: trash is disabled", mcpfs.ErrTrash)
+	}
+	return s.app.Trash, nil
+}
+
+func (s *state) newTrashListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List trashed items, most recently deleted first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			trash, err := s.trash()
+			if err != nil {
+				return err
+			}
+			items, err := trash.List()
+			if err != nil {
+				return err
+			}
+			w := cmd.OutOrStdout()
+			for _, item := range items {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.DeletionDate.Format("2006-01-02 15:04:05"),
+					mcpfs.ByteSize(item.Size), item.Name, item.OriginalPath)
+			}
+			return nil
+		},
+	}
+}
+
+func (s *state) newTrashPurgeCmd() *cobra.Command {
+	var (
+		olderThan time.Duration
+		all       bool
+		dryRun    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Permanently delete trashed items",
+		Long: `Permanently delete trashed items.
+
+By default only items whose original path is writable under the configured
+rules are purged, so items trashed by other programs sharing the trash are
+left alone. Use --all to purge everything.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			trash, err := s.trash()
+			if err != nil {
+				return err
+			}
+			cutoff := s.Clock().Now().Add(-olderThan)
+			keep := func(item mcpfs.TrashItem) bool {
+				if olderThan > 0 && item.DeletionDate.After(cutoff) {
+					return true
+				}
+				return !all && !s.app.Cfg.IsAllowed(mcpfs.PermWrite, item.OriginalPath)
+			}
+
+			w := cmd.OutOrStdout()
+			if dryRun {
+				items, err := trash.List()
+				if err != nil {
+					return err
+				}
+				for _, item := range items {
+					if !keep(item) {
+						fmt.Fprintf(w, "would purge %s (%s)\n", item.Name, item.OriginalPath)
+					}
+				}
+				return nil
+			}
+			purged, err := trash.Purge(keep)
+			for _, item := range purged {
+				fmt.Fprintf(w, "purged %s (%s)\n", item.Name, item.OriginalPath)
+			}
+			return err
+		},
+	}
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "only purge items deleted longer ago than this")
+	cmd.Flags().BoolVar(&all, "all", false, "purge items regardless of the configured rules")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print what would be purged without deleting")
+	return cmd
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/spf13/cobra"
+)
+
+func (s *state) newUndoCmd() *cobra.Command {
+	var (
+		count   int
+		session string
+		list    bool
+	)
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Revert the last operations recorded in the undo journal",
+		Long: `Revert the last write, edit, move and delete operations made by a session.
+
+Without --session the most recently active session is used.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			journal := s.app.Journal
+			if journal == nil {
+				return fmt.Errorf("%w: journal is not available", mcpfs.ErrJournal)
+			}
+			if session == "" {
+				sessions, err := journal.Sessions()
+				if err != nil {
+					return err
+				}
+				if len(sessions) == 0 {
+					return errors.New("journal is empty")
+				}
+				session = sessions[0]
+			}
+
+			w := cmd.OutOrStdout()
+			if list {
+				entries, err := journal.Entries(session)
+				if err != nil {
+					return err
+				}
+				for _, e := range entries {
+					fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", e.Seq, e.Time.Format("2006-01-02 15:04:05"), e.Op, describeEntry(e))
+				}
+				return nil
+			}
+
+			undone, err := journal.Undo(session, count, nil)
+			for _, e := range undone {
+				fmt.Fprintf(w, "undid %s %s\n", e.Op, describeEntry(e))
+			}
+			return err
+		},
+	}
+	cmd.Flags().IntVarP(&count, "count", "n", 1, "number of operations to undo")
+	cmd.Flags().StringVar(&session, "session", "", "session to undo (default: most recent)")
+	cmd.Flags().BoolVar(&list, "list", false, "list the journal entries instead of undoing")
+	return cmd
+}
+
+func describeEntry(e mcpfs.JournalEntry) string {
+	s := e.Path
+	if e.Dest != "" {
+		s = fmt.Sprintf("%s -> %s", e.Path, e.Dest)
+	}
+	if e.Irreversible != "" {
+		s += " (cannot be undone: " + e.Irreversible + ")"
+	}
+	return s
+}
@@ -1,61 +1,20 @@
 package cmd_test
 
 import (
+	"bytes"
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"log/slog"
 
 	"github.com/jlrickert/go-std/pkg"
 	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/jlrickert/mcp-filesystem/mcpfs/cmd"
 )
 
-// // RunCommand runs a cobra command with controlled env, args and stdin and
-// // returns captured stdout/stderr and error. It restores any modified environment
-// // after completion.
-// func RunCommand(root *cobra.Command, env map[string]string, args []string, stdin string) (string, string, error) {
-// 	// Save and restore environment
-// 	origEnv := make(map[string]*string)
-// 	for k := range env {
-// 		if v, ok := os.LookupEnv(k); ok {
-// 			ov := v
-// 			origEnv[k] = &ov
-// 		} else {
-// 			origEnv[k] = nil
-// 		}
-// 		os.Setenv(k, env[k])
-// 	}
-// 	defer func() {
-// 		for k, v := range origEnv {
-// 			if v == nil {
-// 				os.Unsetenv(k)
-// 			} else {
-// 				os.Setenv(k, *v)
-// 			}
-// 		}
-// 	}()
-//
-// 	// Capture output
-// 	outBuf := &bytes.Buffer{}
-// 	errBuf := &bytes.Buffer{}
-// 	root.SetOut(outBuf)
-// 	root.SetErr(errBuf)
-//
-// 	// Set stdin if provided
-// 	if stdin != "" {
-// 		root.SetIn(strings.NewReader(stdin))
-// 	} else {
-// 		root.SetIn(io.NopCloser(strings.NewReader("")))
-// 	}
-//
-// 	// Set args and execute
-// 	root.SetArgs(args)
-// 	_, err := root.ExecuteC()
-//
-// 	return outBuf.String(), errBuf.String(), err
-// }
-
 // Fixture provides a convenient test fixture for command integration tests.
 type Fixture struct {
 	T        *testing.T
@@ -102,10 +61,19 @@ func (f *Fixture) WithConfigFile(content string) (string, error) {
 	return fn, nil
 }
 
-// // Run runs the root command with fixture.Env merged into env and returns captured output.
-// func (f *Fixture) Run(args []string, stdin string) (string, string, error) {
-// 	return RunCommand(f.Root, f.Env, args, stdin)
-// }
+// Run runs the CLI with args against the fixture services and returns the
+// captured stdout and stderr.
+func (f *Fixture) Run(args ...string) (string, string, error) {
+	var out, errOut bytes.Buffer
+	c := &cmd.Cli{
+		Services: f.Services,
+		In:       strings.NewReader(""),
+		Out:      &out,
+		Err:      &errOut,
+	}
+	err := c.Run(context.Background(), args)
+	return out.String(), errOut.String(), err
+}
 
 // Teardown is a placeholder to satisfy the interface. No-op currently.
 func (f *Fixture) Teardown() {}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
+	"time"
 
 	std "github.com/jlrickert/go-std/pkg"
 	"gopkg.in/yaml.v3"
@@ -17,7 +20,8 @@ var AppName = "mcpfs"
 
 // Configuration for filesystem access controls for an MCP server.
 // The configuration is YAML-backed and supports environment-variable
-// substitution in all string fields and in string slices.
+// substitution in all string fields and in string slices: $VAR, ${VAR},
+// ${VAR:-default}, ${VAR:?message} and $$ for a literal $.
 //
 // Typical usage:
 //
@@ -29,7 +33,13 @@ var AppName = "mcpfs"
 // treated as read-only (no write) to align with the typical "no write by default"
 // preference.
 const (
-	ConfigVersionV1       = "1.0"
+	ConfigVersionV1 = "1.0"
+	// ConfigVersionV2 introduced versioned migrations; version 1 documents
+	// are valid version 2 documents.
+	ConfigVersionV2 = "2.0"
+	// CurrentConfigVersion is the version ParseConfigData migrates configs to.
+	CurrentConfigVersion = ConfigVersionV2
+
 	DefaultConfigFilename = "config.yaml"
 	defaultAllowSubpaths  = true
 )
@@ -42,6 +52,9 @@ const (
 	PermRead Permission = 1 << iota
 	PermWrite
 	PermExec
+	// PermGit allows version control operations that change a repository,
+	// such as staging, committing and managing branches.
+	PermGit
 )
 
 func (p Permission) String() string {
@@ -55,6 +68,9 @@ func (p Permission) String() string {
 	if p&PermExec != 0 {
 		parts = append(parts, "exec")
 	}
+	if p&PermGit != 0 {
+		parts = append(parts, "git")
+	}
 	if len(parts) == 0 {
 		return "none"
 	}
@@ -64,29 +80,235 @@ func (p Permission) String() string {
 // PathRule describes one allowed path and which permissions are granted.
 // YAML schema:
 //
-//   - path: "/var/www"              # path on disk; may contain env vars like ${HOME}
-//     perms: ["read", "exec"]       # permitted operations; default: ["read"]
+//   - path: "/var/www"              # path on disk; may contain env vars like ${HOME}, ~ and {state}
+//     perms: ["read", "exec"]       # read, write, exec and/or git; default: ["read"]
 //     users: ["alice", "bob"]       # optional list of users allowed
 //     roles: ["admin"]              # optional list of roles allowed
 //     allow_subpaths: true          # whether subpaths are covered (default true)
 //     description: "web content dir"
+//     max_file_size: "10MiB"        # optional largest single file a write may produce
+//     max_total_bytes: "1GiB"       # optional total size of all files under the path
+//     max_files: 1000               # optional number of files under the path
+//     approval: required            # ask the user before operations on this path (default none)
+//     not_before: 2026-01-02 22:00  # optional time the rule starts to apply
+//     expires_at: 2026-01-03 02:00  # optional time the rule stops applying
+//     active_hours: "* 22-23 * * *" # optional cron expression of the minutes the rule applies
+//     extensions: [md, txt]         # optional file extensions the rule applies to
+//     mime_types: ["text/*"]        # optional content types the rule applies to
+//     max_size: "10MB"              # optional largest file the rule applies to
+//     redact: false                 # return content under the path unredacted (default true)
+//     unsafe_allow_sensitive: true  # also grant access to sensitive paths such as ~/.ssh (default false)
 type PathRule struct {
-	Path          string   `yaml:"path" json:"path"`
-	Perms         []string `yaml:"perms,omitempty" json:"perms,omitempty"`
-	AllowSubpaths *bool    `yaml:"allow_subpaths,omitempty" json:"allow_subpaths,omitempty"`
-	Description   string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Path          string   `yaml:"path" json:"path" jsonschema:"path on disk; may contain environment variables like ${HOME}, ~ and the placeholders {config} {state} {cache} {cwd} {repo_root}; relative paths are relative to the config file"`
+	Perms         []string `yaml:"perms,omitempty" json:"perms,omitempty" jsonschema:"permissions granted on the path; default read"`
+	AllowSubpaths *bool    `yaml:"allow_subpaths,omitempty" json:"allow_subpaths,omitempty" jsonschema:"whether paths below the path are covered; default true"`
+	Description   string   `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"what the path is for"`
+
+	// Quotas for write-granted rules; zero means unlimited.
+	MaxFileSize   ByteSize `yaml:"max_file_size,omitempty" json:"max_file_size,omitempty" jsonschema:"largest file a write may produce, e.g. 10MiB"`
+	MaxTotalBytes ByteSize `yaml:"max_total_bytes,omitempty" json:"max_total_bytes,omitempty" jsonschema:"total size of all files under the path"`
+	MaxFiles      int64    `yaml:"max_files,omitempty" json:"max_files,omitempty" jsonschema:"number of files under the path"`
+
+	// Approval set to "required" makes operations granted by this rule wait
+	// for the user's confirmation.
+	Approval string `yaml:"approval,omitempty" json:"approval,omitempty" jsonschema:"whether operations on the path wait for the user's confirmation"`
+
+	// Schedule of the rule, evaluated with the clock of the config: the rule
+	// grants nothing before NotBefore, from ExpiresAt on, or in minutes
+	// ActiveHours does not match. Times without a zone are local.
+	NotBefore   string `yaml:"not_before,omitempty" json:"not_before,omitempty" jsonschema:"time the rule starts to apply, e.g. 2026-01-02 22:00 or RFC 3339"`
+	ExpiresAt   string `yaml:"expires_at,omitempty" json:"expires_at,omitempty" jsonschema:"time the rule stops applying, e.g. 2026-01-03 or RFC 3339"`
+	ActiveHours string `yaml:"active_hours,omitempty" json:"active_hours,omitempty" jsonschema:"cron expression (minute hour day-of-month month day-of-week) of the minutes the rule applies, e.g. * 9-17 * * mon-fri"`
+
+	// Content predicates restrict the rule to regular files of the given
+	// extensions, detected content types and sizes. Other paths, including
+	// directories, are left to the rules after it.
+	Extensions []string `yaml:"extensions,omitempty" json:"extensions,omitempty" jsonschema:"file extensions the rule applies to, e.g. md or .go"`
+	MimeTypes  []string `yaml:"mime_types,omitempty" json:"mime_types,omitempty" jsonschema:"content types the rule applies to, detected from the content, e.g. text/* or image/png"`
+	MaxSize    ByteSize `yaml:"max_size,omitempty" json:"max_size,omitempty" jsonschema:"largest file the rule applies to, e.g. 10MB"`
+
+	// Redact set to false returns content read through this rule with its
+	// secrets unmasked when redaction is enabled.
+	Redact *bool `yaml:"redact,omitempty" json:"redact,omitempty" jsonschema:"set false to return content under the path unredacted; default true"`
+
+	// UnsafeAllowSensitive lets the rule grant access to the sensitive paths
+	// other rules never grant, such as ~/.ssh, .env files and the config
+	// files themselves; see Config.SensitivePaths.
+	UnsafeAllowSensitive bool `yaml:"unsafe_allow_sensitive,omitempty" json:"unsafe_allow_sensitive,omitempty" jsonschema:"also grant access to sensitive paths such as ~/.ssh, ~/.aws/credentials, .env* and *.pem files, browser profiles and the config files"`
 
 	// runtime fields (not marshaled)
-	parsedPerms Permission `yaml:"-" json:"-"`
-	cleanPath   string     `yaml:"-" json:"-"`
+	parsedPerms Permission    `yaml:"-" json:"-"`
+	extensions  []string      `yaml:"-" json:"-"` // lowercase, with leading dot
+	mimeTypes   []string      `yaml:"-" json:"-"`
+	notBefore   time.Time     `yaml:"-" json:"-"`
+	expiresAt   time.Time     `yaml:"-" json:"-"`
+	activeHours *cronSchedule `yaml:"-" json:"-"`
+	cleanPath   string        `yaml:"-" json:"-"`
+	realPath    string        `yaml:"-" json:"-"` // cleanPath with its symlinks resolved
+	rawPath     string        `yaml:"-" json:"-"` // Path before env expansion
+	source      string        `yaml:"-" json:"-"` // config file the rule is from
 }
 
 // Config is the top-level configuration.
 type Config struct {
-	Version  string     `yaml:"version,omitempty" json:"version,omitempty"`
-	Paths    []PathRule `yaml:"paths" json:"paths"`
-	LogLevel string     `yaml:"log_level" json:"log_level"`
-	LogPath  string     `yaml:"log_path" json:"log_path"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty" jsonschema:"config format version; older configs are migrated when loaded"`
+
+	// Include lists config files merged into this one: file paths, glob
+	// patterns and directories, whose *.yaml and *.yml files are included.
+	// Relative entries are resolved against the directory of the including
+	// file. Files are merged in order, each over the ones before it and the
+	// including file last, over all of them: settings of a later file replace
+	// those of earlier ones, mappings are merged key by key, and the path
+	// rules of a later file are matched before those of earlier ones.
+	Include []string   `yaml:"include,omitempty" json:"include,omitempty" jsonschema:"config files, glob patterns and conf.d directories merged before this file"`
+	Paths   []PathRule `yaml:"paths" json:"paths" jsonschema:"path rules; the first rule matching a path and granting an operation allows it"`
+
+	LogLevel string `yaml:"log_level" json:"log_level" jsonschema:"debug, info, warn or error"`
+	LogPath  string `yaml:"log_path" json:"log_path" jsonschema:"log file"`
+
+	// Profiles are named sets of path rules and log settings, one of which
+	// may be selected at startup with SelectProfile.
+	Profiles map[string]ProfileConfig `yaml:"profiles,omitempty" json:"profiles,omitempty" jsonschema:"named path rules and log settings selected with --profile or MCPFS_PROFILE"`
+
+	// Sandbox configures the isolation applied to executed commands.
+	Sandbox SandboxConfig `yaml:"sandbox,omitempty" json:"sandbox,omitempty" jsonschema:"isolation applied to executed commands"`
+
+	// RateLimits bounds how fast and how many tool calls clients may make.
+	RateLimits RateLimitConfig `yaml:"rate_limits,omitempty" json:"rate_limits,omitempty" jsonschema:"limits on how fast and how many tool calls clients may make"`
+
+	// ApprovalTimeout bounds how long an approval request waits for the user
+	// before it is denied. Defaults to DefaultApprovalTimeout.
+	ApprovalTimeout time.Duration `yaml:"approval_timeout,omitempty" json:"approval_timeout,omitempty" jsonschema:"how long an approval request waits for the user, e.g. 2m"`
+
+	// Journal controls the undo journal of mutating operations.
+	Journal JournalConfig `yaml:"journal,omitempty" json:"journal,omitempty" jsonschema:"undo journal of mutating operations"`
+
+	// Trash controls where deleted paths are moved.
+	Trash TrashConfig `yaml:"trash,omitempty" json:"trash,omitempty" jsonschema:"where deleted paths are moved"`
+
+	// Git sets the identity of commits made through the git tools.
+	Git GitConfig `yaml:"git,omitempty" json:"git,omitempty" jsonschema:"identity of commits made through the git tools"`
+
+	// Policy is an expression every access the path rules grant must
+	// satisfy. It is not expanded, as $ is part of its syntax.
+	Policy PolicyConfig `yaml:"policy,omitempty" json:"policy,omitempty" jsonschema:"expression deciding on the accesses the path rules grant" expand:"-"`
+
+	// Redaction masks secrets in the content read tools return. Its regular
+	// expressions are not expanded, as $ anchors them.
+	Redaction RedactionConfig `yaml:"redaction,omitempty" json:"redaction,omitempty" jsonschema:"masking of secrets in content returned by tools" expand:"-"`
+
+	// source is the YAML document the config was parsed from and base the
+	// config as parsed. ToYAML writes changes made since then into source,
+	// keeping its comments and unexpanded ${VAR} references.
+	source *yaml.Node `yaml:"-" json:"-"`
+	base   *yaml.Node `yaml:"-" json:"-"`
+
+	// migratedFrom is the version of the source document if a migration
+	// rewrote it.
+	migratedFrom string
+
+	// files are the config files read by ReadAndParseConfig in merge order.
+	files []string
+
+	// profile is the name of the selected profile.
+	profile string
+	// opts are the options the config was parsed with, which SelectProfile
+	// expands and resolves the rules of the profile with.
+	opts ParseOptions
+
+	// clock evaluates rule schedules; nil is the system clock.
+	clock std.Clock
+
+	// sensitive are the clean sensitive paths below the home directory and
+	// the default config file.
+	sensitive []string
+
+	// policy is the compiled Policy.Expr, or nil if there is none.
+	policy *Policy
+	// principal is the user the server runs as, which policies see for
+	// accesses that do not name a principal.
+	principal string
+}
+
+// ProfileEnv names the environment variable selecting the profile when none
+// is given on the command line.
+const ProfileEnv = "MCPFS_PROFILE"
+
+// ProfileConfig is a named profile. Its path rules replace the top-level
+// ones, so that a read-only profile stays read-only whatever they grant; a
+// profile without path rules keeps the top-level ones. The log settings it
+// sets replace the top-level ones.
+type ProfileConfig struct {
+	Paths    []PathRule `yaml:"paths,omitempty" json:"paths,omitempty" jsonschema:"path rules of the profile, replacing the top-level ones"`
+	LogLevel string     `yaml:"log_level,omitempty" json:"log_level,omitempty" jsonschema:"log level replacing the top-level one"`
+	LogPath  string     `yaml:"log_path,omitempty" json:"log_path,omitempty" jsonschema:"log file replacing the top-level one"`
+}
+
+// SelectProfile makes the named profile active. An empty name selects no
+// profile. Only here are the environment variables and placeholders of the
+// profile resolved, so that those of the other profiles need not be set.
+// The config no longer writes back to its file with ToYAML after a profile
+// is selected.
+func (c *Config) SelectProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	if c.profile != "" {
+		return fmt.Errorf("profile %q is already selected", c.profile)
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return fmt.Errorf("%w: unknown profile %q: the config defines no profiles", ErrParse, name)
+		}
+		names := slices.Sorted(maps.Keys(c.Profiles))
+		return fmt.Errorf("%w: unknown profile %q, want one of %s", ErrParse, name, strings.Join(names, ", "))
+	}
+	p, err := p.resolve(name, c.opts)
+	if err != nil {
+		return err
+	}
+	if len(p.Paths) > 0 {
+		paths := c.Paths
+		c.Paths = slices.Clone(p.Paths)
+		if err := c.checkSandboxable(); err != nil {
+			c.Paths = paths
+			return err
+		}
+	}
+	if p.LogLevel != "" {
+		c.LogLevel = p.LogLevel
+	}
+	if p.LogPath != "" {
+		c.LogPath = p.LogPath
+	}
+	c.profile = name
+	c.source, c.base = nil, nil
+	return nil
+}
+
+// resolve returns p with its environment variables expanded and its path
+// rules normalized with opts, leaving p as it is.
+func (p ProfileConfig) resolve(name string, opts ParseOptions) (ProfileConfig, error) {
+	p.Paths = slices.Clone(p.Paths)
+	for i := range p.Paths {
+		p.Paths[i] = p.Paths[i].clone()
+	}
+	if err := expandEnvInValue(reflect.ValueOf(&p), opts.expander(), "profiles."+name); err != nil {
+		return p, err
+	}
+	paths := opts.pathResolver()
+	for i := range p.Paths {
+		if err := p.Paths[i].normalize(paths); err != nil {
+			return p, fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	return p, nil
+}
+
+// Profile returns the name of the selected profile, or "" if none is.
+func (c *Config) Profile() string {
+	return c.profile
 }
 
 // ReadConfigData reads the file at configPath and returns its contents.
@@ -109,74 +331,183 @@ func ReadConfigData(configPath string) ([]byte, error) {
 // ReadDefaultConfigData builds the default config path from the user's config directory
 // and the default filename and reads it.
 func ReadDefaultConfigData(env std.Env) ([]byte, error) {
-	dir, err := std.UserConfigPath(AppName, env)
+	configPath, err := DefaultConfigPath(env)
 	if err != nil {
 		return nil, err
 	}
-	configPath := filepath.Join(dir, DefaultConfigFilename)
 	return ReadConfigData(configPath)
 }
 
-// ParseConfigData parses YAML data into FSConfig. It expands environment variables,
-// normalizes paths, and parses permission strings. If a rule omits perms, default to read-only.
+// DefaultConfigPath returns the path of the config file in the user's config
+// directory.
+func DefaultConfigPath(env std.Env) (string, error) {
+	dir, err := std.UserConfigPath(AppName, env)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, DefaultConfigFilename), nil
+}
+
+// ParseOptions are the settings of config parsing.
+type ParseOptions struct {
+	// Env resolves environment variable references. Defaults to the process
+	// environment.
+	Env std.Env
+	// AllowUnsetEnv expands references to unset or empty variables that have
+	// no default to "" instead of failing, for commands that edit the config
+	// file rather than run with it.
+	AllowUnsetEnv bool
+	// Dir is the directory relative rule paths of configs without a file
+	// resolve against. Defaults to Cwd. Rules read from a file resolve
+	// against the directory of that file.
+	Dir string
+	// Cwd is the working directory of {cwd} and {repo_root}. Defaults to the
+	// process working directory.
+	Cwd string
+	// AllowUnresolvedPaths keeps rule paths whose ~user or placeholders
+	// cannot be resolved, such as {repo_root} outside a repository, as
+	// written instead of failing.
+	AllowUnresolvedPaths bool
+}
+
+// expander returns the expander of environment variable references.
+func (o ParseOptions) expander() *envExpander {
+	env := o.Env
+	if env == nil {
+		env = &std.OsEnv{}
+	}
+	return &envExpander{env: env, allowUnset: o.AllowUnsetEnv}
+}
+
+// pathResolver returns the resolver of rule and include paths.
+func (o ParseOptions) pathResolver() *pathResolver {
+	env := o.Env
+	if env == nil {
+		env = &std.OsEnv{}
+	}
+	cwd := o.Cwd
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+	return &pathResolver{env: env, dir: o.Dir, cwd: cwd, lenient: o.AllowUnresolvedPaths}
+}
+
+// ParseConfigData parses YAML data into FSConfig. It expands environment variables
+// outside of policy and redaction, normalizes paths, and parses permission strings. If a rule omits perms, default to read-only.
 //
-// Note: This implementation does not perform comment-preserving roundtrips; it focuses on
-// configuration semantics suitable for enforcement.
+// Documents of older versions are migrated to CurrentConfigVersion first and
+// documents of newer versions are refused. The parsed document is kept with
+// the config so that ToYAML can write changes back without losing comments or
+// the unexpanded values.
+//
+// The include list is kept but not resolved, since data has no location to
+// resolve it against; ReadAndParseConfig resolves includes.
 func ParseConfigData(data []byte) (*Config, error) {
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	return ParseConfigDataWith(data, ParseOptions{})
+}
+
+// ParseConfigDataWith is ParseConfigData with options. A reference to an
+// environment variable that is unset or empty and has no ${VAR:-default} is
+// an error unless opts.AllowUnsetEnv is set.
+func ParseConfigDataWith(data []byte, opts ParseOptions) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, fmt.Errorf("%w: yaml unmarshal: %v", ErrParse, err)
 	}
+	return parseConfigDocument(&doc, opts, nil)
+}
 
-	// Default version if empty
-	if cfg.Version == "" {
-		cfg.Version = ConfigVersionV1 // assume legacy if absent
+// parseConfigDocument is ParseConfigDataWith for a parsed YAML document.
+// sources, if not nil, holds the files of the path rules as configLayer
+// does.
+func parseConfigDocument(doc *yaml.Node, opts ParseOptions, sources map[string][]string) (*Config, error) {
+	var cfg Config
+	if len(doc.Content) > 0 {
+		from, err := migrateConfig(doc.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		cfg.migratedFrom = from
+		if err := doc.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("%w: yaml unmarshal: %v", ErrParse, err)
+		}
+	}
+	for i := range cfg.Paths {
+		cfg.Paths[i].rawPath = cfg.Paths[i].Path
+		if i < len(sources[""]) {
+			cfg.Paths[i].source = sources[""][i]
+		}
+	}
+	for name, p := range cfg.Profiles {
+		for i := range p.Paths {
+			p.Paths[i].rawPath = p.Paths[i].Path
+			if i < len(sources[name]) {
+				p.Paths[i].source = sources[name][i]
+			}
+		}
 	}
 
-	// // Migrate v1 -> v2 if needed (simple migration rule: just set version to v2).
-	// if cfg.Version == ConfigVersionV1 {
-	// 	// In v1 we might have slightly different semantics; for now, migrate by
-	// 	// stamping the version and leaving contents as-is.
-	// 	cfg.Version = ConfigVersionV2
-	// }
+	// Older documents were migrated above; an empty one is current.
+	cfg.Version = CurrentConfigVersion
 
-	// Expand environment variables throughout the struct
-	if err := expandEnvInValue(reflect.ValueOf(&cfg)); err != nil {
-		return nil, fmt.Errorf("expand env: %w", err)
+	// Expand environment variables throughout the struct. Those of the
+	// profiles are expanded when one is selected.
+	profiles := cfg.Profiles
+	cfg.Profiles = nil
+	if err := expandEnvInValue(reflect.ValueOf(&cfg), opts.expander(), ""); err != nil {
+		return nil, err
 	}
+	cfg.Profiles = profiles
 
 	// Normalize and parse each path rule
+	paths := opts.pathResolver()
+	cfg.sensitive = sensitivePaths(paths.env)
+	cfg.principal, _ = paths.env.GetUser()
 	for i := range cfg.Paths {
-		r := &cfg.Paths[i]
-		if r.AllowSubpaths == nil {
-			// default to allowing subpaths; explicit false must be set to disable
-			v := defaultAllowSubpaths
-			r.AllowSubpaths = &v
+		if err := cfg.Paths[i].normalize(paths); err != nil {
+			return nil, err
 		}
-		// Clean and make absolute if possible (do not require existence)
-		clean := filepath.Clean(r.Path)
-		if !filepath.IsAbs(clean) {
-			// attempt to make it absolute relative to current working dir
-			abs, err := filepath.Abs(clean)
-			if err == nil {
-				clean = abs
-			}
+	}
+	// Check the profiles without requiring their variables and
+	// placeholders to resolve.
+	lenient := opts
+	lenient.AllowUnsetEnv, lenient.AllowUnresolvedPaths = true, true
+	for name, p := range cfg.Profiles {
+		if _, err := p.resolve(name, lenient); err != nil {
+			return nil, err
 		}
-		r.cleanPath = clean
-
-		// Parse perms
-		if len(r.Perms) == 0 {
-			// default to read-only
-			r.parsedPerms = PermRead
-		} else {
-			mask, err := parsePerms(r.Perms)
-			if err != nil {
-				return nil, fmt.Errorf("invalid perms for path %q: %w", r.Path, err)
-			}
-			r.parsedPerms = mask
+	}
+	cfg.opts = opts
+
+	if cfg.Sandbox.Landlock != "" {
+		if err := NewSandboxPolicy(&cfg).Validate(); err != nil {
+			return nil, fmt.Errorf("%w: sandbox: %v", ErrParse, err)
 		}
 	}
 
+	if err := cfg.RateLimits.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: rate_limits: %v", ErrParse, err)
+	}
+	if err := cfg.Git.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: git: %v", ErrParse, err)
+	}
+	if err := cfg.Redaction.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: redaction: %v", ErrParse, err)
+	}
+	if cfg.Policy.Expr != "" {
+		p, err := CompilePolicy(cfg.Policy.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: policy.expr: %v", ErrParse, err)
+		}
+		cfg.policy = p
+	}
+	if err := cfg.checkSandboxable(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.keepSource(doc); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 }
 
@@ -184,95 +515,258 @@ func ParseConfigData(data []byte) (*Config, error) {
 func parsePerms(perms []string) (Permission, error) {
 	var mask Permission
 	for _, p := range perms {
-		switch strings.ToLower(strings.TrimSpace(p)) {
-		case "read", "r":
-			mask |= PermRead
-		case "write", "w":
-			mask |= PermWrite
-		case "exec", "execute", "x":
-			mask |= PermExec
-		default:
+		perm, ok := permNames[strings.ToLower(strings.TrimSpace(p))]
+		if !ok {
 			return 0, fmt.Errorf("unknown permission %q", p)
 		}
+		mask |= perm
 	}
 	return mask, nil
 }
 
+// ParsePermission parses a single permission name as accepted in perms.
+func ParsePermission(name string) (Permission, error) {
+	return parsePerms([]string{name})
+}
+
+// permNames maps the names accepted in perms to their permission.
+var permNames = map[string]Permission{
+	"read": PermRead, "r": PermRead,
+	"write": PermWrite, "w": PermWrite,
+	"exec": PermExec, "execute": PermExec, "x": PermExec,
+	"git": PermGit, "vcs": PermGit,
+}
+
 // IsAllowed returns true if the given principal (user with roles) is allowed to perform
 // op on targetPath according to the configured rules. The first matching rule grants access;
-// more sophisticated merging or deny-list semantics are intentionally omitted for simplicity.
+// sensitive paths only if it sets unsafe_allow_sensitive.
 //
 // Matching rules:
 //   - The rule path is matched exactly, or if allow_subpaths=true then any path under
 //     the rule path is considered a match.
 //   - A rule without Users/Roles applies to all principals.
 func (c *Config) IsAllowed(op Permission, targetPath string) bool {
-	if c == nil {
+	return c.MatchRule(op, targetPath) != nil
+}
+
+// MatchRule returns the first rule granting op on targetPath, or nil if no rule
+// does. Callers that need per-rule settings (such as quotas) should use this
+// rather than IsAllowed so they act on the same rule that granted access.
+// See Evaluate for how the rule is chosen.
+func (c *Config) MatchRule(op Permission, targetPath string) *PathRule {
+	return c.Evaluate(Access{Op: op, Path: targetPath}).Rule
+}
+
+// SetClock sets the clock rule schedules are evaluated with. NewApp sets it
+// to Services.Clock.
+func (c *Config) SetClock(clock std.Clock) {
+	c.clock = clock
+}
+
+// Now returns the time of the clock rule schedules are evaluated with.
+func (c *Config) Now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// ActiveAt reports whether t is within the schedule of the rule. A rule
+// without schedule is always active.
+func (r *PathRule) ActiveAt(t time.Time) bool {
+	if !r.notBefore.IsZero() && t.Before(r.notBefore) {
 		return false
 	}
-	cleanTarget := filepath.Clean(targetPath)
-	if !filepath.IsAbs(cleanTarget) {
-		abs, err := filepath.Abs(cleanTarget)
-		if err == nil {
-			cleanTarget = abs
+	if !r.expiresAt.IsZero() && !t.Before(r.expiresAt) {
+		return false
+	}
+	return r.activeHours == nil || r.activeHours.matches(t.In(time.Local))
+}
+
+// CleanPath returns the normalized absolute path of the rule.
+func (r *PathRule) CleanPath() string {
+	return r.cleanPath
+}
+
+// clone returns a copy of r that shares no slices with it.
+func (r PathRule) clone() PathRule {
+	r.Perms = slices.Clone(r.Perms)
+	r.Extensions = slices.Clone(r.Extensions)
+	r.MimeTypes = slices.Clone(r.MimeTypes)
+	return r
+}
+
+// normalize fills in the defaults of the rule and parses and checks its
+// settings. The path is resolved with paths, relative to the file of the
+// rule if it has one.
+func (r *PathRule) normalize(paths *pathResolver) error {
+	if r.AllowSubpaths == nil {
+		// default to allowing subpaths; explicit false must be set to disable
+		v := defaultAllowSubpaths
+		r.AllowSubpaths = &v
+	}
+	// Resolve to a clean absolute path (do not require existence)
+	var dir string
+	if r.source != "" {
+		dir = filepath.Dir(r.source)
+	}
+	clean, err := paths.resolve(r.Path, dir)
+	if err != nil {
+		return fmt.Errorf("%w: path %q: %v", ErrParse, r.Path, err)
+	}
+	r.cleanPath = clean
+	r.realPath = resolvePath(clean)
+
+	// Parse perms
+	if len(r.Perms) == 0 {
+		// default to read-only
+		r.parsedPerms = PermRead
+	} else {
+		mask, err := parsePerms(r.Perms)
+		if err != nil {
+			return fmt.Errorf("invalid perms for path %q: %w", r.Path, err)
 		}
+		r.parsedPerms = mask
+	}
+
+	if r.MaxFileSize < 0 || r.MaxTotalBytes < 0 || r.MaxFiles < 0 || r.MaxSize < 0 {
+		return fmt.Errorf("%w: negative quota for path %q", ErrParse, r.Path)
+	}
+
+	switch r.Approval {
+	case "", ApprovalNone, ApprovalRequired:
+	default:
+		return fmt.Errorf("%w: unknown approval mode %q for path %q", ErrParse, r.Approval, r.Path)
 	}
 
-	for i := range c.Paths {
-		r := &c.Paths[i]
-		// permission check
-		if r.parsedPerms&op == 0 {
-			// this rule doesn't grant the requested operation
-			continue
+	r.notBefore, r.expiresAt, r.activeHours = time.Time{}, time.Time{}, nil
+	if r.NotBefore != "" {
+		t, err := parseRuleTime(r.NotBefore)
+		if err != nil {
+			return fmt.Errorf("%w: invalid not_before %q for path %q: %v", ErrParse, r.NotBefore, r.Path, err)
+		}
+		r.notBefore = t
+	}
+	if r.ExpiresAt != "" {
+		t, err := parseRuleTime(r.ExpiresAt)
+		if err != nil {
+			return fmt.Errorf("%w: invalid expires_at %q for path %q: %v", ErrParse, r.ExpiresAt, r.Path, err)
 		}
+		r.expiresAt = t
+	}
+	if !r.notBefore.IsZero() && !r.expiresAt.IsZero() && !r.notBefore.Before(r.expiresAt) {
+		return fmt.Errorf("%w: path %q expires_at is not after not_before", ErrParse, r.Path)
+	}
+	if r.ActiveHours != "" {
+		sched, err := parseCronSchedule(r.ActiveHours)
+		if err != nil {
+			return fmt.Errorf("%w: invalid active_hours %q for path %q: %v", ErrParse, r.ActiveHours, r.Path, err)
+		}
+		r.activeHours = sched
+	}
 
-		// path match
-		if r.cleanPath == cleanTarget {
-			// exact match passes; fall through to identity checks below
-		} else if *r.AllowSubpaths {
-			rel, err := filepath.Rel(r.cleanPath, cleanTarget)
-			if err != nil {
-				// cannot compute relation; skip this rule
-				continue
-			}
-			if rel == "." {
-				// same path; ok
-			} else if strings.HasPrefix(rel, "..") {
-				// target is outside of rule path; no match
-				continue
-			}
-			// else target is a subpath under rule path -> match
-		} else {
-			// not exact and subpaths aren't allowed
-			continue
+	r.extensions, r.mimeTypes = nil, nil
+	for _, ext := range r.Extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if strings.Trim(ext, ".") == "" || strings.ContainsAny(ext, `/\`) {
+			return fmt.Errorf("%w: invalid extension %q for path %q", ErrParse, ext, r.Path)
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		r.extensions = append(r.extensions, ext)
+	}
+	for _, typ := range r.MimeTypes {
+		p, err := parseMimeTypePattern(typ)
+		if err != nil {
+			return fmt.Errorf("%w: invalid mime type %q for path %q: %v", ErrParse, typ, r.Path, err)
 		}
+		r.mimeTypes = append(r.mimeTypes, p)
+	}
+	return nil
+}
+
+// Source returns the config file the rule was read from, or "" if the
+// config was not read from a file.
+func (r *PathRule) Source() string {
+	return r.source
+}
+
+// Permissions returns the parsed permission mask of the rule.
+func (r *PathRule) Permissions() Permission {
+	return r.parsedPerms
+}
 
-		// passed all checks -> allowed
+// covers reports whether cleanTarget is the rule path or, if allow_subpaths is
+// set, a path below it. Both are compared with their symlinks resolved.
+func (r *PathRule) covers(cleanTarget string) bool {
+	base := r.realPath
+	if base == "" {
+		base = r.cleanPath
+	}
+	// path match
+	if base == cleanTarget {
+		// exact match passes
 		return true
 	}
+	if r.AllowSubpaths != nil && !*r.AllowSubpaths {
+		// not exact and subpaths aren't allowed
+		return false
+	}
+	rel, err := filepath.Rel(base, cleanTarget)
+	if err != nil {
+		// cannot compute relation; skip this rule
+		return false
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// target is outside of rule path; no match
+		return false
+	}
+	// target is a subpath under rule path -> match
+	return true
+}
 
-	return false
+// cleanAbsPath cleans p and makes it absolute relative to the current working
+// directory when possible.
+func cleanAbsPath(p string) string {
+	clean := filepath.Clean(p)
+	if !filepath.IsAbs(clean) {
+		abs, err := filepath.Abs(clean)
+		if err == nil {
+			clean = abs
+		}
+	}
+	return clean
 }
 
 // ExpandEnv walks the config and expands environment variables in all string fields
-// and in elements of []string slices. It mutates the config in place.
+// and in elements of []string slices, except those of policy and redaction, as
+// ParseConfigData does. It mutates the
+// config in place.
 func (c *Config) ExpandEnv() error {
 	if c == nil {
 		return nil
 	}
-	return expandEnvInValue(reflect.ValueOf(c))
+	return expandEnvInValue(reflect.ValueOf(c), ParseOptions{}.expander(), "")
 }
 
-// ToYAML serializes the configuration to a YAML string.
+// ToYAML serializes the configuration to a YAML string. A config returned
+// by ParseConfigData is written into the document it was parsed from:
+// settings unchanged since parsing keep their original text, including
+// comments and ${VAR} references, and changed ones are updated in place.
 func (c *Config) ToYAML() (string, error) {
 	if c == nil {
 		return "", errors.New("nil config")
 	}
-	out, err := yaml.Marshal(c)
-	if err != nil {
+	var cur yaml.Node
+	if err := cur.Encode(c); err != nil {
 		return "", err
 	}
-	return string(out), nil
+	if c.source != nil {
+		doc := yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{mergeYAML(c.source, c.base, &cur)}}
+		return encodeYAML(&doc)
+	}
+	return encodeYAML(&cur)
 }
 
 // ToJSON serializes the configuration to a YAML string.
@@ -287,42 +781,67 @@ func (c *Config) ToJSON() (string, error) {
 	return string(out), nil
 }
 
-// ReadAndParseConfig is a convenience that reads a config file and parses it.
+// ReadAndParseConfig reads a config file and parses it together with the
+// files it includes; see Config.Include for how they are merged.
 func ReadAndParseConfig(path string) (*Config, error) {
-	data, err := ReadConfigData(path)
+	return ReadAndParseConfigWith(path, ParseOptions{})
+}
+
+// ReadAndParseConfigWith is ReadAndParseConfig with options, which apply to
+// the include list as well.
+func ReadAndParseConfigWith(path string, opts ParseOptions) (*Config, error) {
+	l := &configLoader{loaded: map[string]bool{}, expand: opts.expander(), paths: opts.pathResolver()}
+	layer, err := l.load(path)
 	if err != nil {
 		return nil, err
 	}
-	return ParseConfigData(data)
+	cfg, err := parseConfigDocument(layer.doc, opts, layer.sources)
+	if err != nil {
+		if len(l.files) == 1 {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s and its includes: %w", path, err)
+	}
+	cfg.files = l.files
+	// A file added to a directory an include entry reads would be merged
+	// on the next load, so those directories are as sensitive as the files.
+	cfg.addSensitive(l.files...)
+	cfg.addSensitive(l.dirs...)
+	if len(l.files) > 1 {
+		// The merged config is no longer the document of any one file.
+		cfg.source, cfg.base = nil, nil
+	}
+	return cfg, nil
 }
 
 // Utility helpers
 
-// expandEnvInValue recursively walks a value and applies os.ExpandEnv
-// to all string fields and to all elements of []string slices/maps[string]string.
-func expandEnvInValue(v reflect.Value) error {
+// expandEnvInValue recursively walks a value and expands environment
+// variables with e in all string fields and in all elements of string
+// slices and maps, except below fields tagged expand:"-". Errors name the setting by its YAML key path, which name
+// is the path of v.
+func expandEnvInValue(v reflect.Value, e *envExpander, name string) error {
 	if !v.IsValid() {
 		return nil
 	}
-	// If pointer, get the element (allocate if nil)
+	// If pointer, get the element. Nil pointers are left alone so optional
+	// fields such as allow_subpaths keep their "unset" meaning.
 	if v.Kind() == reflect.Pointer {
 		if v.IsNil() {
-			// allocate a new zero value for the element so we can set fields inside it
-			v.Set(reflect.New(v.Type().Elem()))
+			return nil
 		}
-		return expandEnvInValue(v.Elem())
+		return expandEnvInValue(v.Elem(), e, name)
 	}
 
 	switch v.Kind() {
 	case reflect.Struct:
 		for i := 0; i < v.NumField(); i++ {
-			// only settable/exported fields will be modified; skip unexported
-			f := v.Field(i)
-			// skip unexported fields
-			if !v.Type().Field(i).IsExported() {
+			field := v.Type().Field(i)
+			// skip unexported fields and those written verbatim
+			if !field.IsExported() || field.Tag.Get("expand") == "-" {
 				continue
 			}
-			if err := expandEnvInValue(f); err != nil {
+			if err := expandEnvInValue(v.Field(i), e, settingName(name, field)); err != nil {
 				return err
 			}
 		}
@@ -332,41 +851,40 @@ func expandEnvInValue(v reflect.Value) error {
 			return nil
 		}
 		for i := 0; i < v.Len(); i++ {
-			elem := v.Index(i)
-			if elem.Kind() == reflect.String {
-				expanded := os.ExpandEnv(elem.String())
-				if elem.CanSet() {
-					elem.SetString(expanded)
-				} else {
-					// can't set (maybe it's from an unexported field); skip
-				}
-			} else {
-				if err := expandEnvInValue(elem); err != nil {
-					return err
-				}
+			if err := expandEnvInValue(v.Index(i), e, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
 			}
 		}
 	case reflect.Map:
-		// only handle map[string]string conveniently
-		if v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.String {
-			for _, key := range v.MapKeys() {
-				orig := v.MapIndex(key).String()
-				expanded := os.ExpandEnv(orig)
-				v.SetMapIndex(key, reflect.ValueOf(expanded))
-			}
-		} else {
-			// for other map types, recursively walk values
-			for _, key := range v.MapKeys() {
-				val := v.MapIndex(key)
-				if err := expandEnvInValue(val); err != nil {
-					return err
-				}
+		// walk copies of the values, which are not settable in place
+		for _, key := range v.MapKeys() {
+			val := reflect.New(v.Type().Elem()).Elem()
+			val.Set(v.MapIndex(key))
+			if err := expandEnvInValue(val, e, fmt.Sprintf("%s.%v", name, key)); err != nil {
+				return err
 			}
+			v.SetMapIndex(key, val)
 		}
 	case reflect.String:
 		if v.CanSet() {
-			v.SetString(os.ExpandEnv(v.String()))
+			expanded, err := e.expand(v.String())
+			if err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrParse, name, err)
+			}
+			v.SetString(expanded)
 		}
 	}
 	return nil
 }
+
+// settingName returns the YAML key path of field within the setting name.
+func settingName(name string, field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if key == "" {
+		key = strings.ToLower(field.Name)
+	}
+	if name == "" {
+		return key
+	}
+	return name + "." + key
+}
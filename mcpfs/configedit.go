@@ -0,0 +1,343 @@
+package mcpfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// emptyConfigYAML is the document LoadConfigForEdit starts from when the
+// config file does not exist yet.
+const emptyConfigYAML = "version: \"" + CurrentConfigVersion + "\"\npaths: []\n"
+
+// keepSource records the document cfg was parsed from and cfg as parsed, so
+// that ToYAML can later write changes back into the original document.
+func (c *Config) keepSource(doc *yaml.Node) error {
+	if doc == nil || doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	var base yaml.Node
+	if err := base.Encode(c); err != nil {
+		return err
+	}
+	c.source, c.base = doc.Content[0], &base
+	return nil
+}
+
+// mergeYAML returns orig updated with the changes between base and cur,
+// where base is orig as it was decoded and cur is the value to write. Parts
+// of cur equal to base keep the original nodes, with their comments, styles
+// and unexpanded ${VAR} references, and keys only present in orig, such as
+// defaults the parser filled in being absent, stay as they were.
+func mergeYAML(orig, base, cur *yaml.Node) *yaml.Node {
+	if base != nil && orig != nil && yamlEqual(base, cur) {
+		return orig
+	}
+	if orig == nil || base == nil || orig.Kind != cur.Kind || base.Kind != cur.Kind {
+		out := *cur
+		if orig != nil {
+			out.HeadComment, out.LineComment, out.FootComment = orig.HeadComment, orig.LineComment, orig.FootComment
+		}
+		return &out
+	}
+
+	out := *orig
+	switch cur.Kind {
+	case yaml.MappingNode:
+		out.Content = nil
+		// Keep the original keys in order, merged or dropped, then append
+		// keys that are new in cur.
+		done := map[string]bool{}
+		for i := 0; i+1 < len(orig.Content); i += 2 {
+			key := orig.Content[i].Value
+			o, b, c := orig.Content[i+1], mappingValue(base, key), mappingValue(cur, key)
+			switch {
+			case c == nil && b == nil:
+				// not part of the config, e.g. an unknown key
+				out.Content = append(out.Content, orig.Content[i], o)
+			case c == nil:
+				// removed
+			default:
+				out.Content = append(out.Content, orig.Content[i], mergeYAML(o, b, c))
+			}
+			done[key] = true
+		}
+		for i := 0; i+1 < len(cur.Content); i += 2 {
+			key := cur.Content[i].Value
+			if done[key] {
+				continue
+			}
+			if b := mappingValue(base, key); b != nil && yamlEqual(b, cur.Content[i+1]) {
+				continue // a default the original leaves implicit
+			}
+			out.Content = append(out.Content, cur.Content[i], cur.Content[i+1])
+		}
+	case yaml.SequenceNode:
+		// Items equal to an original item keep it; the others are merged
+		// with the original item at the same position if that one is not
+		// kept for another item, and written as they are otherwise.
+		used := make([]bool, len(base.Content))
+		match := make([]int, len(cur.Content))
+		next := 0
+		for i, c := range cur.Content {
+			match[i] = -1
+			for j := next; j < len(base.Content); j++ {
+				if yamlEqual(base.Content[j], c) {
+					match[i], used[j], next = j, true, j+1
+					break
+				}
+			}
+		}
+		out.Content = make([]*yaml.Node, len(cur.Content))
+		for i, c := range cur.Content {
+			switch j := match[i]; {
+			case j >= 0 && j < len(orig.Content):
+				out.Content[i] = orig.Content[j]
+			case i < len(base.Content) && i < len(orig.Content) && !used[i]:
+				used[i] = true
+				out.Content[i] = mergeYAML(orig.Content[i], base.Content[i], c)
+			default:
+				out.Content[i] = c
+			}
+		}
+	default:
+		out.Value, out.Tag = cur.Value, cur.Tag
+		if cur.Tag == "!!str" && out.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && !strings.Contains(cur.Value, "\n") {
+			out.Style = 0
+		}
+	}
+	return &out
+}
+
+// yamlEqual reports whether a and b hold the same data.
+func yamlEqual(a, b *yaml.Node) bool {
+	a, b = resolveAlias(a), resolveAlias(b)
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind == yaml.ScalarNode {
+		return a.Value == b.Value && a.ShortTag() == b.ShortTag()
+	}
+	for i := range a.Content {
+		if !yamlEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadConfigForEdit reads the config file at path for programmatic changes
+// that are written back with WriteConfigFile. A missing file yields an empty
+// config that WriteConfigFile creates.
+func LoadConfigForEdit(path string) (*Config, error) {
+	_, cfg, err := loadConfigForEdit(path)
+	return cfg, err
+}
+
+// editParseOptions returns the options the config file at path is parsed
+// with for editing. Variables and placeholders the config refers to need not
+// resolve in the environment of the command editing it.
+func editParseOptions(path string) ParseOptions {
+	return ParseOptions{Dir: filepath.Dir(path), AllowUnsetEnv: true, AllowUnresolvedPaths: true}
+}
+
+// loadConfigForEdit is LoadConfigForEdit also returning the file content.
+func loadConfigForEdit(path string) ([]byte, *Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data = []byte(emptyConfigYAML)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("read config file: %w", err)
+	}
+	cfg, err := ParseConfigDataWith(data, editParseOptions(path))
+	return data, cfg, err
+}
+
+// WriteConfigFile validates cfg and writes it to path, keeping the comments
+// and layout of the document cfg was parsed from. The file is replaced
+// atomically.
+func WriteConfigFile(path string, cfg *Config) error {
+	out, err := cfg.ToYAML()
+	if err != nil {
+		return err
+	}
+	if _, err := ParseConfigDataWith([]byte(out), editParseOptions(path)); err != nil {
+		return fmt.Errorf("refusing to write invalid config: %w", err)
+	}
+	// A symlinked config, as dotfile managers set up, is written through to
+	// its target; renaming over the link would replace it with a copy.
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	mode := fs.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return writeFileAtomic(path, []byte(out), mode)
+}
+
+// EditConfigFile applies edit to the config file at path and writes the
+// result back with WriteConfigFile. A lock file next to the config keeps
+// concurrent edits from losing each other's changes. A file of an older
+// version is written in the current format after a backup as with
+// MigrateConfigFile.
+func EditConfigFile(path string, edit func(cfg *Config) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("lock config file: %w", err)
+	}
+	defer unlock()
+
+	data, cfg, err := loadConfigForEdit(path)
+	if err != nil {
+		return err
+	}
+	if err := edit(cfg); err != nil {
+		return err
+	}
+	if cfg.migratedFrom != "" {
+		if _, err := backupConfigFile(path, data, cfg.migratedFrom); err != nil {
+			return err
+		}
+	}
+	return WriteConfigFile(path, cfg)
+}
+
+// Set assigns value, parsed as YAML, to the setting named by key, a dotted
+// path of YAML keys such as "log_level" or "rate_limits.max_concurrent".
+// Path rules are changed with AddRule and RemoveRule instead.
+func (c *Config) Set(key, value string) error {
+	v := reflect.ValueOf(c).Elem()
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+			if i != len(parts)-1 {
+				return fmt.Errorf("unknown setting %q", key)
+			}
+			if v.IsNil() {
+				v.Set(reflect.MakeMap(v.Type()))
+			}
+			elem := reflect.New(v.Type().Elem())
+			if err := yaml.Unmarshal([]byte(value), elem.Interface()); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			v.SetMapIndex(reflect.ValueOf(part), elem.Elem())
+			return nil
+		}
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		f, ok := yamlField(v, part)
+		if !ok || (i == 0 && part == "paths") {
+			if part == "paths" {
+				return fmt.Errorf("path rules are changed with add-rule and remove-rule")
+			}
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		v = f
+	}
+	if v.Kind() == reflect.Struct {
+		return fmt.Errorf("%q is a section; set one of its keys", key)
+	}
+	target := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(value), target.Interface()); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	v.Set(target.Elem())
+	return nil
+}
+
+// yamlField returns the field of struct v with the YAML key name.
+func yamlField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = strings.ToLower(f.Name)
+		}
+		if tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// AddRule appends r to the path rules. The path is stored as given, so it
+// may contain ${VAR} references. It fails if a rule for the same path exists.
+func (c *Config) AddRule(r PathRule) error {
+	if strings.TrimSpace(r.Path) == "" {
+		return fmt.Errorf("%w: rule path must not be empty", ErrParse)
+	}
+	if _, err := parsePerms(r.Perms); err != nil {
+		return fmt.Errorf("%w: %v", ErrParse, err)
+	}
+	if i := c.findRules(r.Path); len(i) > 0 {
+		return fmt.Errorf("a rule for %q already exists", c.Paths[i[0]].Path)
+	}
+	c.Paths = append(c.Paths, r)
+	return nil
+}
+
+// RemoveRule removes the rules whose path is path, as written or as
+// expanded, and returns how many were removed.
+func (c *Config) RemoveRule(path string) int {
+	idx := c.findRules(path)
+	for n, i := range idx {
+		i -= n
+		c.Paths = append(c.Paths[:i], c.Paths[i+1:]...)
+	}
+	return len(idx)
+}
+
+// findRules returns the indexes of the rules for path in ascending order.
+func (c *Config) findRules(path string) []int {
+	clean := cleanAbsPath(path)
+	var idx []int
+	for i := range c.Paths {
+		r := &c.Paths[i]
+		if r.Path == path || r.rawPath == path || r.cleanPath == clean {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// encodeYAML marshals the node with two-space indentation.
+func encodeYAML(n *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,214 @@
+package mcpfs_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+const editableConfig = `# mcpfs config
+version: "1"
+paths:
+  # the project checkout
+  - path: ${PROJECT_DIR}/src
+    perms: [read, write] # edited by the agent
+  - path: /tmp/scratch
+    perms: [read]
+log_level: info # keep it quiet
+custom_note: kept as is
+`
+
+func TestConfig_ToYAMLKeepsSource(t *testing.T) {
+	t.Setenv("PROJECT_DIR", "/home/me/project")
+	cfg, err := mcpfs.ParseConfigData([]byte(editableConfig))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+
+	out, err := cfg.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML: %v", err)
+	}
+	if out != editableConfig {
+		t.Fatalf("unchanged config not written back as is:\n%s", out)
+	}
+
+	if err := cfg.Set("log_level", "debug"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cfg.Set("rate_limits.max_concurrent", "4"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := cfg.AddRule(mcpfs.PathRule{Path: "${HOME}/notes", Perms: []string{"read"}}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if n := cfg.RemoveRule("/tmp/scratch"); n != 1 {
+		t.Fatalf("RemoveRule removed %d rules, want 1", n)
+	}
+
+	out, err = cfg.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML: %v", err)
+	}
+	for _, want := range []string{
+		"# mcpfs config",
+		"# the project checkout",
+		"- path: ${PROJECT_DIR}/src",
+		"perms: [read, write] # edited by the agent",
+		"- path: ${HOME}/notes",
+		"log_level: debug # keep it quiet",
+		"custom_note: kept as is",
+		"rate_limits:\n  max_concurrent: 4",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "/tmp/scratch") || strings.Contains(out, "/home/me/project") {
+		t.Errorf("output has removed rule or expanded path:\n%s", out)
+	}
+}
+
+func TestConfig_EditErrors(t *testing.T) {
+	t.Setenv("PROJECT_DIR", "/home/me/project")
+	cfg, err := mcpfs.ParseConfigData([]byte(editableConfig))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	for _, key := range []string{"paths", "no_such_key", "rate_limits"} {
+		if err := cfg.Set(key, "1"); err == nil {
+			t.Errorf("Set(%q) succeeded", key)
+		}
+	}
+	if err := cfg.AddRule(mcpfs.PathRule{Path: "/tmp/scratch"}); err == nil {
+		t.Error("AddRule accepted a duplicate path")
+	}
+	if err := cfg.AddRule(mcpfs.PathRule{Path: "/other", Perms: []string{"fly"}}); err == nil {
+		t.Error("AddRule accepted an unknown permission")
+	}
+	if n := cfg.RemoveRule("/nowhere"); n != 0 {
+		t.Errorf("RemoveRule removed %d rules for an unknown path", n)
+	}
+}
+
+func TestWriteConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcpfs", "config.yaml")
+
+	cfg, err := mcpfs.LoadConfigForEdit(path)
+	if err != nil {
+		t.Fatalf("LoadConfigForEdit: %v", err)
+	}
+	if err := cfg.AddRule(mcpfs.PathRule{Path: "/srv/data", Perms: []string{"read", "write"}}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	if err := mcpfs.WriteConfigFile(path, cfg); err != nil {
+		t.Fatalf("WriteConfigFile: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("new config mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	loaded, err := mcpfs.ReadAndParseConfig(path)
+	if err != nil {
+		t.Fatalf("ReadAndParseConfig: %v", err)
+	}
+	if !loaded.IsAllowed(mcpfs.PermWrite, "/srv/data/file") {
+		t.Fatal("written rule not in effect")
+	}
+
+	// An edit that leaves the config invalid is not written.
+	if err := loaded.Set("sandbox.landlock", "bogus"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := mcpfs.WriteConfigFile(path, loaded); err == nil {
+		t.Fatal("WriteConfigFile wrote an invalid config")
+	}
+	if data := readFile(t, path); strings.Contains(data, "bogus") {
+		t.Fatalf("invalid config written:\n%s", data)
+	}
+}
+
+func TestEditConfigFile_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(editableConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = mcpfs.EditConfigFile(path, func(cfg *mcpfs.Config) error {
+				return cfg.AddRule(mcpfs.PathRule{Path: fmt.Sprintf("/srv/%d", i)})
+			})
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("EditConfigFile: %v", err)
+		}
+	}
+
+	data := readFile(t, path)
+	for i := range n {
+		if !strings.Contains(data, fmt.Sprintf("- path: /srv/%d\n", i)) {
+			t.Errorf("rule %d lost:\n%s", i, data)
+		}
+	}
+	if !strings.Contains(data, "# the project checkout") {
+		t.Errorf("comments lost:\n%s", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("mode = %v, want the original 0644", info.Mode().Perm())
+	}
+
+	err = mcpfs.EditConfigFile(path, func(cfg *mcpfs.Config) error {
+		return cfg.AddRule(mcpfs.PathRule{Path: "/srv/0"})
+	})
+	if err == nil {
+		t.Fatal("EditConfigFile added a duplicate rule")
+	}
+}
+
+func TestEditConfigFile_Symlink(t *testing.T) {
+	dotfiles := t.TempDir()
+	target := filepath.Join(dotfiles, "mcpfs.yaml")
+	if err := os.WriteFile(target, []byte(editableConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PROJECT_DIR", "/home/me/project")
+	err := mcpfs.EditConfigFile(link, func(cfg *mcpfs.Config) error {
+		return cfg.AddRule(mcpfs.PathRule{Path: "/srv/new"})
+	})
+	if err != nil {
+		t.Fatalf("EditConfigFile: %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("config link replaced: %v, %v", info, err)
+	}
+	if data := readFile(t, target); !strings.Contains(data, "- path: /srv/new\n") {
+		t.Fatalf("link target not edited:\n%s", data)
+	}
+}
@@ -0,0 +1,147 @@
+package mcpfs
+
+import (
+	"fmt"
+	"strings"
+
+	std "github.com/jlrickert/go-std/pkg"
+)
+
+// envExpander expands environment variable references in config values:
+//
+//	$VAR, ${VAR}     the value of VAR
+//	${VAR:-default}  the value of VAR, or default if VAR is unset or empty
+//	${VAR:?message}  the value of VAR, or an error with message
+//	$$               a literal $
+//
+// Defaults and messages may themselves contain references. A variable that
+// is unset or empty and has no default is an error unless allowUnset is
+// set, so that "${WORKSPACE}/x" cannot silently become "/x"; write
+// "${WORKSPACE:-}" where an empty value is fine.
+type envExpander struct {
+	env        std.Env
+	allowUnset bool
+}
+
+// expand returns s with its references expanded.
+func (e *envExpander) expand(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c != '$' || i+1 == len(s) {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i += 2
+		case next == '{':
+			end := closingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			v, err := e.expandBraced(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end + 1
+		case isEnvNameStart(next):
+			j := i + 1
+			for j < len(s) && isEnvNameChar(s[j]) {
+				j++
+			}
+			v, err := e.lookup(s[i+1:j], nil)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = j
+		default:
+			// not a reference, e.g. "$5"
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), nil
+}
+
+// expandBraced expands the inside of a ${...} reference.
+func (e *envExpander) expandBraced(ref string) (string, error) {
+	n := 0
+	for n < len(ref) && isEnvNameChar(ref[n]) {
+		n++
+	}
+	name, op := ref[:n], ref[n:]
+	if name == "" || !isEnvNameStart(name[0]) {
+		return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+	}
+	switch {
+	case op == "":
+		return e.lookup(name, nil)
+	case strings.HasPrefix(op, ":-"):
+		def := op[2:]
+		return e.lookup(name, func() (string, error) { return e.expand(def) })
+	case strings.HasPrefix(op, ":?"):
+		msg := op[2:]
+		return e.lookup(name, func() (string, error) {
+			m, err := e.expand(msg)
+			if err != nil {
+				return "", err
+			}
+			if m == "" {
+				m = "must be set"
+			}
+			return "", fmt.Errorf("%s: %s", name, m)
+		})
+	default:
+		return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+	}
+}
+
+// lookup returns the value of the variable name, or what unset returns if
+// it is unset or empty. A nil unset makes that an error.
+func (e *envExpander) lookup(name string, unset func() (string, error)) (string, error) {
+	if v := e.env.Get(name); v != "" {
+		return v, nil
+	}
+	if unset != nil {
+		return unset()
+	}
+	if e.allowUnset {
+		return "", nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set; use ${%s:-default} to give a default", name, name)
+}
+
+// closingBrace returns the index of the brace closing a reference whose
+// content starts at i in s, allowing references nested in defaults, or -1.
+func closingBrace(s string, i int) int {
+	depth := 1
+	for ; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isEnvNameStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isEnvNameChar(c byte) bool {
+	return isEnvNameStart(c) || '0' <= c && c <= '9'
+}
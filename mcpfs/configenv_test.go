@@ -0,0 +1,144 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestParseConfigDataWith_Env(t *testing.T) {
+	env := std.NewTestEnv("/home/me", "me")
+	env.Set("WORKSPACE", "/home/me/ws")
+	env.Set("LEVEL", "debug")
+	opts := mcpfs.ParseOptions{Env: env}
+
+	for value, want := range map[string]string{
+		"/srv":                              "/srv",
+		"${WORKSPACE}/x":                    "/home/me/ws/x",
+		"$WORKSPACE/x":                      "/home/me/ws/x",
+		"${MISSING:-/opt}/x":                "/opt/x",
+		"${MISSING:-${WORKSPACE}}/x":        "/home/me/ws/x",
+		"${WORKSPACE:-/opt}/x":              "/home/me/ws/x",
+		"${WORKSPACE:?set WORKSPACE}/x":     "/home/me/ws/x",
+		"/srv/$$WORKSPACE":                  "/srv/$WORKSPACE",
+		"/srv/price$5":                      "/srv/price$5",
+		"/srv/${MISSING:-}":                 "/srv",
+		"/srv/${MISSING:-a}${MISSING:-b}$$": "/srv/ab$",
+	} {
+		data := "version: \"2.0\"\npaths:\n  - path: '" + value + "'\nlog_level: $LEVEL\n"
+		cfg, err := mcpfs.ParseConfigDataWith([]byte(data), opts)
+		if err != nil {
+			t.Errorf("%s: %v", value, err)
+			continue
+		}
+		if got := cfg.Paths[0].CleanPath(); got != want {
+			t.Errorf("%s expanded to %q, want %q", value, got, want)
+		}
+		if cfg.LogLevel != "debug" {
+			t.Errorf("log_level = %q", cfg.LogLevel)
+		}
+	}
+
+	for value, want := range map[string]string{
+		"${MISSING}/x":                   "paths[0].path: environment variable MISSING is not set",
+		"$MISSING/x":                     "environment variable MISSING is not set",
+		"${MISSING:?set it to the repo}": "paths[0].path: MISSING: set it to the repo",
+		"${MISSING:?}":                   "MISSING: must be set",
+		"${WORKSPACE":                    "unterminated ${",
+		"${1X}":                          "invalid variable reference ${1X}",
+		"${WORKSPACE/x}":                 "invalid variable reference",
+	} {
+		data := "paths:\n  - path: '" + value + "'\n"
+		_, err := mcpfs.ParseConfigDataWith([]byte(data), opts)
+		if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", value, err, want)
+		}
+	}
+
+	// Commands editing the config need not have its variables set.
+	opts.AllowUnsetEnv = true
+	cfg, err := mcpfs.ParseConfigDataWith([]byte("paths:\n  - path: /srv/${MISSING}\n"), opts)
+	if err != nil {
+		t.Fatalf("AllowUnsetEnv: %v", err)
+	}
+	if got := cfg.Paths[0].CleanPath(); got != "/srv" {
+		t.Errorf("AllowUnsetEnv expanded to %q", got)
+	}
+}
+
+func TestReadAndParseConfigWith_EnvIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml":       "include:\n  - ${TEAM_DIR:-team}/shared.yaml\n",
+		"team/shared.yaml":  "paths:\n  - path: /srv/team\n",
+		"other/shared.yaml": "paths:\n  - path: /srv/other\n",
+		"strict.yaml":       "include:\n  - ${TEAM_DIR}/shared.yaml\n",
+	})
+	env := std.NewTestEnv("/home/me", "me")
+	opts := mcpfs.ParseOptions{Env: env}
+
+	cfg, err := mcpfs.ReadAndParseConfigWith(filepath.Join(dir, "config.yaml"), opts)
+	if err != nil {
+		t.Fatalf("ReadAndParseConfigWith: %v", err)
+	}
+	if got := cfg.Paths[0].CleanPath(); got != "/srv/team" {
+		t.Errorf("default include gave rule %q", got)
+	}
+
+	env.Set("TEAM_DIR", filepath.Join(dir, "other"))
+	cfg, err = mcpfs.ReadAndParseConfigWith(filepath.Join(dir, "config.yaml"), opts)
+	if err != nil {
+		t.Fatalf("ReadAndParseConfigWith: %v", err)
+	}
+	if got := cfg.Paths[0].CleanPath(); got != "/srv/other" {
+		t.Errorf("include from TEAM_DIR gave rule %q", got)
+	}
+
+	env.Unset("TEAM_DIR")
+	_, err = mcpfs.ReadAndParseConfigWith(filepath.Join(dir, "strict.yaml"), opts)
+	if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), "TEAM_DIR is not set") {
+		t.Errorf("unset include variable: %v", err)
+	}
+}
+
+func TestParseConfigDataWith_KeepsPolicyAndRedactionVerbatim(t *testing.T) {
+	env := std.NewTestEnv("/home/me", "me")
+	env.Set("SECRET", "expanded")
+	data := `version: "2.0"
+paths:
+  - path: /srv
+policy:
+  expr: "path != '/srv/$SECRET'"
+redaction:
+  enabled: true
+  patterns:
+    - name: api key
+      regex: '(?m)^API_KEY=(\S+)$'
+    - name: placeholder
+      regex: 'token=\$SECRET(\w+)$'
+`
+	cfg, err := mcpfs.ParseConfigDataWith([]byte(data), mcpfs.ParseOptions{Env: env})
+	if err != nil {
+		t.Fatalf("ParseConfigDataWith: %v", err)
+	}
+	if got, want := cfg.Policy.Expr, "path != '/srv/$SECRET'"; got != want {
+		t.Errorf("policy.expr = %q, want %q", got, want)
+	}
+	for i, want := range []string{`(?m)^API_KEY=(\S+)$`, `token=\$SECRET(\w+)$`} {
+		if got := cfg.Redaction.Patterns[i].Regex; got != want {
+			t.Errorf("redaction pattern %d = %q, want %q", i, got, want)
+		}
+	}
+
+	r, err := mcpfs.NewRedactor(cfg.Redaction)
+	if err != nil {
+		t.Fatalf("NewRedactor: %v", err)
+	}
+	out, _ := r.Redact("API_KEY=abc123\nother=1\n")
+	if strings.Contains(out, "abc123") {
+		t.Errorf("pattern ending in $ did not mask the key: %q", out)
+	}
+}
@@ -0,0 +1,275 @@
+package mcpfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configLoader reads a config file and the files it includes.
+type configLoader struct {
+	stack  []string        // files being loaded, outermost first
+	loaded map[string]bool // files merged already
+	files  []string        // files in merge order
+	dirs   []string        // directories glob and directory entries read
+	expand *envExpander    // expands the include list
+	paths  *pathResolver   // resolves the include list
+}
+
+// configLayer is a config document with the file each of its path rules
+// came from.
+type configLayer struct {
+	doc *yaml.Node
+	// sources[p][i] is the file of the i-th path rule of profile p, or of
+	// the top-level rules for p "".
+	sources map[string][]string
+}
+
+// Files returns the config files ReadAndParseConfig merged into the config,
+// in merge order, the file it was given last.
+func (c *Config) Files() []string {
+	return slices.Clone(c.files)
+}
+
+// load returns the config file at path merged over the files it includes.
+// A file included more than once is merged at its first place only.
+func (l *configLoader) load(path string) (*configLayer, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	if i := slices.Index(l.stack, abs); i >= 0 {
+		cycle := append(slices.Clone(l.stack[i:]), abs)
+		return nil, fmt.Errorf("%w: include cycle: %s", ErrParse, strings.Join(cycle, " -> "))
+	}
+	if l.loaded[abs] {
+		return &configLayer{}, nil
+	}
+	l.stack = append(l.stack, abs)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	data, err := ReadConfigData(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %s: yaml unmarshal: %v", ErrParse, path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if _, err := migrateConfig(root); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	includes, err := takeIncludes(root)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	merged := &configLayer{}
+	for _, include := range includes {
+		files, err := l.includedFiles(filepath.Dir(path), include)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: include %q: %v", ErrParse, path, include, err)
+		}
+		for _, file := range files {
+			layer, err := l.load(file)
+			if err != nil {
+				return nil, err
+			}
+			merged = mergeConfigLayers(merged, layer)
+		}
+	}
+	own := &configLayer{doc: &doc, sources: map[string][]string{}}
+	for profile, rules := range ruleLists(root) {
+		for range rules.Content {
+			own.sources[profile] = append(own.sources[profile], path)
+		}
+	}
+	l.loaded[abs] = true
+	l.files = append(l.files, path)
+	return mergeConfigLayers(merged, own), nil
+}
+
+// takeIncludes removes the include list from the config mapping root and
+// returns its entries.
+func takeIncludes(root *yaml.Node) ([]string, error) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "include" {
+			continue
+		}
+		var includes []string
+		if err := root.Content[i+1].Decode(&includes); err != nil {
+			return nil, fmt.Errorf("%w: include must be a list of paths: %v", ErrParse, err)
+		}
+		root.Content = slices.Delete(root.Content, i, i+2)
+		return includes, nil
+	}
+	return nil, nil
+}
+
+// includedFiles returns the files an include entry names, resolved against
+// dir as rule paths are. A glob pattern may match no file, as an empty conf.d directory does;
+// a path that does not exist is an error.
+func (l *configLoader) includedFiles(dir, include string) ([]string, error) {
+	include, err := l.expand.expand(include)
+	if err != nil {
+		return nil, err
+	}
+	if include == "" {
+		return nil, errors.New("empty path")
+	}
+	if include, err = l.paths.resolve(include, dir); err != nil {
+		return nil, err
+	}
+	if strings.ContainsAny(include, "*?[") {
+		files, err := filepath.Glob(include)
+		if err != nil {
+			return nil, err
+		}
+		slices.Sort(files)
+		dir := filepath.Dir(include)
+		for strings.ContainsAny(dir, "*?[") {
+			dir = filepath.Dir(dir)
+		}
+		l.addDir(dir)
+		return files, nil
+	}
+	info, err := os.Stat(include)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s does not exist", include)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{include}, nil
+	}
+	l.addDir(include)
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(include, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// addDir records dir as a directory new config files can be added to.
+func (l *configLoader) addDir(dir string) {
+	if !slices.Contains(l.dirs, dir) {
+		l.dirs = append(l.dirs, dir)
+	}
+}
+
+// ruleLists returns the path rule sequences of the config mapping root by
+// profile, "" for the top-level rules. Aliases are followed.
+func ruleLists(root *yaml.Node) map[string]*yaml.Node {
+	lists := map[string]*yaml.Node{}
+	if paths := resolveAlias(mappingValue(root, "paths")); paths != nil {
+		lists[""] = paths
+	}
+	if profiles := resolveAlias(mappingValue(root, "profiles")); profiles != nil {
+		for i := 0; i+1 < len(profiles.Content); i += 2 {
+			if paths := resolveAlias(mappingValue(resolveAlias(profiles.Content[i+1]), "paths")); paths != nil {
+				lists[profiles.Content[i].Value] = paths
+			}
+		}
+	}
+	return lists
+}
+
+// ownMapping returns the value of key in mapping m, replacing an alias
+// with a copy of the node it refers to so that changes to the value leave
+// the anchored node alone.
+func ownMapping(m *yaml.Node, key string) *yaml.Node {
+	v := mappingValue(m, key)
+	if v == nil || v.Kind != yaml.AliasNode {
+		return v
+	}
+	own := *resolveAlias(v)
+	own.Anchor = ""
+	own.Content = slices.Clone(own.Content)
+	setMapping(m, key, &own)
+	return &own
+}
+
+// mergeConfigLayers returns over merged over base: the path rules of over,
+// top-level and of each profile, come first, and other settings of over
+// replace those of base, mappings being merged key by key.
+func mergeConfigLayers(base, over *configLayer) *configLayer {
+	if base.doc == nil {
+		return over
+	}
+	if over.doc == nil {
+		return base
+	}
+	baseRoot, overRoot := base.doc.Content[0], over.doc.Content[0]
+	baseRules, overRules := ruleLists(baseRoot), ruleLists(overRoot)
+	root := mergeConfigNodes(baseRoot, overRoot)
+	merged := &configLayer{
+		doc:     &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}},
+		sources: map[string][]string{},
+	}
+	for profile := range ruleLists(root) {
+		rules := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if o := overRules[profile]; o != nil {
+			rules.Content = append(rules.Content, o.Content...)
+		}
+		if b := baseRules[profile]; b != nil {
+			rules.Content = append(rules.Content, b.Content...)
+		}
+		parent := root
+		if profile != "" {
+			parent = ownMapping(ownMapping(root, "profiles"), profile)
+		}
+		setMapping(parent, "paths", rules)
+		merged.sources[profile] = append(slices.Clone(over.sources[profile]), base.sources[profile]...)
+	}
+	return merged
+}
+
+// mergeConfigNodes returns over merged over base. Mappings are merged key
+// by key; any other value of over replaces that of base.
+func mergeConfigNodes(base, over *yaml.Node) *yaml.Node {
+	base, over = resolveAlias(base), resolveAlias(over)
+	if base.Kind != yaml.MappingNode || over.Kind != yaml.MappingNode {
+		return over
+	}
+	out := *base
+	out.Content = slices.Clone(base.Content)
+	for i := 0; i+1 < len(over.Content); i += 2 {
+		key, value := over.Content[i].Value, over.Content[i+1]
+		if b := mappingValue(&out, key); b != nil {
+			setMapping(&out, key, mergeConfigNodes(b, value))
+		} else {
+			out.Content = append(out.Content, over.Content[i], value)
+		}
+	}
+	return &out
+}
+
+// setMapping sets key of mapping m to v, adding the key if needed.
+func setMapping(m *yaml.Node, key string, v *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = v
+			return
+		}
+	}
+	appendMapping(m, key, v, 0)
+}
@@ -0,0 +1,219 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+// writeConfigFiles writes files, keyed by slash-separated path relative to
+// dir, and returns dir.
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestReadAndParseConfig_Includes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `version: "2.0"
+include:
+  - base.yaml
+  - conf.d
+paths:
+  - path: /srv/mine
+    perms: [read, write]
+log_level: debug
+`,
+		"base.yaml": `version: "2.0"
+paths:
+  - path: /srv
+    perms: [read]
+log_level: info
+log_path: /var/log/mcpfs.json
+rate_limits:
+  max_concurrent: 2
+`,
+		"conf.d/10-team.yaml": `include: [../base.yaml]
+paths:
+  - path: /srv/team
+    perms: [read, write]
+rate_limits:
+  max_concurrent: 8
+`,
+		"conf.d/20-tools.yml": "paths:\n  - path: /opt/tools\n    perms: [read, exec]\n",
+		"conf.d/README":       "not a config",
+	})
+	path := filepath.Join(dir, "config.yaml")
+
+	cfg, err := mcpfs.ReadAndParseConfig(path)
+	if err != nil {
+		t.Fatalf("ReadAndParseConfig: %v", err)
+	}
+
+	wantFiles := []string{
+		filepath.Join(dir, "base.yaml"),
+		filepath.Join(dir, "conf.d", "10-team.yaml"),
+		filepath.Join(dir, "conf.d", "20-tools.yml"),
+		path,
+	}
+	if got := cfg.Files(); !slices.Equal(got, wantFiles) {
+		t.Fatalf("Files() = %v, want %v", got, wantFiles)
+	}
+
+	var rules []string
+	for i := range cfg.Paths {
+		r := &cfg.Paths[i]
+		rel, _ := filepath.Rel(dir, r.Source())
+		rules = append(rules, r.CleanPath()+" "+filepath.ToSlash(rel))
+	}
+	wantRules := []string{
+		"/srv/mine config.yaml",
+		"/opt/tools conf.d/20-tools.yml",
+		"/srv/team conf.d/10-team.yaml",
+		"/srv base.yaml",
+	}
+	if !slices.Equal(rules, wantRules) {
+		t.Fatalf("rules = %q, want %q", rules, wantRules)
+	}
+	if r := cfg.MatchRule(mcpfs.PermWrite, "/srv/team/x"); r == nil || r.CleanPath() != "/srv/team" {
+		t.Fatalf("write to /srv/team not granted by its rule: %v", r)
+	}
+
+	if cfg.LogLevel != "debug" || cfg.LogPath != "/var/log/mcpfs.json" {
+		t.Errorf("log_level = %q, log_path = %q, want level from config.yaml and path from base.yaml", cfg.LogLevel, cfg.LogPath)
+	}
+	if cfg.RateLimits.MaxConcurrent != 8 {
+		t.Errorf("max_concurrent = %d, want 8 from conf.d", cfg.RateLimits.MaxConcurrent)
+	}
+	if len(cfg.Include) != 0 {
+		t.Errorf("merged config keeps includes %v", cfg.Include)
+	}
+}
+
+func TestReadAndParseConfig_IncludeErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"cycle.yaml":    "include: [a.yaml]\n",
+		"a.yaml":        "include: [b.yaml]\n",
+		"b.yaml":        "include: [a.yaml]\n",
+		"missing.yaml":  "include: [nowhere.yaml]\n",
+		"bad.yaml":      "include: [broken.yaml]\n",
+		"broken.yaml":   "paths:\n  - path: /x\n    perms: [fly]\n",
+		"notlist.yaml":  "include: {a: b}\n",
+		"noglob.yaml":   "include: [conf.d/*.yaml]\npaths: []\n",
+		"newer.yaml":    "include: [future.yaml]\n",
+		"future.yaml":   "version: \"9.0\"\n",
+		"selfloop.yaml": "include: [./selfloop.yaml]\n",
+	})
+	for name, want := range map[string]string{
+		"cycle.yaml":    "include cycle: " + filepath.Join(dir, "a.yaml") + " -> " + filepath.Join(dir, "b.yaml") + " -> " + filepath.Join(dir, "a.yaml"),
+		"selfloop.yaml": "include cycle",
+		"missing.yaml":  `include "nowhere.yaml": ` + filepath.Join(dir, "nowhere.yaml") + " does not exist",
+		"bad.yaml":      `unknown permission "fly"`,
+		"notlist.yaml":  "include must be a list",
+		"newer.yaml":    filepath.Join(dir, "future.yaml") + ": parse error: config version 9.0 is newer",
+	} {
+		_, err := mcpfs.ReadAndParseConfig(filepath.Join(dir, name))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", name, err, want)
+		}
+		if name != "bad.yaml" && !errors.Is(err, mcpfs.ErrParse) {
+			t.Errorf("%s: err = %v, want ErrParse", name, err)
+		}
+	}
+
+	if _, err := mcpfs.ReadAndParseConfig(filepath.Join(dir, "noglob.yaml")); err != nil {
+		t.Errorf("glob without matches: %v", err)
+	}
+}
+
+func TestReadAndParseConfig_AliasedRules(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `include: [base.yaml]
+profiles:
+  dev:
+    paths: &rules
+      - path: /srv/dev
+        perms: [read, write]
+  ops:
+    paths: *rules
+paths: *rules
+`,
+		"base.yaml": `paths:
+  - path: /srv/base
+profiles:
+  ops:
+    paths:
+      - path: /srv/ops
+`,
+	})
+	config, base := filepath.Join(dir, "config.yaml"), filepath.Join(dir, "base.yaml")
+	cfg, err := mcpfs.ReadAndParseConfig(config)
+	if err != nil {
+		t.Fatalf("ReadAndParseConfig: %v", err)
+	}
+	type rule struct{ path, source string }
+	rules := func(paths []mcpfs.PathRule) []rule {
+		var out []rule
+		for i := range paths {
+			out = append(out, rule{paths[i].Path, paths[i].Source()})
+		}
+		return out
+	}
+	want := []rule{{"/srv/dev", config}, {"/srv/base", base}}
+	if got := rules(cfg.Paths); !slices.Equal(got, want) {
+		t.Errorf("top-level rules = %v, want %v", got, want)
+	}
+	want = []rule{{"/srv/dev", config}, {"/srv/ops", base}}
+	if got := rules(cfg.Profiles["ops"].Paths); !slices.Equal(got, want) {
+		t.Errorf("rules of profile ops = %v, want %v", got, want)
+	}
+	want = []rule{{"/srv/dev", config}}
+	if got := rules(cfg.Profiles["dev"].Paths); !slices.Equal(got, want) {
+		t.Errorf("rules of profile dev = %v, want %v", got, want)
+	}
+
+	// Without includes the file is the only layer.
+	if err := os.WriteFile(config, []byte("profiles:\n  dev:\n    paths: &rules [{path: /srv/dev}]\npaths: *rules\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = mcpfs.ReadAndParseConfig(config); err != nil {
+		t.Fatalf("ReadAndParseConfig without includes: %v", err)
+	}
+	if got := rules(cfg.Paths); !slices.Equal(got, want) {
+		t.Errorf("top-level rules without includes = %v, want %v", got, want)
+	}
+	if got := rules(cfg.Profiles["dev"].Paths); !slices.Equal(got, want) {
+		t.Errorf("rules of profile dev without includes = %v, want %v", got, want)
+	}
+}
+
+func TestReadAndParseConfig_WithoutIncludesKeepsSource(t *testing.T) {
+	t.Setenv("PROJECT_DIR", "/home/me/project")
+	dir := writeConfigFiles(t, map[string]string{"config.yaml": editableConfig})
+	cfg, err := mcpfs.ReadAndParseConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ReadAndParseConfig: %v", err)
+	}
+	out, err := cfg.ToYAML()
+	if err != nil {
+		t.Fatalf("ToYAML: %v", err)
+	}
+	if out != editableConfig {
+		t.Fatalf("ToYAML changed the file:\n%s", out)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !unix
+
+package mcpfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// lockFileTimeout bounds how long lockFile waits for another process.
+const lockFileTimeout = 10 * time.Second
+
+// lockFile takes an exclusive lock on path by creating it, and waits until
+// no other process holds it. The lock is released by removing the file.
+func lockFile(path string) (unlock func() error, err error) {
+	deadline := time.Now().Add(lockFileTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o600)
+		if err == nil {
+			f.Close()
+			return func() error { return os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another process; remove it if that process is gone", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,186 @@
+package mcpfs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configVersions lists the config versions this build understands, oldest
+// first. The last one is CurrentConfigVersion.
+var configVersions = []string{ConfigVersionV1, ConfigVersionV2}
+
+// configMigration rewrites a config document of version from into version
+// to. Migrations work on the YAML tree so that a migrated file keeps the
+// comments and unexpanded values of the original. A migration without
+// migrate only raises the version: documents of version from are valid as
+// they are.
+type configMigration struct {
+	from, to string
+	migrate  func(root *yaml.Node) error
+}
+
+// configMigrations is the registry of migrations, applied in order from the
+// version of a document up to CurrentConfigVersion.
+var configMigrations = []configMigration{
+	{from: ConfigVersionV1, to: ConfigVersionV2},
+}
+
+// migrateConfig upgrades the config mapping root in place to
+// CurrentConfigVersion. It returns the version the document had if a
+// migration rewrote it, and "" if the document is valid as it is. A document
+// without a version is a version 1 config. Documents newer than this build
+// are refused, since their settings might not mean what this build would
+// make of them.
+func migrateConfig(root *yaml.Node) (string, error) {
+	if root.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("%w: config must be a mapping", ErrParse)
+	}
+	versionNode := mappingValue(root, "version")
+	from := ConfigVersionV1
+	if versionNode != nil && versionNode.Value != "" {
+		v, err := canonicalConfigVersion(versionNode.Value)
+		if err != nil {
+			return "", err
+		}
+		from = v
+	}
+
+	version, rewritten := from, false
+	for _, m := range configMigrations {
+		if m.from != version {
+			continue
+		}
+		if m.migrate != nil {
+			if err := m.migrate(root); err != nil {
+				return "", fmt.Errorf("%w: migrate config from version %s to %s: %v", ErrParse, m.from, m.to, err)
+			}
+			rewritten = true
+		}
+		version = m.to
+	}
+	if version != CurrentConfigVersion {
+		return "", fmt.Errorf("%w: no migration from config version %s", ErrParse, version)
+	}
+	if !rewritten {
+		return "", nil
+	}
+
+	v := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: version, Style: yaml.DoubleQuotedStyle}
+	if versionNode != nil {
+		versionNode.Value, versionNode.Tag, versionNode.Style = v.Value, v.Tag, v.Style
+	} else {
+		// the comment heading the file stays at its top
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "version"}
+		if len(root.Content) > 0 {
+			key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+		}
+		root.Content = append([]*yaml.Node{key, v}, root.Content...)
+	}
+	return from, nil
+}
+
+// canonicalConfigVersion returns the known version v names, accepting "1"
+// for "1.0". It fails for versions newer than CurrentConfigVersion.
+func canonicalConfigVersion(v string) (string, error) {
+	major, minor, ok := parseConfigVersion(v)
+	if !ok {
+		return "", fmt.Errorf("%w: invalid config version %q", ErrParse, v)
+	}
+	for _, known := range configVersions {
+		if kmajor, kminor, _ := parseConfigVersion(known); kmajor == major && kminor == minor {
+			return known, nil
+		}
+	}
+	cmajor, cminor, _ := parseConfigVersion(CurrentConfigVersion)
+	if major > cmajor || (major == cmajor && minor > cminor) {
+		return "", fmt.Errorf("%w: config version %s is newer than this mcpfs supports (%s); upgrade mcpfs",
+			ErrParse, v, CurrentConfigVersion)
+	}
+	return "", fmt.Errorf("%w: unknown config version %q", ErrParse, v)
+}
+
+// parseConfigVersion splits a "major" or "major.minor" version.
+func parseConfigVersion(v string) (major, minor int, ok bool) {
+	majorStr, minorStr, hasMinor := strings.Cut(strings.TrimSpace(v), ".")
+	major, err := strconv.Atoi(majorStr)
+	if err != nil || major < 0 {
+		return 0, 0, false
+	}
+	if hasMinor {
+		if minor, err = strconv.Atoi(minorStr); err != nil || minor < 0 {
+			return 0, 0, false
+		}
+	}
+	return major, minor, true
+}
+
+// MigratedFrom returns the version of the document the config was parsed
+// from if ParseConfigData migrated it, and "" otherwise.
+func (c *Config) MigratedFrom() string {
+	return c.migratedFrom
+}
+
+// MigrateConfigFile rewrites the config file at path in the current format,
+// after copying the original to backup. It returns the version the file had
+// and "" for backup if no migration had to change it and it was left alone.
+func MigrateConfigFile(path string) (from, backup string, err error) {
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return "", "", fmt.Errorf("lock config file: %w", err)
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("read config file: %w", err)
+	}
+	cfg, err := ParseConfigDataWith(data, editParseOptions(path))
+	if err != nil {
+		return "", "", err
+	}
+	if cfg.migratedFrom == "" {
+		return cfg.Version, "", nil
+	}
+	backup, err = backupConfigFile(path, data, cfg.migratedFrom)
+	if err != nil {
+		return "", "", err
+	}
+	return cfg.migratedFrom, backup, WriteConfigFile(path, cfg)
+}
+
+// backupConfigFile saves data, the content of the config file at path of
+// the given version, next to it under a name that is not taken yet.
+func backupConfigFile(path string, data []byte, version string) (string, error) {
+	mode := fs.FileMode(0o600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	base := path + ".v" + version + ".bak"
+	for i := 0; ; i++ {
+		backup := base
+		if i > 0 {
+			backup = fmt.Sprintf("%s.%d", base, i)
+		}
+		f, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("back up config file: %w", err)
+		}
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(backup)
+			return "", fmt.Errorf("back up config file: %w", err)
+		}
+		return backup, nil
+	}
+}
@@ -0,0 +1,126 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+const configV1 = `# old config
+paths:
+  - path: /srv
+    perms: [read]
+# logging
+log_level: debug # noisy
+log_path: ""
+`
+
+func TestParseConfigData_AcceptsV1(t *testing.T) {
+	for name, data := range map[string]string{
+		"unversioned": configV1,
+		"1":           "version: 1\n" + configV1,
+		"1.0":         "version: \"1.0\"\n" + configV1,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := mcpfs.ParseConfigData([]byte(data))
+			if err != nil {
+				t.Fatalf("ParseConfigData: %v", err)
+			}
+			if cfg.Version != mcpfs.CurrentConfigVersion || cfg.MigratedFrom() != "" {
+				t.Fatalf("version %q migrated from %q", cfg.Version, cfg.MigratedFrom())
+			}
+			if cfg.LogLevel != "debug" || cfg.LogPath != "" {
+				t.Fatalf("log_level = %q, log_path = %q", cfg.LogLevel, cfg.LogPath)
+			}
+
+			// version 1 needs no rewriting to be read as version 2
+			out, err := cfg.ToYAML()
+			if err != nil {
+				t.Fatalf("ToYAML: %v", err)
+			}
+			if out != data {
+				t.Fatalf("version 1 config not written back as is:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestParseConfigData_Versions(t *testing.T) {
+	cfg, err := mcpfs.ParseConfigData([]byte("version: \"2.0\"\npaths: []\nlog_level: warn\n"))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	if cfg.MigratedFrom() != "" || cfg.LogLevel != "warn" {
+		t.Fatalf("current config migrated from %q, log_level %q", cfg.MigratedFrom(), cfg.LogLevel)
+	}
+
+	for data, want := range map[string]string{
+		"version: \"2.1\"\n": "newer than this mcpfs supports",
+		"version: 3\n":       "newer than this mcpfs supports",
+		"version: \"0.9\"\n": "unknown config version",
+		"version: latest\n":  "invalid config version",
+	} {
+		_, err := mcpfs.ParseConfigData([]byte(data))
+		if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseConfigData(%q) = %v, want %q", data, err, want)
+		}
+	}
+}
+
+func TestMigrateConfigFile_LeavesV1Alone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(configV1), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	from, backup, err := mcpfs.MigrateConfigFile(path)
+	if err != nil {
+		t.Fatalf("MigrateConfigFile: %v", err)
+	}
+	if from != mcpfs.CurrentConfigVersion || backup != "" {
+		t.Fatalf("migrated from %q with backup %q", from, backup)
+	}
+	if got := readFile(t, path); got != configV1 {
+		t.Fatalf("config file rewritten:\n%s", got)
+	}
+	matches, err := filepath.Glob(path + ".*.bak")
+	if err != nil || len(matches) != 0 {
+		t.Fatalf("backups %v, %v", matches, err)
+	}
+}
+
+func TestMigrateConfigFile_BacksUpRewrittenFile(t *testing.T) {
+	mcpfs.RewriteV1Configs(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(configV1), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	from, backup, err := mcpfs.MigrateConfigFile(path)
+	if err != nil {
+		t.Fatalf("MigrateConfigFile: %v", err)
+	}
+	if from != mcpfs.ConfigVersionV1 || backup != path+".v1.0.bak" {
+		t.Fatalf("migrated from %q with backup %q", from, backup)
+	}
+	if got := readFile(t, backup); got != configV1 {
+		t.Fatalf("backup holds:\n%s", got)
+	}
+	info, err := os.Stat(backup)
+	if err != nil || info.Mode().Perm() != 0o640 {
+		t.Fatalf("backup mode %v, %v", info, err)
+	}
+	got := readFile(t, path)
+	if !strings.HasPrefix(got, "# old config\nversion: \""+mcpfs.CurrentConfigVersion+"\"\n") || !strings.Contains(got, "log_level: debug # noisy") {
+		t.Fatalf("migrated config:\n%s", got)
+	}
+
+	// a second migration finds nothing to do and keeps the backup
+	if from, backup, err = mcpfs.MigrateConfigFile(path); err != nil || backup != "" || from != mcpfs.CurrentConfigVersion {
+		t.Fatalf("second migration: from %q, backup %q, err %v", from, backup, err)
+	}
+}
@@ -0,0 +1,155 @@
+package mcpfs
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	std "github.com/jlrickert/go-std/pkg"
+)
+
+// pathResolver turns the path of a rule or include, after environment
+// expansion, into a clean absolute path:
+//
+//   - a leading ~ is the home directory and ~name that of user name;
+//   - {config} is the directory of the default config file, {state} and
+//     {cache} the mcpfs state and cache directories, {cwd} the working
+//     directory and {repo_root} the root of the git worktree containing it;
+//   - a relative path is relative to the directory of the config file it is
+//     in, or ParseOptions.Dir.
+//
+// Other text in braces is left alone, since paths may contain braces.
+type pathResolver struct {
+	env std.Env
+	dir string // default directory of relative paths
+	cwd string
+	// lenient leaves ~name and placeholders that cannot be resolved as
+	// written instead of failing.
+	lenient bool
+
+	placeholders map[string]string // resolved placeholders
+}
+
+// placeholderNames are the placeholders pathResolver knows.
+var placeholderNames = []string{"config", "state", "cache", "cwd", "repo_root"}
+
+// resolve returns path resolved as described on pathResolver, relative
+// paths against dir, or the resolver's directory if dir is "".
+func (p *pathResolver) resolve(path, dir string) (string, error) {
+	path, err := p.expandHome(path)
+	if err != nil {
+		return "", err
+	}
+	if path, err = p.expandPlaceholders(path); err != nil {
+		return "", err
+	}
+	if dir == "" {
+		dir = p.dir
+	}
+	if dir == "" {
+		dir = p.cwd
+	}
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	return cleanAbsPath(path), nil
+}
+
+// expandHome expands a leading ~ or ~name in path.
+func (p *pathResolver) expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+	name, rest := path[1:], ""
+	if i := strings.IndexAny(name, `/`+string(filepath.Separator)); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	var home string
+	if cur, err := p.env.GetUser(); name == "" || err == nil && cur == name {
+		h, err := p.env.GetHome()
+		if err != nil {
+			return p.unresolved(path, fmt.Errorf("home directory: %v", err))
+		}
+		home = h
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return p.unresolved(path, fmt.Errorf("home directory of %s: %v", name, err))
+		}
+		home = u.HomeDir
+	}
+	return home + rest, nil
+}
+
+// expandPlaceholders replaces the known {name} placeholders in path.
+func (p *pathResolver) expandPlaceholders(path string) (string, error) {
+	if !strings.Contains(path, "{") {
+		return path, nil
+	}
+	out := path
+	for _, name := range placeholderNames {
+		token := "{" + name + "}"
+		if !strings.Contains(out, token) {
+			continue
+		}
+		v, err := p.placeholder(name)
+		if err != nil {
+			return p.unresolved(path, fmt.Errorf("%s: %v", token, err))
+		}
+		out = strings.ReplaceAll(out, token, v)
+	}
+	return out, nil
+}
+
+// placeholder returns the value of the placeholder name.
+func (p *pathResolver) placeholder(name string) (string, error) {
+	if v, ok := p.placeholders[name]; ok {
+		return v, nil
+	}
+	var v string
+	var err error
+	switch name {
+	case "config":
+		if v, err = DefaultConfigPath(p.env); err == nil {
+			v = filepath.Dir(v)
+		}
+	case "state":
+		v, err = std.UserStatePath(AppName, p.env)
+	case "cache":
+		if v, err = std.UserCachePath(AppName, p.env); err == nil {
+			v = filepath.Join(v, AppName)
+		}
+	case "cwd":
+		v = p.cwd
+		if v == "" {
+			v, err = os.Getwd()
+		}
+	case "repo_root":
+		var cwd string
+		if cwd, err = p.placeholder("cwd"); err == nil {
+			var repo *GitRepo
+			if repo, err = OpenGitRepo(cwd); err == nil {
+				v = repo.Root
+			}
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if p.placeholders == nil {
+		p.placeholders = map[string]string{}
+	}
+	p.placeholders[name] = v
+	return v, nil
+}
+
+// unresolved returns path unchanged if the resolver is lenient and err
+// otherwise.
+func (p *pathResolver) unresolved(path string, err error) (string, error) {
+	if p.lenient {
+		return path, nil
+	}
+	return "", err
+}
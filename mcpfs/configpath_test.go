@@ -0,0 +1,119 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestParseConfigDataWith_RulePaths(t *testing.T) {
+	repo := newGitRepo(t)
+	env := std.NewTestEnv("/home/me", "me")
+	env.Set("XDG_STATE_HOME", "/home/me/.local/state")
+	env.Set("XDG_CACHE_HOME", "/home/me/.cache")
+	opts := mcpfs.ParseOptions{Env: env, Dir: "/etc/mcpfs", Cwd: filepath.Join(repo, "dir")}
+	configPath, err := mcpfs.DefaultConfigPath(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"~":                   "/home/me",
+		"~/notes":             "/home/me/notes",
+		"~me/notes":           "/home/me/notes",
+		"notes":               "/etc/mcpfs/notes",
+		"./shared/../notes":   "/etc/mcpfs/notes",
+		"{state}/journal":     "/home/me/.local/state/mcpfs/journal",
+		"{cache}":             "/home/me/.cache/mcpfs",
+		"{config}/extra.yaml": filepath.Join(filepath.Dir(configPath), "extra.yaml"),
+		"{cwd}/out":           filepath.Join(repo, "dir", "out"),
+		"{repo_root}":         repo,
+		"{repo_root}/{cwd}":   repo + filepath.Join(repo, "dir"),
+		"/srv/{a,b}":          "/srv/{a,b}",
+		"/srv/~old":           "/srv/~old",
+		"${STATE:-{state}}/x": "/home/me/.local/state/mcpfs/x",
+	}
+	if u, err := user.Current(); err == nil && u.HomeDir != "" {
+		cases["~"+u.Username+"/x"] = filepath.Join(u.HomeDir, "x")
+	}
+	for path, want := range cases {
+		cfg, err := mcpfs.ParseConfigDataWith([]byte("paths:\n  - path: '"+path+"'\n"), opts)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		r := &cfg.Paths[0]
+		if r.CleanPath() != want {
+			t.Errorf("%s resolved to %q, want %q", path, r.CleanPath(), want)
+		}
+		if !strings.Contains(path, "$") && r.Path != path {
+			t.Errorf("%s: Path = %q, want it as written", path, r.Path)
+		}
+	}
+
+	outside := opts
+	outside.Cwd = t.TempDir()
+	for path, want := range map[string]string{
+		"~no-such-user-mcpfs/x": "home directory of no-such-user-mcpfs",
+		"{repo_root}/x":         "{repo_root}",
+	} {
+		_, err := mcpfs.ParseConfigDataWith([]byte("paths:\n  - path: '"+path+"'\n"), outside)
+		if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", path, err, want)
+		}
+	}
+
+	// Commands editing the config keep such paths as written.
+	outside.AllowUnresolvedPaths = true
+	cfg, err := mcpfs.ParseConfigDataWith([]byte("paths:\n  - path: /{repo_root}/x\n"), outside)
+	if err != nil {
+		t.Fatalf("AllowUnresolvedPaths: %v", err)
+	}
+	if got := cfg.Paths[0].CleanPath(); got != "/{repo_root}/x" {
+		t.Errorf("AllowUnresolvedPaths resolved to %q", got)
+	}
+}
+
+func TestReadAndParseConfig_RelativePaths(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `include: [team/team.yaml]
+paths:
+  - path: data
+`,
+		"team/team.yaml": `include: [../shared]
+paths:
+  - path: ./scripts
+profiles:
+  dev:
+    paths:
+      - path: ../ws
+`,
+		"shared/10.yaml": "paths:\n  - path: docs\n",
+	})
+	cfg, err := mcpfs.ReadAndParseConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ReadAndParseConfig: %v", err)
+	}
+	rules := func() string {
+		var got []string
+		for i := range cfg.Paths {
+			rel, _ := filepath.Rel(dir, cfg.Paths[i].CleanPath())
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return strings.Join(got, ",")
+	}
+	if got, want := rules(), "data,team/scripts,shared/docs"; got != want {
+		t.Fatalf("rules = %q, want %q", got, want)
+	}
+	if err := cfg.SelectProfile("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rules(), "ws"; got != want {
+		t.Fatalf("dev rules = %q, want %q", got, want)
+	}
+}
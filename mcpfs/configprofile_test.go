@@ -0,0 +1,225 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+const profilesConfig = `version: "2.0"
+paths:
+  - path: /docs
+    perms: [read, write]
+log_level: info
+profiles:
+  review:
+    paths:
+      - path: /docs
+    log_level: warn
+  audit:
+    log_level: debug
+  dev:
+    paths:
+      - path: ${WORKSPACE}
+        perms: [read, write]
+  ops:
+    paths:
+      - path: /opt/scripts
+        perms: [read, exec]
+    log_path: /var/log/mcpfs-ops.json
+`
+
+func TestConfig_SelectProfile(t *testing.T) {
+	t.Setenv("WORKSPACE", "/home/me/ws")
+	cases := []struct {
+		profile  string
+		allowed  map[mcpfs.Permission]string
+		denied   map[mcpfs.Permission]string
+		logLevel string
+		logPath  string
+	}{
+		{
+			profile:  "",
+			allowed:  map[mcpfs.Permission]string{mcpfs.PermWrite: "/docs/a"},
+			denied:   map[mcpfs.Permission]string{mcpfs.PermWrite: "/home/me/ws/a", mcpfs.PermExec: "/opt/scripts/x"},
+			logLevel: "info",
+		},
+		{
+			profile:  "review",
+			allowed:  map[mcpfs.Permission]string{mcpfs.PermRead: "/docs/a"},
+			denied:   map[mcpfs.Permission]string{mcpfs.PermWrite: "/docs/a", mcpfs.PermRead: "/home/me/ws/a"},
+			logLevel: "warn",
+		},
+		{
+			profile:  "dev",
+			allowed:  map[mcpfs.Permission]string{mcpfs.PermWrite: "/home/me/ws/a"},
+			denied:   map[mcpfs.Permission]string{mcpfs.PermExec: "/opt/scripts/x", mcpfs.PermRead: "/docs/a"},
+			logLevel: "info",
+		},
+		{
+			profile:  "ops",
+			allowed:  map[mcpfs.Permission]string{mcpfs.PermExec: "/opt/scripts/x"},
+			denied:   map[mcpfs.Permission]string{mcpfs.PermWrite: "/home/me/ws/a"},
+			logLevel: "info",
+			logPath:  "/var/log/mcpfs-ops.json",
+		},
+		{
+			// Without path rules of its own the profile keeps the top-level
+			// ones.
+			profile:  "audit",
+			allowed:  map[mcpfs.Permission]string{mcpfs.PermWrite: "/docs/a"},
+			logLevel: "debug",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.profile, func(t *testing.T) {
+			cfg, err := mcpfs.ParseConfigData([]byte(profilesConfig))
+			if err != nil {
+				t.Fatalf("ParseConfigData: %v", err)
+			}
+			if err := cfg.SelectProfile(tc.profile); err != nil {
+				t.Fatalf("SelectProfile: %v", err)
+			}
+			if cfg.Profile() != tc.profile {
+				t.Fatalf("Profile() = %q", cfg.Profile())
+			}
+			for op, path := range tc.allowed {
+				if !cfg.IsAllowed(op, path) {
+					t.Errorf("%s %s denied", op, path)
+				}
+			}
+			for op, path := range tc.denied {
+				if cfg.IsAllowed(op, path) {
+					t.Errorf("%s %s allowed", op, path)
+				}
+			}
+			if cfg.LogLevel != tc.logLevel || cfg.LogPath != tc.logPath {
+				t.Errorf("log_level = %q, log_path = %q", cfg.LogLevel, cfg.LogPath)
+			}
+		})
+	}
+}
+
+func TestConfig_SelectProfileErrors(t *testing.T) {
+	t.Setenv("WORKSPACE", "/home/me/ws")
+	cfg, err := mcpfs.ParseConfigData([]byte(profilesConfig))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	err = cfg.SelectProfile("prod")
+	if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), "want one of audit, dev, ops, review") {
+		t.Fatalf("unknown profile: %v", err)
+	}
+	if err := cfg.SelectProfile("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.SelectProfile("ops"); err == nil {
+		t.Fatal("second SelectProfile succeeded")
+	}
+
+	empty, err := mcpfs.ParseConfigData([]byte("paths: []\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.SelectProfile("dev"); err == nil || !strings.Contains(err.Error(), "defines no profiles") {
+		t.Fatalf("profile of config without profiles: %v", err)
+	}
+
+	_, err = mcpfs.ParseConfigData([]byte("profiles:\n  dev:\n    paths:\n      - path: /x\n        perms: [fly]\n"))
+	if err == nil || !strings.Contains(err.Error(), `profile "dev"`) {
+		t.Fatalf("invalid profile rule: %v", err)
+	}
+}
+
+func TestReadAndParseConfig_IncludedProfiles(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `include: [team.yaml]
+profiles:
+  dev:
+    paths:
+      - path: /home/me/scratch
+        perms: [read, write]
+`,
+		"team.yaml": `profiles:
+  dev:
+    paths:
+      - path: /srv/ws
+        perms: [read, write]
+  ops:
+    paths:
+      - path: /opt
+        perms: [exec]
+`,
+	})
+	cfg, err := mcpfs.ReadAndParseConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ReadAndParseConfig: %v", err)
+	}
+	if err := cfg.SelectProfile("dev"); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for i := range cfg.Paths {
+		got = append(got, cfg.Paths[i].CleanPath()+" "+filepath.Base(cfg.Paths[i].Source()))
+	}
+	want := "/home/me/scratch config.yaml,/srv/ws team.yaml"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("dev rules = %q, want %q", got, want)
+	}
+	if _, ok := cfg.Profiles["ops"]; !ok {
+		t.Fatal("included ops profile missing")
+	}
+}
+
+func TestConfig_SelectProfileResolvesVariables(t *testing.T) {
+	env := std.NewTestEnv(t.TempDir(), "me")
+	data := []byte(`version: "2.0"
+paths:
+  - path: /docs
+profiles:
+  review:
+    paths:
+      - path: /docs
+  dev:
+    paths:
+      - path: ${WORKSPACE}
+        perms: [read, write]
+`)
+	// The variables of profiles that are not selected need not be set.
+	for _, profile := range []string{"", "review"} {
+		cfg, err := mcpfs.ParseConfigDataWith(data, mcpfs.ParseOptions{Env: env})
+		if err != nil {
+			t.Fatalf("ParseConfigDataWith: %v", err)
+		}
+		if err := cfg.SelectProfile(profile); err != nil {
+			t.Fatalf("SelectProfile(%q): %v", profile, err)
+		}
+	}
+
+	cfg, err := mcpfs.ParseConfigDataWith(data, mcpfs.ParseOptions{Env: env})
+	if err != nil {
+		t.Fatalf("ParseConfigDataWith: %v", err)
+	}
+	if err := cfg.SelectProfile("dev"); !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), "profiles.dev.paths[0].path") {
+		t.Fatalf("SelectProfile(dev) with WORKSPACE unset: %v", err)
+	}
+
+	env.Set("WORKSPACE", "/home/me/ws")
+	cfg, err = mcpfs.ParseConfigDataWith(data, mcpfs.ParseOptions{Env: env})
+	if err != nil {
+		t.Fatalf("ParseConfigDataWith: %v", err)
+	}
+	if err := cfg.SelectProfile("dev"); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.IsAllowed(mcpfs.PermWrite, "/home/me/ws/a") {
+		t.Error("write below ${WORKSPACE} denied")
+	}
+	if p := cfg.Profiles["dev"].Paths[0].Path; p != "${WORKSPACE}" {
+		t.Errorf("profile rule changed to %q", p)
+	}
+}
@@ -0,0 +1,222 @@
+package mcpfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigSchema returns the JSON Schema of the config file in the current
+// version. Editors use it for completion and validation of config.yaml, and
+// caseInsensitivePattern returns a regular expression matching s in any
+// case. Schema patterns are ECMA-262 regular expressions, which have no
+// inline (?i) flag.
+func caseInsensitivePattern(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if up, low := unicode.ToUpper(r), unicode.ToLower(r); up != low {
+			fmt.Fprintf(&sb, "[%c%c]", low, up)
+		} else {
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return sb.String()
+}
+
+// ValidateConfigData checks configs against it.
+func ConfigSchema() (*jsonschema.Schema, error) {
+	s, err := jsonschema.For[Config](&jsonschema.ForOptions{
+		TypeSchemas: map[any]*jsonschema.Schema{
+			ByteSize(0): {
+				Types:       []string{"integer", "string"},
+				Minimum:     jsonschema.Ptr(0.0),
+				Pattern:     `^\s*[0-9.]+\s*([kKmMgGtT]([iI]?[bB])?|[bB])?\s*$`,
+				Description: "size in bytes, or with a unit such as 10MB or 10MiB",
+			},
+			time.Duration(0): {
+				Types:       []string{"integer", "string"},
+				Minimum:     jsonschema.Ptr(0.0),
+				Pattern:     `^([0-9.]+(ns|us|µs|ms|s|m|h))+$`,
+				Description: "duration such as 30s or 1h30m",
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	s.Title = "mcpfs config"
+	// An empty config is valid; it grants nothing.
+	s.Required = nil
+
+	// Every version migrateConfig accepts, as canonicalConfigVersion spells
+	// them: "2.0", "2" or the number 2.
+	version := s.Properties["version"]
+	version.Type, version.Types = "", []string{"string", "number"}
+	for _, v := range configVersions {
+		n, _ := strconv.ParseFloat(v, 64)
+		version.Enum = append(version.Enum, v, n)
+		if major, minor, _ := parseConfigVersion(v); minor == 0 {
+			version.Enum = append(version.Enum, strconv.Itoa(major))
+		}
+	}
+
+	rule := s.Properties["paths"].Items
+	// parsePerms ignores case and surrounding space, which an enum cannot
+	// express; the examples still offer the names for completion.
+	perms := rule.Properties["perms"].Items
+	var alts []string
+	for _, name := range slices.Sorted(maps.Keys(permNames)) {
+		perms.Examples = append(perms.Examples, name)
+		alts = append(alts, caseInsensitivePattern(name))
+	}
+	perms.Pattern = `^\s*(` + strings.Join(alts, "|") + `)\s*$`
+	rule.Properties["approval"].Enum = []any{ApprovalNone, ApprovalRequired}
+	rule.Properties["path"].MinLength = jsonschema.Ptr(1)
+
+	s.Properties["sandbox"].Properties["landlock"].Enum = []any{LandlockAuto, LandlockRequired, LandlockOff}
+
+	var detectors []any
+	for _, name := range SecretDetectors() {
+		detectors = append(detectors, name)
+	}
+	s.Properties["redaction"].Properties["disable"].Items.Enum = detectors
+	return s, nil
+}
+
+// ValidateConfigData checks data against ConfigSchema and then parses it
+// with ParseConfigData, so that a config that validates also loads. Configs
+// of older versions are checked as migrated to the current one.
+func ValidateConfigData(data []byte) (*Config, error) {
+	return ValidateConfigDataWith(data, ParseOptions{})
+}
+
+// ValidateConfigDataWith is ValidateConfigData parsing with opts.
+func ValidateConfigDataWith(data []byte, opts ParseOptions) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: yaml unmarshal: %v", ErrParse, err)
+	}
+	if len(doc.Content) > 0 {
+		root := cloneNode(doc.Content[0])
+		if _, err := migrateConfig(root); err != nil {
+			return nil, err
+		}
+		instance, err := configInstance(root)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := ConfigSchema()
+		if err != nil {
+			return nil, err
+		}
+		resolved, err := schema.Resolve(nil)
+		if err != nil {
+			return nil, err
+		}
+		// The validator does not name unknown keys, which are likely typos.
+		if err := unknownConfigKey(schema, instance, ""); err != nil {
+			return nil, err
+		}
+		if err := resolved.Validate(instance); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrParse, schemaErrorMessage(err))
+		}
+	}
+	return ParseConfigDataWith(data, opts)
+}
+
+// configInstance converts the config mapping root into the JSON value the
+// schema validates.
+func configInstance(root *yaml.Node) (map[string]any, error) {
+	var v any
+	if err := root.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: yaml unmarshal: %v", ErrParse, err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrParse, err)
+	}
+	var instance map[string]any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrParse, err)
+	}
+	// migrateConfig accepted the version; it may be written as 2 or "2".
+	if _, ok := instance["version"]; ok {
+		instance["version"] = CurrentConfigVersion
+	}
+	return instance, nil
+}
+
+// unknownConfigKey reports the first key of v that schema s does not
+// define, in the order the keys sort.
+func unknownConfigKey(s *jsonschema.Schema, v any, prefix string) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			sub := s.Properties[k]
+			if sub == nil && s.Properties != nil {
+				return fmt.Errorf("%w: unknown setting %q", ErrParse, key)
+			}
+			if sub == nil {
+				sub = s.AdditionalProperties
+			}
+			if sub != nil {
+				if err := unknownConfigKey(sub, v[k], key); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for _, item := range v {
+				if err := unknownConfigKey(s.Items, item, prefix); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// schemaErrorMessage shortens the nested "validating <schema pointer>: "
+// prefixes of a validation error to the dotted key of the innermost setting,
+// e.g. "paths.perms: enum: ...".
+func schemaErrorMessage(err error) string {
+	msg, key := err.Error(), ""
+	for {
+		rest, ok := strings.CutPrefix(msg, "validating ")
+		if !ok {
+			break
+		}
+		ptr, tail, ok := strings.Cut(rest, ": ")
+		if !ok {
+			break
+		}
+		key, msg = ptr, tail
+	}
+	var parts []string
+	for _, p := range strings.Split(key, "/") {
+		switch p {
+		case "", "root", "properties", "items", "additionalProperties":
+		default:
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return msg
+	}
+	return strings.Join(parts, ".") + ": " + msg
+}
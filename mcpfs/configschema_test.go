@@ -0,0 +1,96 @@
+package mcpfs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestConfigSchema(t *testing.T) {
+	schema, err := mcpfs.ConfigSchema()
+	if err != nil {
+		t.Fatalf("ConfigSchema: %v", err)
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("marshal schema: %v", err)
+	}
+	if !json.Valid(data) {
+		t.Fatal("schema is not valid JSON")
+	}
+
+	// Every permission name the schema offers is one the loader accepts,
+	// in any case and with surrounding space as parsePerms allows.
+	perms := schema.Properties["paths"].Items.Properties["perms"].Items.Examples
+	if len(perms) == 0 {
+		t.Fatal("perms has no examples")
+	}
+	for _, p := range perms {
+		for _, perm := range []string{p.(string), strings.ToUpper(p.(string)), `" ` + p.(string) + ` "`} {
+			config := "paths:\n  - path: /srv\n    perms: [" + perm + "]\n"
+			if _, err := mcpfs.ValidateConfigData([]byte(config)); err != nil {
+				t.Errorf("perm %q: %v", perm, err)
+			}
+		}
+	}
+
+	// Every version the schema offers is one the loader understands.
+	for _, v := range schema.Properties["version"].Enum {
+		version, _ := json.Marshal(v)
+		config := "version: " + string(version) + "\npaths:\n  - path: /srv\n"
+		if _, err := mcpfs.ValidateConfigData([]byte(config)); err != nil {
+			t.Errorf("version %q: %v", v, err)
+		}
+	}
+}
+
+func TestValidateConfigData(t *testing.T) {
+	valid := []string{
+		"",
+		"paths: []\n",
+		configV1,
+		`version: 2
+paths:
+  - path: /srv
+    perms: [read, write]
+    allow_subpaths: false
+    max_file_size: 10MiB
+    max_files: 100
+    approval: required
+approval_timeout: 90s
+journal:
+  max_age: 24h
+  max_size: 1073741824
+rate_limits:
+  max_concurrent: 4
+  tools:
+    write_file: {requests: 10, per: 1m}
+sandbox:
+  landlock: auto
+`,
+	}
+	for _, data := range valid {
+		if _, err := mcpfs.ValidateConfigData([]byte(data)); err != nil {
+			t.Errorf("ValidateConfigData(%q): %v", data, err)
+		}
+	}
+
+	for data, want := range map[string]string{
+		"paths:\n  - path: /srv\n    perms: [fly]\n":        "paths.perms: pattern",
+		"paths:\n  - path: /srv\n    prems: [read]\n":       `unknown setting "paths.prems"`,
+		"paths:\n  - perms: [read]\n":                       "paths: required",
+		"journal:\n  max_age: 3 days\n":                     "journal.max_age: pattern",
+		"sandbox:\n  landlock: maybe\n":                     "sandbox.landlock: enum",
+		"paths:\n  - path: /srv\n    approval: sometimes\n": "paths.approval: enum",
+		"version: \"9.0\"\n":                                "newer than this mcpfs supports",
+		"git:\n  trailer: not a trailer\n":                  "git",
+	} {
+		_, err := mcpfs.ValidateConfigData([]byte(data))
+		if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateConfigData(%q) = %v, want %q", data, err, want)
+		}
+	}
+}
@@ -0,0 +1,692 @@
+package mcpfs
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultDataMaxSize is the largest file query_data and update_data load.
+const DefaultDataMaxSize = 8 << 20
+
+// tomlDateTimeTag marks TOML dates and times, which are written back
+// unquoted.
+const tomlDateTimeTag = "!!timestamp"
+
+// DataDocument is a JSON, YAML, TOML or CSV file loaded as a YAML node tree
+// so that all formats are queried and edited the same way. CSV files load as
+// a sequence of mappings keyed by the header row.
+type DataDocument struct {
+	Format string
+
+	doc      *yaml.Node // document node
+	indent   string     // indentation unit of the original
+	newline  bool       // whether the original ended with a newline
+	csvComma rune
+	header   []string
+}
+
+// DataFormat returns the structured format of path by its extension, or ""
+// if it is not supported.
+func DataFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	case ".csv", ".tsv":
+		return "csv"
+	}
+	return ""
+}
+
+// ParseData loads data, the content of path, in the format given by its
+// extension.
+func ParseData(path string, data []byte) (*DataDocument, error) {
+	d := &DataDocument{
+		Format:  DataFormat(path),
+		indent:  detectIndent(data),
+		newline: len(data) == 0 || data[len(data)-1] == '\n',
+	}
+	var err error
+	switch d.Format {
+	case "json", "yaml":
+		err = d.parseYAML(data)
+	case "toml":
+		err = d.parseTOML(data)
+	case "csv":
+		d.csvComma = ','
+		if strings.EqualFold(filepath.Ext(path), ".tsv") {
+			d.csvComma = '\t'
+		}
+		err = d.parseCSV(data)
+	default:
+		return nil, fmt.Errorf("%q is not a JSON, YAML, TOML or CSV file", filepath.Base(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrParse, path, err)
+	}
+	return d, nil
+}
+
+var indentRe = regexp.MustCompile(`(?m)^([ \t]+)\S`)
+
+// detectIndent returns the leading whitespace of the first indented line.
+func detectIndent(data []byte) string {
+	if m := indentRe.FindSubmatch(data); m != nil && len(m[1]) <= 8 {
+		return string(m[1])
+	}
+	return ""
+}
+
+func (d *DataDocument) parseYAML(data []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err == io.EOF {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	} else if err != nil {
+		return err
+	}
+	var extra yaml.Node
+	if err := dec.Decode(&extra); err != io.EOF {
+		return fmt.Errorf("only single-document files are supported")
+	}
+	d.doc = &doc
+	return nil
+}
+
+func (d *DataDocument) parseCSV(data []byte) error {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = d.csvComma
+	r.FieldsPerRecord = -1
+	rows := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	d.doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{rows}}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.header == nil {
+			d.header = rec
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		row := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+		for i, v := range rec {
+			key := "column" + strconv.Itoa(i+1)
+			if i < len(d.header) {
+				key = d.header[i]
+			}
+			row.Content = append(row.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key, Line: line},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: v, Line: line})
+		}
+		rows.Content = append(rows.Content, row)
+	}
+}
+
+// parseTOML builds the node tree from the TOML syntax tree so that keys keep
+// their order. The document is decoded once more to catch the semantic
+// errors the syntax parser does not check, such as duplicate keys.
+func (d *DataDocument) parseTOML(data []byte) error {
+	var check map[string]any
+	if err := toml.Unmarshal(data, &check); err != nil {
+		return err
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: 1}
+	d.doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+
+	p := &unstable.Parser{}
+	p.Reset(data)
+	current := root
+	for p.NextExpression() {
+		expr := p.Expression()
+		line := p.Shape(expr.Raw).Start.Line
+		switch expr.Kind {
+		case unstable.KeyValue:
+			if err := setTOMLKeyValue(p, current, expr, line); err != nil {
+				return err
+			}
+		case unstable.Table:
+			current = tomlTable(root, tomlKey(expr.Key()), line)
+		case unstable.ArrayTable:
+			key := tomlKey(expr.Key())
+			parent := tomlTable(root, key[:len(key)-1], line)
+			arr := mappingValue(parent, key[len(key)-1])
+			if arr == nil {
+				arr = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: line}
+				appendMapping(parent, key[len(key)-1], arr, line)
+			}
+			current = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+			arr.Content = append(arr.Content, current)
+		}
+	}
+	return p.Error()
+}
+
+func tomlKey(it unstable.Iterator) []string {
+	var key []string
+	for it.Next() {
+		key = append(key, string(it.Node().Data))
+	}
+	return key
+}
+
+// tomlTable returns the table at key below root, creating missing tables.
+// Arrays of tables resolve to their last element.
+func tomlTable(root *yaml.Node, key []string, line int) *yaml.Node {
+	n := root
+	for _, k := range key {
+		next := mappingValue(n, k)
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: line}
+			appendMapping(n, k, next, line)
+		}
+		if next.Kind == yaml.SequenceNode && len(next.Content) > 0 {
+			next = next.Content[len(next.Content)-1]
+		}
+		n = next
+	}
+	return n
+}
+
+func setTOMLKeyValue(p *unstable.Parser, table *yaml.Node, expr *unstable.Node, line int) error {
+	key := tomlKey(expr.Key())
+	parent := tomlTable(table, key[:len(key)-1], line)
+	v, err := tomlValue(p, expr.Value(), line)
+	if err != nil {
+		return err
+	}
+	appendMapping(parent, key[len(key)-1], v, line)
+	return nil
+}
+
+func tomlValue(p *unstable.Parser, n *unstable.Node, line int) (*yaml.Node, error) {
+	scalar := func(tag, value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value, Line: line}
+	}
+	switch n.Kind {
+	case unstable.String:
+		return scalar("!!str", string(n.Data)), nil
+	case unstable.Bool:
+		return scalar("!!bool", string(n.Data)), nil
+	case unstable.Integer:
+		i, err := strconv.ParseInt(strings.ReplaceAll(string(n.Data), "_", ""), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		return scalar("!!int", strconv.FormatInt(i, 10)), nil
+	case unstable.Float:
+		s := strings.ReplaceAll(string(n.Data), "_", "")
+		switch strings.TrimLeft(s, "+-") {
+		case "inf":
+			s = strings.Replace(s, "inf", ".inf", 1)
+		case "nan":
+			s = ".nan"
+		}
+		return scalar("!!float", strings.TrimPrefix(s, "+")), nil
+	case unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+		return scalar(tomlDateTimeTag, string(n.Data)), nil
+	case unstable.Array:
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle, Line: line}
+		for it := n.Children(); it.Next(); {
+			v, err := tomlValue(p, it.Node(), line)
+			if err != nil {
+				return nil, err
+			}
+			seq.Content = append(seq.Content, v)
+		}
+		return seq, nil
+	case unstable.InlineTable:
+		m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle, Line: line}
+		for it := n.Children(); it.Next(); {
+			if err := setTOMLKeyValue(p, m, it.Node(), line); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("line %d: unsupported TOML value %s", line, n.Kind)
+}
+
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func appendMapping(m *yaml.Node, key string, v *yaml.Node, line int) {
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key, Line: line}, v)
+}
+
+// root returns the top-level value of the document.
+func (d *DataDocument) root() *yaml.Node {
+	if len(d.doc.Content) == 0 {
+		d.doc.Content = []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}}
+	}
+	return d.doc.Content[0]
+}
+
+// DataMatch is a value selected by a query.
+type DataMatch struct {
+	Path  string `json:"path" jsonschema:"normalized path of the value"`
+	Line  int    `json:"line,omitempty" jsonschema:"line of the value in the file, if known"`
+	Value any    `json:"value"`
+}
+
+// Query returns the values selected by path, in document order.
+func (d *DataDocument) Query(path *DataPath) ([]DataMatch, error) {
+	return d.query(path, nil)
+}
+
+// query is Query calling visit, if not nil, on each selected node before it
+// is converted.
+func (d *DataDocument) query(path *DataPath, visit func(*yaml.Node)) ([]DataMatch, error) {
+	out := []DataMatch{}
+	for _, m := range evalPath(d.root(), path.segments, false) {
+		if visit != nil {
+			visit(m.node)
+		}
+		v, err := nodeJSON(m.node, "", "")
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, DataMatch{Path: m.path, Line: m.node.Line, Value: json.RawMessage(v)})
+	}
+	return out, nil
+}
+
+// Set replaces the values selected by path with value, adding missing
+// mapping keys along the way and appending to a sequence when the last step
+// indexes one past its end. It returns the number of values set.
+func (d *DataDocument) Set(path *DataPath, value any) (int, error) {
+	if len(path.segments) == 0 {
+		return 0, fmt.Errorf("cannot replace the document root")
+	}
+	var v yaml.Node
+	if err := v.Encode(value); err != nil {
+		return 0, err
+	}
+	ms := evalPath(d.root(), path.segments, true)
+	for _, m := range ms {
+		if m.parent == nil {
+			continue
+		}
+		nv := v
+		if m.node.Kind == v.Kind {
+			// keep the comments attached to the old value
+			nv.HeadComment, nv.LineComment, nv.FootComment = m.node.HeadComment, m.node.LineComment, m.node.FootComment
+		}
+		m.parent.Content[m.index] = cloneNode(&nv)
+	}
+	return len(ms), nil
+}
+
+func cloneNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = cloneNode(child)
+	}
+	return &c
+}
+
+// Delete removes the values selected by path and returns how many were
+// removed.
+func (d *DataDocument) Delete(path *DataPath) (int, error) {
+	return removeMatches(evalPath(d.root(), path.segments, false))
+}
+
+// Bytes serializes the document in its original format. YAML keeps comments
+// and key order; JSON keeps key order and indentation; TOML and CSV are
+// written in a normalized layout.
+func (d *DataDocument) Bytes() ([]byte, error) {
+	var (
+		out []byte
+		err error
+	)
+	switch d.Format {
+	case "yaml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(max(len(strings.ReplaceAll(d.indent, "\t", "  ")), 2))
+		if err := enc.Encode(d.doc); err != nil {
+			return nil, err
+		}
+		enc.Close()
+		out = buf.Bytes()
+	case "json":
+		out, err = nodeJSON(d.root(), "", d.indent)
+	case "toml":
+		out, err = nodeTOML(d.root())
+	case "csv":
+		out, err = d.csvBytes()
+	}
+	if err != nil {
+		return nil, err
+	}
+	out = bytes.TrimRight(out, "\n")
+	if d.newline && len(out) > 0 {
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+// nodeJSON renders n as JSON, keeping the order of mapping keys. Values are
+// indented by indent per level unless indent is empty.
+func nodeJSON(n *yaml.Node, prefix, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := writeJSON(&buf, n, prefix, indent)
+	return buf.Bytes(), err
+}
+
+func writeJSON(buf *bytes.Buffer, n *yaml.Node, prefix, indent string) error {
+	n = resolveAlias(n)
+	nl, inner, sep := "", prefix+indent, ":"
+	if indent != "" {
+		nl, sep = "\n", ": "
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSON(buf, n.Content[0], prefix, indent)
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{" + nl)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteString("," + nl)
+			}
+			key, _ := json.Marshal(n.Content[i].Value)
+			buf.WriteString(inner)
+			buf.Write(key)
+			buf.WriteString(sep)
+			if err := writeJSON(buf, n.Content[i+1], inner, indent); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(nl + prefix + "}")
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[" + nl)
+		for i, c := range n.Content {
+			if i > 0 {
+				buf.WriteString("," + nl)
+			}
+			buf.WriteString(inner)
+			if err := writeJSON(buf, c, inner, indent); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(nl + prefix + "]")
+	case yaml.ScalarNode:
+		buf.WriteString(scalarJSON(n))
+	default:
+		buf.WriteString("null")
+	}
+	return nil
+}
+
+func scalarJSON(n *yaml.Node) string {
+	switch scalarTag(n) {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		if b, err := strconv.ParseBool(strings.ToLower(n.Value)); err == nil {
+			return strconv.FormatBool(b)
+		}
+	case "!!int", "!!float":
+		if f, ok := scalarNumber(n); ok && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			if scalarTag(n) == "!!int" {
+				i, _ := strconv.ParseInt(strings.ReplaceAll(n.Value, "_", ""), 0, 64)
+				return strconv.FormatInt(i, 10)
+			}
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	}
+	s, _ := json.Marshal(n.Value)
+	return string(s)
+}
+
+// nodeTOML renders a mapping as a TOML document: plain keys first, then
+// tables and arrays of tables.
+func nodeTOML(root *yaml.Node) ([]byte, error) {
+	root = resolveAlias(root)
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("a TOML document must be a table")
+	}
+	var buf bytes.Buffer
+	err := writeTOMLTable(&buf, nil, root, false)
+	return bytes.TrimLeft(buf.Bytes(), "\n"), err
+}
+
+// isTOMLTableArray reports whether n is written as [[array]] tables.
+func isTOMLTableArray(n *yaml.Node) bool {
+	if n.Kind != yaml.SequenceNode || len(n.Content) == 0 {
+		return false
+	}
+	for _, c := range n.Content {
+		if c = resolveAlias(c); c.Kind != yaml.MappingNode || c.Style&yaml.FlowStyle != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func writeTOMLTable(buf *bytes.Buffer, path []string, m *yaml.Node, arrayItem bool) error {
+	var tables, arrays []int
+	hasKeys := false
+	for i := 1; i < len(m.Content); i += 2 {
+		v := resolveAlias(m.Content[i])
+		switch {
+		case v.Kind == yaml.MappingNode && v.Style&yaml.FlowStyle == 0:
+			tables = append(tables, i)
+		case isTOMLTableArray(v):
+			arrays = append(arrays, i)
+		default:
+			hasKeys = true
+		}
+	}
+	header := strings.Join(path, ".")
+	switch {
+	case arrayItem:
+		buf.WriteString("\n[[" + header + "]]\n")
+	case len(path) > 0 && (hasKeys || len(tables)+len(arrays) == 0):
+		buf.WriteString("\n[" + header + "]\n")
+	}
+	for i := 1; i < len(m.Content); i += 2 {
+		v := resolveAlias(m.Content[i])
+		if v.Kind == yaml.MappingNode && v.Style&yaml.FlowStyle == 0 || isTOMLTableArray(v) {
+			continue
+		}
+		s, err := tomlInline(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", strings.Join(append(path, m.Content[i-1].Value), "."), err)
+		}
+		buf.WriteString(tomlKeyString(m.Content[i-1].Value) + " = " + s + "\n")
+	}
+	for _, i := range tables {
+		sub := append(append([]string(nil), path...), tomlKeyString(m.Content[i-1].Value))
+		if err := writeTOMLTable(buf, sub, resolveAlias(m.Content[i]), false); err != nil {
+			return err
+		}
+	}
+	for _, i := range arrays {
+		sub := append(append([]string(nil), path...), tomlKeyString(m.Content[i-1].Value))
+		for _, item := range resolveAlias(m.Content[i]).Content {
+			if err := writeTOMLTable(buf, sub, resolveAlias(item), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKeyString(k string) string {
+	if bareTOMLKey.MatchString(k) {
+		return k
+	}
+	return tomlQuote(k)
+}
+
+// tomlQuote writes s as a TOML basic string.
+func tomlQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func tomlInline(n *yaml.Node) (string, error) {
+	n = resolveAlias(n)
+	switch n.Kind {
+	case yaml.SequenceNode:
+		parts := make([]string, len(n.Content))
+		for i, c := range n.Content {
+			s, err := tomlInline(c)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "[" + strings.Join(parts, ", ") + "]", nil
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			return "{}", nil
+		}
+		parts := make([]string, 0, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			s, err := tomlInline(n.Content[i+1])
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, tomlKeyString(n.Content[i].Value)+" = "+s)
+		}
+		return "{ " + strings.Join(parts, ", ") + " }", nil
+	case yaml.ScalarNode:
+		switch scalarTag(n) {
+		case "!!null":
+			return "", fmt.Errorf("TOML has no null value")
+		case "!!bool":
+			if b, err := strconv.ParseBool(strings.ToLower(n.Value)); err == nil {
+				return strconv.FormatBool(b), nil
+			}
+		case "!!int", "!!float":
+			if f, ok := scalarNumber(n); ok {
+				switch {
+				case math.IsNaN(f):
+					return "nan", nil
+				case math.IsInf(f, 1):
+					return "inf", nil
+				case math.IsInf(f, -1):
+					return "-inf", nil
+				}
+				return scalarJSON(n), nil
+			}
+		case tomlDateTimeTag:
+			return n.Value, nil
+		}
+		return tomlQuote(n.Value), nil
+	}
+	return "", fmt.Errorf("unsupported value")
+}
+
+// csvBytes writes the rows with the original header, adding columns for new
+// keys in the order they first appear. Nested values are written as JSON.
+func (d *DataDocument) csvBytes() ([]byte, error) {
+	rows := resolveAlias(d.root())
+	if rows.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("a CSV document must be a sequence of rows")
+	}
+	header := append([]string(nil), d.header...)
+	col := map[string]int{}
+	for i, h := range header {
+		col[h] = i
+	}
+	for _, row := range rows.Content {
+		if row = resolveAlias(row); row.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("CSV rows must be mappings")
+		}
+		for i := 0; i+1 < len(row.Content); i += 2 {
+			if _, ok := col[row.Content[i].Value]; !ok {
+				col[row.Content[i].Value] = len(header)
+				header = append(header, row.Content[i].Value)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = d.csvComma
+	if len(header) > 0 {
+		w.Write(header)
+	}
+	for _, row := range rows.Content {
+		rec := make([]string, len(header))
+		row = resolveAlias(row)
+		for i := 0; i+1 < len(row.Content); i += 2 {
+			v := resolveAlias(row.Content[i+1])
+			s := v.Value
+			switch {
+			case v.Kind != yaml.ScalarNode:
+				b, err := nodeJSON(v, "", "")
+				if err != nil {
+					return nil, err
+				}
+				s = string(b)
+			case scalarTag(v) == "!!null":
+				s = ""
+			}
+			rec[col[row.Content[i].Value]] = s
+		}
+		w.Write(rec)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,210 @@
+package mcpfs_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// queryValues runs query against data loaded as path and returns the
+// matched values as compact JSON.
+func queryValues(t *testing.T, path, data, query string) []string {
+	t.Helper()
+	doc, err := mcpfs.ParseData(path, []byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := mcpfs.ParseDataPath(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := doc.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, m := range ms {
+		b, err := json.Marshal(m.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, string(b))
+	}
+	return out
+}
+
+const testYAML = `# servers
+servers:
+  - name: a # primary
+    port: 80
+    tags: [web]
+  - name: b
+    port: 8080
+log:
+  level: info
+`
+
+// aliasBomb returns a YAML document of levels anchors that each alias the
+// previous one twice, expanding to 2^levels nodes.
+func aliasBomb(levels int) string {
+	var sb strings.Builder
+	sb.WriteString("l0: &l0 [x, x]\n")
+	for i := 1; i <= levels; i++ {
+		fmt.Fprintf(&sb, "l%d: &l%d [*l%d, *l%d]\n", i, i, i-1, i-1)
+	}
+	return sb.String()
+}
+
+func TestDataDocument_Query(t *testing.T) {
+	tests := []struct {
+		path, data, query, want string
+	}{
+		{"c.yaml", testYAML, "$.servers[0].name", `"a"`},
+		{"c.yaml", testYAML, ".servers[].port", `80 8080`},
+		{"c.yaml", testYAML, "servers[-1]", `{"name":"b","port":8080}`},
+		{"c.yaml", testYAML, "$..port", `80 8080`},
+		{"c.yaml", testYAML, "$.servers[?(@.port > 100)].name", `"b"`},
+		{"c.yaml", testYAML, "$.servers[?(@.tags)].name", `"a"`},
+		{"c.yaml", testYAML, "$.log['level','missing']", `"info"`},
+		{"c.yaml", testYAML, "$.servers[0:1].port", `80`},
+		{"c.yaml", testYAML, ".", `{"servers":[{"name":"a","port":80,"tags":["web"]},{"name":"b","port":8080}],"log":{"level":"info"}}`},
+		{"c.json", "{\n\t\"z\": 1,\n\t\"a\": {\"x\": [true, null, 1.5]}\n}", "$.*", `1 {"x":[true,null,1.5]}`},
+		{"c.toml", "title = \"t\"\n[db]\nports = [1, 2]\nwhen = 1979-05-27\n[[users]]\nname = \"x\"\n[[users]]\nname = \"y\"\n", "$.users[*].name", `"x" "y"`},
+		{"c.toml", "[db]\nports = [1, 2]\nwhen = 1979-05-27\nopts = { a = 1 }\n", "db", `{"ports":[1,2],"when":"1979-05-27","opts":{"a":1}}`},
+		{"c.csv", "id,name\n1,ann\n2,bob\n", "$[?(@.id >= 2)].name", `"bob"`},
+		// Recursive descent selects the children of an alias but does not
+		// descend further through it.
+		{"c.yaml", "a: &a {x: 1}\nb: *a\n", "$..x", `1 1`},
+		{"c.yaml", "a: &a {x: {y: 1}}\nb: *a\n", "$..y", `1`},
+		{"c.yaml", aliasBomb(22), "$..zzz", ``},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(queryValues(t, tt.path, tt.data, tt.query), " "); got != tt.want {
+			t.Errorf("%s %s = %s, want %s", tt.path, tt.query, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"$.", "$[", "$[?(x)]", "$[1:2:0]", "$.a b"} {
+		if _, err := mcpfs.ParseDataPath(bad); err == nil {
+			t.Errorf("ParseDataPath(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDataDocument_Update(t *testing.T) {
+	update := func(path, data string, edits func(*mcpfs.DataDocument)) string {
+		t.Helper()
+		doc, err := mcpfs.ParseData(path, []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		edits(doc)
+		out, err := doc.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+	set := func(doc *mcpfs.DataDocument, query string, v any) {
+		t.Helper()
+		q, _ := mcpfs.ParseDataPath(query)
+		if n, err := doc.Set(q, v); err != nil || n == 0 {
+			t.Fatalf("Set(%s) = %d, %v", query, n, err)
+		}
+	}
+	del := func(doc *mcpfs.DataDocument, query string) {
+		t.Helper()
+		q, _ := mcpfs.ParseDataPath(query)
+		if n, err := doc.Delete(q); err != nil || n == 0 {
+			t.Fatalf("Delete(%s) = %d, %v", query, n, err)
+		}
+	}
+
+	got := update("c.yaml", testYAML, func(d *mcpfs.DataDocument) {
+		set(d, "$.servers[0].port", 81)
+		set(d, "$.log.file.path", "/var/log/x")
+		del(d, "$.servers[1]")
+	})
+	want := `# servers
+servers:
+  - name: a # primary
+    port: 81
+    tags: [web]
+log:
+  level: info
+  file:
+    path: /var/log/x
+`
+	if got != want {
+		t.Errorf("yaml:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = update("c.json", "{\n    \"z\": 1,\n    \"a\": [1, 2]\n}\n", func(d *mcpfs.DataDocument) {
+		set(d, "$.a[2]", map[string]any{"k": "v"})
+		del(d, "$.z")
+		set(d, "$.b", nil)
+	})
+	want = "{\n    \"a\": [\n        1,\n        2,\n        {\n            \"k\": \"v\"\n        }\n    ],\n    \"b\": null\n}\n"
+	if got != want {
+		t.Errorf("json:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = update("c.toml", "title = \"t\" # comment\n[db]\nport = 1\nwhen = 1979-05-27T07:32:00Z\n[[users]]\nname = \"x\"\n", func(d *mcpfs.DataDocument) {
+		set(d, "$.db.port", 2)
+		set(d, "$.users[0]['full name']", "X \"Y\"")
+	})
+	want = "title = \"t\"\n\n[db]\nport = 2\nwhen = 1979-05-27T07:32:00Z\n\n[[users]]\nname = \"x\"\n\"full name\" = \"X \\\"Y\\\"\"\n"
+	if got != want {
+		t.Errorf("toml:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = update("c.csv", "id,name\n1,ann\n2,bob\n", func(d *mcpfs.DataDocument) {
+		set(d, "$[?(@.name == 'bob')].email", "bob@example.com")
+		del(d, "$[0]")
+	})
+	if want = "id,name,email\n2,bob,bob@example.com\n"; got != want {
+		t.Errorf("csv:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDataTools(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "c.yaml")
+	if err := os.WriteFile(path, []byte(testYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, "paths:\n  - path: "+dir+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+
+	var q mcpfs.QueryDataOutput
+	callTool(t, cs, "query_data", map[string]any{"path": path, "query": "$.servers[*].name"}, &q)
+	if q.Format != "yaml" || len(q.Matches) != 2 || q.Matches[1].Path != "$.servers[1].name" || q.Matches[1].Line != 6 {
+		t.Fatalf("query_data = %+v", q)
+	}
+
+	var u mcpfs.UpdateDataOutput
+	callTool(t, cs, "update_data", map[string]any{"path": path, "query": "$.log.level", "value": "debug"}, &u)
+	if u.Changed != 1 || u.Staged {
+		t.Fatalf("update_data = %+v", u)
+	}
+	if data := readFile(t, path); !strings.Contains(data, "level: debug") || !strings.Contains(data, "# primary") {
+		t.Fatalf("updated file:\n%s", data)
+	}
+
+	// update_data reads the file, so write alone is not enough.
+	cs = connect(t, newTestApp(t, "paths:\n  - path: "+dir+"\n    perms: [write]\n"))
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "update_data",
+		Arguments: map[string]any{"path": path, "query": "$.log.level", "value": "info"},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("update_data without read: res=%v err=%v, want tool error", res, err)
+	}
+}
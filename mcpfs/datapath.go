@@ -0,0 +1,636 @@
+package mcpfs
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A DataPath selects nodes of a structured document. The syntax is a subset
+// of JSONPath that also accepts jq-style paths:
+//
+//	$ or .            the document root ($ may be omitted)
+//	.key ['key']      a mapping key
+//	[0] [-1]          a sequence item, counting from the end when negative
+//	[1:3] [::2]       a slice of a sequence
+//	[0,2] ['a','b']   several items or keys
+//	.* [*] []         every child
+//	..key ..*         recursive descent
+//	[?(@.k == 'v')]   children matching a filter; the operators are
+//	                  == != < <= > >=, or none to test that @.k exists
+type DataPath struct {
+	expr     string
+	segments []pathSegment
+}
+
+type pathSegment struct {
+	recursive bool
+	wildcard  bool
+	keys      []string // by mapping key
+	indexes   []int    // by sequence index
+	slice     *pathSlice
+	filter    *pathFilter
+}
+
+type pathSlice struct {
+	start, end *int
+	step       int
+}
+
+type pathFilter struct {
+	path  []pathSegment // relative to @; keys and indexes only
+	op    string        // "" tests existence
+	value *yaml.Node
+}
+
+// ParseDataPath parses a path expression.
+func ParseDataPath(expr string) (*DataPath, error) {
+	p := &pathParser{src: strings.TrimSpace(expr)}
+	segs, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("%w: path %q: %v", ErrParse, expr, err)
+	}
+	return &DataPath{expr: expr, segments: segs}, nil
+}
+
+func (p *DataPath) String() string { return p.expr }
+
+type pathParser struct {
+	src string
+	pos int
+}
+
+func (p *pathParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) peek(s string) bool { return strings.HasPrefix(p.src[p.pos:], s) }
+
+func (p *pathParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *pathParser) parse() ([]pathSegment, error) {
+	if p.peek("$") {
+		p.pos++
+	} else if p.src == "." {
+		return nil, nil
+	}
+	var segs []pathSegment
+	if p.pos < len(p.src) && !p.peek(".") && !p.peek("[") {
+		// a leading key without a dot, as in "a.b"
+		seg, err := p.dotted(pathSegment{}, false)
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, seg)
+	}
+	for p.pos < len(p.src) {
+		seg, err := p.segment(false)
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+// segment parses one step. In filters only keys and indexes are allowed.
+func (p *pathParser) segment(inFilter bool) (pathSegment, error) {
+	var seg pathSegment
+	switch {
+	case p.peek(".."):
+		if inFilter {
+			return seg, p.errorf("recursive descent is not allowed in filters")
+		}
+		p.pos += 2
+		seg.recursive = true
+		if p.peek("[") {
+			return p.bracket(seg, inFilter)
+		}
+		return p.dotted(seg, inFilter)
+	case p.peek("."):
+		p.pos++
+		return p.dotted(seg, inFilter)
+	case p.peek("["):
+		return p.bracket(seg, inFilter)
+	}
+	return seg, p.errorf("expected '.' or '['")
+}
+
+func (p *pathParser) dotted(seg pathSegment, inFilter bool) (pathSegment, error) {
+	if p.peek("*") && !inFilter {
+		p.pos++
+		seg.wildcard = true
+		return seg, nil
+	}
+	start := p.pos
+	for p.pos < len(p.src) {
+		r := rune(p.src[p.pos])
+		if r != '_' && r != '-' && r < 0x80 && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return seg, p.errorf("expected a key")
+	}
+	seg.keys = []string{p.src[start:p.pos]}
+	return seg, nil
+}
+
+func (p *pathParser) bracket(seg pathSegment, inFilter bool) (pathSegment, error) {
+	p.pos++ // [
+	p.skipSpace()
+	switch {
+	case p.peek("*") && !inFilter:
+		p.pos++
+		seg.wildcard = true
+	case p.peek("]") && !inFilter:
+		seg.wildcard = true // jq's .[]
+	case p.peek("?") && !inFilter:
+		p.pos++
+		f, err := p.filter()
+		if err != nil {
+			return seg, err
+		}
+		seg.filter = f
+	case p.peek("'") || p.peek(`"`):
+		for {
+			s, err := p.quoted()
+			if err != nil {
+				return seg, err
+			}
+			seg.keys = append(seg.keys, s)
+			p.skipSpace()
+			if inFilter || !p.peek(",") {
+				break
+			}
+			p.pos++
+			p.skipSpace()
+		}
+	default:
+		if err := p.indexes(&seg, inFilter); err != nil {
+			return seg, err
+		}
+	}
+	p.skipSpace()
+	if !p.peek("]") {
+		return seg, p.errorf("expected ']'")
+	}
+	p.pos++
+	return seg, nil
+}
+
+func (p *pathParser) quoted() (string, error) {
+	q := p.src[p.pos]
+	var sb strings.Builder
+	for i := p.pos + 1; i < len(p.src); i++ {
+		c := p.src[i]
+		switch {
+		case c == '\\' && i+1 < len(p.src):
+			i++
+			sb.WriteByte(p.src[i])
+		case c == q:
+			p.pos = i + 1
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *pathParser) int() (*int, error) {
+	start := p.pos
+	if p.peek("-") {
+		p.pos++
+	}
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		return nil, p.errorf("bad index %q", p.src[start:p.pos])
+	}
+	return &n, nil
+}
+
+func (p *pathParser) indexes(seg *pathSegment, inFilter bool) error {
+	first, err := p.int()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.peek(":") && !inFilter {
+		s := &pathSlice{start: first, step: 1}
+		p.pos++
+		if s.end, err = p.int(); err != nil {
+			return err
+		}
+		if p.peek(":") {
+			p.pos++
+			step, err := p.int()
+			if err != nil {
+				return err
+			}
+			if step != nil {
+				if *step == 0 {
+					return p.errorf("slice step must not be 0")
+				}
+				s.step = *step
+			}
+		}
+		seg.slice = s
+		return nil
+	}
+	if first == nil {
+		return p.errorf("expected an index, key, '*' or filter")
+	}
+	seg.indexes = []int{*first}
+	for !inFilter && p.peek(",") {
+		p.pos++
+		p.skipSpace()
+		n, err := p.int()
+		if err != nil {
+			return err
+		}
+		if n == nil {
+			return p.errorf("expected an index")
+		}
+		seg.indexes = append(seg.indexes, *n)
+		p.skipSpace()
+	}
+	return nil
+}
+
+func (p *pathParser) filter() (*pathFilter, error) {
+	p.skipSpace()
+	paren := p.peek("(")
+	if paren {
+		p.pos++
+		p.skipSpace()
+	}
+	if !p.peek("@") {
+		return nil, p.errorf("filters start with '@'")
+	}
+	p.pos++
+	f := &pathFilter{}
+	for p.peek(".") || p.peek("[") {
+		seg, err := p.segment(true)
+		if err != nil {
+			return nil, err
+		}
+		f.path = append(f.path, seg)
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.peek(op) {
+			p.pos += len(op)
+			f.op = op
+			break
+		}
+	}
+	if f.op != "" {
+		p.skipSpace()
+		v, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		f.value = v
+		p.skipSpace()
+	}
+	if paren {
+		if !p.peek(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		p.pos++
+	}
+	return f, nil
+}
+
+func (p *pathParser) literal() (*yaml.Node, error) {
+	if p.peek("'") || p.peek(`"`) {
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t)]", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, p.errorf("expected a value")
+	}
+	var n yaml.Node
+	if err := yaml.Unmarshal([]byte(p.src[start:p.pos]), &n); err != nil || len(n.Content) != 1 || n.Content[0].Kind != yaml.ScalarNode {
+		return nil, p.errorf("bad value %q", p.src[start:p.pos])
+	}
+	return n.Content[0], nil
+}
+
+// dataMatch is a node selected by a path. parent is nil for the root; for
+// mapping parents index is the position of the value in parent.Content.
+type dataMatch struct {
+	node   *yaml.Node
+	parent *yaml.Node
+	index  int
+	path   string
+}
+
+// resolveAlias follows alias nodes to their anchor.
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n != nil && n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// eval returns the nodes of root selected by segs. With create, missing keys
+// are added to mappings as empty mappings (or as null for the last segment)
+// so that values can be set at new paths.
+func evalPath(root *yaml.Node, segs []pathSegment, create bool) []dataMatch {
+	cur := []dataMatch{{node: root, path: "$"}}
+	for i, seg := range segs {
+		last := i == len(segs)-1
+		var next []dataMatch
+		for _, m := range cur {
+			if seg.recursive {
+				descend(m, func(d dataMatch) { next = append(next, selectChildren(d, seg, false, false)...) })
+				continue
+			}
+			next = append(next, selectChildren(m, seg, create, last)...)
+		}
+		cur = next
+	}
+	return cur
+}
+
+// descend calls fn for m and every node below it. It does not follow
+// aliases: their anchors are visited where they are defined, and following
+// them lets a small document with nested anchors expand exponentially.
+func descend(m dataMatch, fn func(dataMatch)) {
+	fn(m)
+	if m.node.Kind == yaml.AliasNode {
+		return
+	}
+	all := pathSegment{wildcard: true}
+	for _, c := range selectChildren(m, all, false, false) {
+		descend(c, fn)
+	}
+}
+
+func selectChildren(m dataMatch, seg pathSegment, create, last bool) []dataMatch {
+	n := resolveAlias(m.node)
+	var out []dataMatch
+	child := func(i int, name string) dataMatch {
+		return dataMatch{node: n.Content[i], parent: n, index: i, path: m.path + name}
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		switch {
+		case seg.wildcard, seg.filter != nil:
+			for i := 1; i < len(n.Content); i += 2 {
+				c := child(i, keyPath(n.Content[i-1].Value))
+				if seg.filter == nil || seg.filter.match(c.node) {
+					out = append(out, c)
+				}
+			}
+		case seg.keys != nil:
+			for _, key := range seg.keys {
+				found := false
+				for i := 1; i < len(n.Content); i += 2 {
+					if n.Content[i-1].Value == key {
+						out = append(out, child(i, keyPath(key)))
+						found = true
+					}
+				}
+				if !found && create {
+					val := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+					if last {
+						val = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+					}
+					n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, val)
+					out = append(out, child(len(n.Content)-1, keyPath(key)))
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		size := len(n.Content)
+		switch {
+		case seg.wildcard, seg.filter != nil:
+			for i := range n.Content {
+				c := child(i, "["+strconv.Itoa(i)+"]")
+				if seg.filter == nil || seg.filter.match(c.node) {
+					out = append(out, c)
+				}
+			}
+		case seg.indexes != nil:
+			for _, i := range seg.indexes {
+				if i < 0 {
+					i += size
+				}
+				if i == size && create && last {
+					n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"})
+					size++
+				}
+				if i >= 0 && i < size {
+					out = append(out, child(i, "["+strconv.Itoa(i)+"]"))
+				}
+			}
+		case seg.slice != nil:
+			for _, i := range seg.slice.indexes(size) {
+				out = append(out, child(i, "["+strconv.Itoa(i)+"]"))
+			}
+		}
+	}
+	return out
+}
+
+// keyPath renders a key as a path step.
+func keyPath(key string) string {
+	plain := key != ""
+	for _, r := range key {
+		if r != '_' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return "." + key
+	}
+	return "['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key) + "']"
+}
+
+// indexes returns the positions of a sequence of size n in the slice, with
+// Python semantics.
+func (s *pathSlice) indexes(n int) []int {
+	clamp := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		v := *p
+		if v < 0 {
+			v += n
+		}
+		if s.step > 0 {
+			return min(max(v, 0), n)
+		}
+		return min(max(v, -1), n-1)
+	}
+	var out []int
+	if s.step > 0 {
+		for i := clamp(s.start, 0); i < clamp(s.end, n); i += s.step {
+			out = append(out, i)
+		}
+	} else {
+		for i := clamp(s.start, n-1); i > clamp(s.end, -1); i += s.step {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (f *pathFilter) match(n *yaml.Node) bool {
+	got := evalPath(n, f.path, false)
+	if f.op == "" {
+		return len(got) > 0
+	}
+	for _, m := range got {
+		if v := resolveAlias(m.node); v.Kind == yaml.ScalarNode && compareScalars(v, f.value, f.op) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareScalars compares numerically when both values are numbers and as
+// strings otherwise. Only == and != apply to values of different types.
+func compareScalars(a, b *yaml.Node, op string) bool {
+	var c int
+	af, aok := scalarNumber(a)
+	bf, bok := scalarNumber(b)
+	switch {
+	case aok && bok:
+		c = cmpFloat(af, bf)
+	case aok != bok || scalarTag(a) != scalarTag(b) && (op == "==" || op == "!="):
+		return op == "!="
+	default:
+		c = strings.Compare(a.Value, b.Value)
+		if scalarTag(a) == "!!bool" {
+			c = strings.Compare(strings.ToLower(a.Value), strings.ToLower(b.Value))
+		}
+	}
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// scalarTag returns the resolved tag of a scalar, treating untagged values
+// of CSV files as strings.
+func scalarTag(n *yaml.Node) string {
+	if n.Tag != "" {
+		return n.ShortTag()
+	}
+	return "!!str"
+}
+
+// scalarNumber returns the value of an int or float scalar, or of a string
+// that holds a number, so that CSV cells compare numerically.
+func scalarNumber(n *yaml.Node) (float64, bool) {
+	switch scalarTag(n) {
+	case "!!int":
+		if i, err := strconv.ParseInt(strings.ReplaceAll(n.Value, "_", ""), 0, 64); err == nil {
+			return float64(i), true
+		}
+	case "!!float":
+		switch strings.ToLower(strings.TrimLeft(n.Value, "+-")) {
+		case ".inf":
+			if strings.HasPrefix(n.Value, "-") {
+				return math.Inf(-1), true
+			}
+			return math.Inf(1), true
+		case ".nan":
+			return math.NaN(), true
+		}
+		if f, err := strconv.ParseFloat(strings.ReplaceAll(n.Value, "_", ""), 64); err == nil {
+			return f, true
+		}
+	case "!!str":
+		if n.Style == 0 {
+			if f, err := strconv.ParseFloat(n.Value, 64); err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// removeMatches deletes the matched nodes from their parents. The root
+// cannot be removed.
+func removeMatches(ms []dataMatch) (int, error) {
+	byParent := map[*yaml.Node][]int{}
+	var parents []*yaml.Node
+	for _, m := range ms {
+		if m.parent == nil {
+			return 0, fmt.Errorf("cannot delete the document root")
+		}
+		if _, ok := byParent[m.parent]; !ok {
+			parents = append(parents, m.parent)
+		}
+		byParent[m.parent] = append(byParent[m.parent], m.index)
+	}
+	removed := 0
+	for _, parent := range parents {
+		idx := byParent[parent]
+		sort.Sort(sort.Reverse(sort.IntSlice(idx)))
+		prev := -1
+		for _, i := range idx {
+			if i == prev {
+				continue
+			}
+			prev = i
+			if parent.Kind == yaml.MappingNode {
+				parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
+			} else {
+				parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,275 @@
+package mcpfs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffOp is the kind of a line in an edit script.
+type DiffOp int
+
+const (
+	DiffEqual DiffOp = iota
+	DiffDelete
+	DiffInsert
+)
+
+// DiffLine is one line of an edit script turning a into b. OldLine and
+// NewLine are 1-based line numbers; 0 means the line is absent on that side.
+type DiffLine struct {
+	Op      DiffOp
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// maxDiffCost bounds the number of edits DiffLines searches through for a
+// range of lines. Past it the range is replaced wholesale: the script is
+// still correct but may not be the shortest.
+const maxDiffCost = 1024
+
+// DiffLines computes an edit script between the lines of a and b using the
+// linear-space variant of Myers' O(ND) algorithm. The script is a shortest
+// one unless a and b differ in more than maxDiffCost places.
+func DiffLines(a, b []string) []DiffLine {
+	d := differ{a: a, b: b}
+	d.diff(0, len(a), 0, len(b))
+	return d.script
+}
+
+type differ struct {
+	a, b   []string
+	script []DiffLine
+}
+
+// diff appends the script turning a[x0:x1] into b[y0:y1].
+func (d *differ) diff(x0, x1, y0, y1 int) {
+	for x0 < x1 && y0 < y1 && d.a[x0] == d.b[y0] {
+		d.script = append(d.script, DiffLine{Op: DiffEqual, Text: d.a[x0], OldLine: x0 + 1, NewLine: y0 + 1})
+		x0++
+		y0++
+	}
+	suffix := 0
+	for x1-suffix > x0 && y1-suffix > y0 && d.a[x1-suffix-1] == d.b[y1-suffix-1] {
+		suffix++
+	}
+	x1, y1 = x1-suffix, y1-suffix
+
+	if x, y, ok := d.split(x0, x1, y0, y1); ok && (x != x0 || y != y0) && (x != x1 || y != y1) {
+		d.diff(x0, x, y0, y)
+		d.diff(x, x1, y, y1)
+	} else {
+		for ; x0 < x1; x0++ {
+			d.script = append(d.script, DiffLine{Op: DiffDelete, Text: d.a[x0], OldLine: x0 + 1})
+		}
+		for ; y0 < y1; y0++ {
+			d.script = append(d.script, DiffLine{Op: DiffInsert, Text: d.b[y0], NewLine: y0 + 1})
+		}
+	}
+
+	for i := range suffix {
+		d.script = append(d.script, DiffLine{Op: DiffEqual, Text: d.a[x1+i], OldLine: x1 + i + 1, NewLine: y1 + i + 1})
+	}
+}
+
+// split finds the middle snake of a shortest edit script turning a[x0:x1]
+// into b[y0:y1], searching forward from the start and backward from the
+// end at once, and returns where its forward path ends. It reports false
+// if either range is empty or the script needs more than maxDiffCost
+// edits.
+func (d *differ) split(x0, x1, y0, y1 int) (int, int, bool) {
+	n, m := x1-x0, y1-y0
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := min((n+m+1)/2, maxDiffCost)
+	off := maxD
+	// vf[off+k] is the furthest x the forward search reached on diagonal k
+	// and vb the same for the backward search, counted from the end.
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[off+1], vb[off+1] = 0, 0
+	delta := n - m
+	// When delta is odd the paths meet on a forward step, else on a
+	// backward one.
+	front := delta%2 != 0
+	// The first and last diagonals still inside the grid.
+	var fStart, fEnd, bStart, bEnd int
+	for step := range maxD {
+		for k := -step + fStart; k <= step-fEnd; k += 2 {
+			i := off + k
+			var x int
+			if k == -step || (k != step && vf[i-1] < vf[i+1]) {
+				x = vf[i+1]
+			} else {
+				x = vf[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[x0+x] == d.b[y0+y] {
+				x++
+				y++
+			}
+			vf[i] = x
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case front:
+				if j := off + delta - k; j >= 0 && j < len(vb) && vb[j] != -1 && x >= n-vb[j] {
+					return x0 + x, y0 + y, true
+				}
+			}
+		}
+		for k := -step + bStart; k <= step-bEnd; k += 2 {
+			i := off + k
+			var x int
+			if k == -step || (k != step && vb[i-1] < vb[i+1]) {
+				x = vb[i+1]
+			} else {
+				x = vb[i-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[x1-x-1] == d.b[y1-y-1] {
+				x++
+				y++
+			}
+			vb[i] = x
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !front:
+				if j := off + delta - k; j >= 0 && j < len(vf) && vf[j] != -1 && vf[j] >= n-x {
+					fx := vf[j]
+					return x0 + fx, y0 + fx - (j - off), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// DiffHunk is a group of changes with surrounding context lines.
+type DiffHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"-"`
+}
+
+// DiffHunks groups an edit script into hunks with up to context unchanged
+// lines around each change.
+func DiffHunks(script []DiffLine, context int) []DiffHunk {
+	// Collect [start, end) ranges around each run of changes, merging ranges
+	// whose context overlaps.
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i < len(script); {
+		if script[i].Op == DiffEqual {
+			i++
+			continue
+		}
+		j := i
+		for j < len(script) && script[j].Op != DiffEqual {
+			j++
+		}
+		s := span{start: max(i-context, 0), end: min(j+context, len(script))}
+		if n := len(spans); n > 0 && s.start <= spans[n-1].end {
+			spans[n-1].end = s.end
+		} else {
+			spans = append(spans, s)
+		}
+		i = j
+	}
+
+	// Count the lines consumed on each side before every script position.
+	oldBefore := make([]int, len(script)+1)
+	newBefore := make([]int, len(script)+1)
+	for i, l := range script {
+		oldBefore[i+1], newBefore[i+1] = oldBefore[i], newBefore[i]
+		if l.Op != DiffInsert {
+			oldBefore[i+1]++
+		}
+		if l.Op != DiffDelete {
+			newBefore[i+1]++
+		}
+	}
+
+	hunks := make([]DiffHunk, 0, len(spans))
+	for _, s := range spans {
+		h := DiffHunk{
+			OldStart: oldBefore[s.start],
+			OldLines: oldBefore[s.end] - oldBefore[s.start],
+			NewStart: newBefore[s.start],
+			NewLines: newBefore[s.end] - newBefore[s.start],
+			Lines:    script[s.start:s.end],
+		}
+		// An empty side starts at the line before the hunk, as in diff(1).
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// SplitLines splits text into lines without their trailing newlines.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// UnifiedDiff renders the difference between oldText and newText in unified
+// format with three lines of context. It returns "" when they are equal.
+func UnifiedDiff(oldName, newName, oldText, newText string) string {
+	return unifiedDiff(oldName, newName, DiffLines(SplitLines(oldText), SplitLines(newText)))
+}
+
+// unifiedDiff renders an edit script in unified format with three lines of
+// context.
+func unifiedDiff(oldName, newName string, script []DiffLine) string {
+	hunks := DiffHunks(script, 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, l := range h.Lines {
+			switch l.Op {
+			case DiffEqual:
+				sb.WriteString(" ")
+			case DiffDelete:
+				sb.WriteString("-")
+			case DiffInsert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(l.Text)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,118 @@
+package mcpfs_test
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\n2x\n3\n4\n5\n6\n7\n8\n9\n10\n12\nnew\n"
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++2x
+ 3
+ 4
+ 5
+@@ -8,5 +8,5 @@
+ 8
+ 9
+ 10
+-11
+ 12
++new
+`
+	if got := mcpfs.UnifiedDiff("a", "b", old, new); got != want {
+		t.Fatalf("UnifiedDiff mismatch:\n%s\nwant:\n%s", got, want)
+	}
+	if got := mcpfs.UnifiedDiff("a", "b", "", "x\n"); got != "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n" {
+		t.Fatalf("diff against empty file: %q", got)
+	}
+	if got := mcpfs.UnifiedDiff("a", "b", old, old); got != "" {
+		t.Fatalf("expected no diff for equal input, got %q", got)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	// check reports whether script turns a into b, and how many edits it
+	// makes.
+	check := func(a, b []string, script []mcpfs.DiffLine) int {
+		t.Helper()
+		var gotA, gotB []string
+		edits := 0
+		for _, l := range script {
+			if l.Op != mcpfs.DiffInsert {
+				if l.OldLine != len(gotA)+1 {
+					t.Fatalf("line %q has old line %d, want %d", l.Text, l.OldLine, len(gotA)+1)
+				}
+				gotA = append(gotA, l.Text)
+			}
+			if l.Op != mcpfs.DiffDelete {
+				if l.NewLine != len(gotB)+1 {
+					t.Fatalf("line %q has new line %d, want %d", l.Text, l.NewLine, len(gotB)+1)
+				}
+				gotB = append(gotB, l.Text)
+			}
+			if l.Op != mcpfs.DiffEqual {
+				edits++
+			}
+		}
+		if fmt.Sprint(gotA) != fmt.Sprint(a) || fmt.Sprint(gotB) != fmt.Sprint(b) {
+			t.Fatalf("script of %q -> %q gives %q -> %q", a, b, gotA, gotB)
+		}
+		return edits
+	}
+	// shortest is the length of a shortest edit script, from the longest
+	// common subsequence.
+	shortest := func(a, b []string) int {
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		return len(a) + len(b) - 2*lcs[0][0]
+	}
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	lines := func() []string {
+		l := make([]string, rng.IntN(30))
+		for i := range l {
+			l[i] = string(rune('a' + rng.IntN(4)))
+		}
+		return l
+	}
+	for range 500 {
+		a, b := lines(), lines()
+		if got, want := check(a, b, mcpfs.DiffLines(a, b)), shortest(a, b); got != want {
+			t.Fatalf("DiffLines(%q, %q) makes %d edits, want %d", a, b, got, want)
+		}
+	}
+
+	// Files with nothing in common finish quickly in little memory, and a
+	// change in a long file is still found exactly.
+	a, b := make([]string, 100000), make([]string, 100000)
+	for i := range a {
+		a[i], b[i] = fmt.Sprint("a", i), fmt.Sprint("b", i)
+	}
+	if got := check(a, b, mcpfs.DiffLines(a, b)); got != len(a)+len(b) {
+		t.Errorf("DiffLines of disjoint files makes %d edits", got)
+	}
+	b = append(append(append([]string{}, a[:500]...), "x"), a[50000:]...)
+	if got := check(a, b, mcpfs.DiffLines(a, b)); got != 49501 {
+		t.Errorf("DiffLines of a long file makes %d edits, want 49501", got)
+	}
+}
@@ -3,5 +3,16 @@ package mcpfs
 import "errors"
 
 var (
-	ErrParse = errors.New("parse error")
+	ErrParse            = errors.New("parse error")
+	ErrSandbox          = errors.New("sandbox error")
+	ErrJournal          = errors.New("journal error")
+	ErrTrash            = errors.New("trash error")
+	ErrTransaction      = errors.New("transaction error")
+	ErrGit              = errors.New("git error")
+	ErrPermissionDenied = errors.New("permission denied")
+
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrRateLimited   = errors.New("rate limited")
+
+	ErrApprovalDenied = errors.New("approval denied")
 )
@@ -0,0 +1,18 @@
+package mcpfs
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RewriteV1Configs makes the migration from version 1 rewrite documents
+// until t ends, as a migration changing settings would. The shipped
+// migration leaves them as they are.
+func RewriteV1Configs(t *testing.T) {
+	saved := configMigrations
+	configMigrations = []configMigration{
+		{from: ConfigVersionV1, to: ConfigVersionV2, migrate: func(root *yaml.Node) error { return nil }},
+	}
+	t.Cleanup(func() { configMigrations = saved })
+}
@@ -0,0 +1,238 @@
+package mcpfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// maxSymlinks bounds the symlinks resolvePath follows, like the kernel's
+// limit before ELOOP.
+const maxSymlinks = 40
+
+// resolvePath returns the clean absolute path p refers to with its symlinks
+// resolved: those of its existing parents, and a link at its end even if
+// it dangles, so that writing through it would create its target. The
+// permission checks decide on this path, so that a link in a granted
+// directory does not grant what it points to.
+func resolvePath(p string) string {
+	p = cleanAbsPath(p)
+	for range maxSymlinks {
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			return real
+		}
+		p = resolveParent(p)
+		link, err := os.Readlink(p)
+		if err != nil {
+			return p
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(p), link)
+		}
+		p = filepath.Clean(link)
+	}
+	return p
+}
+
+// resolveParent returns p with the symlinks of its parent directories
+// resolved but not one at its end. Moves and deletes act on that path: on a
+// link itself, not on what it points to.
+func resolveParent(p string) string {
+	p = cleanAbsPath(p)
+	dir := filepath.Dir(p)
+	if dir == p {
+		return p
+	}
+	return filepath.Join(resolvePath(dir), filepath.Base(p))
+}
+
+// readFileNoFollow reads the file at p, which must have been resolved by
+// resolvePath. It fails if a symlink has been put in its place since.
+func readFileNoFollow(p string) ([]byte, error) {
+	f, err := os.OpenFile(p, os.O_RDONLY|oNoFollow, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// copyFile copies the regular file src to dst with the given mode. The data
+// is written to a temporary file next to dst and renamed into place so dst is
+// never left half written, and a symlink at dst is replaced rather than
+// followed. A symlink at src is not followed either. It returns the number
+// of bytes copied.
+func copyFile(src, dst string, mode fs.FileMode) (int64, error) {
+	in, err := os.OpenFile(src, os.O_RDONLY|oNoFollow, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, in)
+	if err == nil {
+		err = tmp.Chmod(mode.Perm())
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// writeFileAtomic writes data to path through a temporary file in the same
+// directory that is renamed into place.
+func writeFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode.Perm())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// filesystemTop returns the top directory of the filesystem holding dir,
+// its mount point, if that filesystem is not the one holding other. other
+// need not exist yet; its nearest existing parent counts. It returns false if
+// both are on the same filesystem or the devices cannot be told.
+func filesystemTop(dir, other string) (string, bool) {
+	dev, ok := fileDevice(dir)
+	if !ok {
+		return "", false
+	}
+	for {
+		if odev, ok := fileDevice(other); ok {
+			if odev == dev {
+				return "", false
+			}
+			break
+		}
+		parent := filepath.Dir(other)
+		if parent == other {
+			return "", false
+		}
+		other = parent
+	}
+	for {
+		parent := filepath.Dir(dir)
+		if pdev, ok := fileDevice(parent); parent == dir || !ok || pdev != dev {
+			return dir, true
+		}
+		dir = parent
+	}
+}
+
+// copyTree copies the file or directory src to dst, preserving permission
+// bits, symlinks and named pipes and leaving out sockets. It returns the
+// number of bytes of file data copied.
+func copyTree(src, dst string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			n, err := copyFile(p, target, info.Mode())
+			total += n
+			return err
+		case info.Mode()&fs.ModeNamedPipe != 0:
+			return mkfifo(target, info.Mode().Perm())
+		case info.Mode()&fs.ModeSocket != 0:
+			// A socket is only of use to the process listening on it.
+			return nil
+		default:
+			return fmt.Errorf("cannot copy %q: unsupported file type %s", p, info.Mode().Type())
+		}
+	})
+	if err != nil {
+		return total, err
+	}
+	// restore directory modes after their contents were written
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		return os.Chmod(filepath.Join(dst, rel), info.Mode().Perm())
+	})
+	return total, err
+}
+
+// treeSize returns the number of bytes of regular file data under p.
+func treeSize(p string) int64 {
+	return PathUsage(p).Bytes
+}
+
+// moveTree renames src to dst, falling back to copy and remove when they are
+// on different filesystems.
+func moveTree(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if _, err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package mcpfs
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// oNoFollow is not supported; resolvePath has resolved the links the
+// permission checks saw.
+const oNoFollow = 0
+
+// mkfifo fails; named pipes are not supported.
+func mkfifo(path string, perm fs.FileMode) error {
+	return fmt.Errorf("cannot create named pipe %q", path)
+}
+
+// fileDevice is not supported; every path counts as being on the same
+// filesystem.
+func fileDevice(path string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package mcpfs
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// oNoFollow makes an open fail if the last element of the path is a
+// symlink.
+const oNoFollow = syscall.O_NOFOLLOW
+
+// mkfifo creates a named pipe at path.
+func mkfifo(path string, perm fs.FileMode) error {
+	return syscall.Mkfifo(path, uint32(perm))
+}
+
+// fileDevice returns the device of the filesystem holding path.
+func fileDevice(path string) (uint64, bool) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
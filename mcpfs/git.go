@@ -0,0 +1,751 @@
+package mcpfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// DefaultGitLogCount is the number of commits GitLog returns when no limit
+// is given.
+const DefaultGitLogCount = 20
+
+// Identity of commits made through the git tools when the config leaves it
+// unset.
+const (
+	DefaultGitAuthorName  = "mcpfs agent"
+	DefaultGitAuthorEmail = "mcpfs@localhost"
+	DefaultGitTrailer     = "Committed-via: mcpfs"
+)
+
+// GitConfig sets the identity of commits made through the git tools, so agent
+// commits can be told apart from human ones in history.
+// YAML schema:
+//
+//	git:
+//	  author_name: "mcpfs agent"     # author and committer name
+//	  author_email: mcpfs@localhost  # author and committer email
+//	  trailer: "Committed-via: mcpfs" # "Key: value" appended to every commit message
+type GitConfig struct {
+	AuthorName  string `yaml:"author_name,omitempty" json:"author_name,omitempty"`
+	AuthorEmail string `yaml:"author_email,omitempty" json:"author_email,omitempty"`
+	Trailer     string `yaml:"trailer,omitempty" json:"trailer,omitempty"`
+}
+
+// Validate checks that the trailer has the "Key: value" form git expects.
+func (c *GitConfig) Validate() error {
+	if c.Trailer == "" {
+		return nil
+	}
+	key, val, ok := strings.Cut(c.Trailer, ":")
+	if !ok || key == "" || strings.ContainsAny(key, " \t") || strings.TrimSpace(val) == "" || strings.Contains(c.Trailer, "\n") {
+		return fmt.Errorf("trailer %q is not of the form \"Key: value\"", c.Trailer)
+	}
+	return nil
+}
+
+func (c GitConfig) withDefaults() GitConfig {
+	if c.AuthorName == "" {
+		c.AuthorName = DefaultGitAuthorName
+	}
+	if c.AuthorEmail == "" {
+		c.AuthorEmail = DefaultGitAuthorEmail
+	}
+	if c.Trailer == "" {
+		c.Trailer = DefaultGitTrailer
+	}
+	return c
+}
+
+// GitRepo is a git repository with a worktree, opened with go-git so no git
+// binary is needed.
+type GitRepo struct {
+	// Root is the absolute path of the worktree.
+	Root string
+
+	repo *git.Repository
+}
+
+// OpenGitRepo opens the repository whose worktree contains path.
+func OpenGitRepo(path string) (*GitRepo, error) {
+	path = cleanAbsPath(path)
+	start := path
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		start = filepath.Dir(path)
+	}
+	repo, err := git.PlainOpenWithOptions(start, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("%w: open repository at %q: %v", ErrGit, path, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrGit, path, err)
+	}
+	return &GitRepo{Root: cleanAbsPath(wt.Filesystem.Root()), repo: repo}, nil
+}
+
+// RelPath returns path relative to the worktree root in slash form, or "" for
+// the root itself. It fails for paths outside the worktree.
+func (r *GitRepo) RelPath(path string) (string, error) {
+	path = cleanAbsPath(path)
+	if !withinPath(path, r.Root) {
+		return "", fmt.Errorf("%w: %q is outside the worktree %q", ErrGit, path, r.Root)
+	}
+	rel, _ := filepath.Rel(r.Root, path)
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// GitStatus is the state of a worktree.
+type GitStatus struct {
+	Root   string          `json:"root"`
+	Branch string          `json:"branch,omitempty" jsonschema:"checked out branch, empty when HEAD is detached"`
+	Head   string          `json:"head,omitempty" jsonschema:"commit HEAD points to, empty in a new repository"`
+	Clean  bool            `json:"clean"`
+	Files  []GitFileStatus `json:"files"`
+}
+
+// GitFileStatus is the state of one changed path. Staging compares the index
+// with HEAD and Worktree compares the working tree with the index.
+type GitFileStatus struct {
+	Path     string `json:"path"`
+	Staging  string `json:"staging" jsonschema:"unmodified, added, modified, deleted, renamed, copied, unmerged or untracked"`
+	Worktree string `json:"worktree" jsonschema:"unmodified, added, modified, deleted, renamed, copied, unmerged or untracked"`
+}
+
+// unstaged reports whether the file in the working tree differs from the
+// index.
+func (f GitFileStatus) unstaged() bool {
+	return f.Worktree != gitStatusNames[git.Unmodified]
+}
+
+var gitStatusNames = map[git.StatusCode]string{
+	git.Unmodified:         "unmodified",
+	git.Untracked:          "untracked",
+	git.Modified:           "modified",
+	git.Added:              "added",
+	git.Deleted:            "deleted",
+	git.Renamed:            "renamed",
+	git.Copied:             "copied",
+	git.UpdatedButUnmerged: "unmerged",
+}
+
+func gitStatusName(c git.StatusCode) string {
+	if name, ok := gitStatusNames[c]; ok {
+		return name
+	}
+	return string(c)
+}
+
+// Status returns the changed paths below the slash-separated prefix (all
+// paths when prefix is empty), sorted by path.
+func (r *GitRepo) Status(prefix string) (*GitStatus, error) {
+	out := &GitStatus{Root: r.Root, Files: []GitFileStatus{}}
+	if head, err := r.repo.Head(); err == nil {
+		out.Head = head.Hash().String()
+		if head.Name().IsBranch() {
+			out.Branch = head.Name().Short()
+		}
+	} else if ref, err := r.repo.Storer.Reference(plumbing.HEAD); err == nil && ref.Type() == plumbing.SymbolicReference {
+		out.Branch = ref.Target().Short() // unborn branch
+	}
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	st, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("%w: status: %v", ErrGit, err)
+	}
+	for p, fs := range st {
+		if fs.Staging == git.Unmodified && fs.Worktree == git.Unmodified || !gitPathMatch(p, prefix) {
+			continue
+		}
+		out.Files = append(out.Files, GitFileStatus{
+			Path:     p,
+			Staging:  gitStatusName(fs.Staging),
+			Worktree: gitStatusName(fs.Worktree),
+		})
+	}
+	sort.Slice(out.Files, func(i, j int) bool { return out.Files[i].Path < out.Files[j].Path })
+	out.Clean = len(out.Files) == 0
+	return out, nil
+}
+
+// GitDiffOptions selects what Diff compares. By default the working tree is
+// compared with the index, like git diff.
+type GitDiffOptions struct {
+	Prefix string // only paths below this slash-separated prefix
+	Staged bool   // compare the index with HEAD, like git diff --staged
+	Commit string // compare this revision with its first parent, like git show
+}
+
+// GitFileDiff is the change to one file.
+type GitFileDiff struct {
+	Path      string `json:"path"`
+	OldPath   string `json:"old_path,omitempty" jsonschema:"previous path of a renamed file"`
+	Status    string `json:"status" jsonschema:"added, modified, deleted or renamed"`
+	Binary    bool   `json:"binary,omitempty"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Patch     string `json:"patch,omitempty" jsonschema:"unified diff of a text file"`
+	Withheld  bool   `json:"withheld,omitempty" jsonschema:"the patch and counts are left out because the file is not readable"`
+	TooLarge  bool   `json:"too_large,omitempty" jsonschema:"the patch and counts are left out because a side of the file is too large to diff"`
+}
+
+// maxGitDiffSize bounds the size of either side of a file Diff computes a
+// patch for.
+const maxGitDiffSize = 1 << 20
+
+// Diff returns the changed files selected by opts, sorted by path.
+func (r *GitRepo) Diff(opts GitDiffOptions) ([]GitFileDiff, error) {
+	if opts.Commit != "" {
+		return r.commitDiff(opts)
+	}
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	st, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("%w: status: %v", ErrGit, err)
+	}
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("%w: read index: %v", ErrGit, err)
+	}
+	var headTree *object.Tree
+	if opts.Staged {
+		if head, err := r.repo.Head(); err == nil {
+			c, err := r.repo.CommitObject(head.Hash())
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrGit, err)
+			}
+			if headTree, err = c.Tree(); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrGit, err)
+			}
+		}
+	}
+
+	indexBlob := func(p string) ([]byte, bool, error) {
+		e, err := idx.Entry(p)
+		if err != nil {
+			return nil, false, nil
+		}
+		data, err := r.blob(e.Hash)
+		return data, true, err
+	}
+
+	diffs := []GitFileDiff{}
+	for p, fs := range st {
+		if !gitPathMatch(p, opts.Prefix) {
+			continue
+		}
+		var (
+			oldData, newData []byte
+			oldOK, newOK     bool
+			err              error
+		)
+		if opts.Staged {
+			if fs.Staging == git.Unmodified || fs.Staging == git.Untracked {
+				continue
+			}
+			if headTree != nil {
+				if f, ferr := headTree.File(p); ferr == nil {
+					oldOK = true
+					oldData, err = fileContents(f)
+				}
+			}
+			if err == nil {
+				newData, newOK, err = indexBlob(p)
+			}
+		} else {
+			if fs.Worktree == git.Unmodified || fs.Worktree == git.Untracked {
+				continue
+			}
+			oldData, oldOK, err = indexBlob(p)
+			if err == nil {
+				newData, err = os.ReadFile(filepath.Join(r.Root, filepath.FromSlash(p)))
+				newOK = err == nil
+				if errors.Is(err, os.ErrNotExist) {
+					err = nil
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: diff %q: %v", ErrGit, p, err)
+		}
+		diffs = append(diffs, newGitFileDiff(p, "", oldData, newData, oldOK, newOK))
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+func (r *GitRepo) commitDiff(opts GitDiffOptions) ([]GitFileDiff, error) {
+	c, err := r.commit(opts.Commit)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	var parentTree *object.Tree
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGit, err)
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGit, err)
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+
+	diffs := []GitFileDiff{}
+	for _, ch := range changes {
+		from, to, err := ch.Files()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGit, err)
+		}
+		path, oldPath := ch.To.Name, ""
+		if path == "" {
+			path = ch.From.Name
+		} else if ch.From.Name != "" && ch.From.Name != path {
+			oldPath = ch.From.Name
+		}
+		if !gitPathMatch(path, opts.Prefix) && (oldPath == "" || !gitPathMatch(oldPath, opts.Prefix)) {
+			continue
+		}
+		var oldData, newData []byte
+		if from != nil {
+			if oldData, err = fileContents(from); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrGit, err)
+			}
+		}
+		if to != nil {
+			if newData, err = fileContents(to); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrGit, err)
+			}
+		}
+		diffs = append(diffs, newGitFileDiff(path, oldPath, oldData, newData, from != nil, to != nil))
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+func newGitFileDiff(path, oldPath string, oldData, newData []byte, oldOK, newOK bool) GitFileDiff {
+	d := GitFileDiff{Path: path, OldPath: oldPath}
+	switch {
+	case !oldOK:
+		d.Status = "added"
+	case !newOK:
+		d.Status = "deleted"
+	case oldPath != "":
+		d.Status = "renamed"
+	default:
+		d.Status = "modified"
+	}
+	if bytes.IndexByte(oldData, 0) >= 0 || bytes.IndexByte(newData, 0) >= 0 {
+		d.Binary = true
+		return d
+	}
+	if len(oldData) > maxGitDiffSize || len(newData) > maxGitDiffSize {
+		d.TooLarge = true
+		return d
+	}
+	oldName, newName := "a/"+path, "b/"+path
+	if oldPath != "" {
+		oldName = "a/" + oldPath
+	}
+	if !oldOK {
+		oldName = "/dev/null"
+	}
+	if !newOK {
+		newName = "/dev/null"
+	}
+	script := DiffLines(SplitLines(string(oldData)), SplitLines(string(newData)))
+	for _, l := range script {
+		switch l.Op {
+		case DiffInsert:
+			d.Additions++
+		case DiffDelete:
+			d.Deletions++
+		}
+	}
+	d.Patch = unifiedDiff(oldName, newName, script)
+	return d
+}
+
+// GitCommit describes a commit.
+type GitCommit struct {
+	Hash        string   `json:"hash"`
+	Parents     []string `json:"parents"`
+	Author      string   `json:"author"`
+	AuthorEmail string   `json:"author_email"`
+	Date        string   `json:"date" jsonschema:"author date (RFC 3339)"`
+	Committer   string   `json:"committer"`
+	Subject     string   `json:"subject"`
+	Message     string   `json:"message"`
+}
+
+func newGitCommit(c *object.Commit) GitCommit {
+	gc := GitCommit{
+		Hash:        c.Hash.String(),
+		Parents:     []string{},
+		Author:      c.Author.Name,
+		AuthorEmail: c.Author.Email,
+		Date:        c.Author.When.Format(time.RFC3339),
+		Committer:   c.Committer.Name,
+		Message:     c.Message,
+	}
+	gc.Subject, _, _ = strings.Cut(strings.TrimSpace(c.Message), "\n")
+	for _, p := range c.ParentHashes {
+		gc.Parents = append(gc.Parents, p.String())
+	}
+	return gc
+}
+
+// GitLogOptions selects the commits Log returns.
+type GitLogOptions struct {
+	Rev    string // start from this revision instead of HEAD
+	Prefix string // only commits touching paths below this prefix
+	Max    int    // at most this many commits (default DefaultGitLogCount)
+}
+
+// Log returns commits reachable from opts.Rev, newest first by committer time.
+func (r *GitRepo) Log(opts GitLogOptions) ([]GitCommit, error) {
+	start, err := r.commit(opts.Rev)
+	if err != nil {
+		return nil, err
+	}
+	lo := &git.LogOptions{From: start.Hash, Order: git.LogOrderCommitterTime}
+	if opts.Prefix != "" {
+		lo.PathFilter = func(p string) bool { return gitPathMatch(p, opts.Prefix) }
+	}
+	limit := opts.Max
+	if limit <= 0 {
+		limit = DefaultGitLogCount
+	}
+	iter, err := r.repo.Log(lo)
+	if err != nil {
+		return nil, fmt.Errorf("%w: log: %v", ErrGit, err)
+	}
+	defer iter.Close()
+	commits := []GitCommit{}
+	for len(commits) < limit {
+		c, err := iter.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: log: %v", ErrGit, err)
+		}
+		commits = append(commits, newGitCommit(c))
+	}
+	return commits, nil
+}
+
+// GitBlameLine is the origin of one line of a file.
+type GitBlameLine struct {
+	Line        int    `json:"line"`
+	Hash        string `json:"hash"`
+	Author      string `json:"author"`
+	AuthorEmail string `json:"author_email"`
+	Date        string `json:"date" jsonschema:"when the line was introduced (RFC 3339)"`
+	Text        string `json:"text"`
+}
+
+// Blame returns the commit that last changed each line of the file at the
+// slash-separated path as of rev (HEAD when empty).
+func (r *GitRepo) Blame(path, rev string) ([]GitBlameLine, error) {
+	c, err := r.commit(rev)
+	if err != nil {
+		return nil, err
+	}
+	res, err := git.Blame(c, path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: blame %q: %v", ErrGit, path, err)
+	}
+	lines := make([]GitBlameLine, 0, len(res.Lines))
+	for i, l := range res.Lines {
+		lines = append(lines, GitBlameLine{
+			Line:        i + 1,
+			Hash:        l.Hash.String(),
+			Author:      l.AuthorName,
+			AuthorEmail: l.Author,
+			Date:        l.Date.Format(time.RFC3339),
+			Text:        l.Text,
+		})
+	}
+	return lines, nil
+}
+
+// Stage adds the current state of the slash-separated paths to the index,
+// including deletions.
+func (r *GitRepo) Stage(paths []string) error {
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	for _, p := range paths {
+		if _, err := wt.Add(p); err != nil {
+			return fmt.Errorf("%w: stage %q: %v", ErrGit, p, err)
+		}
+	}
+	return nil
+}
+
+// Commit records the index as a new commit on HEAD. Author and committer come
+// from cfg and its trailer is appended to message. It refuses to create an
+// empty commit.
+func (r *GitRepo) Commit(message string, cfg GitConfig, when time.Time) (*GitCommit, error) {
+	message = strings.TrimSpace(message)
+	if message == "" {
+		return nil, fmt.Errorf("%w: empty commit message", ErrGit)
+	}
+	cfg = cfg.withDefaults()
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	sig := &object.Signature{Name: cfg.AuthorName, Email: cfg.AuthorEmail, When: when}
+	h, err := wt.Commit(addTrailer(message, cfg.Trailer), &git.CommitOptions{Author: sig, Committer: sig})
+	if err != nil {
+		return nil, fmt.Errorf("%w: commit: %v", ErrGit, err)
+	}
+	c, err := r.repo.CommitObject(h)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	gc := newGitCommit(c)
+	return &gc, nil
+}
+
+// addTrailer appends trailer to message, in the trailer block if the message
+// already ends with one.
+func addTrailer(message, trailer string) string {
+	lines := strings.Split(message, "\n")
+	last := lines[len(lines)-1]
+	if key, _, ok := strings.Cut(last, ": "); ok && len(lines) > 2 && !strings.Contains(key, " ") {
+		return message + "\n" + trailer + "\n"
+	}
+	return message + "\n\n" + trailer + "\n"
+}
+
+// GitBranch is a local branch.
+type GitBranch struct {
+	Name    string `json:"name"`
+	Hash    string `json:"hash"`
+	Current bool   `json:"current"`
+}
+
+// Branches returns the local branches sorted by name.
+func (r *GitRepo) Branches() ([]GitBranch, error) {
+	var current plumbing.ReferenceName
+	if head, err := r.repo.Storer.Reference(plumbing.HEAD); err == nil && head.Type() == plumbing.SymbolicReference {
+		current = head.Target()
+	}
+	iter, err := r.repo.Branches()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	branches := []GitBranch{}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		branches = append(branches, GitBranch{
+			Name:    ref.Name().Short(),
+			Hash:    ref.Hash().String(),
+			Current: ref.Name() == current,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
+	return branches, nil
+}
+
+// CreateBranch creates the branch name at rev (HEAD when empty). It fails if
+// the branch exists.
+func (r *GitRepo) CreateBranch(name, rev string) (*GitBranch, error) {
+	ref, err := branchRef(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.repo.Storer.Reference(ref); err == nil {
+		return nil, fmt.Errorf("%w: branch %q already exists", ErrGit, name)
+	}
+	c, err := r.commit(rev)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(ref, c.Hash)); err != nil {
+		return nil, fmt.Errorf("%w: create branch %q: %v", ErrGit, name, err)
+	}
+	return &GitBranch{Name: name, Hash: c.Hash.String()}, nil
+}
+
+// DeleteBranch deletes the branch name. The current branch cannot be
+// deleted.
+func (r *GitRepo) DeleteBranch(name string) error {
+	ref, err := branchRef(name)
+	if err != nil {
+		return err
+	}
+	if _, err := r.repo.Storer.Reference(ref); err != nil {
+		return fmt.Errorf("%w: no branch %q", ErrGit, name)
+	}
+	if head, err := r.repo.Storer.Reference(plumbing.HEAD); err == nil && head.Target() == ref {
+		return fmt.Errorf("%w: cannot delete the current branch %q", ErrGit, name)
+	}
+	if err := r.repo.Storer.RemoveReference(ref); err != nil {
+		return fmt.Errorf("%w: delete branch %q: %v", ErrGit, name, err)
+	}
+	return nil
+}
+
+// CheckoutChanges returns the slash-separated paths of the files checking
+// out the branch name changes, adds or removes, sorted.
+func (r *GitRepo) CheckoutChanges(name string) ([]string, error) {
+	ref, err := branchRef(name)
+	if err != nil {
+		return nil, err
+	}
+	target, err := r.repo.Storer.Reference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no branch %q", ErrGit, name)
+	}
+	c, err := r.repo.CommitObject(target.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	to, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	var from *object.Tree
+	if head, err := r.commit(""); err == nil {
+		if from, err = head.Tree(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrGit, err)
+		}
+	}
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	var paths []string
+	for _, ch := range changes {
+		for _, p := range []string{ch.From.Name, ch.To.Name} {
+			if p != "" && !slices.Contains(paths, p) {
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Checkout switches the worktree to the branch name. It refuses to run with
+// uncommitted changes, or with untracked files where the branch has files,
+// so nothing is lost.
+func (r *GitRepo) Checkout(name string) error {
+	ref, err := branchRef(name)
+	if err != nil {
+		return err
+	}
+	changed, err := r.CheckoutChanges(name)
+	if err != nil {
+		return err
+	}
+	st, err := r.Status("")
+	if err != nil {
+		return err
+	}
+	for _, f := range st.Files {
+		if f.Worktree != "untracked" {
+			return fmt.Errorf("%w: worktree has uncommitted changes (%s)", ErrGit, f.Path)
+		}
+		if slices.Contains(changed, f.Path) {
+			return fmt.Errorf("%w: checkout would overwrite the untracked file %s", ErrGit, f.Path)
+		}
+	}
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrGit, err)
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: ref}); err != nil {
+		return fmt.Errorf("%w: checkout %q: %v", ErrGit, name, err)
+	}
+	return nil
+}
+
+func branchRef(name string) (plumbing.ReferenceName, error) {
+	ref := plumbing.NewBranchReferenceName(name)
+	if name == "" || ref.Validate() != nil {
+		return "", fmt.Errorf("%w: invalid branch name %q", ErrGit, name)
+	}
+	return ref, nil
+}
+
+// commit resolves rev, or HEAD when it is empty, to a commit.
+func (r *GitRepo) commit(rev string) (*object.Commit, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	h, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("%w: resolve %q: %v", ErrGit, rev, err)
+	}
+	c, err := r.repo.CommitObject(*h)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q is not a commit: %v", ErrGit, rev, err)
+	}
+	return c, nil
+}
+
+func (r *GitRepo) blob(h plumbing.Hash) ([]byte, error) {
+	b, err := r.repo.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+	rd, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return io.ReadAll(rd)
+}
+
+func fileContents(f *object.File) ([]byte, error) {
+	rd, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return io.ReadAll(rd)
+}
+
+// gitPathMatch reports whether the slash-separated path p is prefix or below
+// it. An empty prefix matches everything.
+func gitPathMatch(p, prefix string) bool {
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
@@ -0,0 +1,392 @@
+package mcpfs_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// newGitRepo initializes a repository in a temporary directory with two
+// commits touching a.txt and one adding dir/b.txt.
+func newGitRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, _ := repo.Worktree()
+	when := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	commit := func(msg, name string, files map[string]string) {
+		t.Helper()
+		for p, content := range files {
+			full := filepath.Join(root, p)
+			os.MkdirAll(filepath.Dir(full), 0o755)
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := wt.Add(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		when = when.Add(time.Hour)
+		sig := &object.Signature{Name: name, Email: strings.ToLower(name) + "@example.com", When: when}
+		if _, err := wt.Commit(msg, &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit("Add a\n\nFirst commit.", "Alice", map[string]string{"a.txt": "one\ntwo\n"})
+	commit("Change a and add b", "Bob", map[string]string{"a.txt": "one\nTWO\n", "dir/b.txt": "b\n"})
+	return root
+}
+
+func TestGitRepo_StatusAndDiff(t *testing.T) {
+	root := newGitRepo(t)
+	repo, err := mcpfs.OpenGitRepo(filepath.Join(root, "dir", "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Root != root {
+		t.Fatalf("Root = %q, want %q", repo.Root, root)
+	}
+
+	st, err := repo.Status("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Clean || st.Branch != "master" || st.Head == "" {
+		t.Fatalf("Status of clean repo = %+v", st)
+	}
+
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\nTWO\nthree\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "new.txt"), []byte("x"), 0o644)
+	os.Remove(filepath.Join(root, "dir", "b.txt"))
+
+	st, err = repo.Status("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mcpfs.GitFileStatus{
+		{Path: "a.txt", Staging: "unmodified", Worktree: "modified"},
+		{Path: "dir/b.txt", Staging: "unmodified", Worktree: "deleted"},
+		{Path: "new.txt", Staging: "untracked", Worktree: "untracked"},
+	}
+	if st.Clean || len(st.Files) != len(want) {
+		t.Fatalf("Status = %+v", st)
+	}
+	for i := range want {
+		if st.Files[i] != want[i] {
+			t.Fatalf("Files[%d] = %+v, want %+v", i, st.Files[i], want[i])
+		}
+	}
+	if st, _ := repo.Status("dir"); len(st.Files) != 1 {
+		t.Fatalf("Status(dir) = %+v", st.Files)
+	}
+
+	diffs, err := repo.Diff(mcpfs.GitDiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].Path != "a.txt" || diffs[1].Status != "deleted" {
+		t.Fatalf("Diff = %+v", diffs)
+	}
+	if d := diffs[0]; d.Additions != 1 || d.Deletions != 0 || !strings.Contains(d.Patch, "+three\n") {
+		t.Fatalf("a.txt diff = %+v", d)
+	}
+
+	diffs, err = repo.Diff(mcpfs.GitDiffOptions{Commit: "HEAD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].Status != "modified" || diffs[1].Path != "dir/b.txt" || diffs[1].Status != "added" {
+		t.Fatalf("Diff(HEAD) = %+v", diffs)
+	}
+	if !strings.Contains(diffs[0].Patch, "-two\n+TWO\n") {
+		t.Fatalf("Diff(HEAD) patch = %q", diffs[0].Patch)
+	}
+}
+
+func TestGitRepo_LogAndBlame(t *testing.T) {
+	root := newGitRepo(t)
+	repo, err := mcpfs.OpenGitRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commits, err := repo.Log(mcpfs.GitLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 2 || commits[0].Author != "Bob" || commits[1].Subject != "Add a" || len(commits[1].Parents) != 0 {
+		t.Fatalf("Log = %+v", commits)
+	}
+	if commits, _ := repo.Log(mcpfs.GitLogOptions{Prefix: "dir"}); len(commits) != 1 {
+		t.Fatalf("Log(dir) = %+v", commits)
+	}
+	if commits, _ := repo.Log(mcpfs.GitLogOptions{Max: 1}); len(commits) != 1 {
+		t.Fatalf("Log(max 1) = %+v", commits)
+	}
+
+	lines, err := repo.Blame("a.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0].Author != "Alice" || lines[1].Author != "Bob" || lines[1].Text != "TWO" {
+		t.Fatalf("Blame = %+v", lines)
+	}
+}
+
+func TestGitTools_RequireReadableWorktree(t *testing.T) {
+	root := newGitRepo(t)
+	app := newTestApp(t, "paths:\n  - path: "+filepath.Join(root, "dir")+"\n    perms: [read]\n")
+	cs := connect(t, app)
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "git_log",
+		Arguments: map[string]any{"path": filepath.Join(root, "dir")},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("git_log with only a subdirectory readable: res=%v err=%v, want tool error", res, err)
+	}
+
+	app = newTestApp(t, "paths:\n  - path: "+root+"\n    perms: [read]\n")
+	cs = connect(t, app)
+	var out mcpfs.GitBlameOutput
+	callTool(t, cs, "git_blame", map[string]any{"path": filepath.Join(root, "a.txt")}, &out)
+	if out.Path != "a.txt" || len(out.Lines) != 2 {
+		t.Fatalf("git_blame = %+v", out)
+	}
+}
+
+func TestGitDiffTool_WithholdsUnreadableFiles(t *testing.T) {
+	root := newGitRepo(t)
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("one\nthree\n"), 0o644)
+	os.WriteFile(filepath.Join(root, ".env"), []byte("TOKEN=hunter2\n"), 0o644)
+	repo, _ := git.PlainOpen(root)
+	wt, _ := repo.Worktree()
+	for _, p := range []string{"a.txt", ".env"} {
+		if _, err := wt.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := newTestApp(t, "paths:\n  - path: "+root+"\n    perms: [read]\n")
+	var out mcpfs.GitDiffOutput
+	callTool(t, connect(t, app), "git_diff", map[string]any{"path": root, "staged": true}, &out)
+	if len(out.Files) != 2 {
+		t.Fatalf("git_diff = %+v", out.Files)
+	}
+	for _, f := range out.Files {
+		switch f.Path {
+		case ".env":
+			if !f.Withheld || f.Patch != "" || f.Additions != 0 {
+				t.Errorf(".env diff = %+v, want it withheld", f)
+			}
+		case "a.txt":
+			if f.Withheld || !strings.Contains(f.Patch, "+three") {
+				t.Errorf("a.txt diff = %+v", f)
+			}
+		}
+	}
+}
+
+func TestGitRepo_StageCommitBranch(t *testing.T) {
+	root := newGitRepo(t)
+	repo, err := mcpfs.OpenGitRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(filepath.Join(root, "c.txt"), []byte("c\n"), 0o644)
+	os.Remove(filepath.Join(root, "dir", "b.txt"))
+	if err := repo.Stage([]string{"c.txt", "dir/b.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	st, _ := repo.Status("")
+	if len(st.Files) != 2 || st.Files[0].Staging != "added" || st.Files[1].Staging != "deleted" {
+		t.Fatalf("Status after Stage = %+v", st.Files)
+	}
+
+	when := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	c, err := repo.Commit("Add c, drop b", mcpfs.GitConfig{AuthorName: "Bot", AuthorEmail: "bot@example.com"}, when)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Author != "Bot" || c.Committer != "Bot" || c.Message != "Add c, drop b\n\n"+mcpfs.DefaultGitTrailer+"\n" {
+		t.Fatalf("Commit = %+v", c)
+	}
+	if _, err := repo.Commit("nothing staged", mcpfs.GitConfig{}, when); !errors.Is(err, mcpfs.ErrGit) {
+		t.Fatalf("empty Commit: err = %v, want ErrGit", err)
+	}
+
+	if _, err := repo.CreateBranch("feature", "HEAD~1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Checkout("feature"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(root, "dir", "b.txt")); got != "b\n" {
+		t.Fatalf("dir/b.txt after checkout = %q", got)
+	}
+	branches, _ := repo.Branches()
+	if len(branches) != 2 || branches[0].Name != "feature" || !branches[0].Current {
+		t.Fatalf("Branches = %+v", branches)
+	}
+	if err := repo.DeleteBranch("feature"); !errors.Is(err, mcpfs.ErrGit) {
+		t.Fatalf("deleting the current branch: err = %v, want ErrGit", err)
+	}
+	if err := repo.DeleteBranch("master"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateBranch("bad..name", ""); !errors.Is(err, mcpfs.ErrGit) {
+		t.Fatalf("invalid branch name: err = %v, want ErrGit", err)
+	}
+}
+
+func TestGitConfig(t *testing.T) {
+	cfg, err := mcpfs.ParseConfigData([]byte("paths:\n  - path: /repo\n    perms: [read, vcs]\ngit:\n  trailer: \"Agent: yes\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.IsAllowed(mcpfs.PermGit, "/repo/x") || cfg.IsAllowed(mcpfs.PermWrite, "/repo/x") {
+		t.Fatalf("perms = %v", cfg.Paths[0].Permissions())
+	}
+	if _, err := mcpfs.ParseConfigData([]byte("paths: []\ngit:\n  trailer: not a trailer\n")); !errors.Is(err, mcpfs.ErrParse) {
+		t.Fatalf("bad trailer: err = %v, want ErrParse", err)
+	}
+}
+
+func TestGitWriteTools_RequireGitPermission(t *testing.T) {
+	root := newGitRepo(t)
+	os.WriteFile(filepath.Join(root, "c.txt"), []byte("c\n"), 0o644)
+
+	app := newTestApp(t, "paths:\n  - path: "+root+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "git_stage",
+		Arguments: map[string]any{"paths": []string{filepath.Join(root, "c.txt")}},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("git_stage without git permission: res=%v err=%v, want tool error", res, err)
+	}
+
+	app = newTestApp(t, "paths:\n  - path: "+root+"\n    perms: [read, git]\ngit:\n  author_name: Agent\n  trailer: \"Agent-Commit: true\"\n")
+	cs = connect(t, app)
+	callTool(t, cs, "git_stage", map[string]any{"paths": []string{filepath.Join(root, "c.txt")}}, nil)
+	var out mcpfs.GitCommitOutput
+	callTool(t, cs, "git_commit", map[string]any{"path": root, "message": "Add c"}, &out)
+	if out.Commit.Author != "Agent" || !strings.HasSuffix(out.Commit.Message, "\n\nAgent-Commit: true\n") {
+		t.Fatalf("git_commit = %+v", out.Commit)
+	}
+	var branches mcpfs.GitBranchOutput
+	callTool(t, cs, "git_branch", map[string]any{"path": root, "action": "create", "name": "topic"}, &branches)
+	if len(branches.Branches) != 2 {
+		t.Fatalf("git_branch = %+v", branches)
+	}
+	res, err = cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "git_branch",
+		Arguments: map[string]any{"path": root, "action": "checkout", "name": "topic"},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("checkout without write permission: res=%v err=%v, want tool error", res, err)
+	}
+}
+
+func TestGitStageTool_ChecksFilesOfDirectories(t *testing.T) {
+	root := newGitRepo(t)
+	dir := filepath.Join(root, "dir")
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("B\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, ".env"), []byte("TOKEN=secret\n"), 0o644)
+
+	app := newTestApp(t, "paths:\n  - path: "+root+"\n    perms: [read, git]\n")
+	cs := connect(t, app)
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "git_stage",
+		Arguments: map[string]any{"paths": []string{dir}},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("git_stage of a directory holding .env: res=%v err=%v, want tool error", res, err)
+	}
+	repo, err := mcpfs.OpenGitRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := repo.Status("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range st.Files {
+		if f.Staging != "unmodified" && f.Staging != "untracked" {
+			t.Errorf("%s was staged: %+v", f.Path, f)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, ".env"))
+	var out mcpfs.GitStageOutput
+	callTool(t, cs, "git_stage", map[string]any{"paths": []string{dir}}, &out)
+	if !slices.Equal(out.Staged, []string{"dir/b.txt"}) {
+		t.Fatalf("git_stage staged %q, want dir/b.txt", out.Staged)
+	}
+}
+
+func TestGitBranchTool_CheckoutGuardedFiles(t *testing.T) {
+	root := newGitRepo(t)
+	repo, err := mcpfs.OpenGitRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateBranch("old", "HEAD~1"); err != nil {
+		t.Fatal(err)
+	}
+	checkout := func(cs *mcp.ClientSession) (*mcp.CallToolResult, error) {
+		return cs.CallTool(context.Background(), &mcp.CallToolParams{
+			Name:      "git_branch",
+			Arguments: map[string]any{"path": root, "action": "checkout", "name": "old"},
+		})
+	}
+
+	// Checking out old changes a.txt and removes dir/b.txt, which a rule
+	// only a transaction can enforce covers.
+	for _, guard := range []string{"max_file_size: 1KiB", "approval: required", "extensions: [txt]"} {
+		app := newTestApp(t, `version: "2.0"
+paths:
+  - path: `+root+`/dir
+    perms: [read, write, git]
+    `+guard+`
+  - path: `+root+`
+    perms: [read, write, git]
+`)
+		res, err := checkout(connect(t, app))
+		if err != nil || !res.IsError {
+			t.Fatalf("checkout over %s: res=%v err=%v, want tool error", guard, res, err)
+		}
+		if got := readFile(t, filepath.Join(root, "dir", "b.txt")); got != "b\n" {
+			t.Fatalf("dir/b.txt after refused checkout = %q", got)
+		}
+	}
+
+	app := newTestApp(t, "paths:\n  - path: "+root+"\n    perms: [read, write, git]\n")
+	var out mcpfs.GitBranchOutput
+	callTool(t, connect(t, app), "git_branch", map[string]any{"path": root, "action": "checkout", "name": "old"}, &out)
+	if _, err := os.Stat(filepath.Join(root, "dir", "b.txt")); !os.IsNotExist(err) {
+		t.Fatalf("dir/b.txt after checkout: %v", err)
+	}
+
+	// Back on master dir/b.txt would replace an untracked file.
+	os.MkdirAll(filepath.Join(root, "dir"), 0o755)
+	os.WriteFile(filepath.Join(root, "dir", "b.txt"), []byte("mine\n"), 0o644)
+	if err := repo.Checkout("master"); !errors.Is(err, mcpfs.ErrGit) || !strings.Contains(err.Error(), "untracked") {
+		t.Fatalf("checkout over an untracked file: err = %v", err)
+	}
+	if got := readFile(t, filepath.Join(root, "dir", "b.txt")); got != "mine\n" {
+		t.Fatalf("untracked dir/b.txt = %q", got)
+	}
+}
@@ -0,0 +1,781 @@
+package mcpfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultGoSearchTimeout bounds how long find_references type-checks the
+// packages of a module.
+const DefaultGoSearchTimeout = 30 * time.Second
+
+// GoSymbol is a declaration in a Go source file.
+type GoSymbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind" jsonschema:"func, method, type, field, var or const"`
+	Parent    string `json:"parent,omitempty" jsonschema:"receiver of a method or type of a field"`
+	Signature string `json:"signature"`
+	Doc       string `json:"doc,omitempty" jsonschema:"first line of the doc comment"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line"`
+	Exported  bool   `json:"exported"`
+}
+
+// GoLocation is a position in a Go source file.
+type GoLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Text   string `json:"text,omitempty" jsonschema:"the source line, trimmed"`
+}
+
+// GoDefinition is the declaration an identifier refers to. Declarations in
+// packages that could not be loaded, such as the standard library or
+// dependencies outside the allowed paths, have no location.
+type GoDefinition struct {
+	Name       string      `json:"name"`
+	Kind       string      `json:"kind"`
+	ImportPath string      `json:"import_path,omitempty"`
+	Signature  string      `json:"signature,omitempty"`
+	Location   *GoLocation `json:"location,omitempty"`
+}
+
+// GoCode navigates the Go sources of one module. Packages of the module are
+// parsed and type-checked from source on demand; imports from outside the
+// module are not loaded, so identifiers from them resolve only by import path
+// and name. Directories for which allow returns false are never read.
+type GoCode struct {
+	// Root is the module root, or the starting directory when there is no
+	// go.mod.
+	Root string
+	// Module is the module path declared in go.mod, if any.
+	Module string
+
+	allow func(path string) bool
+	fset  *token.FileSet
+	ctxt  build.Context
+	pkgs  map[string]*goPackage // by directory
+	src   map[string][]byte     // file contents by name, for location
+}
+
+// goPackage is a parsed directory and its type-checked variants.
+type goPackage struct {
+	dir        string
+	importPath string
+	files      []*ast.File // non-test files
+	tests      []*ast.File // in-package test files
+	xtests     []*ast.File // external test package files
+	pkg        *types.Package
+	info       *types.Info
+	loading    bool
+	err        error
+}
+
+// NewGoCode prepares navigation of the module containing path. allow is
+// called with every directory before it is read; a nil allow permits
+// everything.
+func NewGoCode(path string, allow func(string) bool) (*GoCode, error) {
+	if allow == nil {
+		allow = func(string) bool { return true }
+	}
+	path = cleanAbsPath(path)
+	start := path
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		start = filepath.Dir(path)
+	}
+	g := &GoCode{Root: start, allow: allow, fset: token.NewFileSet(), ctxt: build.Default, pkgs: map[string]*goPackage{}, src: map[string][]byte{}}
+	g.ctxt.CgoEnabled = false
+	for dir := start; ; dir = filepath.Dir(dir) {
+		if !allow(dir) {
+			break
+		}
+		if mod, ok := readModulePath(filepath.Join(dir, "go.mod")); ok {
+			g.Root, g.Module = dir, mod
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return g, nil
+}
+
+func readModulePath(gomod string) (string, bool) {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if rest, ok := strings.CutPrefix(line, "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			return strings.Trim(strings.TrimSpace(rest), `"`), true
+		}
+	}
+	return "", false
+}
+
+// importPath returns the import path of dir within the module.
+func (g *GoCode) importPath(dir string) string {
+	rel, err := filepath.Rel(g.Root, dir)
+	if err != nil || g.Module == "" {
+		return filepath.Base(dir)
+	}
+	if rel == "." {
+		return g.Module
+	}
+	return g.Module + "/" + filepath.ToSlash(rel)
+}
+
+// dirOf returns the directory of a module import path.
+func (g *GoCode) dirOf(importPath string) (string, bool) {
+	if g.Module == "" {
+		return "", false
+	}
+	if importPath == g.Module {
+		return g.Root, true
+	}
+	rest, ok := strings.CutPrefix(importPath, g.Module+"/")
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(g.Root, filepath.FromSlash(rest)), true
+}
+
+// parseDir parses the Go files of dir that match the current build context.
+func (g *GoCode) parseDir(dir string) (*goPackage, error) {
+	if p, ok := g.pkgs[dir]; ok {
+		return p, nil
+	}
+	if !g.allow(dir) {
+		return nil, fmt.Errorf("%w: read %q", ErrPermissionDenied, dir)
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := &goPackage{dir: dir, importPath: g.importPath(dir)}
+	var name string
+	for _, de := range des {
+		fn := de.Name()
+		if de.IsDir() || !strings.HasSuffix(fn, ".go") {
+			continue
+		}
+		if ok, err := g.ctxt.MatchFile(dir, fn); err != nil || !ok {
+			continue
+		}
+		f, err := parser.ParseFile(g.fset, filepath.Join(dir, fn), nil, parser.ParseComments|parser.SkipObjectResolution)
+		if f == nil {
+			return nil, err
+		}
+		isTest := strings.HasSuffix(fn, "_test.go")
+		switch {
+		case isTest && strings.HasSuffix(f.Name.Name, "_test"):
+			p.xtests = append(p.xtests, f)
+		case isTest:
+			p.tests = append(p.tests, f)
+		default:
+			if name == "" {
+				name = f.Name.Name
+			}
+			if f.Name.Name == name {
+				p.files = append(p.files, f)
+			}
+		}
+	}
+	g.pkgs[dir] = p
+	return p, nil
+}
+
+// load type-checks the non-test files of dir.
+func (g *GoCode) load(dir string) (*goPackage, error) {
+	p, err := g.parseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if p.pkg != nil || p.loading {
+		return p, p.err
+	}
+	p.loading = true
+	p.pkg, p.info = g.check(p.importPath, p.files)
+	p.loading = false
+	return p, nil
+}
+
+// check type-checks files as package path. Type errors are ignored so that
+// partially broken code can still be navigated.
+func (g *GoCode) check(path string, files []*ast.File) (*types.Package, *types.Info) {
+	info := &types.Info{
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	conf := types.Config{
+		Importer:    goImporter{g},
+		Error:       func(error) {},
+		FakeImportC: true,
+	}
+	pkg, _ := conf.Check(path, g.fset, files, info)
+	return pkg, info
+}
+
+// goImporter loads packages of the module from source. Other packages are
+// returned empty, leaving their identifiers unresolved.
+type goImporter struct{ g *GoCode }
+
+func (im goImporter) Import(path string) (*types.Package, error) {
+	return im.ImportFrom(path, "", 0)
+}
+
+func (im goImporter) ImportFrom(path, _ string, _ types.ImportMode) (*types.Package, error) {
+	if dir, ok := im.g.dirOf(path); ok {
+		if p, err := im.g.load(dir); err == nil && p.pkg != nil {
+			return p.pkg, nil
+		}
+	}
+	pkg := types.NewPackage(path, pathBase(path))
+	pkg.MarkComplete()
+	return pkg, nil
+}
+
+func pathBase(importPath string) string {
+	base := importPath[strings.LastIndex(importPath, "/")+1:]
+	if strings.HasPrefix(base, "v") && len(base) > 1 && strings.Trim(base[1:], "0123456789") == "" {
+		// major version suffix, e.g. example.com/mod/v2
+		parent := strings.TrimSuffix(importPath, "/"+base)
+		base = parent[strings.LastIndex(parent, "/")+1:]
+	}
+	return base
+}
+
+// fileInfo type-checks the variant of the package containing file: the
+// package itself, the package with its in-package tests, or the external
+// test package. It returns the parsed file and the type information.
+func (g *GoCode) fileInfo(file string) (*ast.File, *types.Info, error) {
+	file = cleanAbsPath(file)
+	p, err := g.load(filepath.Dir(file))
+	if err != nil {
+		return nil, nil, err
+	}
+	find := func(files []*ast.File) *ast.File {
+		for _, f := range files {
+			if g.fset.File(f.Pos()).Name() == file {
+				return f
+			}
+		}
+		return nil
+	}
+	if f := find(p.files); f != nil {
+		return f, p.info, nil
+	}
+	if f := find(p.tests); f != nil {
+		_, info := g.check(p.importPath, append(append([]*ast.File(nil), p.files...), p.tests...))
+		return f, info, nil
+	}
+	if f := find(p.xtests); f != nil {
+		_, info := g.check(p.importPath+"_test", p.xtests)
+		return f, info, nil
+	}
+	return nil, nil, fmt.Errorf("%q is not a Go file of package %q for %s/%s", file, p.importPath, g.ctxt.GOOS, g.ctxt.GOARCH)
+}
+
+// Symbols lists the declarations in the Go file or package directory at
+// path, in source order.
+func (g *GoCode) Symbols(path string) ([]GoSymbol, error) {
+	path = cleanAbsPath(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	if info.IsDir() {
+		p, err := g.parseDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(append(append(files, p.files...), p.tests...), p.xtests...)
+	} else {
+		if !g.allow(path) {
+			return nil, fmt.Errorf("%w: read %q", ErrPermissionDenied, path)
+		}
+		f, err := parser.ParseFile(g.fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if f == nil {
+			return nil, err
+		}
+		files = []*ast.File{f}
+	}
+	syms := []GoSymbol{}
+	for _, f := range files {
+		syms = append(syms, g.fileSymbols(f)...)
+	}
+	return syms, nil
+}
+
+func (g *GoCode) fileSymbols(f *ast.File) []GoSymbol {
+	var syms []GoSymbol
+	add := func(name *ast.Ident, kind, parent, sig string, doc *ast.CommentGroup, end token.Pos) {
+		pos := g.fset.Position(name.Pos())
+		syms = append(syms, GoSymbol{
+			Name:      name.Name,
+			Kind:      kind,
+			Parent:    parent,
+			Signature: sig,
+			Doc:       firstDocLine(doc),
+			File:      pos.Filename,
+			Line:      pos.Line,
+			Column:    pos.Column,
+			EndLine:   g.fset.Position(end).Line,
+			Exported:  name.IsExported(),
+		})
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind, parent := "func", ""
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				kind, parent = "method", recvTypeName(d.Recv.List[0].Type)
+			}
+			add(d.Name, kind, parent, g.funcSignature(d), d.Doc, d.End())
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				doc := d.Doc
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					if s.Doc != nil {
+						doc = s.Doc
+					}
+					add(s.Name, "type", "", g.typeSignature(s), doc, s.End())
+					syms = append(syms, g.memberSymbols(s)...)
+				case *ast.ValueSpec:
+					if s.Doc != nil {
+						doc = s.Doc
+					}
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for i, name := range s.Names {
+						if name.Name == "_" {
+							continue
+						}
+						add(name, kind, "", g.valueSignature(kind, s, i), doc, s.End())
+					}
+				}
+			}
+		}
+	}
+	return syms
+}
+
+// memberSymbols lists the fields of a struct type and the methods of an
+// interface type.
+func (g *GoCode) memberSymbols(s *ast.TypeSpec) []GoSymbol {
+	var (
+		list *ast.FieldList
+		kind string
+	)
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		list, kind = t.Fields, "field"
+	case *ast.InterfaceType:
+		list, kind = t.Methods, "method"
+	default:
+		return nil
+	}
+	var syms []GoSymbol
+	for _, field := range list.List {
+		names := field.Names
+		if len(names) == 0 {
+			// embedded field or interface
+			if id := embeddedName(field.Type); id != nil {
+				names = []*ast.Ident{id}
+			}
+		}
+		for _, name := range names {
+			pos := g.fset.Position(name.Pos())
+			syms = append(syms, GoSymbol{
+				Name:      name.Name,
+				Kind:      kind,
+				Parent:    s.Name.Name,
+				Signature: name.Name + " " + g.nodeString(field.Type),
+				Doc:       firstDocLine(field.Doc),
+				File:      pos.Filename,
+				Line:      pos.Line,
+				Column:    pos.Column,
+				EndLine:   g.fset.Position(field.End()).Line,
+				Exported:  name.IsExported(),
+			})
+		}
+	}
+	return syms
+}
+
+func embeddedName(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	}
+	return nil
+}
+
+func recvTypeName(expr ast.Expr) string {
+	if id := embeddedName(expr); id != nil {
+		return id.Name
+	}
+	return ""
+}
+
+func (g *GoCode) funcSignature(d *ast.FuncDecl) string {
+	return g.nodeString(&ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type})
+}
+
+func (g *GoCode) typeSignature(s *ast.TypeSpec) string {
+	var sb strings.Builder
+	sb.WriteString("type ")
+	sb.WriteString(s.Name.Name)
+	if s.TypeParams != nil {
+		sb.WriteString(strings.TrimPrefix(g.nodeString(&ast.FuncType{TypeParams: s.TypeParams, Params: &ast.FieldList{}}), "func"))
+		// drop the empty parameter list printed for the function type
+		str := strings.TrimSuffix(sb.String(), "()")
+		sb.Reset()
+		sb.WriteString(str)
+	}
+	if s.Assign.IsValid() {
+		sb.WriteString(" =")
+	}
+	sb.WriteByte(' ')
+	switch s.Type.(type) {
+	case *ast.StructType:
+		sb.WriteString("struct")
+	case *ast.InterfaceType:
+		sb.WriteString("interface")
+	default:
+		sb.WriteString(g.nodeString(s.Type))
+	}
+	return sb.String()
+}
+
+func (g *GoCode) valueSignature(kind string, s *ast.ValueSpec, i int) string {
+	sig := kind + " " + s.Names[i].Name
+	if s.Type != nil {
+		sig += " " + g.nodeString(s.Type)
+	}
+	if kind == "const" && i < len(s.Values) {
+		if v := g.nodeString(s.Values[i]); len(v) <= 80 {
+			sig += " = " + v
+		}
+	}
+	return sig
+}
+
+func (g *GoCode) nodeString(node any) string {
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.RawFormat}
+	if err := cfg.Fprint(&buf, g.fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+func firstDocLine(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(doc.Text()), "\n")
+	return line
+}
+
+// identAt returns the identifier of f spanning line and column (1-based,
+// column in bytes).
+func (g *GoCode) identAt(f *ast.File, line, column int) (*ast.Ident, error) {
+	tf := g.fset.File(f.Pos())
+	if line < 1 || line > tf.LineCount() {
+		return nil, fmt.Errorf("line %d is outside %q", line, tf.Name())
+	}
+	pos := tf.LineStart(line) + token.Pos(column-1)
+	var found *ast.Ident
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || found != nil || pos < n.Pos() || pos > n.End() {
+			return false
+		}
+		if id, ok := n.(*ast.Ident); ok && pos < id.End() {
+			found = id
+		}
+		return true
+	})
+	if found == nil {
+		return nil, fmt.Errorf("no identifier at %s:%d:%d", tf.Name(), line, column)
+	}
+	return found, nil
+}
+
+// objectAt resolves the identifier at the position to the object it declares
+// or refers to.
+func (g *GoCode) objectAt(file string, line, column int) (*ast.Ident, types.Object, error) {
+	f, info, err := g.fileInfo(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := g.identAt(f, line, column)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj := info.Defs[id]
+	if obj == nil {
+		obj = info.Uses[id]
+	}
+	if obj == nil {
+		// a selector into a package that was not loaded
+		var pkgName *types.PkgName
+		ast.Inspect(f, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel == id {
+				if x, ok := sel.X.(*ast.Ident); ok {
+					pkgName, _ = info.Uses[x].(*types.PkgName)
+				}
+				return false
+			}
+			return pkgName == nil
+		})
+		if pkgName != nil {
+			obj = types.NewFunc(token.NoPos, pkgName.Imported(), id.Name, nil)
+			return id, &unresolvedObject{obj}, nil
+		}
+		return id, nil, fmt.Errorf("%q at %s:%d:%d does not resolve to a declaration", id.Name, file, line, column)
+	}
+	return id, obj, nil
+}
+
+// unresolvedObject stands for an identifier of a package that was not
+// loaded; only its package and name are known.
+type unresolvedObject struct{ types.Object }
+
+// Definition returns the declaration of the identifier at the position.
+func (g *GoCode) Definition(file string, line, column int) (*GoDefinition, error) {
+	_, obj, err := g.objectAt(file, line, column)
+	if err != nil {
+		return nil, err
+	}
+	def := &GoDefinition{Name: obj.Name(), Kind: objectKind(obj)}
+	if obj.Pkg() != nil {
+		def.ImportPath = obj.Pkg().Path()
+	}
+	if _, ok := obj.(*unresolvedObject); ok {
+		def.Kind = ""
+		return def, nil
+	}
+	def.Signature = types.ObjectString(obj, types.RelativeTo(obj.Pkg()))
+	if obj.Pos().IsValid() {
+		def.Location = g.location(obj.Pos())
+	}
+	return def, nil
+}
+
+func objectKind(obj types.Object) string {
+	switch o := obj.(type) {
+	case *types.Func:
+		if sig, ok := o.Type().(*types.Signature); ok && sig.Recv() != nil {
+			return "method"
+		}
+		return "func"
+	case *types.TypeName:
+		return "type"
+	case *types.Var:
+		if o.IsField() {
+			return "field"
+		}
+		return "var"
+	case *types.Const:
+		return "const"
+	case *types.PkgName:
+		return "package"
+	case *types.Label:
+		return "label"
+	}
+	return "object"
+}
+
+func (g *GoCode) location(pos token.Pos) *GoLocation {
+	p := g.fset.Position(pos)
+	loc := &GoLocation{File: p.Filename, Line: p.Line, Column: p.Column}
+	if tf := g.fset.File(pos); tf != nil {
+		if data, err := g.source(p.Filename); err == nil {
+			start := tf.LineStart(p.Line)
+			end := len(data)
+			if p.Line < tf.LineCount() {
+				end = tf.Offset(tf.LineStart(p.Line + 1))
+			}
+			if off := tf.Offset(start); off <= end && end <= len(data) {
+				loc.Text = strings.TrimSpace(string(data[off:end]))
+			}
+		}
+	}
+	return loc
+}
+
+// source returns the content of file, reading it only the first time.
+func (g *GoCode) source(file string) ([]byte, error) {
+	if data, ok := g.src[file]; ok {
+		return data, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	g.src[file] = data
+	return data, nil
+}
+
+// References returns the uses of the identifier at the position in every
+// package of the module that allow permits, including tests, sorted by file
+// and position. At most limit references are returned when limit > 0; the
+// second result reports whether more were found. The search stops early when
+// ctx is done.
+func (g *GoCode) References(ctx context.Context, file string, line, column, limit int) (*GoDefinition, []GoLocation, bool, error) {
+	_, obj, err := g.objectAt(file, line, column)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	def, err := g.Definition(file, line, column)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	_, unresolved := obj.(*unresolvedObject)
+	if obj.Pkg() == nil || !unresolved && !obj.Pos().IsValid() {
+		return def, nil, false, fmt.Errorf("%q is predeclared", obj.Name())
+	}
+	if obj.Parent() != nil && obj.Parent() != obj.Pkg().Scope() && obj.Parent() != types.Universe {
+		// local objects can only be used in their own file
+		return def, g.usesIn([]string{filepath.Dir(cleanAbsPath(file))}, obj, unresolved, limit), false, nil
+	}
+
+	var dirs []string
+	err = filepath.WalkDir(g.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if p != g.Root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+			return filepath.SkipDir
+		}
+		if p != g.Root {
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir // nested module
+			}
+		}
+		if !g.allow(p) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, p)
+		return ctx.Err()
+	})
+	if err != nil {
+		return def, nil, false, err
+	}
+	var refs []GoLocation
+	for _, dir := range dirs {
+		if err := ctx.Err(); err != nil {
+			return def, refs, true, fmt.Errorf("search stopped after %d references: %w", len(refs), err)
+		}
+		refs = append(refs, g.usesIn([]string{dir}, obj, unresolved, 0)...)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].File != refs[j].File {
+			return refs[i].File < refs[j].File
+		}
+		if refs[i].Line != refs[j].Line {
+			return refs[i].Line < refs[j].Line
+		}
+		return refs[i].Column < refs[j].Column
+	})
+	if limit > 0 && len(refs) > limit {
+		return def, refs[:limit], true, nil
+	}
+	return def, refs, false, nil
+}
+
+// usesIn returns the uses of obj in all variants of the packages in dirs.
+// Objects are matched by their declaring position, which is shared between
+// the variants, or by package path and name when obj is unresolved.
+func (g *GoCode) usesIn(dirs []string, obj types.Object, unresolved bool, limit int) []GoLocation {
+	var refs []GoLocation
+	seen := map[token.Pos]bool{}
+	collect := func(info *types.Info, files []*ast.File) {
+		if !unresolved {
+			// info also covers the files checked along with files; only
+			// uses in files count
+			in := map[*token.File]bool{}
+			for _, f := range files {
+				in[g.fset.File(f.Pos())] = true
+			}
+			for id, use := range info.Uses {
+				if seen[id.Pos()] || use.Pos() != obj.Pos() || use.Name() != obj.Name() || !in[g.fset.File(id.Pos())] {
+					continue
+				}
+				seen[id.Pos()] = true
+				refs = append(refs, *g.location(id.Pos()))
+			}
+			return
+		}
+		for _, f := range files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok || sel.Sel.Name != obj.Name() || seen[sel.Sel.Pos()] {
+					return true
+				}
+				if x, ok := sel.X.(*ast.Ident); ok {
+					if pn, ok := info.Uses[x].(*types.PkgName); ok && pn.Imported().Path() == obj.Pkg().Path() {
+						seen[sel.Sel.Pos()] = true
+						refs = append(refs, *g.location(sel.Sel.Pos()))
+					}
+				}
+				return true
+			})
+		}
+	}
+	for _, dir := range dirs {
+		p, err := g.load(dir)
+		if err != nil || p.info == nil {
+			continue
+		}
+		collect(p.info, p.files)
+		if len(p.tests) > 0 {
+			_, info := g.check(p.importPath, append(append([]*ast.File(nil), p.files...), p.tests...))
+			collect(info, p.tests)
+		}
+		if len(p.xtests) > 0 {
+			_, info := g.check(p.importPath+"_test", p.xtests)
+			collect(info, p.xtests)
+		}
+		if limit > 0 && len(refs) >= limit {
+			break
+		}
+	}
+	return refs
+}
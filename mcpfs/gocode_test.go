@@ -0,0 +1,194 @@
+package mcpfs_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// newGoModule writes a small module with two packages and a test.
+func newGoModule(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"a/a.go": `package a
+
+import "fmt"
+
+// T is a thing.
+type T struct {
+	// Name names it.
+	Name string
+}
+
+// M prints the name.
+func (t *T) M() { fmt.Println(t.Name) }
+
+const Max = 3
+
+// F makes a T.
+func F(name string) *T { return &T{Name: name} }
+`,
+		"a/a_test.go": `package a
+
+import "testing"
+
+func TestF(t *testing.T) { F("x").M() }
+`,
+		"b/b.go": `package b
+
+import (
+	"fmt"
+
+	"example.com/m/a"
+)
+
+func G() {
+	t := a.F("b")
+	t.M()
+	fmt.Println(t.Name, a.Max)
+}
+`,
+	}
+	for p, content := range files {
+		full := filepath.Join(root, p)
+		os.MkdirAll(filepath.Dir(full), 0o755)
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGoCode_Symbols(t *testing.T) {
+	root := newGoModule(t)
+	g, err := mcpfs.NewGoCode(filepath.Join(root, "a"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Root != root || g.Module != "example.com/m" {
+		t.Fatalf("module = %q at %q", g.Module, g.Root)
+	}
+	syms, err := g.Symbols(filepath.Join(root, "a", "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range syms {
+		got = append(got, s.Kind+" "+s.Parent+"."+s.Name+": "+s.Signature)
+	}
+	want := []string{
+		"type .T: type T struct",
+		"field T.Name: Name string",
+		"method T.M: func (t *T) M()",
+		"const .Max: const Max = 3",
+		"func .F: func F(name string) *T",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("symbols:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if syms[0].Doc != "T is a thing." || syms[0].Line != 6 || syms[0].EndLine != 9 {
+		t.Fatalf("T = %+v", syms[0])
+	}
+
+	all, err := g.Symbols(filepath.Join(root, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(want)+1 || all[len(all)-1].Name != "TestF" {
+		t.Fatalf("package symbols = %+v", all)
+	}
+}
+
+func TestGoCode_DefinitionAndReferences(t *testing.T) {
+	root := newGoModule(t)
+	b := filepath.Join(root, "b", "b.go")
+	g, err := mcpfs.NewGoCode(b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// t.M() on line 11 of b.go
+	def, err := g.Definition(b, 11, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Kind != "method" || def.ImportPath != "example.com/m/a" || def.Location == nil ||
+		def.Location.File != filepath.Join(root, "a", "a.go") || def.Location.Line != 12 {
+		t.Fatalf("definition = %+v %+v", def, def.Location)
+	}
+	if !strings.Contains(def.Signature, "func (*T).M()") {
+		t.Fatalf("signature = %q", def.Signature)
+	}
+
+	// fmt is outside the module and only resolves by name
+	def, err = g.Definition(b, 12, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.ImportPath != "fmt" || def.Name != "Println" || def.Location != nil {
+		t.Fatalf("external definition = %+v", def)
+	}
+
+	// references to M from its declaration, including the in-package test
+	a := filepath.Join(root, "a", "a.go")
+	def, refs, truncated, err := g.References(context.Background(), a, 12, 13, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.Name != "M" || truncated {
+		t.Fatalf("definition = %+v, truncated = %v", def, truncated)
+	}
+	var got []string
+	for _, r := range refs {
+		rel, _ := filepath.Rel(root, r.File)
+		got = append(got, rel+": "+r.Text)
+	}
+	want := []string{`a/a_test.go: func TestF(t *testing.T) { F("x").M() }`, "b/b.go: t.M()"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("references:\n%s", strings.Join(got, "\n"))
+	}
+
+	// unreadable directories are skipped
+	g, _ = mcpfs.NewGoCode(a, func(p string) bool { return !strings.HasPrefix(p, filepath.Join(root, "b")) })
+	_, refs, _, err = g.References(context.Background(), a, 12, 13, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 {
+		t.Fatalf("references with b denied = %+v", refs)
+	}
+}
+
+func TestGoTools(t *testing.T) {
+	root := newGoModule(t)
+	app := newTestApp(t, "paths:\n  - path: "+filepath.Join(root, "b")+"\n    perms: [read]\n")
+	cs := connect(t, app)
+
+	var syms mcpfs.ListSymbolsOutput
+	callTool(t, cs, "list_symbols", map[string]any{"path": filepath.Join(root, "b")}, &syms)
+	if len(syms.Symbols) != 1 || syms.Symbols[0].Name != "G" {
+		t.Fatalf("list_symbols = %+v", syms)
+	}
+
+	// package a is not readable, so a.F resolves by import path only
+	var def mcpfs.GoDefinition
+	callTool(t, cs, "find_definition", map[string]any{"path": filepath.Join(root, "b", "b.go"), "line": 10, "column": 9}, &def)
+	if def.ImportPath != "example.com/m/a" || def.Name != "F" || def.Location != nil {
+		t.Fatalf("find_definition = %+v", def)
+	}
+
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "list_symbols",
+		Arguments: map[string]any{"path": filepath.Join(root, "a")},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("list_symbols on an unreadable package: res=%v err=%v, want tool error", res, err)
+	}
+}
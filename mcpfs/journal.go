@@ -0,0 +1,564 @@
+package mcpfs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+)
+
+// Journal retention defaults used when the config leaves them unset.
+const (
+	DefaultJournalMaxAge  = 7 * 24 * time.Hour
+	DefaultJournalMaxSize = ByteSize(256 << 20)
+)
+
+// JournalConfig controls the undo journal.
+// YAML schema:
+//
+//	journal:
+//	  disabled: false   # turn off recording (undo becomes unavailable)
+//	  max_age: 168h     # drop entries older than this (default 7 days)
+//	  max_size: 256MiB  # drop the oldest entries once the journal is larger
+type JournalConfig struct {
+	Disabled bool          `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	MaxAge   time.Duration `yaml:"max_age,omitempty" json:"max_age,omitempty"`
+	MaxSize  ByteSize      `yaml:"max_size,omitempty" json:"max_size,omitempty"`
+}
+
+// JournalOp is the kind of mutation recorded in the journal.
+type JournalOp string
+
+const (
+	JournalWrite  JournalOp = "write"
+	JournalEdit   JournalOp = "edit"
+	JournalMove   JournalOp = "move"
+	JournalDelete JournalOp = "delete"
+	// JournalRestore records a path restored from the trash, which undo
+	// moves back into the trash.
+	JournalRestore JournalOp = "restore"
+)
+
+// JournalEntry records the state of a path before a mutating operation.
+type JournalEntry struct {
+	Seq         int64       `json:"seq"`
+	Session     string      `json:"session"`
+	Op          JournalOp   `json:"op"`
+	Path        string      `json:"path"`
+	Dest        string      `json:"dest,omitempty"`
+	Existed     bool        `json:"existed"`
+	IsDir       bool        `json:"is_dir,omitempty"`
+	Mode        fs.FileMode `json:"mode,omitempty"`
+	DestExisted bool        `json:"dest_existed,omitempty"`
+	Size        int64       `json:"size"`
+	Time        time.Time   `json:"time"`
+	// TrashName is the trash item holding a deleted path, which undo
+	// restores instead of a before-image.
+	TrashName string `json:"trash_name,omitempty"`
+	// Irreversible says why undo cannot revert the entry, such as a write
+	// replacing a socket, of which no before-image can be kept.
+	Irreversible string `json:"irreversible,omitempty"`
+
+	dir string // directory holding the entry and its before-images
+}
+
+const (
+	journalEntryFile = "entry.json"
+	journalBefore    = "before"
+	journalDestPrior = "dest-before"
+)
+
+var unsafeSessionChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Journal stores before-images of mutated paths so operations can be undone.
+// Entries live in <dir>/<session>/<seq>/ and are pruned by age and total size
+// according to JournalConfig, reading time from the injected clock.
+type Journal struct {
+	dir   string
+	clock std.Clock
+	cfg   JournalConfig
+	trash *Trash
+
+	mu sync.Mutex
+}
+
+// DefaultJournalDir returns the journal directory under the user's state path.
+func DefaultJournalDir(env std.Env) (string, error) {
+	dir, err := std.UserStatePath(AppName, env)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal"), nil
+}
+
+// NewJournal returns a journal rooted at dir. The directory is created on the
+// first Record.
+func NewJournal(dir string, clock std.Clock, cfg JournalConfig) *Journal {
+	if clock == nil {
+		clock = std.OsClock{}
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = DefaultJournalMaxAge
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = DefaultJournalMaxSize
+	}
+	return &Journal{dir: dir, clock: clock, cfg: cfg}
+}
+
+// Dir returns the directory the journal is stored in.
+func (j *Journal) Dir() string { return j.dir }
+
+// SetTrash sets the trash that entries recorded with RecordTrashed are
+// restored from.
+func (j *Journal) SetTrash(t *Trash) { j.trash = t }
+
+// Record saves the current state of path (and of dest for moves) before op
+// mutates it. Call it right before performing the operation; if the
+// operation fails, Discard the returned entry.
+func (j *Journal) Record(session string, op JournalOp, path, dest string) (*JournalEntry, error) {
+	if j.cfg.Disabled {
+		return nil, nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	session = sessionDirName(session)
+	seq, err := j.nextSeq(session)
+	if err != nil {
+		return nil, err
+	}
+	return newJournalEntry(filepath.Join(j.dir, session, fmt.Sprintf("%012d", seq)), JournalEntry{
+		Seq:     seq,
+		Session: session,
+		Op:      op,
+		Path:    path,
+		Dest:    dest,
+		Time:    j.clock.Now(),
+	})
+}
+
+// RecordTrashed records the delete of path, which was moved to the trash as
+// item. The entry keeps no copy; undo restores the item.
+func (j *Journal) RecordTrashed(session, path string, item *TrashItem) (*JournalEntry, error) {
+	if j.cfg.Disabled {
+		return nil, nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	session = sessionDirName(session)
+	seq, err := j.nextSeq(session)
+	if err != nil {
+		return nil, err
+	}
+	return newJournalEntry(filepath.Join(j.dir, session, fmt.Sprintf("%012d", seq)), JournalEntry{
+		Seq:       seq,
+		Session:   session,
+		Op:        JournalDelete,
+		Path:      path,
+		Existed:   true,
+		IsDir:     item.IsDir,
+		Time:      j.clock.Now(),
+		TrashName: item.Name,
+	})
+}
+
+// newJournalEntry captures the before-images of e into dir and saves e there.
+func newJournalEntry(dir string, e JournalEntry) (*JournalEntry, error) {
+	e.dir = dir
+	if err := os.MkdirAll(e.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+
+	err := e.capture()
+	if err == nil {
+		err = e.save()
+	}
+	if err != nil {
+		os.RemoveAll(e.dir)
+		return nil, fmt.Errorf("journal: record %s %q: %w", e.Op, e.Path, err)
+	}
+	return &e, nil
+}
+
+// capture copies the before-images the entry needs to be reverted.
+func (e *JournalEntry) capture() error {
+	if e.TrashName != "" {
+		return nil
+	}
+	info, err := os.Lstat(e.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		e.Existed = true
+		e.IsDir = info.IsDir()
+		e.Mode = info.Mode()
+	}
+
+	switch e.Op {
+	case JournalWrite, JournalEdit:
+		switch {
+		case !e.Existed:
+		case info.Mode().IsRegular():
+			n, err := copyFile(e.Path, filepath.Join(e.dir, journalBefore), info.Mode())
+			e.Size = n
+			return err
+		case info.Mode()&(fs.ModeSymlink|fs.ModeNamedPipe) != 0:
+			// A symlink written over rather than followed, or a named
+			// pipe, is replaced by the file written; keep it as it is.
+			_, err := copyTree(e.Path, filepath.Join(e.dir, journalBefore))
+			return err
+		default:
+			e.Irreversible = fmt.Sprintf("it replaced a file of type %s, of which the journal keeps no copy", info.Mode().Type())
+		}
+	case JournalDelete:
+		if !e.Existed {
+			return fmt.Errorf("%q does not exist", e.Path)
+		}
+		n, err := copyTree(e.Path, filepath.Join(e.dir, journalBefore))
+		e.Size = n
+		return err
+	case JournalRestore:
+		if e.Existed {
+			return fmt.Errorf("%q already exists", e.Path)
+		}
+	case JournalMove:
+		if _, err := os.Lstat(e.Dest); err == nil {
+			e.DestExisted = true
+			n, err := copyTree(e.Dest, filepath.Join(e.dir, journalDestPrior))
+			e.Size = n
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown journal op %q", e.Op)
+	}
+	return nil
+}
+
+func (e *JournalEntry) save() error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(e.dir, journalEntryFile), data, 0o600)
+}
+
+// Discard removes an entry whose operation did not happen.
+func (j *Journal) Discard(e *JournalEntry) error {
+	if e == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return os.RemoveAll(e.dir)
+}
+
+// Entries returns the entries of session, newest first.
+func (j *Journal) Entries(session string) ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.entries(sessionDirName(session))
+}
+
+func (j *Journal) entries(session string) ([]JournalEntry, error) {
+	dir := filepath.Join(j.dir, session)
+	des, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []JournalEntry
+	for _, de := range des {
+		if !de.IsDir() {
+			continue
+		}
+		e, err := loadJournalEntry(filepath.Join(dir, de.Name()))
+		if err != nil {
+			// an entry left behind by a crash mid-record; skip it
+			continue
+		}
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Seq > out[b].Seq })
+	return out, nil
+}
+
+func loadJournalEntry(dir string) (*JournalEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, journalEntryFile))
+	if err != nil {
+		return nil, err
+	}
+	var e JournalEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	e.dir = dir
+	return &e, nil
+}
+
+// Sessions lists the sessions with journal entries, most recently active
+// first.
+func (j *Journal) Sessions() ([]string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	des, err := os.ReadDir(j.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	type active struct {
+		name string
+		last time.Time
+	}
+	var sessions []active
+	for _, de := range des {
+		if !de.IsDir() {
+			continue
+		}
+		entries, err := j.entries(de.Name())
+		if err != nil || len(entries) == 0 {
+			continue
+		}
+		sessions = append(sessions, active{name: de.Name(), last: entries[0].Time})
+	}
+	sort.Slice(sessions, func(a, b int) bool { return sessions[a].last.After(sessions[b].last) })
+	names := make([]string, len(sessions))
+	for i, s := range sessions {
+		names[i] = s.name
+	}
+	return names, nil
+}
+
+// Undo reverts the last n operations of session, newest first, and removes
+// their entries. If allow is non-nil it is called for every entry before
+// anything is reverted so callers can enforce permissions. Undo stops at the
+// first entry that cannot be reverted, or that is flagged Irreversible, and
+// returns the entries undone so far.
+func (j *Journal) Undo(session string, n int, allow func(JournalEntry) error) ([]JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries, err := j.entries(sessionDirName(session))
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: nothing to undo for session %q", ErrJournal, session)
+	}
+	if n <= 0 || n > len(entries) {
+		n = len(entries)
+	}
+	entries = entries[:n]
+	// Stop before the first entry that cannot be reverted.
+	var stop error
+	for i, e := range entries {
+		if e.Irreversible != "" {
+			stop = fmt.Errorf("%w: cannot undo %s %q: %s", ErrJournal, e.Op, e.Path, e.Irreversible)
+			entries = entries[:i]
+			break
+		}
+	}
+	if len(entries) == 0 {
+		return nil, stop
+	}
+	if allow != nil {
+		for _, e := range entries {
+			if err := allow(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var done []JournalEntry
+	for _, e := range entries {
+		if err := e.revert(j.trash); err != nil {
+			return done, fmt.Errorf("%w: undo %s %q: %v", ErrJournal, e.Op, e.Path, err)
+		}
+		if err := os.RemoveAll(e.dir); err != nil {
+			return done, err
+		}
+		done = append(done, e)
+	}
+	return done, stop
+}
+
+// restored returns the file or tree reverting e puts back at e.Path, or ""
+// if reverting e removes what is there. A trashed path is in trash. Moves
+// are reverted from e.Dest.
+func (e *JournalEntry) restored(trash *Trash) string {
+	switch {
+	case e.Op == JournalMove:
+		return e.Dest
+	case e.TrashName != "":
+		if trash == nil {
+			return ""
+		}
+		return trash.itemPath(e.TrashName)
+	case e.Existed:
+		return filepath.Join(e.dir, journalBefore)
+	}
+	return ""
+}
+
+// destBefore returns the tree reverting a move puts back at e.Dest, or ""
+// if there is none.
+func (e *JournalEntry) destBefore() string {
+	if e.Op == JournalMove && e.DestExisted {
+		return filepath.Join(e.dir, journalDestPrior)
+	}
+	return ""
+}
+
+// revert restores the state captured by the entry, from trash if the entry
+// is of a trashed path.
+func (e *JournalEntry) revert(trash *Trash) error {
+	if e.Irreversible != "" {
+		return errors.New(e.Irreversible)
+	}
+	before := filepath.Join(e.dir, journalBefore)
+	switch e.Op {
+	case JournalWrite, JournalEdit:
+		if !e.Existed {
+			err := os.Remove(e.Path)
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if e.Mode.IsRegular() {
+			_, err := copyFile(before, e.Path, e.Mode)
+			return err
+		}
+		if _, err := os.Lstat(before); err != nil {
+			return err
+		}
+		if err := os.Remove(e.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		_, err := copyTree(before, e.Path)
+		return err
+	case JournalDelete:
+		if _, err := os.Lstat(e.Path); err == nil {
+			return fmt.Errorf("%q exists again", e.Path)
+		}
+		if e.TrashName != "" {
+			if trash == nil {
+				return fmt.Errorf("the trash holding %q is not available", e.Path)
+			}
+			_, err := trash.Restore(e.TrashName, e.Path)
+			return err
+		}
+		_, err := copyTree(before, e.Path)
+		return err
+	case JournalRestore:
+		if trash == nil {
+			return fmt.Errorf("the trash to return %q to is not available", e.Path)
+		}
+		_, err := trash.Put(e.Path)
+		return err
+	case JournalMove:
+		if _, err := os.Lstat(e.Path); err == nil {
+			return fmt.Errorf("%q exists again", e.Path)
+		}
+		if err := moveTree(e.Dest, e.Path); err != nil {
+			return err
+		}
+		if e.DestExisted {
+			_, err := copyTree(filepath.Join(e.dir, journalDestPrior), e.Dest)
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown journal op %q", e.Op)
+}
+
+// Prune drops entries older than max_age and then the oldest entries until
+// the journal fits in max_size.
+func (j *Journal) Prune() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	des, err := os.ReadDir(j.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var all []JournalEntry
+	for _, de := range des {
+		if !de.IsDir() {
+			continue
+		}
+		entries, err := j.entries(de.Name())
+		if err != nil {
+			return err
+		}
+		all = append(all, entries...)
+	}
+	sort.Slice(all, func(a, b int) bool {
+		if !all[a].Time.Equal(all[b].Time) {
+			return all[a].Time.Before(all[b].Time)
+		}
+		return all[a].Seq < all[b].Seq
+	})
+
+	cutoff := j.clock.Now().Add(-j.cfg.MaxAge)
+	var total int64
+	for _, e := range all {
+		total += e.Size
+	}
+	var errs []error
+	for _, e := range all {
+		if !e.Time.Before(cutoff) && total <= int64(j.cfg.MaxSize) {
+			break
+		}
+		if err := os.RemoveAll(e.dir); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		total -= e.Size
+	}
+	for _, de := range des {
+		// remove now empty session directories; fails harmlessly otherwise
+		os.Remove(filepath.Join(j.dir, de.Name()))
+	}
+	return errors.Join(errs...)
+}
+
+// nextSeq returns the sequence number for a new entry in session.
+func (j *Journal) nextSeq(session string) (int64, error) {
+	des, err := os.ReadDir(filepath.Join(j.dir, session))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	var last int64
+	for _, de := range des {
+		if n, err := strconv.ParseInt(de.Name(), 10, 64); err == nil && n > last {
+			last = n
+		}
+	}
+	return last + 1, nil
+}
+
+func sessionDirName(session string) string {
+	if session == "" {
+		return "default"
+	}
+	return unsafeSessionChars.ReplaceAllString(session, "_")
+}
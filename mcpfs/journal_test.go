@@ -0,0 +1,167 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestJournal_RecordAndUndo(t *testing.T) {
+	work := t.TempDir()
+	clock := std.NewTestClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	j := mcpfs.NewJournal(filepath.Join(t.TempDir(), "journal"), clock, mcpfs.JournalConfig{})
+
+	existing := filepath.Join(work, "notes.txt")
+	if err := os.WriteFile(existing, []byte("original\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(work, "new.txt")
+	moved := filepath.Join(work, "moved.txt")
+	dir := filepath.Join(work, "dir")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "sub", "f"), []byte("deep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	mustRecord := func(op mcpfs.JournalOp, path, dest string) {
+		t.Helper()
+		if _, err := j.Record("s1", op, path, dest); err != nil {
+			t.Fatalf("Record %s %s: %v", op, path, err)
+		}
+		clock.Advance(time.Second)
+	}
+
+	mustRecord(mcpfs.JournalEdit, existing, "")
+	os.WriteFile(existing, []byte("edited\n"), 0o640)
+	mustRecord(mcpfs.JournalWrite, created, "")
+	os.WriteFile(created, []byte("fresh\n"), 0o644)
+	mustRecord(mcpfs.JournalMove, created, moved)
+	os.Rename(created, moved)
+	mustRecord(mcpfs.JournalDelete, dir, "")
+	os.RemoveAll(dir)
+
+	undone, err := j.Undo("s1", 2, nil)
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if len(undone) != 2 || undone[0].Op != mcpfs.JournalDelete || undone[1].Op != mcpfs.JournalMove {
+		t.Fatalf("unexpected undone entries: %+v", undone)
+	}
+	if got := readFile(t, filepath.Join(dir, "sub", "f")); got != "deep" {
+		t.Fatalf("deleted tree not restored: %q", got)
+	}
+	if got := readFile(t, created); got != "fresh\n" {
+		t.Fatalf("move not reverted: %q", got)
+	}
+
+	if _, err := j.Undo("s1", 0, nil); err != nil {
+		t.Fatalf("Undo rest: %v", err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("created file should be removed, stat err = %v", err)
+	}
+	if got := readFile(t, existing); got != "original\n" {
+		t.Fatalf("edit not reverted: %q", got)
+	}
+	if entries, _ := j.Entries("s1"); len(entries) != 0 {
+		t.Fatalf("journal should be empty, has %d entries", len(entries))
+	}
+}
+
+func TestJournal_UndoWriteOverSpecialFiles(t *testing.T) {
+	work := t.TempDir()
+	clock := std.NewTestClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	j := mcpfs.NewJournal(filepath.Join(t.TempDir(), "journal"), clock, mcpfs.JournalConfig{})
+
+	// A write replacing a symlink puts the symlink back on undo.
+	link := filepath.Join(work, "link")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Record("s1", mcpfs.JournalWrite, link, ""); err != nil {
+		t.Fatalf("Record over symlink: %v", err)
+	}
+	os.Remove(link)
+	os.WriteFile(link, []byte("replaced\n"), 0o644)
+	if _, err := j.Undo("s1", 1, nil); err != nil {
+		t.Fatalf("Undo write over symlink: %v", err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != "target.txt" {
+		t.Fatalf("symlink after undo: %q, %v", target, err)
+	}
+
+	// A socket cannot be kept: the entry is flagged and undo refuses it
+	// without reverting anything.
+	sock := filepath.Join(work, "sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("no unix sockets: %v", err)
+	}
+	defer l.Close()
+	e, err := j.Record("s1", mcpfs.JournalWrite, sock, "")
+	if err != nil {
+		t.Fatalf("Record over socket: %v", err)
+	}
+	if e.Irreversible == "" {
+		t.Fatal("write over a socket is not flagged irreversible")
+	}
+	os.Remove(sock)
+	os.WriteFile(sock, []byte("replaced\n"), 0o644)
+	undone, err := j.Undo("s1", 1, nil)
+	if len(undone) != 0 || !errors.Is(err, mcpfs.ErrJournal) || !strings.Contains(err.Error(), "cannot undo write") {
+		t.Fatalf("Undo write over socket = %+v, %v", undone, err)
+	}
+	if got := readFile(t, sock); got != "replaced\n" {
+		t.Fatalf("file over the socket after refused undo = %q", got)
+	}
+}
+
+func TestJournal_PruneByAgeAndSize(t *testing.T) {
+	work := t.TempDir()
+	clock := std.NewTestClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	j := mcpfs.NewJournal(filepath.Join(t.TempDir(), "journal"), clock, mcpfs.JournalConfig{
+		MaxAge:  time.Hour,
+		MaxSize: 15,
+	})
+
+	for i, name := range []string{"a", "b", "c"} {
+		p := filepath.Join(work, name)
+		os.WriteFile(p, []byte("0123456789"), 0o644)
+		if _, err := j.Record("s1", mcpfs.JournalEdit, p, ""); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			clock.Advance(2 * time.Hour)
+		}
+	}
+
+	if err := j.Prune(); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	entries, err := j.Entries("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "a" expired by age, "b" was dropped to fit 15 bytes
+	if len(entries) != 1 || entries[0].Path != filepath.Join(work, "c") {
+		t.Fatalf("unexpected entries after prune: %+v", entries)
+	}
+}
@@ -0,0 +1,693 @@
+package mcpfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultOutlineMaxSize is the largest file file_outline parses.
+	DefaultOutlineMaxSize = 8 << 20
+	// DefaultOutlineTimeout bounds how long file_outline parses a file.
+	DefaultOutlineTimeout = 5 * time.Second
+	// maxOutlineNodes caps the number of nodes in an outline.
+	maxOutlineNodes = 5000
+)
+
+// OutlineNode is an entry of a file outline: a declaration, heading, key or
+// table spanning Line to EndLine (1-based, inclusive).
+type OutlineNode struct {
+	Name     string        `json:"name"`
+	Kind     string        `json:"kind"`
+	Detail   string        `json:"detail,omitempty" jsonschema:"signature or short value"`
+	Line     int           `json:"line"`
+	EndLine  int           `json:"end_line"`
+	Children []OutlineNode `json:"children,omitempty"`
+}
+
+// Outline is the hierarchical shape of a file.
+type Outline struct {
+	Language  string        `json:"language"`
+	Nodes     []OutlineNode `json:"nodes"`
+	Truncated bool          `json:"truncated,omitempty" jsonschema:"whether nodes were dropped because of the depth or node limit"`
+}
+
+// OutlineLanguage returns the outline language for path by its extension,
+// or "" if outlines are not supported for it.
+func OutlineLanguage(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".go":
+		return "go"
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return "markdown"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	case ".toml":
+		return "toml"
+	}
+	return ""
+}
+
+// FileOutline builds the outline of data, the content of path. maxDepth
+// limits the nesting of the result when positive. Parsing is abandoned when
+// ctx is done: the parsers check it as they go.
+func FileOutline(ctx context.Context, path string, data []byte, maxDepth int) (*Outline, error) {
+	lang := OutlineLanguage(path)
+	var parse func(context.Context, string, []byte) ([]OutlineNode, error)
+	switch lang {
+	case "go":
+		parse = goOutline
+	case "markdown":
+		parse = markdownOutline
+	case "yaml":
+		parse = yamlOutline
+	case "json":
+		parse = jsonOutline
+	case "toml":
+		parse = tomlOutline
+	default:
+		return nil, fmt.Errorf("no outline support for %q", filepath.Base(path))
+	}
+	if len(data) > DefaultOutlineMaxSize {
+		return nil, fmt.Errorf("%q is %s, larger than the outline limit of %s",
+			path, ByteSize(len(data)), ByteSize(DefaultOutlineMaxSize))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("outline of %q: %w", path, err)
+	}
+	nodes, err := parse(ctx, path, data)
+	if err != nil {
+		return nil, fmt.Errorf("outline of %q: %w", path, err)
+	}
+	out := &Outline{Language: lang}
+	budget := maxOutlineNodes
+	out.Nodes, out.Truncated = pruneOutline(nodes, 1, maxDepth, &budget)
+	if out.Nodes == nil {
+		out.Nodes = []OutlineNode{}
+	}
+	return out, nil
+}
+
+// pruneOutline drops nodes deeper than maxDepth and beyond the budget.
+func pruneOutline(nodes []OutlineNode, depth, maxDepth int, budget *int) ([]OutlineNode, bool) {
+	truncated := false
+	var out []OutlineNode
+	for _, n := range nodes {
+		if *budget <= 0 {
+			return out, true
+		}
+		*budget--
+		if maxDepth > 0 && depth >= maxDepth {
+			truncated = truncated || len(n.Children) > 0
+			n.Children = nil
+		} else {
+			var t bool
+			n.Children, t = pruneOutline(n.Children, depth+1, maxDepth, budget)
+			truncated = truncated || t
+		}
+		out = append(out, n)
+	}
+	return out, truncated
+}
+
+// goOutline lists the declarations of a Go file with fields, interface
+// methods and methods nested under their types. go/parser cannot be
+// interrupted, but it runs in time linear in the size of the file, which
+// FileOutline bounds.
+func goOutline(ctx context.Context, path string, data []byte) ([]OutlineNode, error) {
+	g := &GoCode{fset: token.NewFileSet()}
+	f, err := parser.ParseFile(g.fset, path, data, parser.ParseComments|parser.SkipObjectResolution)
+	if f == nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	syms := g.fileSymbols(f)
+	types := map[string]int{}
+	var nodes []OutlineNode
+	for _, s := range syms {
+		if s.Kind == "type" {
+			types[s.Name] = len(nodes)
+		}
+		if s.Kind == "type" || s.Parent == "" {
+			nodes = append(nodes, OutlineNode{Name: s.Name, Kind: s.Kind, Detail: s.Signature, Line: s.Line, EndLine: s.EndLine})
+		}
+	}
+	for _, s := range syms {
+		if s.Parent == "" {
+			continue
+		}
+		n := OutlineNode{Name: s.Name, Kind: s.Kind, Detail: s.Signature, Line: s.Line, EndLine: s.EndLine}
+		if i, ok := types[s.Parent]; ok {
+			nodes[i].Children = append(nodes[i].Children, n)
+		} else {
+			// method of a type declared in another file
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+// markdownOutline nests ATX and setext headings by level. Headings inside
+// fenced code blocks and front matter are ignored.
+func markdownOutline(ctx context.Context, _ string, data []byte) ([]OutlineNode, error) {
+	lines := splitLines(data)
+	type heading struct {
+		level int
+		node  OutlineNode
+	}
+	var flat []heading
+	fence := ""
+	start := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+				start = i + 1
+				break
+			}
+		}
+	}
+	for i := start; i < len(lines); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		line := lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if fence != "" {
+			if indent < 4 && strings.HasPrefix(trimmed, fence) && strings.Trim(strings.TrimSpace(trimmed), fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if indent < 4 && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+			fence = trimmed[:n]
+			continue
+		}
+		if indent >= 4 {
+			continue
+		}
+		if level := len(trimmed) - len(strings.TrimLeft(trimmed, "#")); level >= 1 && level <= 6 &&
+			(len(trimmed) == level || trimmed[level] == ' ' || trimmed[level] == '\t') {
+			text := strings.TrimSpace(trimmed[level:])
+			if closing := strings.TrimRight(text, "#"); closing != text && (closing == "" || strings.HasSuffix(closing, " ")) {
+				text = strings.TrimSpace(closing)
+			}
+			flat = append(flat, heading{level, OutlineNode{Name: text, Kind: "h" + strconv.Itoa(level), Line: i + 1}})
+			continue
+		}
+		if i > start && (isSetextUnderline(trimmed, '=') || isSetextUnderline(trimmed, '-')) {
+			prev := strings.TrimSpace(lines[i-1])
+			if prev == "" || len(flat) > 0 && flat[len(flat)-1].node.Line == i {
+				continue
+			}
+			level := 1
+			if trimmed[0] == '-' {
+				level = 2
+			}
+			flat = append(flat, heading{level, OutlineNode{Name: prev, Kind: "h" + strconv.Itoa(level), Line: i}})
+		}
+	}
+
+	// A heading ends before the next heading of the same or a higher level.
+	for i := range flat {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		flat[i].node.EndLine = len(lines)
+		for _, next := range flat[i+1:] {
+			if next.level <= flat[i].level {
+				flat[i].node.EndLine = next.node.Line - 1
+				break
+			}
+		}
+	}
+	var build func(lo, hi int) []OutlineNode
+	build = func(lo, hi int) []OutlineNode {
+		var nodes []OutlineNode
+		for i := lo; i < hi; {
+			j := i + 1
+			for j < hi && flat[j].level > flat[i].level {
+				j++
+			}
+			n := flat[i].node
+			n.Children = build(i+1, j)
+			nodes = append(nodes, n)
+			i = j
+		}
+		return nodes
+	}
+	return build(0, len(flat)), nil
+}
+
+func isSetextUnderline(s string, c byte) bool {
+	s = strings.TrimSpace(s)
+	return s != "" && strings.Trim(s, string(c)) == ""
+}
+
+func splitLines(data []byte) []string {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	return lines
+}
+
+// yamlOutline lists the keys of every document. Sequence items appear only
+// when they are collections themselves.
+func yamlOutline(ctx context.Context, _ string, data []byte) ([]OutlineNode, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []OutlineNode
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		root := &doc
+		if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+			root = root.Content[0]
+		}
+		children, err := yamlChildren(ctx, root)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, OutlineNode{
+			Name:     fmt.Sprintf("document %d", len(docs)+1),
+			Kind:     "document",
+			Detail:   yamlDetail(root),
+			Line:     doc.Line,
+			EndLine:  yamlLastLine(root),
+			Children: children,
+		})
+	}
+	if len(docs) == 1 {
+		return docs[0].Children, nil
+	}
+	return docs, nil
+}
+
+func yamlChildren(ctx context.Context, n *yaml.Node) ([]OutlineNode, error) {
+	if n.Kind == yaml.AliasNode {
+		return nil, nil // do not expand aliases
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var nodes []OutlineNode
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			children, err := yamlChildren(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, OutlineNode{
+				Name:     k.Value,
+				Kind:     "key",
+				Detail:   yamlDetail(v),
+				Line:     k.Line,
+				EndLine:  max(yamlLastLine(v), k.Line),
+				Children: children,
+			})
+		}
+	case yaml.SequenceNode:
+		for i, v := range n.Content {
+			if v.Kind != yaml.MappingNode && v.Kind != yaml.SequenceNode {
+				continue
+			}
+			children, err := yamlChildren(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, OutlineNode{
+				Name:     "[" + strconv.Itoa(i) + "]",
+				Kind:     "item",
+				Detail:   yamlDetail(v),
+				Line:     v.Line,
+				EndLine:  yamlLastLine(v),
+				Children: children,
+			})
+		}
+	}
+	return nodes, nil
+}
+
+func yamlDetail(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return fmt.Sprintf("mapping (%d keys)", len(n.Content)/2)
+	case yaml.SequenceNode:
+		return fmt.Sprintf("sequence (%d items)", len(n.Content))
+	case yaml.AliasNode:
+		return "*" + n.Value
+	case yaml.ScalarNode:
+		return outlineValue(n.Value)
+	}
+	return ""
+}
+
+func yamlLastLine(n *yaml.Node) int {
+	line := n.Line
+	if n.Kind == yaml.ScalarNode && (n.Style&(yaml.LiteralStyle|yaml.FoldedStyle)) != 0 {
+		line += strings.Count(strings.TrimRight(n.Value, "\n"), "\n") + 1
+	}
+	for _, c := range n.Content {
+		line = max(line, yamlLastLine(c))
+	}
+	return line
+}
+
+// outlineValue shortens a scalar value for display.
+func outlineValue(s string) string {
+	s, _, cut := strings.Cut(s, "\n")
+	if len(s) > 60 {
+		s, cut = s[:60], true
+	}
+	if cut {
+		s += "…"
+	}
+	return s
+}
+
+// jsonOutline lists the keys of a JSON document. Array items appear only
+// when they are objects or arrays.
+func jsonOutline(ctx context.Context, _ string, data []byte) ([]OutlineNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	lineAt := newLineIndex(data)
+	var value func() (OutlineNode, error)
+	value = func() (OutlineNode, error) {
+		if err := ctx.Err(); err != nil {
+			return OutlineNode{}, err
+		}
+		tok, err := dec.Token()
+		if err != nil {
+			return OutlineNode{}, err
+		}
+		line := lineAt(dec.InputOffset() - 1)
+		n := OutlineNode{Line: line, EndLine: line}
+		switch tok {
+		case json.Delim('{'):
+			count := 0
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return n, err
+				}
+				keyLine := lineAt(dec.InputOffset() - 1)
+				child, err := value()
+				if err != nil {
+					return n, err
+				}
+				child.Name, child.Kind, child.Line = fmt.Sprint(key), "key", keyLine
+				n.Children = append(n.Children, child)
+				count++
+			}
+			n.Detail = fmt.Sprintf("object (%d keys)", count)
+		case json.Delim('['):
+			count := 0
+			for dec.More() {
+				child, err := value()
+				if err != nil {
+					return n, err
+				}
+				if child.Children != nil || strings.HasPrefix(child.Detail, "object") || strings.HasPrefix(child.Detail, "array") {
+					child.Name, child.Kind = "["+strconv.Itoa(count)+"]", "item"
+					n.Children = append(n.Children, child)
+				}
+				count++
+			}
+			n.Detail = fmt.Sprintf("array (%d items)", count)
+		default:
+			n.Detail = outlineValue(fmt.Sprint(tok))
+			if tok == nil {
+				n.Detail = "null"
+			}
+			return n, nil
+		}
+		if _, err := dec.Token(); err != nil { // closing delimiter
+			return n, err
+		}
+		n.EndLine = lineAt(dec.InputOffset() - 1)
+		return n, nil
+	}
+	root, err := value()
+	if err != nil {
+		return nil, err
+	}
+	return root.Children, nil
+}
+
+// newLineIndex returns a function mapping byte offsets of data to 1-based
+// line numbers.
+func newLineIndex(data []byte) func(int64) int {
+	var starts []int64
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, int64(i))
+		}
+	}
+	return func(off int64) int {
+		lo, hi := 0, len(starts)
+		for lo < hi {
+			mid := (lo + hi) / 2
+			if starts[mid] < off {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		return lo + 1
+	}
+}
+
+// tomlOutline nests tables by their dotted names and lists the keys of each
+// table. Multi-line strings and arrays are skipped over.
+func tomlOutline(ctx context.Context, _ string, data []byte) ([]OutlineNode, error) {
+	type node struct {
+		OutlineNode
+		children []*node
+	}
+	lines := splitLines(data)
+	root := &node{}
+	// tables maps dotted table names to their node; array tables map to
+	// their most recent element.
+	tables := map[string]*node{"": root}
+	var table func(path []string, line int) *node
+	table = func(path []string, line int) *node {
+		name := strings.Join(path, ".")
+		if n, ok := tables[name]; ok {
+			return n
+		}
+		parent := table(path[:len(path)-1], line)
+		n := &node{OutlineNode: OutlineNode{Name: path[len(path)-1], Kind: "table", Line: line}}
+		parent.children = append(parent.children, n)
+		tables[name] = n
+		return n
+	}
+
+	current := root
+	for i := 0; i < len(lines); i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			array := strings.HasPrefix(line, "[[")
+			end := strings.Index(line, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated table header", i+1)
+			}
+			path, err := tomlKeyPath(strings.TrimLeft(line[:end], "[ \t"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			if current != root {
+				current.EndLine = i
+			}
+			if array {
+				parent := table(path[:len(path)-1], i+1)
+				idx := 0
+				for _, c := range parent.children {
+					if c.Name == path[len(path)-1] && c.Kind == "array_table" {
+						idx++
+					}
+				}
+				current = &node{OutlineNode: OutlineNode{Name: path[len(path)-1], Kind: "array_table", Detail: "[" + strconv.Itoa(idx) + "]", Line: i + 1}}
+				parent.children = append(parent.children, current)
+				tables[strings.Join(path, ".")] = current
+			} else {
+				current = table(path, i+1)
+				current.Line = i + 1 // may have been created implicitly by a subtable
+			}
+			continue
+		}
+
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		path, err := tomlKeyPath(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		val = strings.TrimSpace(val)
+		start := i
+		i = tomlValueEnd(lines, i, val)
+		detail := outlineValue(val)
+		if i > start && !strings.HasSuffix(detail, "…") {
+			detail += "…"
+		}
+		current.children = append(current.children, &node{OutlineNode: OutlineNode{
+			Name:    strings.Join(path, "."),
+			Kind:    "key",
+			Detail:  detail,
+			Line:    start + 1,
+			EndLine: i + 1,
+		}})
+	}
+	if current != root {
+		current.EndLine = len(lines)
+	}
+
+	var convert func(ns []*node) []OutlineNode
+	convert = func(ns []*node) []OutlineNode {
+		var out []OutlineNode
+		for _, n := range ns {
+			o := n.OutlineNode
+			o.Children = convert(n.children)
+			// tables only created implicitly end with their last subtable
+			for _, c := range o.Children {
+				o.EndLine = max(o.EndLine, c.EndLine)
+			}
+			out = append(out, o)
+		}
+		return out
+	}
+	return convert(root.children), nil
+}
+
+// tomlKeyPath splits a dotted TOML key, honoring quoted parts.
+func tomlKeyPath(key string) ([]string, error) {
+	var (
+		path []string
+		part strings.Builder
+	)
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; c {
+		case '"', '\'':
+			end := strings.IndexByte(key[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted key %s", key)
+			}
+			quoted := key[i : i+end+2]
+			if c == '"' {
+				if s, err := strconv.Unquote(quoted); err == nil {
+					quoted = s
+				} else {
+					quoted = quoted[1 : len(quoted)-1]
+				}
+			} else {
+				quoted = quoted[1 : len(quoted)-1]
+			}
+			part.WriteString(quoted)
+			i += end + 1
+		case '.':
+			path = append(path, strings.TrimSpace(part.String()))
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	path = append(path, strings.TrimSpace(part.String()))
+	for _, p := range path {
+		if p == "" && len(path) > 1 {
+			return nil, fmt.Errorf("empty part in key %s", key)
+		}
+	}
+	return path, nil
+}
+
+// tomlValueEnd returns the index of the last line of the value starting on
+// line i, following multi-line strings and arrays.
+func tomlValueEnd(lines []string, i int, val string) int {
+	for _, delim := range []string{`"""`, "'''"} {
+		if strings.HasPrefix(val, delim) {
+			if strings.Contains(val[3:], delim) {
+				return i
+			}
+			for j := i + 1; j < len(lines); j++ {
+				if strings.Contains(lines[j], delim) {
+					return j
+				}
+			}
+			return len(lines) - 1
+		}
+	}
+	depth := 0
+	for j := i; j < len(lines); j++ {
+		text := val
+		if j > i {
+			text = lines[j]
+		}
+		depth += tomlBracketDepth(text)
+		if depth <= 0 {
+			return j
+		}
+	}
+	return len(lines) - 1
+}
+
+// tomlBracketDepth returns the change in array and inline table nesting
+// over a line, ignoring strings and comments.
+func tomlBracketDepth(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return depth
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth
+}
@@ -0,0 +1,206 @@
+package mcpfs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+// renderOutline prints one node per line as "name kind line-end", indented
+// by depth.
+func renderOutline(nodes []mcpfs.OutlineNode, depth int, sb *strings.Builder) {
+	for _, n := range nodes {
+		fmt.Fprintf(sb, "%s%s %s %d-%d\n", strings.Repeat("  ", depth), n.Name, n.Kind, n.Line, n.EndLine)
+		renderOutline(n.Children, depth+1, sb)
+	}
+}
+
+func TestFileOutline(t *testing.T) {
+	tests := []struct {
+		path, data, want string
+	}{
+		{"x.go", `package x
+
+type T struct {
+	A int
+}
+
+func (t T) M() {}
+
+func F() {}
+
+func (u *U) N() {}
+`, `
+T type 3-5
+  A field 4-4
+  M method 7-7
+F func 9-9
+N method 11-11
+`},
+		{"README.md", `# Title
+
+intro
+
+## Install
+` + "```sh\n# not a heading\n```" + `
+
+Usage
+-----
+
+### Flags
+
+# Other
+`, `
+Title h1 1-14
+  Install h2 5-9
+  Usage h2 10-14
+    Flags h3 13-14
+Other h1 15-15
+`},
+		{"c.yaml", `# comment
+server:
+  port: 80
+  hosts:
+    - name: a
+    - b
+log: info
+`, `
+server key 2-6
+  port key 3-3
+  hosts key 4-6
+    [0] item 5-5
+      name key 5-5
+log key 7-7
+`},
+		{"c.json", `{
+  "a": {
+    "b": [1, 2],
+    "c": [{"d": true}]
+  },
+  "e": "x"
+}`, `
+a key 2-5
+  b key 3-3
+  c key 4-4
+    [0] item 4-4
+      d key 4-4
+e key 6-6
+`},
+		{"c.toml", `title = "x"
+
+[server]
+port = 80
+hosts = [
+  "a",
+]
+
+[server.tls]
+cert = """
+pem
+"""
+
+[[plugins]]
+name = "a"
+
+[[plugins]]
+name = "b"
+`, `
+title key 1-1
+server table 3-13
+  port key 4-4
+  hosts key 5-7
+  tls table 9-13
+    cert key 10-12
+plugins array_table 14-16
+  name key 15-15
+plugins array_table 17-18
+  name key 18-18
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			out, err := mcpfs.FileOutline(context.Background(), tt.path, []byte(tt.data), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sb strings.Builder
+			renderOutline(out.Nodes, 0, &sb)
+			if got, want := sb.String(), strings.TrimPrefix(tt.want, "\n"); got != want {
+				t.Fatalf("outline:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestFileOutline_Limits(t *testing.T) {
+	out, err := mcpfs.FileOutline(context.Background(), "c.yaml", []byte("a:\n  b:\n    c: 1\n"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Nodes) != 1 || out.Nodes[0].Children != nil || !out.Truncated {
+		t.Fatalf("depth 1 outline = %+v", out)
+	}
+
+	if _, err := mcpfs.FileOutline(context.Background(), "x.txt", nil, 0); err == nil {
+		t.Fatal("outline of an unsupported file succeeded")
+	}
+	big := make([]byte, mcpfs.DefaultOutlineMaxSize+1)
+	if _, err := mcpfs.FileOutline(context.Background(), "big.json", big, 0); err == nil {
+		t.Fatal("outline of an oversized file succeeded")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mcpfs.FileOutline(ctx, filepath.Join("dir", "x.go"), []byte(strings.Repeat("func f() {}\n", 100000)), 0); err == nil {
+		t.Fatal("outline with a cancelled context succeeded")
+	}
+}
+
+// expiringContext is done from the second time Err is asked on, so that it
+// expires once parsing has started.
+type expiringContext struct {
+	context.Context
+	calls int
+}
+
+func (c *expiringContext) Err() error {
+	if c.calls++; c.calls > 1 {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func TestFileOutline_StopsParsing(t *testing.T) {
+	for path, data := range map[string]string{
+		"x.go":   strings.Repeat("func f() {}\n", 1000),
+		"x.md":   strings.Repeat("# a\n\ntext\n", 1000),
+		"x.yaml": strings.Repeat("a:\n  b: 1\n", 1000),
+		"x.json": "[" + strings.Repeat("{\"a\": 1},", 1000) + "{}]",
+		"x.toml": strings.Repeat("[a]\nb = 1\n", 1000),
+	} {
+		_, err := mcpfs.FileOutline(&expiringContext{Context: context.Background()}, path, []byte(data), 0)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("outline of %s after the deadline = %v", path, err)
+		}
+	}
+}
+
+func TestFileOutlineTool(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(path, []byte("# A\n## B\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, "paths:\n  - path: "+dir+"\n    perms: [read]\n")
+	cs := connect(t, app)
+	var out mcpfs.Outline
+	callTool(t, cs, "file_outline", map[string]any{"path": path}, &out)
+	if out.Language != "markdown" || len(out.Nodes) != 1 || len(out.Nodes[0].Children) != 1 || out.Nodes[0].Children[0].Name != "B" {
+		t.Fatalf("file_outline = %+v", out)
+	}
+}
@@ -0,0 +1,308 @@
+package mcpfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Access is an operation the policy evaluator decides on.
+type Access struct {
+	Op   Permission
+	Path string
+	// Principal is the identity the access is for, as the policy expression
+	// sees it; tools set it from App.Principal. Empty is the user the server
+	// runs as.
+	Principal string
+
+	// Content is the content a write produces. Without it the content is
+	// that of ContentPath, or of the file at Path.
+	Content []byte
+	// ContentPath is a file holding the content a write produces, such as
+	// the staged copy of a transaction or the source of a move.
+	ContentPath string
+}
+
+// Decision is the outcome of evaluating an Access.
+type Decision struct {
+	Access Access
+	// Rule is the rule granting the access, or nil if it is denied.
+	Rule *PathRule
+	// Reason says why rules covering the path did not grant the access, or
+	// is empty if no rule covers it.
+	Reason string
+}
+
+// Allowed reports whether the access is granted.
+func (d Decision) Allowed() bool {
+	return d.Rule != nil
+}
+
+// Err returns nil if the access is granted and an error wrapping
+// ErrPermissionDenied otherwise.
+func (d Decision) Err() error {
+	if d.Allowed() {
+		return nil
+	}
+	var action string
+	switch d.Access.Op {
+	case PermWrite:
+		action = "write to"
+	case PermGit:
+		action = "git on"
+	default:
+		action = d.Access.Op.String()
+	}
+	if d.Reason == "" {
+		return fmt.Errorf("%w: %s %q", ErrPermissionDenied, action, d.Access.Path)
+	}
+	return fmt.Errorf("%w: %s %q: %s", ErrPermissionDenied, action, d.Access.Path, d.Reason)
+}
+
+// Evaluate decides an access. It is granted by the first rule that grants
+// the operation, covers the path, is active by its schedule and whose
+// content predicates hold for the file. Sensitive paths, such as ~/.ssh,
+// .env files and the config files, are granted only by rules that set
+// unsafe_allow_sensitive; see Config.SensitivePaths. So is write on a
+// directory containing one, which would move, delete or replace it along
+// with the directory. An access a rule grants is finally subject to the
+// policy expression, if there is one. IsAllowed, MatchRule and the tools
+// all decide through Evaluate.
+//
+// Paths are compared with their symlinks resolved, so a link in a granted
+// directory grants nothing outside it.
+//
+// Rules with content predicates apply to regular files only. The file is
+// inspected only if such a rule covers the path; a file that does not exist
+// yet is judged by its extension and has size zero.
+func (c *Config) Evaluate(acc Access) Decision {
+	d := Decision{Access: acc}
+	if c == nil {
+		return d
+	}
+	// A symlink is decided on both where it is and what it points to: the
+	// first is what a move or delete changes, the second what a read or
+	// write reaches.
+	// The names of the path as given count for sensitive names too.
+	name := sensitiveName(cleanAbsPath(acc.Path))
+	loc := resolveParent(acc.Path)
+	if real := resolvePath(loc); real != loc {
+		if ld := c.evaluate(acc, loc, name); ld.Rule == nil {
+			return ld
+		}
+		return c.evaluate(acc, real, name)
+	}
+	return c.evaluate(acc, loc, name)
+}
+
+// evaluate is Evaluate for the access at path, whose symlinks are resolved.
+// name is a sensitive name pattern matched by the path as given, or "".
+func (c *Config) evaluate(acc Access, path, name string) Decision {
+	d := Decision{Access: acc}
+	target := &accessTarget{Access: acc, path: path}
+	sensitive := c.sensitiveMatch(target.path)
+	if sensitive == "" {
+		sensitive = name
+	}
+	if sensitive == "" && acc.Op == PermWrite {
+		sensitive = c.sensitiveBelow(target.path)
+	}
+	now := c.Now()
+	for i := range c.Paths {
+		r := &c.Paths[i]
+		if r.parsedPerms&acc.Op == 0 || !r.covers(target.path) {
+			continue
+		}
+		var why string
+		if sensitive != "" && !r.UnsafeAllowSensitive {
+			why = r.sensitiveReason(target.path, sensitive)
+		} else if !r.ActiveAt(now) {
+			why = fmt.Sprintf("rule %s is not active now", r.cleanPath)
+		} else {
+			why = r.checkContent(target)
+		}
+		if why != "" {
+			if d.Reason == "" {
+				d.Reason = why
+			}
+			continue
+		}
+		d.Rule, d.Reason = r, ""
+		if why := c.checkPolicy(target, r, now); why != "" {
+			d.Rule, d.Reason = nil, why
+		}
+		return d
+	}
+	return d
+}
+
+// checkPolicy returns why the policy expression denies an access r grants,
+// or "" if it allows it. An expression that fails to evaluate denies.
+func (c *Config) checkPolicy(t *accessTarget, r *PathRule, now time.Time) string {
+	if c.policy == nil {
+		return ""
+	}
+	in := PolicyInput{Principal: t.Principal, Op: t.Op, Path: t.path, Time: now, Rule: r}
+	if in.Principal == "" {
+		in.Principal = c.principal
+	}
+	if c.policy.UsesFile() {
+		if err := t.load(); err != nil {
+			return fmt.Sprintf("policy: %v", err)
+		}
+		in.File = &PolicyFile{Exists: t.exists, IsDir: t.dir, Size: t.size, Mime: t.mime}
+	}
+	ok, err := c.policy.Eval(in)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("policy: %v", err)
+	case !ok:
+		return "denied by policy"
+	}
+	return ""
+}
+
+// hasContentPredicates reports whether the rule restricts the files it
+// applies to.
+func (r *PathRule) hasContentPredicates() bool {
+	return len(r.extensions) > 0 || len(r.mimeTypes) > 0 || r.MaxSize > 0
+}
+
+// checkContent returns why the content predicates of the rule do not hold
+// for target, or "" if they do.
+func (r *PathRule) checkContent(t *accessTarget) string {
+	if !r.hasContentPredicates() {
+		return ""
+	}
+	if len(r.extensions) > 0 {
+		name := strings.ToLower(filepath.Base(t.path))
+		if !slices.ContainsFunc(r.extensions, func(ext string) bool { return strings.HasSuffix(name, ext) }) {
+			return fmt.Sprintf("%s does not have one of the extensions %s of rule %s", filepath.Base(t.path), strings.Join(r.extensions, " "), r.cleanPath)
+		}
+	}
+	if err := t.load(); err != nil {
+		return fmt.Sprintf("rule %s: %v", r.cleanPath, err)
+	}
+	if !t.file {
+		return fmt.Sprintf("rule %s applies to files only", r.cleanPath)
+	}
+	if r.MaxSize > 0 && t.size > int64(r.MaxSize) {
+		return fmt.Sprintf("size %s exceeds max_size %s of rule %s", ByteSize(t.size), r.MaxSize, r.cleanPath)
+	}
+	if len(r.mimeTypes) > 0 && !slices.ContainsFunc(r.mimeTypes, func(p string) bool { return mimeTypeMatch(p, t.mime) }) {
+		typ := t.mime
+		if typ == "" {
+			typ = "unknown type"
+		}
+		return fmt.Sprintf("%s is not one of the mime_types %s of rule %s", typ, strings.Join(r.mimeTypes, " "), r.cleanPath)
+	}
+	return ""
+}
+
+// accessTarget is what the evaluator knows of the file of an access. It is
+// loaded the first time a rule needs it.
+type accessTarget struct {
+	Access
+	path string // clean Access.Path
+
+	loaded bool
+	err    error
+	exists bool  // whether path exists
+	dir    bool  // whether path is a directory
+	file   bool  // a regular file or one to be created
+	size   int64 // size of the content
+	mime   string
+}
+
+// sniffLen is how much content is read to detect its type.
+const sniffLen = 512
+
+func (t *accessTarget) load() error {
+	if t.loaded {
+		return t.err
+	}
+	t.loaded = true
+	if info, err := os.Stat(t.path); err == nil {
+		t.exists, t.dir = true, info.IsDir()
+	}
+	if t.Content != nil {
+		t.file, t.size, t.mime = true, int64(len(t.Content)), detectMimeType(t.Content)
+		return nil
+	}
+	file := t.ContentPath
+	if file == "" {
+		file = t.path
+	}
+	info, err := os.Stat(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Content not known yet; judge it by the extension.
+		t.file = true
+		if typ, _, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(t.path))); err == nil {
+			t.mime = typ
+		}
+		return nil
+	}
+	if err != nil {
+		t.err = err
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	t.file, t.size = true, info.Size()
+	f, err := os.Open(file)
+	if err != nil {
+		t.err = err
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		t.err = err
+		return err
+	}
+	t.mime = detectMimeType(head[:n])
+	return nil
+}
+
+// detectMimeType returns the media type of content, without parameters, as
+// net/http detects it.
+func detectMimeType(content []byte) string {
+	typ, _, _ := strings.Cut(http.DetectContentType(content), ";")
+	return strings.TrimSpace(typ)
+}
+
+// mimeTypeMatch reports whether the media type typ matches pattern, which is
+// a type such as text/plain or a wildcard such as text/* or */*.
+func mimeTypeMatch(pattern, typ string) bool {
+	if typ == "" {
+		return false
+	}
+	if pattern == "*/*" || pattern == typ {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		major, _, _ := strings.Cut(typ, "/")
+		return major == prefix
+	}
+	return false
+}
+
+// parseMimeTypePattern checks and lowercases a mime_types entry.
+func parseMimeTypePattern(p string) (string, error) {
+	p = strings.ToLower(strings.TrimSpace(p))
+	major, minor, ok := strings.Cut(p, "/")
+	if !ok || major == "" || minor == "" || strings.ContainsAny(minor, "/;") || major == "*" && minor != "*" {
+		return "", fmt.Errorf("want a type such as text/plain, text/* or */*")
+	}
+	return p, nil
+}
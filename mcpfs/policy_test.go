@@ -0,0 +1,135 @@
+package mcpfs_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// contentRulesConfig grants write on Markdown text, read on small Go and
+// Markdown files and read on PNG images below img.
+func contentRulesConfig(work string) string {
+	return `paths:
+  - path: ` + work + `
+    perms: [write]
+    extensions: [md]
+    mime_types: ["text/*"]
+  - path: ` + work + `
+    perms: [read]
+    extensions: [.go, MD]
+    max_size: 16
+  - path: ` + work + `/img
+    perms: [read]
+    mime_types: [image/png]
+`
+}
+
+func TestConfig_EvaluateContentRules(t *testing.T) {
+	work := writeConfigFiles(t, map[string]string{
+		"notes.md":     "# notes\n",
+		"image.png":    string(pngHeader),
+		"small.go":     "package a\n",
+		"big.go":       "package a\n\nvar x = 1\n",
+		"docs.md/a.md": "# a\n",
+		"img/x.png":    string(pngHeader),
+		"img/fake.png": "not an image",
+	})
+	cfg, err := mcpfs.ParseConfigData([]byte(contentRulesConfig(work)))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	path := func(name string) string { return filepath.Join(work, name) }
+
+	for _, tc := range []struct {
+		acc    mcpfs.Access
+		reason string // "" if allowed
+	}{
+		{mcpfs.Access{Op: mcpfs.PermWrite, Path: path("notes.md")}, ""},
+		{mcpfs.Access{Op: mcpfs.PermWrite, Path: path("NOTES.MD")}, ""},
+		{mcpfs.Access{Op: mcpfs.PermWrite, Path: path("image.png")}, "image.png does not have one of the extensions .md of rule " + work},
+		{mcpfs.Access{Op: mcpfs.PermWrite, Path: path("new.md"), Content: []byte("# new\n")}, ""},
+		{mcpfs.Access{Op: mcpfs.PermWrite, Path: path("new.md"), Content: pngHeader}, "image/png is not one of the mime_types text/*"},
+		{mcpfs.Access{Op: mcpfs.PermWrite, Path: path("new.md"), ContentPath: path("image.png")}, "image/png is not one of the mime_types"},
+		{mcpfs.Access{Op: mcpfs.PermWrite, Path: path("docs.md")}, "rule " + work + " applies to files only"},
+		{mcpfs.Access{Op: mcpfs.PermRead, Path: path("small.go")}, ""},
+		{mcpfs.Access{Op: mcpfs.PermRead, Path: path("notes.md")}, ""},
+		{mcpfs.Access{Op: mcpfs.PermRead, Path: path("big.go")}, "size 21 B exceeds max_size 16 B of rule " + work},
+		{mcpfs.Access{Op: mcpfs.PermRead, Path: path("img/x.png")}, ""},
+		{mcpfs.Access{Op: mcpfs.PermRead, Path: path("img/fake.png")}, "fake.png does not have one of the extensions"},
+		{mcpfs.Access{Op: mcpfs.PermRead, Path: path("img")}, "img does not have one of the extensions"},
+	} {
+		d := cfg.Evaluate(tc.acc)
+		name := tc.acc.Op.String() + " " + filepath.Base(tc.acc.Path)
+		if tc.reason == "" {
+			if !d.Allowed() || d.Err() != nil {
+				t.Errorf("%s denied: %v", name, d.Err())
+			}
+			continue
+		}
+		err := d.Err()
+		if d.Allowed() || !errors.Is(err, mcpfs.ErrPermissionDenied) || !strings.Contains(err.Error(), tc.reason) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.reason)
+		}
+	}
+
+	// The first rule whose predicates fail gives the reason.
+	d := cfg.Evaluate(mcpfs.Access{Op: mcpfs.PermRead, Path: path("img/fake.png")})
+	if !strings.Contains(d.Reason, "extensions .go .md") {
+		t.Errorf("reason = %q", d.Reason)
+	}
+	if cfg.IsAllowed(mcpfs.PermRead, path("big.go")) || !cfg.IsAllowed(mcpfs.PermRead, path("img/x.png")) {
+		t.Error("IsAllowed does not apply the content predicates")
+	}
+}
+
+func TestConfig_ContentRuleErrors(t *testing.T) {
+	for rule, want := range map[string]string{
+		"extensions: [\"\"]":         `invalid extension ""`,
+		"extensions: [a/b]":          `invalid extension "a/b"`,
+		"mime_types: [text]":         `invalid mime type "text"`,
+		"mime_types: [\"*/plain\"]":  `invalid mime type "*/plain"`,
+		"mime_types: [text/plain/x]": `invalid mime type "text/plain/x"`,
+		"max_size: -1":               `unknown size unit "-1"`,
+	} {
+		data := "paths:\n  - path: /srv\n    " + rule + "\n"
+		_, err := mcpfs.ParseConfigData([]byte(data))
+		if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", rule, err, want)
+		}
+	}
+}
+
+func TestFileTools_ContentRules(t *testing.T) {
+	work := writeConfigFiles(t, map[string]string{"notes.md": "# notes\n"})
+	app := newTestApp(t, contentRulesConfig(work))
+	cs := connect(t, app)
+	notes := filepath.Join(work, "notes.md")
+
+	var out mcpfs.WriteFileOutput
+	callTool(t, cs, "write_file", map[string]any{"path": filepath.Join(work, "new.md"), "content": "# new\n"}, &out)
+
+	for name, args := range map[string]map[string]any{
+		"write_file": {"path": filepath.Join(work, "new.txt"), "content": "text"},
+		// Checked against the edited content when the edit is committed.
+		"edit_file": {"path": notes, "old_text": "# notes\n", "new_text": string(pngHeader)},
+	} {
+		res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+		if err != nil || !res.IsError {
+			t.Errorf("%s: res=%v err=%v, want tool error", name, res, err)
+		}
+	}
+	if got := readFile(t, notes); got != "# notes\n" {
+		t.Errorf("notes.md = %q after denied edit", got)
+	}
+	if _, err := os.Stat(filepath.Join(work, "new.txt")); err == nil {
+		t.Error("denied write created new.txt")
+	}
+}
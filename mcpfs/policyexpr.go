@@ -0,0 +1,865 @@
+package mcpfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PolicyConfig delegates the final say on accesses the path rules grant to
+// an expression in a subset of CEL. The expression is evaluated for every
+// granted access; unless it is true the access is denied. It can only
+// narrow what the rules grant, never widen it. Commands are only confined by
+// the path rules, so no rule of a config with a policy may grant exec.
+//
+// The expression sees these variables:
+//
+//	principal  string  the identity the access is for; see App.Principal
+//	op         string  read, write, exec or git
+//	path       string  clean absolute path of the access
+//	rule       map     path, perms, description and source of the granting rule
+//	file       map     name, ext, dir, exists, is_dir, size and mime of the
+//	                   content read or written
+//	now        map     year, month, day, hour, minute, weekday (0 is Sunday)
+//	                   and unix of the current time
+//
+// It is made of literals (true, false, null, integers, 'strings' and
+// [lists]), the operators ! - + && || == != < <= > >= in and ?:, field
+// access and indexing, the function size(x) and the string methods
+// startsWith, endsWith, contains, matches (an RE2 regular expression) and
+// lowerAscii.
+//
+// Example:
+//
+//	policy:
+//	  expr: >
+//	    op == 'read' ||
+//	    principal in ['alice', 'bob'] && now.weekday >= 1 && now.weekday <= 5
+type PolicyConfig struct {
+	Expr string `yaml:"expr,omitempty" json:"expr,omitempty" jsonschema:"CEL-like expression that must be true for an access the path rules grant, e.g. op == 'read' || now.hour >= 9 && now.hour < 17"`
+}
+
+// PolicyInput is what a policy expression decides on.
+type PolicyInput struct {
+	Principal string
+	Op        Permission
+	Path      string
+	Time      time.Time
+	// Rule is the rule granting the access.
+	Rule *PathRule
+	// File describes the content of the access. It is only needed by
+	// policies whose UsesFile reports true.
+	File *PolicyFile
+}
+
+// PolicyFile is the file metadata a policy expression sees.
+type PolicyFile struct {
+	Exists bool
+	IsDir  bool
+	Size   int64
+	Mime   string
+}
+
+// Policy is a compiled policy expression.
+type Policy struct {
+	expr     string
+	root     exprNode
+	usesFile bool
+}
+
+// policyVars are the variables of policy expressions.
+var policyVars = []string{"principal", "op", "path", "rule", "file", "now"}
+
+// CompilePolicy parses and checks a policy expression.
+func CompilePolicy(expr string) (*Policy, error) {
+	p := &exprParser{src: expr}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return &Policy{expr: expr, root: root, usesFile: slices.Contains(p.vars, "file")}, nil
+}
+
+// String returns the source of the expression.
+func (p *Policy) String() string {
+	return p.expr
+}
+
+// UsesFile reports whether the expression refers to file, so that callers
+// need only inspect the file for policies that do.
+func (p *Policy) UsesFile() bool {
+	return p.usesFile
+}
+
+// Eval evaluates the expression for in. It is an error for the expression
+// not to produce a boolean.
+func (p *Policy) Eval(in PolicyInput) (bool, error) {
+	v, err := p.root.eval(policyActivation(in))
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("result is %s, not a bool", typeName(v))
+	}
+	return b, nil
+}
+
+// policyActivation returns the variables of in.
+func policyActivation(in PolicyInput) map[string]any {
+	vars := map[string]any{
+		"principal": in.Principal,
+		"op":        in.Op.String(),
+		"path":      in.Path,
+		"now": map[string]any{
+			"year":    int64(in.Time.Year()),
+			"month":   int64(in.Time.Month()),
+			"day":     int64(in.Time.Day()),
+			"hour":    int64(in.Time.Hour()),
+			"minute":  int64(in.Time.Minute()),
+			"weekday": int64(in.Time.Weekday()),
+			"unix":    in.Time.Unix(),
+		},
+		"rule": nil,
+		"file": nil,
+	}
+	if r := in.Rule; r != nil {
+		var perms []any
+		for _, op := range []Permission{PermRead, PermWrite, PermExec, PermGit} {
+			if r.parsedPerms&op != 0 {
+				perms = append(perms, op.String())
+			}
+		}
+		vars["rule"] = map[string]any{
+			"path":        r.cleanPath,
+			"perms":       perms,
+			"description": r.Description,
+			"source":      r.source,
+		}
+	}
+	if f := in.File; f != nil {
+		name := filepath.Base(in.Path)
+		vars["file"] = map[string]any{
+			"name":   name,
+			"ext":    strings.ToLower(filepath.Ext(name)),
+			"dir":    filepath.Dir(in.Path),
+			"exists": f.Exists,
+			"is_dir": f.IsDir,
+			"size":   f.Size,
+			"mime":   f.Mime,
+		}
+	}
+	return vars
+}
+
+// Lexer
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokInt
+	tokString
+	tokOp
+)
+
+type exprToken struct {
+	kind tokKind
+	text string // identifier, operator or decoded string
+	num  int64
+	pos  int
+}
+
+func (t exprToken) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.text)
+	case tokInt:
+		return strconv.FormatInt(t.num, 10)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// exprOps are the operators and punctuation, longest first.
+var exprOps = []string{"&&", "||", "==", "!=", "<=", ">=", "!", "<", ">", "+", "-", "?", ":", "(", ")", "[", "]", ",", "."}
+
+type exprParser struct {
+	src  string
+	off  int
+	tok  exprToken
+	vars []string // variables referred to
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+// next reads the next token into p.tok.
+func (p *exprParser) next() error {
+	for p.off < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.off])) {
+		p.off++
+	}
+	start := p.off
+	p.tok = exprToken{pos: start}
+	if p.off >= len(p.src) {
+		p.tok.kind = tokEOF
+		return nil
+	}
+	c := p.src[p.off]
+	switch {
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.off < len(p.src) && (p.src[p.off] == '_' || unicode.IsLetter(rune(p.src[p.off])) || unicode.IsDigit(rune(p.src[p.off]))) {
+			p.off++
+		}
+		p.tok.kind, p.tok.text = tokIdent, p.src[start:p.off]
+	case c >= '0' && c <= '9':
+		for p.off < len(p.src) && p.src[p.off] >= '0' && p.src[p.off] <= '9' {
+			p.off++
+		}
+		n, err := strconv.ParseInt(p.src[start:p.off], 10, 64)
+		if err != nil {
+			return fmt.Errorf("at offset %d: invalid integer %s", start, p.src[start:p.off])
+		}
+		p.tok.kind, p.tok.num = tokInt, n
+	case c == '\'' || c == '"':
+		s, err := p.readString(c)
+		if err != nil {
+			return err
+		}
+		p.tok.kind, p.tok.text = tokString, s
+	default:
+		for _, op := range exprOps {
+			if strings.HasPrefix(p.src[p.off:], op) {
+				p.off += len(op)
+				p.tok.kind, p.tok.text = tokOp, op
+				return nil
+			}
+		}
+		r, _ := utf8.DecodeRuneInString(p.src[p.off:])
+		return fmt.Errorf("at offset %d: unexpected character %q", start, r)
+	}
+	return nil
+}
+
+// readString reads a string literal quoted with q.
+func (p *exprParser) readString(q byte) (string, error) {
+	start := p.off
+	p.off++
+	var b strings.Builder
+	for p.off < len(p.src) {
+		c := p.src[p.off]
+		p.off++
+		switch c {
+		case q:
+			return b.String(), nil
+		case '\\':
+			if p.off >= len(p.src) {
+				break
+			}
+			e := p.src[p.off]
+			p.off++
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '\'', '"':
+				b.WriteByte(e)
+			default:
+				// Kept, so that regular expressions such as '\.go$' read
+				// as written.
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("at offset %d: unterminated string", start)
+}
+
+// isOp reports whether the current token is the operator op.
+func (p *exprParser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+// expect consumes the operator op.
+func (p *exprParser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.errorf("want %q, got %s", op, p.tok)
+	}
+	return p.next()
+}
+
+// Parser, from the lowest precedence to the highest.
+
+func (p *exprParser) parseExpr() (exprNode, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil || !p.isOp("?") {
+		return cond, err
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{cond: cond, then: then, els: els}, nil
+}
+
+// binaryLevels are the binary operators by increasing precedence.
+var binaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">=", "in"},
+	{"+", "-"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.tok.text
+		if p.tok.kind != tokOp && !(p.tok.kind == tokIdent && op == "in") || !slices.Contains(binaryLevels[level], op) {
+			return left, nil
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+		if level == 2 {
+			// Relations do not chain.
+			return left, nil
+		}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("!") || p.isOp("-") {
+		op := p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, x: x}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isOp("."):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokIdent {
+				return nil, p.errorf("want a field or method name, got %s", p.tok)
+			}
+			name := p.tok.text
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if !p.isOp("(") {
+				x = &indexNode{x: x, index: &literalNode{v: name}, field: true}
+				continue
+			}
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			if x, err = newCall(name, append([]exprNode{x}, args...), true); err != nil {
+				return nil, err
+			}
+		case p.isOp("["):
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexNode{x: x, index: index}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokInt:
+		return &literalNode{v: tok.num}, p.next()
+	case tok.kind == tokString:
+		return &literalNode{v: tok.text}, p.next()
+	case tok.kind == tokIdent:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch tok.text {
+		case "true":
+			return &literalNode{v: true}, nil
+		case "false":
+			return &literalNode{v: false}, nil
+		case "null":
+			return &literalNode{v: nil}, nil
+		}
+		if p.isOp("(") {
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return newCall(tok.text, args, false)
+		}
+		if !slices.Contains(policyVars, tok.text) {
+			return nil, fmt.Errorf("at offset %d: unknown variable %s, want one of %s", tok.pos, tok.text, strings.Join(policyVars, ", "))
+		}
+		if !slices.Contains(p.vars, tok.text) {
+			p.vars = append(p.vars, tok.text)
+		}
+		return &varNode{name: tok.text}, nil
+	case p.isOp("("):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case p.isOp("["):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := &listNode{}
+		for !p.isOp("]") {
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, x)
+			if !p.isOp(",") {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		return list, p.expect("]")
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
+
+// parseArgs parses a parenthesized argument list.
+func (p *exprParser) parseArgs() ([]exprNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []exprNode
+	for !p.isOp(")") {
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, x)
+		if !p.isOp(",") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	return args, p.expect(")")
+}
+
+// Evaluation
+
+// exprNode is a node of a parsed expression. Values are bool, int64,
+// string, nil, []any and map[string]any.
+type exprNode interface {
+	eval(vars map[string]any) (any, error)
+}
+
+type literalNode struct{ v any }
+
+func (n *literalNode) eval(map[string]any) (any, error) { return n.v, nil }
+
+type varNode struct{ name string }
+
+func (n *varNode) eval(vars map[string]any) (any, error) { return vars[n.name], nil }
+
+type listNode struct{ items []exprNode }
+
+func (n *listNode) eval(vars map[string]any) (any, error) {
+	list := make([]any, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+type condNode struct{ cond, then, els exprNode }
+
+func (n *condNode) eval(vars map[string]any) (any, error) {
+	c, err := evalBool(n.cond, vars, "?:")
+	if err != nil {
+		return nil, err
+	}
+	if c {
+		return n.then.eval(vars)
+	}
+	return n.els.eval(vars)
+}
+
+type unaryNode struct {
+	op string
+	x  exprNode
+}
+
+func (n *unaryNode) eval(vars map[string]any) (any, error) {
+	if n.op == "!" {
+		b, err := evalBool(n.x, vars, "!")
+		return !b, err
+	}
+	v, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return nil, fmt.Errorf("- of %s", typeName(v))
+	}
+	return -i, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(vars map[string]any) (any, error) {
+	switch n.op {
+	case "&&", "||":
+		l, err := evalBool(n.left, vars, n.op)
+		if err != nil || l == (n.op == "||") {
+			return l, err
+		}
+		return evalBool(n.right, vars, n.op)
+	}
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(l, r), nil
+	case "!=":
+		return !reflect.DeepEqual(l, r), nil
+	case "in":
+		switch r := r.(type) {
+		case []any:
+			return slices.ContainsFunc(r, func(v any) bool { return reflect.DeepEqual(v, l) }), nil
+		case map[string]any:
+			key, ok := l.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s in map", typeName(l))
+			}
+			_, found := r[key]
+			return found, nil
+		}
+		return nil, fmt.Errorf("in %s", typeName(r))
+	case "+":
+		switch l := l.(type) {
+		case int64:
+			if r, ok := r.(int64); ok {
+				return l + r, nil
+			}
+		case string:
+			if r, ok := r.(string); ok {
+				return l + r, nil
+			}
+		}
+	case "-":
+		li, lok := l.(int64)
+		ri, rok := r.(int64)
+		if lok && rok {
+			return li - ri, nil
+		}
+	default:
+		c, ok := compareValues(l, r)
+		if !ok {
+			break
+		}
+		switch n.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		case ">=":
+			return c >= 0, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s %s", typeName(l), n.op, typeName(r))
+}
+
+// compareValues orders two integers or two strings.
+func compareValues(l, r any) (int, bool) {
+	switch l := l.(type) {
+	case int64:
+		if r, ok := r.(int64); ok {
+			return cmpInt(l, r), true
+		}
+	case string:
+		if r, ok := r.(string); ok {
+			return strings.Compare(l, r), true
+		}
+	}
+	return 0, false
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type indexNode struct {
+	x, index exprNode
+	field    bool // x.name rather than x[index]
+}
+
+func (n *indexNode) eval(vars map[string]any) (any, error) {
+	x, err := n.x.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	i, err := n.index.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case map[string]any:
+		key, ok := i.(string)
+		if !ok {
+			return nil, fmt.Errorf("map index %s", typeName(i))
+		}
+		v, found := x[key]
+		if !found {
+			return nil, fmt.Errorf("no field %s", key)
+		}
+		return v, nil
+	case []any:
+		k, ok := i.(int64)
+		if !ok || n.field {
+			return nil, fmt.Errorf("list index %s", typeName(i))
+		}
+		if k < 0 || k >= int64(len(x)) {
+			return nil, fmt.Errorf("index %d out of range of %d items", k, len(x))
+		}
+		return x[k], nil
+	case nil:
+		if n.field {
+			return nil, fmt.Errorf("field %s of null", i)
+		}
+	}
+	return nil, fmt.Errorf("index of %s", typeName(x))
+}
+
+// exprFuncs are the functions of policy expressions, called as f(x, ...)
+// or, for methods, x.f(...).
+var exprFuncs = map[string]struct {
+	args   int
+	method bool
+	fn     func(args []any) (any, error)
+}{
+	"size":       {1, false, exprSize},
+	"startsWith": {2, true, stringFunc(strings.HasPrefix)},
+	"endsWith":   {2, true, stringFunc(strings.HasSuffix)},
+	"contains":   {2, true, stringFunc(strings.Contains)},
+	"lowerAscii": {1, true, func(args []any) (any, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("lowerAscii of %s", typeName(args[0]))
+		}
+		return strings.ToLower(s), nil
+	}},
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+	fn   func(args []any) (any, error)
+}
+
+// newCall checks a call of name. Methods receive the receiver as their
+// first argument.
+func newCall(name string, args []exprNode, method bool) (exprNode, error) {
+	if name == "matches" {
+		return newMatchesCall(args, method)
+	}
+	f, ok := exprFuncs[name]
+	if !ok || f.method != method && name != "size" {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	if len(args) != f.args {
+		n := f.args
+		if method {
+			n--
+		}
+		return nil, fmt.Errorf("%s takes %d argument(s)", name, n)
+	}
+	return &callNode{name: name, args: args, fn: f.fn}, nil
+}
+
+// newMatchesCall checks a call of matches, whose pattern must be a string
+// literal. The pattern is compiled once, here.
+func newMatchesCall(args []exprNode, method bool) (exprNode, error) {
+	if !method || len(args) != 2 {
+		return nil, fmt.Errorf("matches is called as s.matches('pattern')")
+	}
+	var pattern string
+	lit, ok := args[1].(*literalNode)
+	if ok {
+		pattern, ok = lit.v.(string)
+	}
+	if !ok {
+		return nil, fmt.Errorf("matches takes a string literal")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("matches: %v", err)
+	}
+	return &callNode{name: "matches", args: args[:1], fn: func(args []any) (any, error) {
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("matches on %s", typeName(args[0]))
+		}
+		return re.MatchString(s), nil
+	}}, nil
+}
+
+func (n *callNode) eval(vars map[string]any) (any, error) {
+	args := make([]any, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	return n.fn(args)
+}
+
+func exprSize(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case string:
+		return int64(utf8.RuneCountInString(v)), nil
+	case []any:
+		return int64(len(v)), nil
+	case map[string]any:
+		return int64(len(v)), nil
+	}
+	return nil, fmt.Errorf("size of %s", typeName(args[0]))
+}
+
+// stringFunc adapts a function of two strings.
+func stringFunc(f func(s, t string) bool) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		s, ok1 := args[0].(string)
+		t, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("string function of %s and %s", typeName(args[0]), typeName(args[1]))
+		}
+		return f(s, t), nil
+	}
+}
+
+// evalBool evaluates n, which must be a boolean operand of op.
+func evalBool(n exprNode, vars map[string]any, op string) (bool, error) {
+	v, err := n.eval(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s of %s", op, typeName(v))
+	}
+	return b, nil
+}
+
+// typeName names the type of an expression value in errors.
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case string:
+		return "string"
+	case []any:
+		return "list"
+	case map[string]any:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,169 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestPolicy_Eval(t *testing.T) {
+	in := mcpfs.PolicyInput{
+		Principal: "alice",
+		Op:        mcpfs.PermWrite,
+		Path:      "/srv/app/Main.GO",
+		Time:      time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC), // a Wednesday
+		File:      &mcpfs.PolicyFile{Exists: true, Size: 2048, Mime: "text/plain"},
+	}
+	for expr, want := range map[string]bool{
+		"true":                          true,
+		"op == 'write'":                 true,
+		`op == "read"`:                  false,
+		"principal in ['bob', 'alice']": true,
+		"!(principal in ['bob'])":       true,
+		"path.startsWith('/srv/') && !path.contains('..')":                       true,
+		"path.lowerAscii().endsWith('.go')":                                      true,
+		`path.matches('\.go$')`:                                                  false,
+		`path.matches('(?i)\.go$')`:                                              true,
+		"file.ext == '.go' && file.name == 'Main.GO'":                            true,
+		"file.dir == '/srv/app'":                                                 true,
+		"file.size <= 1024 || file.mime.startsWith('text/')":                     true,
+		"file.exists && !file.is_dir":                                            true,
+		"now.weekday >= 1 && now.weekday <= 5":                                   true,
+		"now.hour >= 9 && now.hour < 17":                                         true,
+		"now.year == 2026 && now.month == 3 && now.day == 4 && now.minute == 30": true,
+		"now.unix > 0": true,
+		"size(path) == 16 && size([1, 2]) == 2 && path.size() == 16": true,
+		"'size' in file && !('owner' in file)":                       true,
+		"rule == null":                                               true,
+		"[1, 2, 3][1] + 1 == 3":                                      true,
+		"-1 < 0 && 'a' + 'b' == 'ab'":                                true,
+		"op == 'read' ? false : true":                                true,
+		"op == 'write' || file.missing":                              true, // short-circuits
+	} {
+		p, err := mcpfs.CompilePolicy(expr)
+		if err != nil {
+			t.Errorf("CompilePolicy(%q): %v", expr, err)
+			continue
+		}
+		got, err := p.Eval(in)
+		if err != nil || got != want {
+			t.Errorf("%s = %v, %v, want %v", expr, got, err, want)
+		}
+	}
+}
+
+func TestPolicy_Errors(t *testing.T) {
+	for expr, want := range map[string]string{
+		"":                      "at offset 0: unexpected end of expression",
+		"op ==":                 "at offset 5: unexpected end of expression",
+		"op = 'read'":           `at offset 3: unexpected character '='`,
+		"user == 'alice'":       "unknown variable user",
+		"path.glob('*.go')":     "unknown function glob",
+		"startsWith(path, '/')": "unknown function startsWith",
+		"path.startsWith()":     "startsWith takes 1 argument(s)",
+		"path.matches(op)":      "matches takes a string literal",
+		"path.matches('(')":     "matches: error parsing regexp",
+		"'abc":                  "unterminated string",
+		"(op == 'read'":         `want ")"`,
+		"op == 'read' 'write'":  `unexpected "write"`,
+		"1 < 2 < 3":             `unexpected "<"`,
+	} {
+		_, err := mcpfs.CompilePolicy(expr)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("CompilePolicy(%q) = %v, want %q", expr, err, want)
+		}
+	}
+
+	in := mcpfs.PolicyInput{Op: mcpfs.PermRead, Path: "/srv/a"}
+	for expr, want := range map[string]string{
+		"path":            "result is string, not a bool",
+		"path && true":    "&& of string",
+		"op < 1":          "string < int",
+		"file.size > 0":   "field size of null",
+		"now.second == 0": "no field second",
+		"[1][2] == 1":     "index 2 out of range of 1 items",
+		"1 in 'abc'":      "in string",
+	} {
+		p, err := mcpfs.CompilePolicy(expr)
+		if err != nil {
+			t.Errorf("CompilePolicy(%q): %v", expr, err)
+			continue
+		}
+		if _, err := p.Eval(in); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", expr, err, want)
+		}
+	}
+}
+
+func TestConfig_Policy(t *testing.T) {
+	work := writeConfigFiles(t, map[string]string{
+		"docs/a.md":   "# a\n",
+		"docs/big.md": strings.Repeat("x", 100),
+		"ops/run.sh":  "#!/bin/sh\n",
+	})
+	clock := std.NewTestClock(time.Date(2026, 3, 7, 12, 0, 0, 0, time.Local)) // a Saturday
+	cfg, err := mcpfs.ParseConfigDataWith([]byte(`paths:
+  - path: `+work+`
+    perms: [read, write]
+policy:
+  expr: >
+    op == 'read' && (file.size < 64 || principal == 'admin') ||
+    op == 'write' && rule.path + '/docs' == file.dir && now.weekday >= 1 && now.weekday <= 5
+`), mcpfs.ParseOptions{Env: std.NewTestEnv(t.TempDir(), "me")})
+	if err != nil {
+		t.Fatalf("ParseConfigDataWith: %v", err)
+	}
+	cfg.SetClock(clock)
+	path := func(name string) string { return filepath.Join(work, filepath.FromSlash(name)) }
+
+	if !cfg.IsAllowed(mcpfs.PermRead, path("docs/a.md")) {
+		t.Error("read docs/a.md denied")
+	}
+	d := cfg.Evaluate(mcpfs.Access{Op: mcpfs.PermRead, Path: path("docs/big.md")})
+	if err := d.Err(); !errors.Is(err, mcpfs.ErrPermissionDenied) || !strings.Contains(err.Error(), "denied by policy") {
+		t.Errorf("read docs/big.md as me: err = %v", err)
+	}
+	if !cfg.Evaluate(mcpfs.Access{Op: mcpfs.PermRead, Path: path("docs/big.md"), Principal: "admin"}).Allowed() {
+		t.Error("read docs/big.md as admin denied")
+	}
+	if cfg.IsAllowed(mcpfs.PermWrite, path("docs/a.md")) {
+		t.Error("write on a Saturday allowed")
+	}
+	clock.Set(time.Date(2026, 3, 9, 12, 0, 0, 0, time.Local)) // a Monday
+	if !cfg.IsAllowed(mcpfs.PermWrite, path("docs/a.md")) || cfg.IsAllowed(mcpfs.PermWrite, path("ops/run.sh")) {
+		t.Error("policy does not restrict writes on a Monday to docs")
+	}
+
+	// The policy only narrows what the rules grant.
+	if cfg.IsAllowed(mcpfs.PermRead, filepath.Join(os.TempDir(), "elsewhere")) {
+		t.Error("policy granted a path no rule covers")
+	}
+
+	_, err = mcpfs.ParseConfigData([]byte("policy:\n  expr: \"op =\"\n"))
+	if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), "policy.expr: at offset 3") {
+		t.Errorf("invalid policy: err = %v", err)
+	}
+}
+
+func TestPolicy_ToolPrincipal(t *testing.T) {
+	work := writeConfigFiles(t, map[string]string{"c.yaml": "a: 1\n"})
+	// Tools decide for the principal of the call, here the test env's user
+	// rather than the OS user the config was parsed with.
+	app := newTestApp(t, `version: "2.0"
+paths:
+  - path: `+work+`
+policy:
+  expr: principal == 'testuser'
+`)
+	var out mcpfs.QueryDataOutput
+	callTool(t, connect(t, app), "query_data", map[string]any{"path": filepath.Join(work, "c.yaml"), "query": "$.a"}, &out)
+	if len(out.Matches) != 1 {
+		t.Errorf("query_data = %+v", out)
+	}
+}
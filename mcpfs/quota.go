@@ -0,0 +1,264 @@
+package mcpfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// QuotaError describes a write refused because it would exceed a quota of the
+// rule granting write access. It wraps ErrQuotaExceeded.
+type QuotaError struct {
+	Path  string // target of the write
+	Rule  string // path of the rule whose quota applies
+	Limit string // name of the exceeded setting, e.g. "max_total_bytes"
+	Max   int64  // configured limit
+	Used  int64  // current usage under the rule
+	Want  int64  // usage the write asked for
+}
+
+func (e *QuotaError) Error() string {
+	switch e.Limit {
+	case "max_files":
+		return fmt.Sprintf("quota exceeded writing %q: %s allows %d files under %q (%d in use)",
+			e.Path, e.Limit, e.Max, e.Rule, e.Used)
+	case "max_file_size":
+		return fmt.Sprintf("quota exceeded writing %q: %s is %s under %q but the file would be %s",
+			e.Path, e.Limit, ByteSize(e.Max), e.Rule, ByteSize(e.Want))
+	default:
+		return fmt.Sprintf("quota exceeded writing %q: %s is %s under %q (%s in use, %s requested)",
+			e.Path, e.Limit, ByteSize(e.Max), e.Rule, ByteSize(e.Used), ByteSize(e.Want))
+	}
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExceeded }
+
+// QuotaUsage is the disk usage accounted to one rule.
+type QuotaUsage struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// QuotaTracker accounts the usage of write-granted rules that configure
+// max_total_bytes or max_files. Usage is computed once by Recompute (on
+// startup) and then kept up to date by the tools through Reserve and Remove,
+// so enforcing a quota does not require walking the tree on every write.
+type QuotaTracker struct {
+	cfg *Config
+
+	mu    sync.Mutex
+	usage map[*PathRule]*QuotaUsage
+}
+
+// NewQuotaTracker creates a tracker for the rules of cfg. Call Recompute
+// before use to load the current usage from disk.
+func NewQuotaTracker(cfg *Config) *QuotaTracker {
+	return &QuotaTracker{cfg: cfg, usage: map[*PathRule]*QuotaUsage{}}
+}
+
+// Recompute walks the paths of every rule with an aggregate quota and replaces
+// the cached usage. Paths that do not exist count as empty.
+func (q *QuotaTracker) Recompute() error {
+	if q.cfg == nil {
+		return nil
+	}
+	usage := map[*PathRule]*QuotaUsage{}
+	var errs []error
+	for i := range q.cfg.Paths {
+		r := &q.cfg.Paths[i]
+		if !r.hasAggregateQuota() {
+			continue
+		}
+		u, err := diskUsage(r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("quota usage for %q: %w", r.Path, err))
+		}
+		usage[r] = u
+	}
+	q.mu.Lock()
+	q.usage = usage
+	q.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// Usage returns the cached usage of the rule granting write on path.
+func (q *QuotaTracker) Usage(path string) (QuotaUsage, bool) {
+	r := q.cfg.MatchRule(PermWrite, path)
+	if r == nil {
+		return QuotaUsage{}, false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u, ok := q.usage[r]
+	if !ok {
+		return QuotaUsage{}, false
+	}
+	return *u, true
+}
+
+// QuotaReservation is usage charged by Reserve. Cancel it if the write fails.
+type QuotaReservation struct {
+	q     *QuotaTracker
+	rule  *PathRule
+	bytes int64
+	files int64
+}
+
+// Cancel returns the reserved usage. It is safe to call on a nil reservation
+// and more than once.
+func (r *QuotaReservation) Cancel() {
+	if r == nil || r.q == nil {
+		return
+	}
+	r.q.charge(r.rule, -r.bytes, -r.files)
+	r.q = nil
+}
+
+// Reserve checks that writing size bytes to path stays within the quotas of
+// the rule granting write on it and charges the difference to that rule. An
+// existing file at path is replaced, so only the growth counts. Writes that
+// no rule grants are not the tracker's concern and are reserved for free.
+func (q *QuotaTracker) Reserve(path string, size int64) (*QuotaReservation, error) {
+	r := q.cfg.MatchRule(PermWrite, path)
+	if r == nil {
+		return &QuotaReservation{}, nil
+	}
+	if r.MaxFileSize > 0 && size > int64(r.MaxFileSize) {
+		return nil, &QuotaError{Path: path, Rule: r.Path, Limit: "max_file_size", Max: int64(r.MaxFileSize), Want: size}
+	}
+	if !r.hasAggregateQuota() {
+		return &QuotaReservation{}, nil
+	}
+
+	var oldSize, newFiles int64 = 0, 1
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+		oldSize, newFiles = info.Size(), 0
+	}
+	return q.reserve(r, path, size-oldSize, newFiles)
+}
+
+// Check reports whether writing size bytes to path would stay within the
+// quotas Reserve enforces, without charging anything.
+func (q *QuotaTracker) Check(path string, size int64) error {
+	res, err := q.Reserve(path, size)
+	res.Cancel()
+	return err
+}
+
+// ReserveUsage checks that adding a tree with the given usage at path, which
+// must not exist yet, stays within the aggregate quotas of the rule granting
+// write on it and charges the usage to that rule. Per-file limits are not
+// checked.
+func (q *QuotaTracker) ReserveUsage(path string, add QuotaUsage) (*QuotaReservation, error) {
+	r := q.cfg.MatchRule(PermWrite, path)
+	if r == nil || !r.hasAggregateQuota() {
+		return &QuotaReservation{}, nil
+	}
+	return q.reserve(r, path, add.Bytes, add.Files)
+}
+
+func (q *QuotaTracker) reserve(r *PathRule, path string, bytes, files int64) (*QuotaReservation, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.usageLocked(r)
+	if r.MaxTotalBytes > 0 && bytes > 0 && u.Bytes+bytes > int64(r.MaxTotalBytes) {
+		return nil, &QuotaError{Path: path, Rule: r.Path, Limit: "max_total_bytes", Max: int64(r.MaxTotalBytes), Used: u.Bytes, Want: bytes}
+	}
+	if r.MaxFiles > 0 && files > 0 && u.Files+files > r.MaxFiles {
+		return nil, &QuotaError{Path: path, Rule: r.Path, Limit: "max_files", Max: r.MaxFiles, Used: u.Files, Want: files}
+	}
+	u.Bytes += bytes
+	u.Files += files
+	return &QuotaReservation{q: q, rule: r, bytes: bytes, files: files}, nil
+}
+
+// Remove releases usage freed by deleting path. Measure it with PathUsage
+// before the deletion.
+func (q *QuotaTracker) Remove(path string, freed QuotaUsage) {
+	r := q.cfg.MatchRule(PermWrite, path)
+	if r == nil || !r.hasAggregateQuota() {
+		return
+	}
+	q.charge(r, -freed.Bytes, -freed.Files)
+}
+
+// PathUsage returns the bytes and number of regular files at or below p.
+func PathUsage(p string) QuotaUsage {
+	var u QuotaUsage
+	filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			u.Bytes += info.Size()
+			u.Files++
+		}
+		return nil
+	})
+	return u
+}
+
+func (q *QuotaTracker) charge(r *PathRule, bytes, files int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.usageLocked(r)
+	u.Bytes = max(u.Bytes+bytes, 0)
+	u.Files = max(u.Files+files, 0)
+}
+
+func (q *QuotaTracker) usageLocked(r *PathRule) *QuotaUsage {
+	u, ok := q.usage[r]
+	if !ok {
+		u = &QuotaUsage{}
+		q.usage[r] = u
+	}
+	return u
+}
+
+func (r *PathRule) hasAggregateQuota() bool {
+	return r.parsedPerms&PermWrite != 0 && (r.MaxTotalBytes > 0 || r.MaxFiles > 0)
+}
+
+// diskUsage sums the regular files covered by r.
+func diskUsage(r *PathRule) (*QuotaUsage, error) {
+	u := &QuotaUsage{}
+	info, err := os.Lstat(r.cleanPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return u, nil
+	}
+	if err != nil {
+		return u, err
+	}
+	if !info.IsDir() {
+		if info.Mode().IsRegular() {
+			u.Bytes, u.Files = info.Size(), 1
+		}
+		return u, nil
+	}
+	recursive := r.AllowSubpaths == nil || *r.AllowSubpaths
+	err = filepath.WalkDir(r.cleanPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if !recursive && p != r.cleanPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		u.Bytes += info.Size()
+		u.Files++
+		return nil
+	})
+	return u, err
+}
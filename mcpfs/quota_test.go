@@ -0,0 +1,87 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]mcpfs.ByteSize{
+		"512":    512,
+		"1KB":    1000,
+		"1KiB":   1024,
+		"10M":    10 << 20,
+		"1.5GiB": 3 << 29,
+	}
+	for in, want := range cases {
+		got, err := mcpfs.ParseByteSize(in)
+		if err != nil {
+			t.Fatalf("ParseByteSize(%q): %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("ParseByteSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+	if _, err := mcpfs.ParseByteSize("10 parsecs"); err == nil {
+		t.Fatal("expected error for unknown unit")
+	}
+	for _, in := range []string{"9223372036854775808", "9000000000.5TiB", "8388608.5TiB"} {
+		if got, err := mcpfs.ParseByteSize(in); err == nil {
+			t.Errorf("ParseByteSize(%q) = %d, want out of range", in, got)
+		}
+	}
+}
+
+func TestQuotaTracker_Reserve(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "existing.txt"), make([]byte, 60), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := mcpfs.ParseConfigData([]byte(`
+paths:
+  - path: ` + dir + `
+    perms: [read, write]
+    max_file_size: 80
+    max_total_bytes: 100
+    max_files: 2
+`))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	q := mcpfs.NewQuotaTracker(cfg)
+	if err := q.Recompute(); err != nil {
+		t.Fatalf("Recompute: %v", err)
+	}
+	if u, _ := q.Usage(dir); u != (mcpfs.QuotaUsage{Bytes: 60, Files: 1}) {
+		t.Fatalf("usage after recompute = %+v", u)
+	}
+
+	var qe *mcpfs.QuotaError
+	_, err = q.Reserve(filepath.Join(dir, "big.bin"), 90)
+	if !errors.As(err, &qe) || qe.Limit != "max_file_size" {
+		t.Fatalf("expected max_file_size error, got %v", err)
+	}
+	_, err = q.Reserve(filepath.Join(dir, "new.txt"), 50)
+	if !errors.Is(err, mcpfs.ErrQuotaExceeded) {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+
+	// replacing an existing file only charges the growth
+	res, err := q.Reserve(filepath.Join(dir, "existing.txt"), 70)
+	if err != nil {
+		t.Fatalf("Reserve existing: %v", err)
+	}
+	res.Cancel()
+
+	if _, err := q.Reserve(filepath.Join(dir, "a.txt"), 10); err != nil {
+		t.Fatalf("Reserve a.txt: %v", err)
+	}
+	_, err = q.Reserve(filepath.Join(dir, "b.txt"), 10)
+	if !errors.As(err, &qe) || qe.Limit != "max_files" {
+		t.Fatalf("expected max_files error, got %v", err)
+	}
+}
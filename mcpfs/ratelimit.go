@@ -0,0 +1,234 @@
+package mcpfs
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+)
+
+// concurrencyRetryAfter is the hint returned when the concurrency cap is hit.
+// Unlike token buckets there is no way to know when a slot frees up.
+const concurrencyRetryAfter = time.Second
+
+// RateLimitConfig configures request limits.
+// YAML schema:
+//
+//	rate_limits:
+//	  max_concurrent: 8                            # global cap on in-flight tool calls
+//	  session: {requests: 20, per: 1s, burst: 40}  # per MCP session
+//	  principal: {requests: 600, per: 1m}          # per principal, across sessions
+//	  tools:                                       # per tool within a session
+//	    search_files: {requests: 1, per: 2s, burst: 5}
+//
+// The principal is the identity App.Principal returns: the subject of the
+// bearer token where the transport authenticates clients, and otherwise
+// the OS user, so that the limit spans every session of the server.
+// Omitted limits are unlimited.
+type RateLimitConfig struct {
+	MaxConcurrent int                  `yaml:"max_concurrent,omitempty" json:"max_concurrent,omitempty"`
+	Session       *RateLimit           `yaml:"session,omitempty" json:"session,omitempty"`
+	Principal     *RateLimit           `yaml:"principal,omitempty" json:"principal,omitempty"`
+	Tools         map[string]RateLimit `yaml:"tools,omitempty" json:"tools,omitempty"`
+}
+
+// RateLimit is a token bucket refilled with Requests tokens every Per. Burst
+// is the bucket size and defaults to Requests; Per defaults to one second.
+type RateLimit struct {
+	Requests int           `yaml:"requests" json:"requests"`
+	Per      time.Duration `yaml:"per,omitempty" json:"per,omitempty"`
+	Burst    int           `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+func (l RateLimit) validate() error {
+	if l.Requests <= 0 {
+		return fmt.Errorf("requests must be positive")
+	}
+	if l.Per < 0 || l.Burst < 0 {
+		return fmt.Errorf("per and burst must not be negative")
+	}
+	return nil
+}
+
+// Validate checks the limits for nonsensical values.
+func (c *RateLimitConfig) Validate() error {
+	if c.MaxConcurrent < 0 {
+		return fmt.Errorf("max_concurrent must not be negative")
+	}
+	if c.Session != nil {
+		if err := c.Session.validate(); err != nil {
+			return fmt.Errorf("session: %w", err)
+		}
+	}
+	if c.Principal != nil {
+		if err := c.Principal.validate(); err != nil {
+			return fmt.Errorf("principal: %w", err)
+		}
+	}
+	for name, l := range c.Tools {
+		if err := l.validate(); err != nil {
+			return fmt.Errorf("tool %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// RateLimitError reports a request rejected by a limit. RetryAfter tells the
+// client when a retry can succeed. It wraps ErrRateLimited.
+type RateLimitError struct {
+	Scope      string // "session", "principal", "tool" or "concurrency"
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	secs := math.Ceil(e.RetryAfter.Seconds()*10) / 10
+	if e.Scope == "concurrency" {
+		return fmt.Sprintf("rate limited: too many concurrent requests; retry after %.1fs", secs)
+	}
+	return fmt.Sprintf("rate limited: %s limit for %q exceeded; retry after %.1fs", e.Scope, e.Key, secs)
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+// RateLimitKey identifies the caller of a tool.
+type RateLimitKey struct {
+	Session   string
+	Principal string
+	Tool      string
+}
+
+// RateLimiter enforces a RateLimitConfig. Time is read from the injected
+// clock so tests can drive it with std.TestClock.
+type RateLimiter struct {
+	cfg   RateLimitConfig
+	clock std.Clock
+
+	mu       sync.Mutex
+	buckets  map[bucketKey]*tokenBucket
+	inFlight int
+}
+
+type bucketKey struct {
+	scope string
+	key   string
+}
+
+type tokenBucket struct {
+	limit  RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter constructs a limiter. A nil clock uses the OS clock.
+func NewRateLimiter(cfg RateLimitConfig, clock std.Clock) *RateLimiter {
+	if clock == nil {
+		clock = std.OsClock{}
+	}
+	return &RateLimiter{cfg: cfg, clock: clock, buckets: map[bucketKey]*tokenBucket{}}
+}
+
+// Acquire admits one request for key. On success the returned release func
+// must be called when the request finishes. On rejection no tokens are
+// consumed and the error is a *RateLimitError.
+func (l *RateLimiter) Acquire(key RateLimitKey) (release func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	var (
+		buckets []*tokenBucket
+		denied  *RateLimitError
+	)
+	check := func(scope, k string, limit *RateLimit) {
+		if limit == nil || k == "" {
+			return
+		}
+		b := l.bucket(bucketKey{scope: scope, key: k}, *limit, now)
+		buckets = append(buckets, b)
+		if wait := b.wait(); wait > 0 && (denied == nil || wait > denied.RetryAfter) {
+			denied = &RateLimitError{Scope: scope, Key: k, RetryAfter: wait}
+		}
+	}
+	check("session", key.Session, l.cfg.Session)
+	check("principal", key.Principal, l.cfg.Principal)
+	if tl, ok := l.cfg.Tools[key.Tool]; ok {
+		check("tool", key.Session+"\x00"+key.Tool, &tl)
+		if denied != nil && denied.Scope == "tool" {
+			denied.Key = key.Tool
+		}
+	}
+	if denied != nil {
+		return nil, denied
+	}
+	if l.cfg.MaxConcurrent > 0 && l.inFlight >= l.cfg.MaxConcurrent {
+		return nil, &RateLimitError{Scope: "concurrency", RetryAfter: concurrencyRetryAfter}
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	l.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.inFlight--
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// Forget drops the buckets of a session once it has ended.
+func (l *RateLimiter) Forget(session string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k := range l.buckets {
+		if (k.scope == "session" && k.key == session) ||
+			(k.scope == "tool" && strings.HasPrefix(k.key, session+"\x00")) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// bucket returns the bucket for k refilled up to now.
+func (l *RateLimiter) bucket(k bucketKey, limit RateLimit, now time.Time) *tokenBucket {
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &tokenBucket{limit: limit, tokens: float64(limit.burst()), last: now}
+		l.buckets[k] = b
+		return b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.burst()), b.tokens+elapsed.Seconds()*limit.rate())
+		b.last = now
+	}
+	return b
+}
+
+// wait returns how long until the bucket holds a full token, or 0 if it does.
+func (b *tokenBucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.limit.rate() * float64(time.Second))
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// rate returns the refill rate in tokens per second.
+func (l RateLimit) rate() float64 {
+	per := l.Per
+	if per <= 0 {
+		per = time.Second
+	}
+	return float64(l.Requests) / per.Seconds()
+}
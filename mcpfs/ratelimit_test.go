@@ -0,0 +1,114 @@
+package mcpfs_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	clock := std.NewTestClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	l := mcpfs.NewRateLimiter(mcpfs.RateLimitConfig{
+		Session: &mcpfs.RateLimit{Requests: 1, Per: time.Second, Burst: 2},
+		Tools: map[string]mcpfs.RateLimit{
+			"search_files": {Requests: 1, Per: 10 * time.Second},
+		},
+	}, clock)
+	key := mcpfs.RateLimitKey{Session: "s1", Tool: "read_file"}
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire(key)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		release()
+	}
+	_, err := l.Acquire(key)
+	var rle *mcpfs.RateLimitError
+	if !errors.As(err, &rle) || rle.Scope != "session" {
+		t.Fatalf("expected session limit error, got %v", err)
+	}
+	if rle.RetryAfter != time.Second {
+		t.Fatalf("RetryAfter = %v, want 1s", rle.RetryAfter)
+	}
+
+	clock.Advance(time.Second)
+	if _, err := l.Acquire(key); err != nil {
+		t.Fatalf("after refill: %v", err)
+	}
+
+	// the tool limit is tracked separately from the session bucket
+	clock.Advance(2 * time.Second)
+	search := mcpfs.RateLimitKey{Session: "s1", Tool: "search_files"}
+	if _, err := l.Acquire(search); err != nil {
+		t.Fatalf("first search: %v", err)
+	}
+	_, err = l.Acquire(search)
+	if !errors.As(err, &rle) || rle.Scope != "tool" || rle.RetryAfter != 10*time.Second {
+		t.Fatalf("expected tool limit with 10s retry, got %v", err)
+	}
+	if !errors.Is(err, mcpfs.ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestRateLimiter_MaxConcurrent(t *testing.T) {
+	l := mcpfs.NewRateLimiter(mcpfs.RateLimitConfig{MaxConcurrent: 1}, &std.TestClock{})
+	release, err := l.Acquire(mcpfs.RateLimitKey{Session: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(mcpfs.RateLimitKey{Session: "b"}); !errors.Is(err, mcpfs.ErrRateLimited) {
+		t.Fatalf("expected concurrency rejection, got %v", err)
+	}
+	release()
+	release()
+	if _, err := l.Acquire(mcpfs.RateLimitKey{Session: "b"}); err != nil {
+		t.Fatalf("after release: %v", err)
+	}
+}
+
+func TestParseConfigData_RateLimits(t *testing.T) {
+	cfg, err := mcpfs.ParseConfigData([]byte(`
+rate_limits:
+  max_concurrent: 4
+  session: {requests: 20, per: 1m}
+  tools:
+    search_files: {requests: 1, per: 2s, burst: 5}
+`))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	rl := cfg.RateLimits
+	if rl.MaxConcurrent != 4 || rl.Session.Per != time.Minute || rl.Tools["search_files"].Burst != 5 {
+		t.Fatalf("unexpected rate limits: %+v", rl)
+	}
+
+	if _, err := mcpfs.ParseConfigData([]byte("rate_limits:\n  session: {requests: 0}\n")); err == nil {
+		t.Fatal("expected error for zero requests")
+	}
+}
+
+func TestRateLimits_PrincipalAcrossSessions(t *testing.T) {
+	app := newTestApp(t, `version: "2.0"
+rate_limits:
+  principal: {requests: 1, per: 1m}
+`)
+	first, second := connect(t, app), connect(t, app)
+	callTool(t, first, "list_trash", map[string]any{}, nil)
+
+	// The second session is the same OS user, so it shares the bucket.
+	res, err := second.CallTool(context.Background(), &mcp.CallToolParams{Name: "list_trash", Arguments: map[string]any{}})
+	if err == nil && res.IsError {
+		err = errors.New(res.Content[0].(*mcp.TextContent).Text)
+	}
+	if err == nil || !strings.Contains(err.Error(), `principal limit for "testuser" exceeded`) {
+		t.Fatalf("second session: err = %v, want the principal limit", err)
+	}
+}
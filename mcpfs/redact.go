@@ -0,0 +1,303 @@
+package mcpfs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+// RedactionConfig controls masking secrets in file content returned by the
+// read tools. Every redaction is logged as an audit entry; the secrets
+// themselves are never logged.
+//
+// Example:
+//
+//	redaction:
+//	  enabled: true
+//	  disable: [high_entropy]
+//	  patterns:
+//	    - name: internal_token
+//	      regex: 'itk_[0-9a-f]{32}'
+type RedactionConfig struct {
+	Enabled bool `yaml:"enabled,omitempty" json:"enabled,omitempty" jsonschema:"mask secrets in file content returned by tools"`
+	// Disable turns off built-in detectors by name.
+	Disable []string `yaml:"disable,omitempty" json:"disable,omitempty" jsonschema:"built-in secret detectors to turn off"`
+	// Patterns are regular expressions of further secrets. Only the first
+	// capture group is masked in patterns that have one.
+	Patterns []RedactionPattern `yaml:"patterns,omitempty" json:"patterns,omitempty" jsonschema:"regular expressions of further secrets to mask"`
+}
+
+// RedactionPattern is a configured secret pattern.
+type RedactionPattern struct {
+	Name  string `yaml:"name,omitempty" json:"name,omitempty" jsonschema:"name the masked secrets are reported by; default custom"`
+	Regex string `yaml:"regex" json:"regex" jsonschema:"regular expression (RE2 syntax) of the secret"`
+}
+
+// Validate checks the detector names and compiles the patterns.
+func (c RedactionConfig) Validate() error {
+	_, err := NewRedactor(c)
+	return err
+}
+
+// secretDetector finds one kind of secret.
+type secretDetector struct {
+	name string
+	re   *regexp.Regexp
+	// check, if set, filters the matches.
+	check func(string) bool
+}
+
+// builtinSecretDetectors are the detectors of well-known secret formats, in
+// the order they run.
+var builtinSecretDetectors = []secretDetector{
+	{name: "private_key", re: regexp.MustCompile(`-----BEGIN[A-Z ]* PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END[A-Z ]* PRIVATE KEY( BLOCK)?-----`)},
+	{name: "aws_access_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA|AGPA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`)},
+	{name: "aws_secret_key", re: regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|private).{0,20}?['"]?\s*[:=]\s*['"]?([A-Za-z0-9/+]{40})\b`)},
+	{name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{name: "github_token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{50,})\b`)},
+	{name: "slack_token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{name: "high_entropy", re: regexp.MustCompile(`[A-Za-z0-9+/_=-]{20,}`), check: highEntropy},
+}
+
+// SecretDetectors returns the names of the built-in secret detectors.
+func SecretDetectors() []string {
+	names := make([]string, len(builtinSecretDetectors))
+	for i, d := range builtinSecretDetectors {
+		names[i] = d.name
+	}
+	return names
+}
+
+// highEntropy reports whether s looks like a random token: it has letters
+// and digits and a Shannon entropy close to the most its length allows.
+// Identifiers, words and hex hashes fall short of it.
+func highEntropy(s string) bool {
+	if !strings.ContainsAny(s, "0123456789") || strings.ToLower(s) == s || strings.ToUpper(s) == s {
+		return false
+	}
+	counts := map[rune]int{}
+	for _, c := range s {
+		counts[c]++
+	}
+	var h float64
+	for _, n := range counts {
+		p := float64(n) / float64(len(s))
+		h -= p * math.Log2(p)
+	}
+	return h >= 0.85*math.Log2(float64(min(len(s), 64)))
+}
+
+// Redactor masks secrets in text.
+type Redactor struct {
+	detectors []secretDetector
+}
+
+// NewRedactor returns the redactor of cfg, which runs the enabled built-in
+// detectors and then the configured patterns.
+func NewRedactor(cfg RedactionConfig) (*Redactor, error) {
+	names := SecretDetectors()
+	for _, name := range cfg.Disable {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("unknown secret detector %q, want one of %s", name, strings.Join(names, ", "))
+		}
+	}
+	r := &Redactor{}
+	var generic []secretDetector
+	for _, d := range builtinSecretDetectors {
+		if slices.Contains(cfg.Disable, d.name) {
+			continue
+		}
+		if d.check != nil {
+			// Generic detectors run last, on what the specific ones left.
+			generic = append(generic, d)
+			continue
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	for i, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: %v", i, err)
+		}
+		name := p.Name
+		if name == "" {
+			name = "custom"
+		}
+		r.detectors = append(r.detectors, secretDetector{name: name, re: re})
+	}
+	r.detectors = append(r.detectors, generic...)
+	return r, nil
+}
+
+// Redact returns s with the secrets in it masked, and the detector name of
+// each masked secret. A mask keeps the line breaks of the secret, so the
+// lines of s keep their numbers. A nil Redactor returns s unchanged.
+func (r *Redactor) Redact(s string) (string, []string) {
+	if r == nil {
+		return s, nil
+	}
+	var found []string
+	for _, d := range r.detectors {
+		matches := d.re.FindAllStringSubmatchIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if d.check != nil && !d.check(s[start:end]) {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString("[REDACTED:" + d.name + "]")
+			b.WriteString(strings.Repeat("\n", strings.Count(s[start:end], "\n")))
+			last = end
+			found = append(found, d.name)
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s, found
+}
+
+// RedactLines is Redact for the lines of a text, so that secrets spanning
+// lines, such as private keys, are found.
+func (r *Redactor) RedactLines(lines []string) ([]string, []string) {
+	if r == nil || len(lines) == 0 {
+		return lines, nil
+	}
+	text, found := r.Redact(strings.Join(lines, "\n"))
+	if len(found) == 0 {
+		return lines, nil
+	}
+	return strings.Split(text, "\n"), found
+}
+
+// redactNode masks the secrets in the string scalars of a YAML tree in
+// place and returns the detector names of the masked secrets.
+func (r *Redactor) redactNode(n *yaml.Node) []string {
+	if r == nil {
+		return nil
+	}
+	var found []string
+	// Each node is visited once, so aliases of aliases do not multiply the
+	// work.
+	seen := map[*yaml.Node]bool{}
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n == nil || seen[n] {
+			return
+		}
+		seen[n] = true
+		if n.Kind == yaml.AliasNode {
+			walk(n.Alias)
+			return
+		}
+		if n.Kind == yaml.ScalarNode && n.Tag == "!!str" {
+			var f []string
+			n.Value, f = r.Redact(n.Value)
+			found = append(found, f...)
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(n)
+	return found
+}
+
+// redactorFor returns the redactor for content read from path, or nil if
+// redaction is disabled or the rule granting read on path opts out of it.
+func (a *App) redactorFor(path string) *Redactor {
+	if a.Redactor == nil {
+		return nil
+	}
+	if r := a.Cfg.MatchRule(PermRead, path); r != nil && r.Redact != nil && !*r.Redact {
+		return nil
+	}
+	return a.Redactor
+}
+
+// outputRedaction masks secrets in the output of one tool call, with the
+// redactor of the file each piece of output comes from.
+type outputRedaction struct {
+	app   *App
+	tool  string
+	files []string            // files with masked secrets, in order
+	found map[string][]string // detector names by file
+}
+
+func (a *App) newOutputRedaction(tool string) *outputRedaction {
+	return &outputRedaction{app: a, tool: tool, found: map[string][]string{}}
+}
+
+func (o *outputRedaction) record(path string, found []string) {
+	if len(found) == 0 {
+		return
+	}
+	if _, ok := o.found[path]; !ok {
+		o.files = append(o.files, path)
+	}
+	o.found[path] = append(o.found[path], found...)
+}
+
+// String masks the secrets in s, read from path.
+func (o *outputRedaction) String(path, s string) string {
+	s, found := o.app.redactorFor(path).Redact(s)
+	o.record(path, found)
+	return s
+}
+
+// Lines masks the secrets in lines, read from path.
+func (o *outputRedaction) Lines(path string, lines []string) []string {
+	lines, found := o.app.redactorFor(path).RedactLines(lines)
+	o.record(path, found)
+	return lines
+}
+
+// Outline masks the secrets in the names and details of the outline nodes,
+// read from path.
+func (o *outputRedaction) Outline(path string, nodes []OutlineNode) {
+	r := o.app.redactorFor(path)
+	for i := range nodes {
+		var found []string
+		n := &nodes[i]
+		n.Name, found = r.Redact(n.Name)
+		o.record(path, found)
+		n.Detail, found = r.Redact(n.Detail)
+		o.record(path, found)
+		o.Outline(path, n.Children)
+	}
+}
+
+// Node masks the secrets in the strings of the YAML tree n, read from path.
+func (o *outputRedaction) Node(path string, n *yaml.Node) {
+	o.record(path, o.app.redactorFor(path).redactNode(n))
+}
+
+// audit logs an entry for each file whose secrets were masked, with the
+// number and kinds of the secrets.
+func (o *outputRedaction) audit(ctx context.Context, req *mcp.CallToolRequest) {
+	var ss *mcp.ServerSession
+	if req != nil {
+		ss = req.Session
+	}
+	for _, path := range o.files {
+		found := o.found[path]
+		kinds := slices.Compact(slices.Sorted(slices.Values(found)))
+		o.app.Logger.LogAttrs(ctx, slog.LevelWarn, "secrets redacted",
+			slog.String("session", o.app.SessionID(ss)), slog.String("tool", o.tool), slog.String("path", path),
+			slog.Int("count", len(found)), slog.String("kinds", strings.Join(kinds, ",")))
+	}
+}
@@ -0,0 +1,266 @@
+package mcpfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Landlock modes accepted by SandboxConfig.Landlock.
+const (
+	LandlockAuto     = "auto"
+	LandlockRequired = "required"
+	LandlockOff      = "off"
+)
+
+// SandboxExecCommand is the hidden subcommand the binary re-executes itself
+// with to apply the sandbox before exec'ing the real child process.
+const SandboxExecCommand = "sandbox-exec"
+
+// SandboxPolicyEnv carries the JSON encoded SandboxPolicy to the re-executed
+// helper process.
+const SandboxPolicyEnv = "MCPFS_SANDBOX_POLICY"
+
+// SandboxConfig controls the isolation applied to executed commands.
+// YAML schema:
+//
+//	sandbox:
+//	  landlock: auto          # auto (default), required or off
+//	  user_namespace: false   # run children in a new user namespace
+//	  mount_namespace: false  # run children in a new mount namespace (implies user_namespace)
+//	  seccomp: false          # install a seccomp filter denying privileged syscalls
+type SandboxConfig struct {
+	Landlock       string `yaml:"landlock,omitempty" json:"landlock,omitempty"`
+	UserNamespace  bool   `yaml:"user_namespace,omitempty" json:"user_namespace,omitempty"`
+	MountNamespace bool   `yaml:"mount_namespace,omitempty" json:"mount_namespace,omitempty"`
+	Seccomp        bool   `yaml:"seccomp,omitempty" json:"seccomp,omitempty"`
+}
+
+// SandboxRule grants access to a path (and, if Recursive, everything below it)
+// inside the sandbox.
+type SandboxRule struct {
+	Path      string     `json:"path"`
+	Access    Permission `json:"access"`
+	Recursive bool       `json:"recursive"`
+}
+
+// SandboxPolicy is the isolation policy applied to a child process. It is
+// derived from a Config with NewSandboxPolicy and is serializable so it can be
+// handed to the re-executed helper process.
+type SandboxPolicy struct {
+	Rules          []SandboxRule `json:"rules"`
+	Landlock       string        `json:"landlock"`
+	UserNamespace  bool          `json:"user_namespace"`
+	MountNamespace bool          `json:"mount_namespace"`
+	Seccomp        bool          `json:"seccomp"`
+}
+
+// SandboxFeatures reports which isolation mechanisms are available on the
+// running kernel, or which are active for a given policy.
+type SandboxFeatures struct {
+	Landlock        bool `json:"landlock"`
+	LandlockABI     int  `json:"landlock_abi,omitempty"`
+	UserNamespace   bool `json:"user_namespace"`
+	MountNamespace  bool `json:"mount_namespace"`
+	Seccomp         bool `json:"seccomp"`
+	NoNewPrivileges bool `json:"no_new_privileges"`
+}
+
+func (f SandboxFeatures) String() string {
+	var parts []string
+	if f.Landlock {
+		parts = append(parts, fmt.Sprintf("landlock(abi=%d)", f.LandlockABI))
+	}
+	if f.UserNamespace {
+		parts = append(parts, "userns")
+	}
+	if f.MountNamespace {
+		parts = append(parts, "mountns")
+	}
+	if f.Seccomp {
+		parts = append(parts, "seccomp")
+	}
+	if f.NoNewPrivileges {
+		parts = append(parts, "no_new_privs")
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ",")
+}
+
+// NewSandboxPolicy maps the path rules of cfg to a sandbox policy. Rules for
+// the same path are merged by OR-ing their permissions, rules granting no
+// permissions are dropped, and the result is sorted by path so it is stable.
+//
+// Landlock rules always cover a whole hierarchy, so a rule with
+// allow_subpaths=false is marked non-recursive and only receives the access
+// bits that do not reach into children (see applyLandlock).
+//
+// Landlock cannot deny below a grant or judge file content, so rules the
+// sandbox could only enforce more loosely than Evaluate are left out: rules
+// with content predicates, rules outside their schedule now, and rules on or
+// above a sensitive path that do not set unsafe_allow_sensitive. Sensitive
+// names such as .env files below the remaining rules stay reachable to
+// commands; configs with a policy expression cannot grant exec at all (see
+// checkSandboxable).
+func NewSandboxPolicy(cfg *Config) *SandboxPolicy {
+	p := &SandboxPolicy{Landlock: LandlockAuto}
+	if cfg == nil {
+		return p
+	}
+	sc := cfg.Sandbox
+	if sc.Landlock != "" {
+		p.Landlock = sc.Landlock
+	}
+	p.UserNamespace = sc.UserNamespace || sc.MountNamespace
+	p.MountNamespace = sc.MountNamespace
+	p.Seccomp = sc.Seccomp
+
+	type key struct {
+		path      string
+		recursive bool
+	}
+	merged := map[key]Permission{}
+	now := cfg.Now()
+	for i := range cfg.Paths {
+		r := &cfg.Paths[i]
+		if r.parsedPerms&^PermGit == PermNone || r.cleanPath == "" {
+			continue
+		}
+		recursive := r.AllowSubpaths == nil || *r.AllowSubpaths
+		if r.hasContentPredicates() || !r.ActiveAt(now) ||
+			!r.UnsafeAllowSensitive && (cfg.sensitiveMatch(r.cleanPath) != "" || recursive && cfg.sensitivePathBelow(r.cleanPath) != "") {
+			continue
+		}
+		k := key{path: r.cleanPath, recursive: recursive}
+		merged[k] |= r.parsedPerms &^ PermGit
+	}
+	for k, perms := range merged {
+		p.Rules = append(p.Rules, SandboxRule{Path: k.path, Access: perms, Recursive: k.recursive})
+	}
+	sort.Slice(p.Rules, func(i, j int) bool {
+		if p.Rules[i].Path != p.Rules[j].Path {
+			return p.Rules[i].Path < p.Rules[j].Path
+		}
+		return p.Rules[i].Recursive && !p.Rules[j].Recursive
+	})
+	return p
+}
+
+// checkSandboxable refuses configs granting exec that the sandbox of
+// commands could not enforce. A policy expression decides on every access,
+// while a command is only confined by the path rules.
+func (c *Config) checkSandboxable() error {
+	if c.policy == nil {
+		return nil
+	}
+	for i := range c.Paths {
+		if r := &c.Paths[i]; r.parsedPerms&PermExec != 0 {
+			return fmt.Errorf("%w: rule %s grants exec, but policy.expr cannot restrict what commands access; remove exec or the policy", ErrParse, r.cleanPath)
+		}
+	}
+	return nil
+}
+
+// Validate checks that the policy only uses known settings.
+func (p *SandboxPolicy) Validate() error {
+	switch p.Landlock {
+	case LandlockAuto, LandlockRequired, LandlockOff:
+	default:
+		return fmt.Errorf("unknown landlock mode %q", p.Landlock)
+	}
+	return nil
+}
+
+// ActiveFeatures returns the isolation features that will actually be applied
+// for this policy given the features supported by the running kernel.
+func (p *SandboxPolicy) ActiveFeatures(supported SandboxFeatures) SandboxFeatures {
+	var f SandboxFeatures
+	if p.Landlock != LandlockOff && supported.Landlock {
+		f.Landlock = true
+		f.LandlockABI = supported.LandlockABI
+	}
+	f.UserNamespace = p.UserNamespace && supported.UserNamespace
+	f.MountNamespace = p.MountNamespace && f.UserNamespace && supported.MountNamespace
+	f.Seccomp = p.Seccomp && supported.Seccomp
+	f.NoNewPrivileges = (f.Landlock || f.Seccomp) && supported.NoNewPrivileges
+	return f
+}
+
+// ProbeSandboxFeatures reports the isolation mechanisms supported by the
+// running kernel.
+func ProbeSandboxFeatures() SandboxFeatures {
+	return probeSandboxFeatures()
+}
+
+// SandboxCommand builds a command that runs name with args under policy. The
+// current executable is re-executed with SandboxExecCommand so the
+// restrictions are applied in the child before the target is exec'd; the
+// namespaces are requested through the command's SysProcAttr.
+func SandboxCommand(ctx context.Context, policy *SandboxPolicy, name string, args ...string) (*exec.Cmd, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if policy.Landlock == LandlockRequired && !ProbeSandboxFeatures().Landlock {
+		return nil, fmt.Errorf("%w: landlock is required but not supported by this kernel", ErrSandbox)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("locate executable: %w", err)
+	}
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return nil, fmt.Errorf("encode sandbox policy: %w", err)
+	}
+
+	argv := append([]string{SandboxExecCommand, "--", name}, args...)
+	cmd := exec.CommandContext(ctx, self, argv...)
+	cmd.Env = append(os.Environ(), SandboxPolicyEnv+"="+string(data))
+	cmd.SysProcAttr = sandboxSysProcAttr(policy)
+	return cmd, nil
+}
+
+// RunSandboxed is the entrypoint of the re-executed helper. It decodes the
+// policy from SandboxPolicyEnv, restricts the current process and replaces it
+// with argv. It only returns on error.
+func RunSandboxed(argv []string) error {
+	if len(argv) == 0 {
+		return errors.New("no command to run")
+	}
+	raw := os.Getenv(SandboxPolicyEnv)
+	if raw == "" {
+		return fmt.Errorf("%w: %s is not set", ErrSandbox, SandboxPolicyEnv)
+	}
+	var policy SandboxPolicy
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		return fmt.Errorf("%w: decode policy: %v", ErrSandbox, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrSandbox, err)
+	}
+	path, err := exec.LookPath(argv[0])
+	if err != nil {
+		return err
+	}
+
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, SandboxPolicyEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	// Landlock, seccomp and no_new_privs are per-thread; keep applying them and
+	// the final exec on the same OS thread.
+	runtime.LockOSThread()
+	if err := applySandbox(&policy); err != nil {
+		return fmt.Errorf("%w: %v", ErrSandbox, err)
+	}
+	return execProcess(path, argv, env)
+}
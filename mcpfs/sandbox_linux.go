@@ -0,0 +1,348 @@
+//go:build linux
+
+package mcpfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Landlock access rights grouped by the ABI version that introduced them.
+const (
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockWriteAccessV1 = unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	landlockExecAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE
+)
+
+// landlockSystemRules grant the read and exec access a sandboxed command
+// needs to start at all: its binary, the dynamic loader and shared libraries,
+// and the files of /etc that dynamic loading, name and user lookups, time
+// zones and TLS read. The rest of /etc, such as /etc/ssl/private, stays
+// closed. They are added to every Landlock ruleset on top of the policy
+// rules.
+var landlockSystemRules = []SandboxRule{
+	{Path: "/bin", Access: PermRead | PermExec, Recursive: true},
+	{Path: "/sbin", Access: PermRead | PermExec, Recursive: true},
+	{Path: "/usr", Access: PermRead | PermExec, Recursive: true},
+	{Path: "/lib", Access: PermRead | PermExec, Recursive: true},
+	{Path: "/lib32", Access: PermRead | PermExec, Recursive: true},
+	{Path: "/lib64", Access: PermRead | PermExec, Recursive: true},
+	{Path: "/etc/ld.so.cache", Access: PermRead},
+	{Path: "/etc/ld.so.conf", Access: PermRead},
+	{Path: "/etc/ld.so.conf.d", Access: PermRead, Recursive: true},
+	{Path: "/etc/ld.so.preload", Access: PermRead},
+	{Path: "/etc/nsswitch.conf", Access: PermRead},
+	{Path: "/etc/resolv.conf", Access: PermRead},
+	{Path: "/etc/hosts", Access: PermRead},
+	{Path: "/etc/passwd", Access: PermRead},
+	{Path: "/etc/group", Access: PermRead},
+	{Path: "/etc/localtime", Access: PermRead},
+	{Path: "/etc/ssl/certs", Access: PermRead, Recursive: true},
+	{Path: "/dev/null", Access: PermRead | PermWrite},
+	{Path: "/dev/zero", Access: PermRead},
+	{Path: "/dev/urandom", Access: PermRead},
+}
+
+// seccompX32SyscallBit marks the syscall numbers of the x32 ABI on amd64. The
+// filter denies every number with it set so the x32 aliases of the syscalls
+// in seccompDenied cannot be used to get around it.
+const seccompX32SyscallBit = 0x40000000
+
+// seccompAuditArch maps GOARCH to the audit architecture checked by the
+// seccomp filter. Architectures missing here do not get a filter.
+var seccompAuditArch = map[string]uint32{
+	"amd64": unix.AUDIT_ARCH_X86_64,
+	"arm64": unix.AUDIT_ARCH_AARCH64,
+}
+
+// seccompDenied lists syscalls a sandboxed command never needs. They fail
+// with EPERM rather than killing the process so tools can report the error.
+var seccompDenied = []uint32{
+	unix.SYS_PTRACE,
+	unix.SYS_MOUNT,
+	unix.SYS_UMOUNT2,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_SETNS,
+	unix.SYS_UNSHARE,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_BPF,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_SWAPON,
+	unix.SYS_SWAPOFF,
+	unix.SYS_REBOOT,
+	unix.SYS_KEYCTL,
+	unix.SYS_ADD_KEY,
+	unix.SYS_REQUEST_KEY,
+}
+
+// seccompCloneNamespaces are the clone flags creating namespaces. The filter
+// denies clone with any of them, as it denies unshare and setns. CLONE_NEWTIME
+// is left out: clone takes the exit signal in the bits it uses, and only
+// unshare and clone3 accept it.
+const seccompCloneNamespaces = unix.CLONE_NEWNS | unix.CLONE_NEWCGROUP | unix.CLONE_NEWUTS |
+	unix.CLONE_NEWIPC | unix.CLONE_NEWUSER | unix.CLONE_NEWPID | unix.CLONE_NEWNET
+
+func probeSandboxFeatures() SandboxFeatures {
+	var f SandboxFeatures
+	if abi := landlockABI(); abi > 0 {
+		f.Landlock = true
+		f.LandlockABI = abi
+	}
+	f.UserNamespace = userNamespacesEnabled()
+	f.MountNamespace = f.UserNamespace
+	if _, ok := seccompAuditArch[runtime.GOARCH]; ok {
+		if _, err := unix.PrctlRetInt(unix.PR_GET_SECCOMP, 0, 0, 0, 0); err == nil {
+			f.Seccomp = true
+		}
+	}
+	if _, err := unix.PrctlRetInt(unix.PR_GET_NO_NEW_PRIVS, 0, 0, 0, 0); err == nil {
+		f.NoNewPrivileges = true
+	}
+	return f
+}
+
+// landlockABI returns the Landlock ABI version of the running kernel or 0 if
+// Landlock is unsupported or disabled.
+func landlockABI() int {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+func userNamespacesEnabled() bool {
+	if data, err := os.ReadFile("/proc/sys/kernel/unprivileged_userns_clone"); err == nil {
+		if strings.TrimSpace(string(data)) == "0" {
+			return false
+		}
+	}
+	data, err := os.ReadFile("/proc/sys/user/max_user_namespaces")
+	if err != nil {
+		return false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return err == nil && n > 0
+}
+
+func sandboxSysProcAttr(p *SandboxPolicy) *syscall.SysProcAttr {
+	attr := &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	supported := probeSandboxFeatures()
+	active := p.ActiveFeatures(supported)
+	if !active.UserNamespace {
+		return attr
+	}
+	uid, gid := os.Getuid(), os.Getgid()
+	attr.Cloneflags |= syscall.CLONE_NEWUSER
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	if active.MountNamespace {
+		attr.Cloneflags |= syscall.CLONE_NEWNS
+	}
+	return attr
+}
+
+// applySandbox restricts the current process according to p. It must be
+// called from the helper process right before exec.
+func applySandbox(p *SandboxPolicy) error {
+	active := p.ActiveFeatures(probeSandboxFeatures())
+	if p.Landlock == LandlockRequired && !active.Landlock {
+		return errors.New("landlock is required but not supported by this kernel")
+	}
+	if !active.Landlock && !active.Seccomp {
+		return nil
+	}
+
+	// Landlock and unprivileged seccomp filters both require no_new_privs.
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	if active.Landlock {
+		if err := applyLandlock(p.Rules, active.LandlockABI); err != nil {
+			return fmt.Errorf("landlock: %w", err)
+		}
+	}
+	if active.Seccomp {
+		if err := applySeccomp(); err != nil {
+			return fmt.Errorf("seccomp: %w", err)
+		}
+	}
+	return nil
+}
+
+// landlockAccess returns the Landlock access rights granted by perms. A
+// non-recursive rule only keeps the rights that apply to the path itself:
+// listing a directory or reading, writing and executing a regular file.
+func landlockAccess(perms Permission, recursive bool, isDir bool, handled uint64) uint64 {
+	var access uint64
+	if perms&PermRead != 0 {
+		access |= landlockReadAccess
+	}
+	if perms&PermWrite != 0 {
+		access |= landlockWriteAccessV1 | unix.LANDLOCK_ACCESS_FS_REFER | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	if perms&PermExec != 0 {
+		access |= landlockExecAccess
+	}
+	if !recursive {
+		if isDir {
+			access &= unix.LANDLOCK_ACCESS_FS_READ_DIR
+		} else {
+			access &^= unix.LANDLOCK_ACCESS_FS_READ_DIR
+		}
+	}
+	if !isDir {
+		// Directory-only rights are rejected by the kernel on files.
+		access &= unix.LANDLOCK_ACCESS_FS_READ_FILE |
+			unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+			unix.LANDLOCK_ACCESS_FS_EXECUTE |
+			unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	return access & handled
+}
+
+func landlockHandledAccess(abi int) uint64 {
+	handled := uint64(landlockReadAccess | landlockWriteAccessV1 | landlockExecAccess)
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+	return handled
+}
+
+func applyLandlock(rules []SandboxRule, abi int) error {
+	handled := landlockHandledAccess(abi)
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("create ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	for _, r := range slices.Concat(landlockSystemRules, rules) {
+		if err := addLandlockRule(ruleset, r, handled); err != nil {
+			return err
+		}
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("restrict self: %w", errno)
+	}
+	return nil
+}
+
+func addLandlockRule(ruleset int, r SandboxRule, handled uint64) error {
+	fd, err := unix.Open(r.Path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) {
+			// a rule for a path that does not exist yet grants nothing
+			return nil
+		}
+		return fmt.Errorf("open %q: %w", r.Path, err)
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("stat %q: %w", r.Path, err)
+	}
+	access := landlockAccess(r.Access, r.Recursive, st.Mode&unix.S_IFMT == unix.S_IFDIR, handled)
+	if access == 0 {
+		return nil
+	}
+	attr := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(fd)}
+	if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset),
+		unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("add rule for %q: %w", r.Path, errno)
+	}
+	return nil
+}
+
+// applySeccomp installs a filter that makes the syscalls in seccompDenied,
+// clone creating namespaces and any syscall of the x32 ABI fail with EPERM.
+// clone3 fails with ENOSYS, since the filter cannot read the flags it takes
+// in memory; libc then falls back to clone.
+func applySeccomp() error {
+	arch, ok := seccompAuditArch[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("unsupported architecture %s", runtime.GOARCH)
+	}
+
+	const (
+		offsetNr   = 0 // offsetof(struct seccomp_data, nr)
+		offsetArch = 4 // offsetof(struct seccomp_data, arch)
+		// offsetof(struct seccomp_data, args[0]), the low half of the clone
+		// flags on the little-endian architectures in seccompAuditArch.
+		offsetArg0 = 16
+	)
+	stmt := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	deny := unix.SECCOMP_RET_ERRNO | (uint32(unix.EPERM) & unix.SECCOMP_RET_DATA)
+	unsupported := unix.SECCOMP_RET_ERRNO | (uint32(unix.ENOSYS) & unix.SECCOMP_RET_DATA)
+
+	filter := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArch),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, deny),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetNr),
+		jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, seccompX32SyscallBit, 0, 1),
+		stmt(unix.BPF_RET|unix.BPF_K, deny),
+	}
+	for _, nr := range seccompDenied {
+		filter = append(filter,
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, 0, 1),
+			stmt(unix.BPF_RET|unix.BPF_K, deny),
+		)
+	}
+	filter = append(filter,
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE3, 0, 1),
+		stmt(unix.BPF_RET|unix.BPF_K, unsupported),
+		// Last, as loading the flags replaces the syscall number.
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE, 0, 3),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArg0),
+		jump(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, seccompCloneNamespaces, 0, 1),
+		stmt(unix.BPF_RET|unix.BPF_K, deny),
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+	)
+
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return err
+	}
+	return nil
+}
+
+func execProcess(path string, argv []string, env []string) error {
+	return unix.Exec(path, argv, env)
+}
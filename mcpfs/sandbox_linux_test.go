@@ -0,0 +1,60 @@
+package mcpfs_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"golang.org/x/sys/unix"
+)
+
+// namespaceProbeEnv makes the test binary report whether it can create
+// namespaces instead of running the tests, once it runs in the sandbox.
+const namespaceProbeEnv = "MCPFS_TEST_NAMESPACE_PROBE"
+
+func init() {
+	if os.Getenv(namespaceProbeEnv) != "" && (len(os.Args) < 2 || os.Args[1] != mcpfs.SandboxExecCommand) {
+		fmt.Print(probeNamespaces())
+		os.Exit(0)
+	}
+}
+
+// probeNamespaces tries clone with CLONE_NEWUSER and clone3 and reports
+// their errors.
+func probeNamespaces() string {
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWUSER}
+	_, _, errno := unix.Syscall(unix.SYS_CLONE3, 0, 0, 0)
+	return fmt.Sprintf("clone: %v\nclone3: %v\n", cmd.Run(), errno)
+}
+
+func TestSandboxCommand_SeccompDeniesNamespaces(t *testing.T) {
+	if !mcpfs.ProbeSandboxFeatures().Seccomp {
+		t.Skip("seccomp is not supported")
+	}
+	if out := probeNamespaces(); !strings.HasPrefix(out, "clone: <nil>\n") {
+		t.Skipf("cannot create user namespaces:\n%s", out)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := mcpfs.SandboxCommand(context.Background(), &mcpfs.SandboxPolicy{Landlock: mcpfs.LandlockOff, Seccomp: true}, self)
+	if err != nil {
+		t.Fatalf("SandboxCommand: %v", err)
+	}
+	cmd.Env = append(cmd.Env, namespaceProbeEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("probe: %v\n%s", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], syscall.EPERM.Error()) || lines[1] != "clone3: "+syscall.ENOSYS.Error() {
+		t.Fatalf("sandboxed probe =\n%s\nwant clone to fail with EPERM and clone3 with ENOSYS", out)
+	}
+}
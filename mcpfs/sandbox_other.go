@@ -0,0 +1,43 @@
+//go:build !linux
+
+package mcpfs
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func probeSandboxFeatures() SandboxFeatures {
+	return SandboxFeatures{}
+}
+
+func sandboxSysProcAttr(p *SandboxPolicy) *syscall.SysProcAttr {
+	return nil
+}
+
+func applySandbox(p *SandboxPolicy) error {
+	if p.Landlock == LandlockRequired {
+		return errors.New("landlock is only supported on linux")
+	}
+	return nil
+}
+
+// execProcess runs path as a child and exits with its status since not every
+// platform can replace the current process image.
+func execProcess(path string, argv []string, env []string) error {
+	cmd := exec.Command(path, argv[1:]...)
+	cmd.Env = env
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,261 @@
+package mcpfs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TestMain lets the test binary act as the sandbox helper App.Command
+// re-executes, as the mcpfs binary does through its hidden subcommand.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == mcpfs.SandboxExecCommand {
+		args := os.Args[2:]
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+		fmt.Fprintln(os.Stderr, mcpfs.RunSandboxed(args))
+		os.Exit(126)
+	}
+	os.Exit(m.Run())
+}
+
+func TestNewSandboxPolicy_MapsRules(t *testing.T) {
+	cfg, err := mcpfs.ParseConfigData([]byte(`
+paths:
+  - path: /srv/data
+    perms: [read]
+  - path: /srv/data
+    perms: [write]
+  - path: /srv/bin
+    perms: [read, exec]
+  - path: /srv/single
+    perms: [read]
+    allow_subpaths: false
+sandbox:
+  mount_namespace: true
+  seccomp: true
+`))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+
+	got := mcpfs.NewSandboxPolicy(cfg)
+	want := &mcpfs.SandboxPolicy{
+		Rules: []mcpfs.SandboxRule{
+			{Path: "/srv/bin", Access: mcpfs.PermRead | mcpfs.PermExec, Recursive: true},
+			{Path: "/srv/data", Access: mcpfs.PermRead | mcpfs.PermWrite, Recursive: true},
+			{Path: "/srv/single", Access: mcpfs.PermRead, Recursive: false},
+		},
+		Landlock:       mcpfs.LandlockAuto,
+		UserNamespace:  true,
+		MountNamespace: true,
+		Seccomp:        true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("policy mismatch:\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestNewSandboxPolicy_LeavesOutUnenforceableRules(t *testing.T) {
+	home := t.TempDir()
+	cfg, err := mcpfs.ParseConfigDataWith([]byte(`
+paths:
+  - path: "~"
+    perms: [read, exec]
+  - path: "~/.ssh"
+    perms: [read]
+  - path: "~/.gnupg"
+    perms: [read]
+    unsafe_allow_sensitive: true
+  - path: "~/src"
+    perms: [read, write]
+  - path: "~/docs"
+    perms: [read]
+    extensions: [md]
+  - path: "~/old"
+    perms: [read]
+    expires_at: 2000-01-01
+`), mcpfs.ParseOptions{Env: std.NewTestEnv(home, "me")})
+	if err != nil {
+		t.Fatalf("ParseConfigDataWith: %v", err)
+	}
+	got := mcpfs.NewSandboxPolicy(cfg).Rules
+	want := []mcpfs.SandboxRule{
+		{Path: filepath.Join(home, ".gnupg"), Access: mcpfs.PermRead, Recursive: true},
+		{Path: filepath.Join(home, "src"), Access: mcpfs.PermRead | mcpfs.PermWrite, Recursive: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sandbox rules:\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestParseConfigData_RejectsExecWithPolicy(t *testing.T) {
+	_, err := mcpfs.ParseConfigData([]byte(`
+paths:
+  - path: /usr/bin
+    perms: [read, exec]
+policy:
+  expr: "op == 'read'"
+`))
+	if !errors.Is(err, mcpfs.ErrParse) {
+		t.Fatalf("ParseConfigData = %v, want an error for exec with a policy", err)
+	}
+
+	cfg, err := mcpfs.ParseConfigData([]byte(`
+paths:
+  - path: /srv
+profiles:
+  tools:
+    paths:
+      - path: /usr/bin
+        perms: [exec]
+policy:
+  expr: "op == 'read'"
+`))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	if err := cfg.SelectProfile("tools"); !errors.Is(err, mcpfs.ErrParse) {
+		t.Fatalf("SelectProfile = %v, want an error for exec with a policy", err)
+	}
+}
+
+func TestSandboxPolicy_ActiveFeatures(t *testing.T) {
+	supported := mcpfs.SandboxFeatures{
+		Landlock:        true,
+		LandlockABI:     3,
+		UserNamespace:   true,
+		MountNamespace:  true,
+		Seccomp:         true,
+		NoNewPrivileges: true,
+	}
+
+	p := &mcpfs.SandboxPolicy{Landlock: mcpfs.LandlockOff}
+	if got := p.ActiveFeatures(supported); got != (mcpfs.SandboxFeatures{}) {
+		t.Fatalf("expected no active features, got %v", got)
+	}
+
+	p = &mcpfs.SandboxPolicy{Landlock: mcpfs.LandlockAuto, Seccomp: true}
+	got := p.ActiveFeatures(mcpfs.SandboxFeatures{Seccomp: true, NoNewPrivileges: true})
+	want := mcpfs.SandboxFeatures{Seccomp: true, NoNewPrivileges: true}
+	if got != want {
+		t.Fatalf("ActiveFeatures = %v, want %v", got, want)
+	}
+}
+
+func TestParseConfigData_RejectsUnknownLandlockMode(t *testing.T) {
+	_, err := mcpfs.ParseConfigData([]byte("sandbox:\n  landlock: maybe\n"))
+	if err == nil {
+		t.Fatal("expected error for unknown landlock mode")
+	}
+}
+
+func TestApp_CommandRunsSandboxed(t *testing.T) {
+	if runtime.GOOS != "linux" || !mcpfs.ProbeSandboxFeatures().Landlock {
+		t.Skip("landlock is not supported")
+	}
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("inside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "out.txt"), []byte("outside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, fmt.Sprintf(`
+paths:
+  - path: %s
+    perms: [read]
+  - path: /usr/bin/cat
+    perms: [exec]
+sandbox:
+  landlock: required
+  seccomp: true
+`, dir))
+	ctx := context.Background()
+
+	cmd, err := app.Command(ctx, nil, "cat", filepath.Join(dir, "in.txt"))
+	if err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil || string(out) != "inside" {
+		t.Fatalf("cat inside the rules = %q, %v", out, err)
+	}
+
+	cmd, err = app.Command(ctx, nil, "cat", filepath.Join(outside, "out.txt"))
+	if err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+	out, err = cmd.CombinedOutput()
+	if err == nil || strings.Contains(string(out), "outside") {
+		t.Fatalf("cat outside the rules = %q, %v; want permission denied", out, err)
+	}
+
+	// Only the files of /etc that starting and running commands needs are
+	// readable.
+	cmd, err = app.Command(ctx, nil, "cat", "/etc/passwd")
+	if err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("cat /etc/passwd = %q, %v", out, err)
+	}
+	cmd, err = app.Command(ctx, nil, "cat", "/etc/hostname")
+	if err != nil {
+		t.Fatalf("Command: %v", err)
+	}
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("cat /etc/hostname = %q, want permission denied", out)
+	}
+
+	if _, err := app.Command(ctx, nil, "ls", dir); err == nil {
+		t.Fatal("expected Command to refuse a binary without exec permission")
+	}
+}
+
+func TestRunCommandTool(t *testing.T) {
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("no cat in PATH")
+	}
+	if cat, err = filepath.Abs(cat); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "in.txt"), []byte("inside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestApp(t, fmt.Sprintf("paths:\n  - path: %s\n    perms: [read]\n  - path: %s\n    perms: [exec]\n", dir, cat))
+	cs := connect(t, app)
+
+	var out mcpfs.RunCommandOutput
+	callTool(t, cs, "run_command", map[string]any{"command": "cat", "args": []string{"in.txt"}, "dir": dir}, &out)
+	if out.ExitCode != 0 || out.Stdout != "inside" {
+		t.Fatalf("run_command cat = %+v", out)
+	}
+	callTool(t, cs, "run_command", map[string]any{"command": "cat", "args": []string{"missing.txt"}, "dir": dir}, &out)
+	if out.ExitCode == 0 || out.Stderr == "" {
+		t.Fatalf("run_command cat of a missing file = %+v", out)
+	}
+
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "run_command",
+		Arguments: map[string]any{"command": "ls", "args": []string{dir}},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("run_command without exec permission: res=%v err=%v, want tool error", res, err)
+	}
+}
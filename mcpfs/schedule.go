@@ -0,0 +1,156 @@
+package mcpfs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ruleTimeLayouts are the layouts not_before and expires_at are parsed
+// with. Layouts without a zone are in local time.
+var ruleTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseRuleTime parses a not_before or expires_at value. A date alone is
+// the start of that day.
+func parseRuleTime(s string) (time.Time, error) {
+	for _, layout := range ruleTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("want a date such as 2026-01-02, 2026-01-02 15:04 or RFC 3339 time")
+}
+
+// cronSchedule is a cron expression of five fields, minute, hour, day of
+// month, month and day of week, that matches the minutes it names. Fields
+// are *, a value, a range a-b or a list of them, each optionally with a
+// step /n. Months and days of week may be given by their first three
+// letters; Sunday is 0 or 7. As in cron, a time matches if its day of month
+// or its day of week does when both fields are restricted.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// cronField describes a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names of values from min
+}
+
+var cronFields = [5]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// parseCronSchedule parses a cron expression.
+func parseCronSchedule(s string) (*cronSchedule, error) {
+	fields := strings.Fields(s)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("want 5 fields, minute hour day-of-month month day-of-week, got %d", len(fields))
+	}
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := cronFields[i].parse(strings.ToLower(f))
+		if err != nil {
+			return nil, fmt.Errorf("%s %q: %v", cronFields[i].name, f, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is 0 and 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parse returns the set of values of the field s as a bit set.
+func (f cronField) parse(s string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(s, ",") {
+		expr, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			expr, step = part[:i], n
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case expr == "*":
+		case strings.Contains(expr, "-"):
+			a, b, _ := strings.Cut(expr, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("range %s is backwards", expr)
+			}
+		default:
+			v, err := f.value(expr)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// value parses a single value of the field.
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%d is outside %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// matches reports whether the minute of t matches the schedule.
+func (c *cronSchedule) matches(t time.Time) bool {
+	has := func(set uint64, v int) bool { return set&(1<<v) != 0 }
+	if !has(c.minute, t.Minute()) || !has(c.hour, t.Hour()) || !has(c.month, int(t.Month())) {
+		return false
+	}
+	dom, dow := has(c.dom, t.Day()), has(c.dow, int(t.Weekday()))
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,104 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+const scheduledConfig = `paths:
+  - path: /srv/release
+    perms: [read, write]
+    not_before: 2026-03-06 22:00
+    expires_at: 2026-03-07T02:00:00
+  - path: /srv/reports
+    perms: [write]
+    active_hours: "*/30 9-16 * * mon-fri"
+  - path: /srv/backup
+    perms: [write]
+    active_hours: "0-59 0 1 * sun"
+  - path: /srv
+    perms: [read]
+`
+
+func TestConfig_RuleSchedule(t *testing.T) {
+	cfg, err := mcpfs.ParseConfigData([]byte(scheduledConfig))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	at := func(s string) time.Time {
+		t.Helper()
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	clock := std.NewTestClock(at("2026-03-06 21:59"))
+	cfg.SetClock(clock)
+
+	for _, tc := range []struct {
+		now     string
+		path    string
+		allowed bool
+	}{
+		{"2026-03-06 21:59", "/srv/release/a", false},
+		{"2026-03-06 22:00", "/srv/release/a", true},
+		{"2026-03-07 01:59", "/srv/release/a", true},
+		{"2026-03-07 02:00", "/srv/release/a", false},
+		// Friday, then Saturday
+		{"2026-03-06 09:00", "/srv/reports/a", true},
+		{"2026-03-06 16:30", "/srv/reports/a", true},
+		{"2026-03-06 16:31", "/srv/reports/a", false},
+		{"2026-03-06 17:00", "/srv/reports/a", false},
+		{"2026-03-07 10:00", "/srv/reports/a", false},
+		// day of month or day of week, as in cron
+		{"2026-03-01 00:10", "/srv/backup/a", true},
+		{"2026-04-01 00:10", "/srv/backup/a", true},
+		{"2026-03-08 00:10", "/srv/backup/a", true},
+		{"2026-03-09 00:10", "/srv/backup/a", false},
+		{"2026-03-01 01:00", "/srv/backup/a", false},
+	} {
+		clock.Set(at(tc.now))
+		if got := cfg.IsAllowed(mcpfs.PermWrite, tc.path); got != tc.allowed {
+			t.Errorf("write %s at %s = %v, want %v", tc.path, tc.now, got, tc.allowed)
+		}
+	}
+
+	// An expired rule no longer shadows the rules after it.
+	clock.Set(at("2026-03-08 12:00"))
+	if r := cfg.MatchRule(mcpfs.PermRead, "/srv/release/a"); r == nil || r.CleanPath() != "/srv" {
+		t.Errorf("read after expiry matched %v, want /srv", r)
+	}
+}
+
+func TestConfig_RuleScheduleErrors(t *testing.T) {
+	for rule, want := range map[string]string{
+		"not_before: tomorrow":                               `invalid not_before "tomorrow"`,
+		"expires_at: 2026-13-01":                             `invalid expires_at "2026-13-01"`,
+		"not_before: 2026-02-01\n    expires_at: 2026-01-01": "expires_at is not after not_before",
+		"active_hours: \"* 9-17 * *\"":                       "want 5 fields",
+		"active_hours: \"* 17-9 * * *\"":                     "hour \"17-9\": range 17-9 is backwards",
+		"active_hours: \"60 * * * *\"":                       "minute \"60\": 60 is outside 0-59",
+		"active_hours: \"* * * * funday\"":                   `day of week "funday": invalid value "funday"`,
+		"active_hours: \"*/0 * * * *\"":                      `invalid step "0"`,
+	} {
+		data := "paths:\n  - path: /srv\n    " + rule + "\n"
+		_, err := mcpfs.ParseConfigData([]byte(data))
+		if !errors.Is(err, mcpfs.ErrParse) || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", rule, err, want)
+		}
+	}
+}
+
+func TestNewApp_SetsConfigClock(t *testing.T) {
+	app := newTestApp(t, "paths:\n  - path: /srv\n    expires_at: 2000-01-01\n")
+	// The test app's clock is at the zero time, long before the rule expires.
+	if !app.Cfg.IsAllowed(mcpfs.PermRead, "/srv/a") {
+		t.Fatal("rule evaluated with the system clock rather than Services.Clock")
+	}
+}
@@ -0,0 +1,136 @@
+package mcpfs
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	std "github.com/jlrickert/go-std/pkg"
+)
+
+// sensitiveHomePaths are the paths below the home directory that no rule
+// grants access to unless it sets unsafe_allow_sensitive: keys,
+// credentials and browser profiles.
+var sensitiveHomePaths = []string{
+	".ssh",
+	".gnupg",
+	".aws/credentials",
+	".mozilla",
+	".config/google-chrome",
+	".config/chromium",
+	".config/BraveSoftware",
+	".config/microsoft-edge",
+	"Library/Application Support/Google/Chrome",
+	"Library/Application Support/Firefox",
+	"Library/Application Support/BraveSoftware",
+	"Library/Application Support/Microsoft Edge",
+	"Library/Safari",
+	"AppData/Local/Google/Chrome/User Data",
+	"AppData/Local/Microsoft/Edge/User Data",
+	"AppData/Roaming/Mozilla/Firefox",
+}
+
+// sensitiveNames are glob patterns of file and directory names that are
+// sensitive wherever they are.
+var sensitiveNames = []string{".env*", "*.pem"}
+
+// sensitivePaths returns the absolute sensitive paths for env: those below
+// its home directory and the default config file. The config files a
+// config was read from are sensitive as well; see Config.SensitivePaths.
+func sensitivePaths(env std.Env) []string {
+	var paths []string
+	if home, err := env.GetHome(); err == nil && home != "" {
+		for _, p := range sensitiveHomePaths {
+			paths = append(paths, resolvePath(filepath.Join(home, filepath.FromSlash(p))))
+		}
+	}
+	if p, err := DefaultConfigPath(env); err == nil {
+		paths = append(paths, resolvePath(p))
+	}
+	return paths
+}
+
+// addSensitive adds the paths to the sensitive paths of c, with their
+// symlinks resolved.
+func (c *Config) addSensitive(paths ...string) {
+	for _, p := range paths {
+		if p = resolvePath(p); !slices.Contains(c.sensitive, p) {
+			c.sensitive = append(c.sensitive, p)
+		}
+	}
+}
+
+// SensitivePaths returns the paths and name patterns rules grant nothing on
+// unless they set unsafe_allow_sensitive, including the config files the
+// config was read from. Paths have their symlinks resolved.
+func (c *Config) SensitivePaths() []string {
+	return append(slices.Clone(c.sensitive), sensitiveNames...)
+}
+
+// sensitiveMatch returns the sensitive path path is or is below, or the
+// pattern of a sensitive name on it, or "" if path is not sensitive. path
+// must be clean.
+func (c *Config) sensitiveMatch(path string) string {
+	for _, p := range c.sensitive {
+		if withinPath(path, p) {
+			return p
+		}
+	}
+	return sensitiveName(path)
+}
+
+// sensitiveName returns the pattern of a sensitive name on the clean path,
+// or "" if there is none.
+func sensitiveName(path string) string {
+	for _, name := range strings.Split(filepath.ToSlash(path), "/") {
+		name = strings.ToLower(name)
+		for _, pattern := range sensitiveNames {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return pattern
+			}
+		}
+	}
+	return ""
+}
+
+// sensitiveBelow returns a note naming a sensitive path, config file or
+// file with a sensitive name below path, or "" if there is none. Moving or
+// deleting path, or putting something in its place, would move, delete or
+// replace what it contains. Names are found by walking the tree below path;
+// directories that cannot be read are skipped.
+func (c *Config) sensitiveBelow(path string) string {
+	if note := c.sensitivePathBelow(path); note != "" {
+		return note
+	}
+	var note string
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == path {
+			return nil
+		}
+		if pattern := sensitiveName(d.Name()); pattern != "" {
+			note = fmt.Sprintf("contains %s (%s)", p, pattern)
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return note
+}
+
+// sensitivePathBelow is sensitiveBelow for the configured sensitive paths
+// and config files only, without looking at the file system.
+func (c *Config) sensitivePathBelow(path string) string {
+	for _, p := range c.sensitive {
+		if p != path && withinPath(p, path) {
+			return "contains " + p
+		}
+	}
+	return ""
+}
+
+// sensitiveReason says why r does not grant access to a path matching the
+// sensitive path or pattern match.
+func (r *PathRule) sensitiveReason(path, match string) string {
+	return fmt.Sprintf("%s is sensitive (%s); rule %s does not set unsafe_allow_sensitive", path, match, r.cleanPath)
+}
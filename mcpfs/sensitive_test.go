@@ -0,0 +1,155 @@
+package mcpfs_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestConfig_SensitivePaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	env := std.NewTestEnv(home, "me")
+	configPath, err := mcpfs.DefaultConfigPath(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := mcpfs.ParseConfigDataWith([]byte(`paths:
+  - path: "~"
+    perms: [read, write]
+  - path: "~/.ssh/config"
+    unsafe_allow_sensitive: true
+`), mcpfs.ParseOptions{Env: env})
+	if err != nil {
+		t.Fatalf("ParseConfigDataWith: %v", err)
+	}
+	path := func(name string) string { return filepath.Join(home, filepath.FromSlash(name)) }
+
+	for name, want := range map[string]string{
+		"notes.txt":               "",
+		".aws/config":             "",
+		".envoy.txt":              "(.env*)",
+		"project/main.go":         "",
+		".ssh":                    "(" + path(".ssh") + ")",
+		".ssh/id_ed25519":         "(" + path(".ssh") + ")",
+		".gnupg/pubring.kbx":      "(" + path(".gnupg") + ")",
+		".aws/credentials":        "(" + path(".aws/credentials") + ")",
+		".mozilla/firefox/x":      "(" + path(".mozilla") + ")",
+		"project/.env":            "(.env*)",
+		"project/.env.local":      "(.env*)",
+		"project/.ENV":            "(.env*)",
+		"project/certs/tls.pem":   "(*.pem)",
+		"project/keys.pem/readme": "(*.pem)",
+	} {
+		err := cfg.Evaluate(mcpfs.Access{Op: mcpfs.PermRead, Path: path(name)}).Err()
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("%s: %v", name, err)
+		case want != "" && (err == nil || !strings.Contains(err.Error(), "is sensitive "+want+"; rule "+home+" does not set unsafe_allow_sensitive")):
+			t.Errorf("%s: err = %v, want it sensitive %s", name, err, want)
+		}
+	}
+	if err := cfg.Evaluate(mcpfs.Access{Op: mcpfs.PermWrite, Path: configPath}).Err(); err == nil || !strings.Contains(err.Error(), "is sensitive") {
+		t.Errorf("write to the default config file: err = %v", err)
+	}
+
+	// Moving or deleting a parent would take the sensitive path with it, but
+	// reading it lists what is there.
+	for _, name := range []string{".config", ".aws", ""} {
+		err := cfg.Evaluate(mcpfs.Access{Op: mcpfs.PermWrite, Path: path(name)}).Err()
+		if err == nil || !strings.Contains(err.Error(), "is sensitive (contains ") {
+			t.Errorf("write %q: err = %v, want it sensitive", name, err)
+		}
+		if !cfg.IsAllowed(mcpfs.PermRead, path(name)) {
+			t.Errorf("read %q denied", name)
+		}
+	}
+	if !cfg.IsAllowed(mcpfs.PermWrite, path(".config/mcpfs-notes.txt")) {
+		t.Error("write to a file beside the sensitive paths denied")
+	}
+
+	// Only the rule that sets unsafe_allow_sensitive grants a sensitive path.
+	if r := cfg.MatchRule(mcpfs.PermRead, path(".ssh/config")); r == nil || r != &cfg.Paths[1] {
+		t.Errorf("read ~/.ssh/config matched %v, want the unsafe_allow_sensitive rule", r)
+	}
+	if cfg.IsAllowed(mcpfs.PermWrite, path(".ssh/config")) {
+		t.Error("write ~/.ssh/config allowed by a rule granting read only")
+	}
+}
+
+func TestReadAndParseConfig_ConfigFilesAreSensitive(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml":      "include: [conf.d, 'extra/*/*.yaml']\npaths:\n  - path: .\n    perms: [read, write]\n",
+		"conf.d/10.yaml":   "paths:\n  - path: ../data\n",
+		"extra/a/x.yaml":   "paths: []\n",
+		"data/report.yaml": "a: 1\n",
+	})
+	cfg, err := mcpfs.ReadAndParseConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("ReadAndParseConfig: %v", err)
+	}
+	// New files in the directories the includes read would be merged too,
+	// and moving a directory holding a config file replaces it.
+	for _, name := range []string{"config.yaml", "conf.d/10.yaml", "conf.d/20.yaml", "extra/b/y.yaml", "extra", "."} {
+		if cfg.IsAllowed(mcpfs.PermWrite, filepath.Join(dir, name)) {
+			t.Errorf("write to config path %s allowed", name)
+		}
+	}
+	if !cfg.IsAllowed(mcpfs.PermWrite, filepath.Join(dir, "data/report.yaml")) {
+		t.Error("write to data/report.yaml denied")
+	}
+	got := cfg.SensitivePaths()
+	for _, want := range []string{filepath.Join(dir, "config.yaml"), filepath.Join(dir, "conf.d"), filepath.Join(dir, "extra"), ".env*", "*.pem"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("SensitivePaths() = %q, missing %q", got, want)
+		}
+	}
+}
+
+func TestMoveAndDeleteTools_RefuseDirectoriesWithSensitiveNames(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+
+	project := filepath.Join(work, "project")
+	for name, content := range map[string]string{
+		"project/main.go":         "package main\n",
+		"project/deploy/.env.prd": "TOKEN=secret\n",
+		"plain/readme.txt":        "hi\n",
+	} {
+		p := filepath.Join(work, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, call := range []mcp.CallToolParams{
+		{Name: "move_path", Arguments: map[string]any{"source": project, "destination": filepath.Join(work, "moved")}},
+		{Name: "delete_path", Arguments: map[string]any{"path": project, "recursive": true}},
+	} {
+		res, err := cs.CallTool(context.Background(), &call)
+		if err != nil || !res.IsError {
+			t.Fatalf("%s of a directory holding .env.prd: res=%v err=%v, want tool error", call.Name, res, err)
+		}
+		if text := res.Content[0].(*mcp.TextContent).Text; !strings.Contains(text, "(.env*)") {
+			t.Errorf("%s error %q does not name the sensitive file", call.Name, text)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(project, "deploy", ".env.prd")); err != nil {
+		t.Fatalf(".env.prd is gone: %v", err)
+	}
+
+	// Other directories and the files beside the sensitive one stay writable.
+	callTool(t, cs, "move_path", map[string]any{"source": filepath.Join(work, "plain"), "destination": filepath.Join(work, "moved")}, nil)
+	callTool(t, cs, "delete_path", map[string]any{"path": filepath.Join(project, "main.go")}, nil)
+}
@@ -2,7 +2,11 @@ package mcpfs
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
 
 	"github.com/jlrickert/go-std/pkg"
 )
@@ -17,22 +21,146 @@ type App struct {
 	Cfg      *Config
 	Logger   *slog.Logger
 	Services *Services
+
+	// Quotas accounts usage of write-granted rules with quotas.
+	Quotas *QuotaTracker
+
+	// Limiter enforces the configured rate and concurrency limits.
+	Limiter *RateLimiter
+
+	// Journal records before-images of mutated paths for undo. It is nil if
+	// the state directory cannot be determined.
+	Journal *Journal
+
+	// Transactions holds the open transactions of all sessions and the shadow
+	// areas their operations are staged in.
+	Transactions *Transactions
+
+	// Trash receives deleted paths. It is nil if trashing is disabled, in
+	// which case deletes are permanent.
+	Trash *Trash
+
+	// Redactor masks secrets in content returned by the read tools. It is
+	// nil if redaction is disabled.
+	Redactor *Redactor
+
+	processSession string
 }
 
 // NewApp constructs an App. It accepts the minimal fields the serve command uses.
-func NewApp(cfg *Config, logger *slog.Logger, services *Services) *App {
+// It fails if the redaction settings of cfg are invalid.
+func NewApp(cfg *Config, logger *slog.Logger, services *Services) (*App, error) {
 	if logger == nil {
 		logger = std.NewDiscardLogger()
 	}
 	if services == nil {
 		services = NewDefaultServices()
 	}
-	return &App{Cfg: cfg, Logger: logger, Services: services}
+	var (
+		limits  RateLimitConfig
+		journal JournalConfig
+		trash   TrashConfig
+		redact  RedactionConfig
+	)
+	if cfg != nil {
+		cfg.SetClock(services.Clock)
+		limits = cfg.RateLimits
+		journal = cfg.Journal
+		trash = cfg.Trash
+		redact = cfg.Redaction
+	}
+	app := &App{
+		Cfg:            cfg,
+		Logger:         logger,
+		Services:       services,
+		Quotas:         NewQuotaTracker(cfg),
+		Limiter:        NewRateLimiter(limits, services.Clock),
+		processSession: fmt.Sprintf("%s-%d", services.Clock.Now().UTC().Format("20060102T150405"), os.Getpid()),
+	}
+	if dir, err := DefaultJournalDir(services.Env); err == nil {
+		app.Journal = NewJournal(dir, services.Clock, journal)
+	}
+	if dir, err := DefaultTransactionDir(services.Env); err == nil {
+		app.Transactions = NewTransactions(dir, services.Clock)
+	} else {
+		app.Transactions = NewTransactions(filepath.Join(os.TempDir(), AppName+"-transactions"), services.Clock)
+	}
+	app.Transactions.SetQuotas(app.Quotas)
+	if !trash.Disabled {
+		dir := trash.Dir
+		if dir == "" {
+			dir, _ = DefaultTrashDir(services.Env)
+		}
+		if dir != "" {
+			app.Trash = NewTrash(dir, services.Clock)
+		}
+	}
+	if app.Journal != nil {
+		app.Journal.SetTrash(app.Trash)
+	}
+	if redact.Enabled {
+		r, err := NewRedactor(redact)
+		if err != nil {
+			return nil, fmt.Errorf("%w: redaction: %v", ErrParse, err)
+		}
+		app.Redactor = r
+	}
+	return app, nil
 }
 
 // Run executes the application. It logs that it's running and the cfg.Foo
 // value. It returns nil on success.
 func (a *App) Run(ctx context.Context) error {
 	a.Logger.LogAttrs(ctx, slog.LevelInfo, "application running", slog.Any("config", a.Cfg))
+	a.Logger.LogAttrs(ctx, slog.LevelInfo, "sandbox features",
+		slog.String("active", a.SandboxFeatures().String()),
+		slog.String("supported", ProbeSandboxFeatures().String()),
+	)
+	if err := a.Quotas.Recompute(); err != nil {
+		a.Logger.LogAttrs(ctx, slog.LevelWarn, "quota usage incomplete", slog.Any("error", err))
+	}
+	if a.Journal != nil {
+		if err := a.Journal.Prune(); err != nil {
+			a.Logger.LogAttrs(ctx, slog.LevelWarn, "journal prune failed", slog.Any("error", err))
+		}
+	}
+	if err := a.Transactions.Prune(DefaultTransactionMaxAge); err != nil {
+		a.Logger.LogAttrs(ctx, slog.LevelWarn, "transaction prune failed", slog.Any("error", err))
+	}
 	return nil
 }
+
+// SandboxFeatures reports which isolation features are applied to commands
+// executed on behalf of clients with the current configuration.
+func (a *App) SandboxFeatures() SandboxFeatures {
+	return NewSandboxPolicy(a.Cfg).ActiveFeatures(ProbeSandboxFeatures())
+}
+
+// commandPath returns the absolute path of the program name, looked up in
+// PATH unless it contains a slash.
+func commandPath(name string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// Command builds a command that runs name with args on behalf of the
+// principal of ctx. name is looked up in PATH and the config must grant exec
+// on the binary found, with the approval of approver where a rule requires
+// it; the command runs under the sandbox derived from the config (see
+// SandboxCommand).
+func (a *App) Command(ctx context.Context, approver Approver, name string, args ...string) (*exec.Cmd, error) {
+	path, err := commandPath(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkAccess(ctx, Access{Op: PermExec, Path: path}); err != nil {
+		return nil, err
+	}
+	if err := a.Confirm(ctx, approver, ApprovalRequest{Op: PermExec, Path: path, Action: "execute"}); err != nil {
+		return nil, err
+	}
+	return SandboxCommand(ctx, NewSandboxPolicy(a.Cfg), path, args...)
+}
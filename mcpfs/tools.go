@@ -0,0 +1,144 @@
+package mcpfs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// NewServer builds the MCP server exposing the filesystem tools.
+func (a *App) NewServer() *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: AppName, Version: Version}, &mcp.ServerOptions{
+		InitializedHandler: func(ctx context.Context, req *mcp.InitializedRequest) {
+			go a.watchSession(req.Session)
+		},
+	})
+	a.addFileTools(server)
+	a.addTransactionTools(server)
+	a.addJournalTools(server)
+	a.addTrashTools(server)
+	a.addGitTools(server)
+	a.addGitWriteTools(server)
+	a.addGoTools(server)
+	a.addOutlineTools(server)
+	a.addDataTools(server)
+	a.addExecTools(server)
+	return server
+}
+
+// watchSession releases the per-session state of ss once the client
+// disconnects. Open transactions are discarded.
+func (a *App) watchSession(ss *mcp.ServerSession) {
+	ss.Wait()
+	id := a.SessionID(ss)
+	for _, tx := range a.Transactions.EndSession(id) {
+		a.Logger.LogAttrs(context.Background(), slog.LevelInfo, "transaction expired with session",
+			slog.String("session", id), slog.String("transaction", tx.ID), slog.Int("ops", len(tx.Ops())))
+	}
+	a.Limiter.Forget(id)
+}
+
+// addTool registers a tool whose calls are subject to the app's rate and
+// concurrency limits. The handler's ctx carries the caller's principal, for
+// which the permission checks decide.
+func addTool[In, Out any](a *App, server *mcp.Server, tool *mcp.Tool, h mcp.ToolHandlerFor[In, Out]) {
+	mcp.AddTool(server, tool, func(ctx context.Context, req *mcp.CallToolRequest, in In) (*mcp.CallToolResult, Out, error) {
+		principal := a.Principal(req)
+		release, err := a.Limiter.Acquire(RateLimitKey{Session: a.SessionID(req.Session), Principal: principal, Tool: tool.Name})
+		if err != nil {
+			var zero Out
+			return nil, zero, err
+		}
+		defer release()
+		return h(context.WithValue(ctx, principalKey{}, principal), req, in)
+	})
+}
+
+// principalKey is the context key of the principal a tool call is made for.
+type principalKey struct{}
+
+// Principal returns the identity a tool call is made for, which the
+// principal rate limit and the policy expression see: the subject ("sub")
+// of the verified bearer token on transports that authenticate clients, and
+// otherwise the OS user the server runs as.
+func (a *App) Principal(req *mcp.CallToolRequest) string {
+	if req != nil && req.Extra != nil && req.Extra.TokenInfo != nil {
+		if sub, ok := req.Extra.TokenInfo.Extra["sub"].(string); ok && sub != "" {
+			return sub
+		}
+	}
+	user, _ := a.Services.Env.GetUser()
+	return user
+}
+
+// principalFrom returns the principal of the tool call ctx belongs to, or ""
+// outside tool calls, which Config.Evaluate takes as the OS user.
+func principalFrom(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// Serve runs the startup tasks and then serves MCP over t until the client
+// disconnects or ctx is cancelled.
+func (a *App) Serve(ctx context.Context, t mcp.Transport) error {
+	if err := a.Run(ctx); err != nil {
+		return err
+	}
+	return a.NewServer().Run(ctx, t)
+}
+
+// SessionID returns the identifier used to key per-session state such as the
+// journal and rate limits. Transports without session ids (stdio) share the
+// id generated for this process.
+func (a *App) SessionID(ss *mcp.ServerSession) string {
+	if ss != nil {
+		if id := ss.ID(); id != "" {
+			return id
+		}
+	}
+	return a.processSession
+}
+
+type UndoInput struct {
+	Count int `json:"count,omitempty" jsonschema:"number of operations to undo, newest first (default 1)"`
+}
+
+type UndoOutput struct {
+	Undone []UndoneOperation `json:"undone"`
+}
+
+type UndoneOperation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	Dest string `json:"dest,omitempty"`
+	Time string `json:"time" jsonschema:"when the original operation happened (RFC 3339)"`
+}
+
+func (a *App) addJournalTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name:        "undo",
+		Description: "Revert the most recent write, edit, move or delete operations made in this session.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in UndoInput) (*mcp.CallToolResult, UndoOutput, error) {
+		if a.Journal == nil {
+			return nil, UndoOutput{}, fmt.Errorf("%w: journal is not available", ErrJournal)
+		}
+		count := in.Count
+		if count <= 0 {
+			count = 1
+		}
+		undone, err := a.Undo(ctx, NewSessionApprover(a.Cfg, req.Session), a.SessionID(req.Session), count)
+		out := UndoOutput{Undone: []UndoneOperation{}}
+		for _, e := range undone {
+			out.Undone = append(out.Undone, UndoneOperation{
+				Op:   string(e.Op),
+				Path: e.Path,
+				Dest: e.Dest,
+				Time: e.Time.Format(time.RFC3339),
+			})
+		}
+		return nil, out, err
+	})
+}
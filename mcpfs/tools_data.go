@@ -0,0 +1,136 @@
+package mcpfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"gopkg.in/yaml.v3"
+)
+
+type QueryDataInput struct {
+	Path  string `json:"path" jsonschema:"absolute path of a JSON, YAML, TOML or CSV file"`
+	Query string `json:"query" jsonschema:"JSONPath or jq-style path, e.g. $.servers[0].name, .items[*].id, $..port or $.users[?(@.age > 30)]"`
+}
+
+type QueryDataOutput struct {
+	Format  string      `json:"format"`
+	Matches []DataMatch `json:"matches"`
+}
+
+type UpdateDataInput struct {
+	Path        string `json:"path" jsonschema:"absolute path of a JSON, YAML, TOML or CSV file"`
+	Query       string `json:"query" jsonschema:"path of the values to change; missing mapping keys are created when setting"`
+	Value       any    `json:"value,omitempty" jsonschema:"new value; required unless delete is set"`
+	Delete      bool   `json:"delete,omitempty" jsonschema:"remove the selected values instead of setting them"`
+	Transaction string `json:"transaction,omitempty" jsonschema:"stage the update in this transaction instead of applying it"`
+}
+
+type UpdateDataOutput struct {
+	Path    string `json:"path"`
+	Changed int    `json:"changed" jsonschema:"number of values set or removed"`
+	Staged  bool   `json:"staged"`
+}
+
+func (a *App) addDataTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name:        "query_data",
+		Description: "Select values from a JSON, YAML, TOML or CSV file with a JSONPath or jq-style path. CSV files are a list of rows keyed by the header.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in QueryDataInput) (*mcp.CallToolResult, QueryDataOutput, error) {
+		out := QueryDataOutput{Matches: []DataMatch{}}
+		path := cleanAbsPath(in.Path)
+		if err := a.checkRead(ctx, path); err != nil {
+			return nil, out, err
+		}
+		q, err := ParseDataPath(in.Query)
+		if err != nil {
+			return nil, out, err
+		}
+		data, err := readDataFile(path)
+		if err != nil {
+			return nil, out, err
+		}
+		doc, err := ParseData(path, data)
+		if err != nil {
+			return nil, out, err
+		}
+		out.Format = doc.Format
+		red := a.newOutputRedaction("query_data")
+		out.Matches, err = doc.query(q, func(n *yaml.Node) { red.Node(path, n) })
+		red.audit(ctx, req)
+		return nil, out, err
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name: "update_data",
+		Description: "Set or delete the values selected by a path in a JSON, YAML, TOML or CSV file. " +
+			"YAML keeps its comments and key order and JSON its key order; TOML and CSV files are rewritten in a normalized layout.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in UpdateDataInput) (*mcp.CallToolResult, UpdateDataOutput, error) {
+		out := UpdateDataOutput{Path: cleanAbsPath(in.Path)}
+		// The update parses the file and reports how many values matched,
+		// which says as much about its content as query_data does.
+		if err := a.checkRead(ctx, out.Path); err != nil {
+			return nil, out, err
+		}
+		if err := a.checkWrite(ctx, out.Path); err != nil {
+			return nil, out, err
+		}
+		q, err := ParseDataPath(in.Query)
+		if err != nil {
+			return nil, out, err
+		}
+		if DataFormat(out.Path) == "" {
+			return nil, out, fmt.Errorf("%q is not a JSON, YAML, TOML or CSV file", out.Path)
+		}
+		staged, _, err := a.stage(ctx, req.Session, in.Transaction, func(tx *Transaction) error {
+			data, mode, err := tx.Read(out.Path)
+			if err != nil {
+				return err
+			}
+			if len(data) > DefaultDataMaxSize {
+				return fmt.Errorf("%q is %s, larger than the limit of %s", out.Path, ByteSize(len(data)), ByteSize(DefaultDataMaxSize))
+			}
+			doc, err := ParseData(out.Path, data)
+			if err != nil {
+				return err
+			}
+			if in.Delete {
+				out.Changed, err = doc.Delete(q)
+			} else {
+				out.Changed, err = doc.Set(q, in.Value)
+			}
+			if err != nil {
+				return err
+			}
+			if out.Changed == 0 {
+				return fmt.Errorf("%q selects nothing in %q", in.Query, out.Path)
+			}
+			updated, err := doc.Bytes()
+			if err != nil {
+				return err
+			}
+			return tx.StageWrite(JournalEdit, out.Path, updated, mode)
+		})
+		out.Staged = staged
+		return nil, out, err
+	})
+}
+
+// readDataFile reads a file for query_data, refusing directories and files
+// larger than DefaultDataMaxSize. Symlinks are followed as the permission
+// checks follow them.
+func readDataFile(path string) ([]byte, error) {
+	file := resolvePath(path)
+	info, err := os.Lstat(file)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%q is a directory", path)
+	}
+	if info.Size() > DefaultDataMaxSize {
+		return nil, fmt.Errorf("%q is %s, larger than the limit of %s", path, ByteSize(info.Size()), ByteSize(DefaultDataMaxSize))
+	}
+	return readFileNoFollow(file)
+}
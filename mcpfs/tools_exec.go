@@ -0,0 +1,106 @@
+package mcpfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// DefaultCommandTimeout bounds how long run_command lets a command run
+	// when the caller sets no timeout.
+	DefaultCommandTimeout = time.Minute
+	// MaxCommandTimeout is the longest timeout run_command accepts.
+	MaxCommandTimeout = 10 * time.Minute
+	// MaxCommandOutput is how much of each of stdout and stderr run_command
+	// returns.
+	MaxCommandOutput = 1 << 20
+)
+
+type RunCommandInput struct {
+	Command string   `json:"command" jsonschema:"name of a program in PATH or its absolute path"`
+	Args    []string `json:"args,omitempty" jsonschema:"arguments passed to the program"`
+	Dir     string   `json:"dir,omitempty" jsonschema:"absolute working directory; must be readable"`
+	Timeout int      `json:"timeout_seconds,omitempty" jsonschema:"seconds before the command is killed (default 60, at most 600)"`
+}
+
+type RunCommandOutput struct {
+	ExitCode  int    `json:"exit_code" jsonschema:"exit status, or -1 if the command was killed"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated,omitempty" jsonschema:"whether output beyond the limit was dropped"`
+	TimedOut  bool   `json:"timed_out,omitempty"`
+}
+
+func (a *App) addExecTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name: "run_command",
+		Description: "Run a program the config grants exec on and return its exit code and output. " +
+			"The program runs under the sandbox of the config, which confines it to the path rules. " +
+			"Changes it makes are not journaled and cannot be undone.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in RunCommandInput) (*mcp.CallToolResult, RunCommandOutput, error) {
+		var out RunCommandOutput
+		timeout := DefaultCommandTimeout
+		if in.Timeout > 0 {
+			timeout = min(time.Duration(in.Timeout)*time.Second, MaxCommandTimeout)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		path, err := commandPath(in.Command)
+		if err != nil {
+			return nil, out, err
+		}
+		dir := ""
+		if in.Dir != "" {
+			dir = cleanAbsPath(in.Dir)
+			if err := a.checkRead(ctx, dir); err != nil {
+				return nil, out, err
+			}
+		}
+		cmd, err := a.Command(ctx, NewSessionApprover(a.Cfg, req.Session), path, in.Args...)
+		if err != nil {
+			return nil, out, err
+		}
+		cmd.Dir = dir
+		stdout, stderr := &cappedBuffer{max: MaxCommandOutput}, &cappedBuffer{max: MaxCommandOutput}
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		err = cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+		case errors.As(err, &exitErr):
+			out.ExitCode = exitErr.ExitCode()
+		default:
+			return nil, out, fmt.Errorf("run %s: %w", in.Command, err)
+		}
+		out.TimedOut = ctx.Err() != nil
+
+		red := a.newOutputRedaction("run_command")
+		out.Stdout = red.String(path, string(stdout.buf))
+		out.Stderr = red.String(path, string(stderr.buf))
+		red.audit(ctx, req)
+		out.Truncated = stdout.dropped || stderr.dropped
+		return nil, out, nil
+	})
+}
+
+// cappedBuffer keeps the first max bytes written to it and drops the rest.
+type cappedBuffer struct {
+	buf     []byte
+	max     int
+	dropped bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := min(len(p), b.max-len(b.buf))
+	b.buf = append(b.buf, p[:n]...)
+	if n < len(p) {
+		b.dropped = true
+	}
+	return len(p), nil
+}
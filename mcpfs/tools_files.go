@@ -0,0 +1,179 @@
+package mcpfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type WriteFileInput struct {
+	Path        string `json:"path" jsonschema:"absolute path of the file to create or replace"`
+	Content     string `json:"content"`
+	Transaction string `json:"transaction,omitempty" jsonschema:"stage the write in this transaction instead of applying it"`
+}
+
+type WriteFileOutput struct {
+	Path   string `json:"path"`
+	Bytes  int    `json:"bytes"`
+	Staged bool   `json:"staged"`
+}
+
+type EditFileInput struct {
+	Path        string `json:"path" jsonschema:"absolute path of the file to edit"`
+	OldText     string `json:"old_text" jsonschema:"exact text to replace"`
+	NewText     string `json:"new_text" jsonschema:"replacement text"`
+	ReplaceAll  bool   `json:"replace_all,omitempty" jsonschema:"replace every occurrence instead of requiring exactly one"`
+	Transaction string `json:"transaction,omitempty" jsonschema:"stage the edit in this transaction instead of applying it"`
+}
+
+type EditFileOutput struct {
+	Path         string `json:"path"`
+	Replacements int    `json:"replacements"`
+	Staged       bool   `json:"staged"`
+}
+
+type MovePathInput struct {
+	Source      string `json:"source" jsonschema:"absolute path to move"`
+	Destination string `json:"destination" jsonschema:"absolute path to move to; must not exist"`
+	Transaction string `json:"transaction,omitempty" jsonschema:"stage the move in this transaction instead of applying it"`
+}
+
+type MovePathOutput struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Staged      bool   `json:"staged"`
+}
+
+type DeletePathInput struct {
+	Path        string `json:"path" jsonschema:"absolute path of the file or directory to delete"`
+	Recursive   bool   `json:"recursive,omitempty" jsonschema:"delete a non-empty directory and everything below it"`
+	Transaction string `json:"transaction,omitempty" jsonschema:"stage the delete in this transaction instead of applying it"`
+}
+
+type DeletePathOutput struct {
+	Path      string `json:"path"`
+	Staged    bool   `json:"staged"`
+	Trashed   bool   `json:"trashed" jsonschema:"whether the item was moved to the trash rather than deleted permanently"`
+	TrashName string `json:"trash_name,omitempty" jsonschema:"name to pass to restore_from_trash"`
+}
+
+func (a *App) addFileTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name:        "write_file",
+		Description: "Create or replace a file with the given content.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in WriteFileInput) (*mcp.CallToolResult, WriteFileOutput, error) {
+		out := WriteFileOutput{Path: cleanAbsPath(in.Path), Bytes: len(in.Content)}
+		if err := a.checkAccess(ctx, Access{Op: PermWrite, Path: out.Path, Content: []byte(in.Content)}); err != nil {
+			return nil, out, err
+		}
+		staged, _, err := a.stage(ctx, req.Session, in.Transaction, func(tx *Transaction) error {
+			return tx.StageWrite(JournalWrite, out.Path, []byte(in.Content), 0)
+		})
+		out.Staged = staged
+		return nil, out, err
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "edit_file",
+		Description: "Replace text in a file. old_text must occur exactly once unless replace_all is set.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in EditFileInput) (*mcp.CallToolResult, EditFileOutput, error) {
+		out := EditFileOutput{Path: cleanAbsPath(in.Path)}
+		// The replacement count and the errors about old_text tell what the
+		// file contains.
+		if err := a.checkRead(ctx, out.Path); err != nil {
+			return nil, out, err
+		}
+		if err := a.checkWrite(ctx, out.Path); err != nil {
+			return nil, out, err
+		}
+		if in.OldText == "" {
+			return nil, out, fmt.Errorf("old_text must not be empty")
+		}
+		staged, _, err := a.stage(ctx, req.Session, in.Transaction, func(tx *Transaction) error {
+			data, mode, err := tx.Read(out.Path)
+			if err != nil {
+				return err
+			}
+			n := strings.Count(string(data), in.OldText)
+			switch {
+			case n == 0:
+				return fmt.Errorf("old_text not found in %q", out.Path)
+			case n > 1 && !in.ReplaceAll:
+				return fmt.Errorf("old_text occurs %d times in %q; make it unique or set replace_all", n, out.Path)
+			}
+			out.Replacements = n
+			edited := strings.ReplaceAll(string(data), in.OldText, in.NewText)
+			return tx.StageWrite(JournalEdit, out.Path, []byte(edited), mode)
+		})
+		out.Staged = staged
+		return nil, out, err
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "move_path",
+		Description: "Move or rename a file or directory. The destination must not exist.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in MovePathInput) (*mcp.CallToolResult, MovePathOutput, error) {
+		out := MovePathOutput{Source: cleanAbsPath(in.Source), Destination: cleanAbsPath(in.Destination)}
+		if err := a.checkWrite(ctx, out.Source); err != nil {
+			return nil, out, err
+		}
+		if err := a.checkAccess(ctx, Access{Op: PermWrite, Path: out.Destination, ContentPath: out.Source}); err != nil {
+			return nil, out, err
+		}
+		staged, _, err := a.stage(ctx, req.Session, in.Transaction, func(tx *Transaction) error {
+			return tx.StageMove(out.Source, out.Destination)
+		})
+		out.Staged = staged
+		return nil, out, err
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "delete_path",
+		Description: "Delete a file or directory. Deleted items are moved to the trash and can be restored with restore_from_trash.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in DeletePathInput) (*mcp.CallToolResult, DeletePathOutput, error) {
+		out := DeletePathOutput{Path: cleanAbsPath(in.Path)}
+		if err := a.checkWrite(ctx, out.Path); err != nil {
+			return nil, out, err
+		}
+		staged, applied, err := a.stage(ctx, req.Session, in.Transaction, func(tx *Transaction) error {
+			return tx.StageDelete(out.Path, in.Recursive)
+		})
+		out.Staged = staged
+		if len(applied) == 1 {
+			out.TrashName = applied[0].TrashName
+			out.Trashed = out.TrashName != ""
+		}
+		return nil, out, err
+	})
+}
+
+// stage runs fn against the open transaction id of the session, or, when id
+// is empty, against a one-off transaction that is committed right away. It
+// reports whether the operation was only staged, and otherwise returns the
+// applied operations.
+func (a *App) stage(ctx context.Context, ss *mcp.ServerSession, id string, fn func(*Transaction) error) (bool, []TxOp, error) {
+	session := a.SessionID(ss)
+	if id != "" {
+		tx, err := a.Transactions.Get(session, id)
+		if err != nil {
+			return false, nil, err
+		}
+		if err := fn(tx); err != nil {
+			return false, nil, err
+		}
+		return true, nil, nil
+	}
+
+	tx, err := a.Transactions.create(session)
+	if err != nil {
+		return false, nil, err
+	}
+	defer a.Transactions.Close(tx)
+	if err := fn(tx); err != nil {
+		return false, nil, err
+	}
+	applied, err := a.Commit(ctx, NewSessionApprover(a.Cfg, ss), tx)
+	return false, applied, err
+}
@@ -0,0 +1,401 @@
+package mcpfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type GitStatusInput struct {
+	Path string `json:"path" jsonschema:"absolute path inside the worktree; limits the result to paths below it"`
+}
+
+type GitDiffInput struct {
+	Path   string `json:"path" jsonschema:"absolute path inside the worktree; limits the result to paths below it"`
+	Staged bool   `json:"staged,omitempty" jsonschema:"compare the index with HEAD instead of the working tree with the index"`
+	Commit string `json:"commit,omitempty" jsonschema:"show the changes made by this revision instead"`
+}
+
+type GitDiffOutput struct {
+	Root  string        `json:"root"`
+	Files []GitFileDiff `json:"files"`
+}
+
+type GitLogInput struct {
+	Path     string `json:"path" jsonschema:"absolute path inside the worktree; only commits touching it are listed unless it is the root"`
+	Rev      string `json:"rev,omitempty" jsonschema:"revision to start from (default HEAD)"`
+	MaxCount int    `json:"max_count,omitempty" jsonschema:"maximum number of commits (default 20)"`
+}
+
+type GitLogOutput struct {
+	Root    string      `json:"root"`
+	Commits []GitCommit `json:"commits"`
+}
+
+type GitBlameInput struct {
+	Path string `json:"path" jsonschema:"absolute path of a file in the worktree"`
+	Rev  string `json:"rev,omitempty" jsonschema:"revision to blame (default HEAD)"`
+}
+
+type GitBlameOutput struct {
+	Root  string         `json:"root"`
+	Path  string         `json:"path" jsonschema:"path relative to the worktree root"`
+	Lines []GitBlameLine `json:"lines"`
+}
+
+type GitStageInput struct {
+	Paths []string `json:"paths" jsonschema:"absolute paths to stage; deleted files are staged as deletions"`
+}
+
+type GitStageOutput struct {
+	Root   string          `json:"root"`
+	Staged []string        `json:"staged" jsonschema:"staged paths relative to the worktree root"`
+	Files  []GitFileStatus `json:"files" jsonschema:"status of the worktree after staging"`
+}
+
+type GitCommitInput struct {
+	Path    string `json:"path" jsonschema:"absolute path inside the worktree"`
+	Message string `json:"message"`
+}
+
+type GitCommitOutput struct {
+	Root   string    `json:"root"`
+	Commit GitCommit `json:"commit"`
+}
+
+type GitBranchInput struct {
+	Path   string `json:"path" jsonschema:"absolute path inside the worktree"`
+	Action string `json:"action,omitempty" jsonschema:"list (default), create, delete or checkout"`
+	Name   string `json:"name,omitempty" jsonschema:"branch to create, delete or check out"`
+	From   string `json:"from,omitempty" jsonschema:"revision a created branch starts at (default HEAD)"`
+}
+
+type GitBranchOutput struct {
+	Root     string      `json:"root"`
+	Branches []GitBranch `json:"branches"`
+}
+
+func (a *App) addGitTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name:        "git_status",
+		Description: "Show the branch, HEAD and changed files of the git worktree containing path.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in GitStatusInput) (*mcp.CallToolResult, GitStatus, error) {
+		repo, rel, err := a.openGitRepo(ctx, in.Path)
+		if err != nil {
+			return nil, GitStatus{}, err
+		}
+		st, err := repo.Status(rel)
+		if err != nil {
+			return nil, GitStatus{}, err
+		}
+		return nil, *st, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "git_diff",
+		Description: "Show unstaged, staged or committed changes in the git worktree containing path as per-file unified diffs.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in GitDiffInput) (*mcp.CallToolResult, GitDiffOutput, error) {
+		out := GitDiffOutput{Files: []GitFileDiff{}}
+		repo, rel, err := a.openGitRepo(ctx, in.Path)
+		if err != nil {
+			return nil, out, err
+		}
+		out.Root = repo.Root
+		files, err := repo.Diff(GitDiffOptions{Prefix: rel, Staged: in.Staged, Commit: in.Commit})
+		if err != nil {
+			return nil, out, err
+		}
+		red := a.newOutputRedaction("git_diff")
+		for i := range files {
+			f := &files[i]
+			path := filepath.Join(repo.Root, filepath.FromSlash(f.Path))
+			// Reading the worktree does not grant every file in it, such as
+			// sensitive files and those failing content predicates.
+			if a.checkRead(ctx, path) != nil || f.OldPath != "" && a.checkRead(ctx, filepath.Join(repo.Root, filepath.FromSlash(f.OldPath))) != nil {
+				*f = GitFileDiff{Path: f.Path, OldPath: f.OldPath, Status: f.Status, Binary: f.Binary, Withheld: true}
+				continue
+			}
+			f.Patch = red.String(path, f.Patch)
+		}
+		red.audit(ctx, req)
+		out.Files = files
+		return nil, out, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "git_log",
+		Description: "List commits of the git repository containing path, newest first.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in GitLogInput) (*mcp.CallToolResult, GitLogOutput, error) {
+		out := GitLogOutput{Commits: []GitCommit{}}
+		repo, rel, err := a.openGitRepo(ctx, in.Path)
+		if err != nil {
+			return nil, out, err
+		}
+		out.Root = repo.Root
+		commits, err := repo.Log(GitLogOptions{Rev: in.Rev, Prefix: rel, Max: in.MaxCount})
+		if err != nil {
+			return nil, out, err
+		}
+		out.Commits = commits
+		return nil, out, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "git_blame",
+		Description: "Show the commit and author that last changed each line of a file.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in GitBlameInput) (*mcp.CallToolResult, GitBlameOutput, error) {
+		out := GitBlameOutput{Lines: []GitBlameLine{}}
+		repo, rel, err := a.openGitRepo(ctx, in.Path)
+		if err != nil {
+			return nil, out, err
+		}
+		out.Root, out.Path = repo.Root, rel
+		if rel == "" {
+			return nil, out, fmt.Errorf("%w: %q is the worktree root, not a file", ErrGit, in.Path)
+		}
+		lines, err := repo.Blame(rel, in.Rev)
+		if err != nil {
+			return nil, out, err
+		}
+		text := make([]string, len(lines))
+		for i, l := range lines {
+			text[i] = l.Text
+		}
+		red := a.newOutputRedaction("git_blame")
+		text = red.Lines(filepath.Join(repo.Root, filepath.FromSlash(rel)), text)
+		for i := range lines {
+			lines[i].Text = text[i]
+		}
+		red.audit(ctx, req)
+		out.Lines = lines
+		return nil, out, nil
+	})
+}
+
+func (a *App) addGitWriteTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name:        "git_stage",
+		Description: "Stage files for the next commit. Requires the git permission.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in GitStageInput) (*mcp.CallToolResult, GitStageOutput, error) {
+		out := GitStageOutput{Staged: []string{}, Files: []GitFileStatus{}}
+		if len(in.Paths) == 0 {
+			return nil, out, fmt.Errorf("%w: no paths to stage", ErrGit)
+		}
+		repo, _, err := a.openGitRepoFor(ctx, PermGit, in.Paths[0])
+		if err != nil {
+			return nil, out, err
+		}
+		out.Root = repo.Root
+		if out.Staged, err = a.stagedFiles(ctx, repo, in.Paths); err != nil {
+			return nil, out, err
+		}
+		approver := NewSessionApprover(a.Cfg, req.Session)
+		if err := a.Confirm(ctx, approver, ApprovalRequest{
+			Op: PermGit, Path: repo.Root, Action: "stage " + strings.Join(out.Staged, ", ") + " in",
+		}); err != nil {
+			return nil, out, err
+		}
+		if !a.Cfg.RequiresApproval(PermGit, repo.Root) {
+			for _, rel := range out.Staged {
+				if err := a.Confirm(ctx, approver, ApprovalRequest{
+					Op: PermGit, Path: filepath.Join(repo.Root, filepath.FromSlash(rel)), Action: "stage",
+				}); err != nil {
+					return nil, out, err
+				}
+			}
+		}
+		if err := repo.Stage(out.Staged); err != nil {
+			return nil, out, err
+		}
+		st, err := repo.Status("")
+		if err != nil {
+			return nil, out, err
+		}
+		out.Files = st.Files
+		return nil, out, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "git_commit",
+		Description: "Commit the staged changes. The configured author and trailer are applied. Requires the git permission.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in GitCommitInput) (*mcp.CallToolResult, GitCommitOutput, error) {
+		var out GitCommitOutput
+		repo, _, err := a.openGitRepoFor(ctx, PermGit, in.Path)
+		if err != nil {
+			return nil, out, err
+		}
+		out.Root = repo.Root
+		if err := a.Confirm(ctx, NewSessionApprover(a.Cfg, req.Session), ApprovalRequest{
+			Op: PermGit, Path: repo.Root, Action: "commit " + strconv.Quote(in.Message) + " to",
+		}); err != nil {
+			return nil, out, err
+		}
+		var cfg GitConfig
+		if a.Cfg != nil {
+			cfg = a.Cfg.Git
+		}
+		c, err := repo.Commit(in.Message, cfg, a.Services.Clock.Now())
+		if err != nil {
+			return nil, out, err
+		}
+		out.Commit = *c
+		return nil, out, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name: "git_branch",
+		Description: "List, create, delete or check out local branches. Changing branches requires the git " +
+			"permission; checkout also requires write since it rewrites the worktree.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in GitBranchInput) (*mcp.CallToolResult, GitBranchOutput, error) {
+		out := GitBranchOutput{Branches: []GitBranch{}}
+		var (
+			repo *GitRepo
+			err  error
+		)
+		switch in.Action {
+		case "", "list":
+			repo, _, err = a.openGitRepo(ctx, in.Path)
+		case "create", "delete":
+			repo, _, err = a.openGitRepoFor(ctx, PermGit, in.Path)
+		case "checkout":
+			repo, _, err = a.openGitRepoFor(ctx, PermGit|PermWrite, in.Path)
+		default:
+			err = fmt.Errorf("%w: unknown branch action %q", ErrGit, in.Action)
+		}
+		if err != nil {
+			return nil, out, err
+		}
+		out.Root = repo.Root
+		if in.Action == "checkout" {
+			if err := a.checkCheckout(ctx, repo, in.Name); err != nil {
+				return nil, out, err
+			}
+		}
+		if in.Action != "" && in.Action != "list" {
+			if err := a.Confirm(ctx, NewSessionApprover(a.Cfg, req.Session), ApprovalRequest{
+				Op: PermGit, Path: repo.Root, Action: in.Action + " branch " + strconv.Quote(in.Name) + " in",
+			}); err != nil {
+				return nil, out, err
+			}
+		}
+		switch in.Action {
+		case "create":
+			_, err = repo.CreateBranch(in.Name, in.From)
+		case "delete":
+			err = repo.DeleteBranch(in.Name)
+		case "checkout":
+			err = repo.Checkout(in.Name)
+		}
+		if err != nil {
+			return nil, out, err
+		}
+		branches, err := repo.Branches()
+		if err != nil {
+			return nil, out, err
+		}
+		out.Branches = branches
+		return nil, out, nil
+	})
+}
+
+// checkCheckout checks that the config grants write on every file checking
+// out branch changes. Checkout rewrites them outside a transaction, so it
+// refuses to change files whose rule requires approval or sets quotas or
+// content predicates, which only a transaction can enforce.
+func (a *App) checkCheckout(ctx context.Context, repo *GitRepo, branch string) error {
+	changed, err := repo.CheckoutChanges(branch)
+	if err != nil {
+		return err
+	}
+	for _, rel := range changed {
+		p := filepath.Join(repo.Root, filepath.FromSlash(rel))
+		if err := a.checkWrite(ctx, p); err != nil {
+			return err
+		}
+		r := a.Cfg.MatchRule(PermWrite, p)
+		if a.Cfg.RequiresApproval(PermWrite, p) || r != nil && (r.MaxFileSize > 0 || r.hasAggregateQuota() || r.hasContentPredicates()) {
+			return fmt.Errorf("%w: checkout of %q would change %q, whose rule requires approval or sets quotas or content predicates",
+				ErrPermissionDenied, branch, p)
+		}
+	}
+	return nil
+}
+
+// stagedFiles returns the worktree-relative files git_stage stages for
+// paths. A directory stands for the changed files below it, each of which
+// the config must grant git on: staging the directory as a whole would also
+// stage files that no rule, or only a narrower one, grants.
+func (a *App) stagedFiles(ctx context.Context, repo *GitRepo, paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		p = cleanAbsPath(p)
+		if !a.allowed(ctx, PermGit, p) {
+			return nil, fmt.Errorf("%w: git on %q", ErrPermissionDenied, p)
+		}
+		rel, err := repo.RelPath(p)
+		if err != nil {
+			return nil, err
+		}
+		if rel == "" || gitPathMatch(rel, ".git") {
+			return nil, fmt.Errorf("%w: cannot stage %q", ErrGit, p)
+		}
+		if info, err := os.Lstat(p); err != nil || !info.IsDir() {
+			files = append(files, rel)
+			continue
+		}
+		st, err := repo.Status(rel)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range st.Files {
+			if !f.unstaged() {
+				continue
+			}
+			if err := a.checkAccess(ctx, Access{Op: PermGit, Path: filepath.Join(repo.Root, filepath.FromSlash(f.Path))}); err != nil {
+				return nil, err
+			}
+			files = append(files, f.Path)
+		}
+	}
+	return files, nil
+}
+
+// openGitRepoFor opens the repository containing path for a tool that changes
+// it: besides being readable, its worktree must be granted every permission
+// in perms.
+func (a *App) openGitRepoFor(ctx context.Context, perms Permission, path string) (*GitRepo, string, error) {
+	repo, rel, err := a.openGitRepo(ctx, path)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, op := range []Permission{PermRead, PermWrite, PermExec, PermGit} {
+		if perms&op != 0 && !a.allowed(ctx, op, repo.Root) {
+			return nil, "", fmt.Errorf("%w: %s on worktree %q", ErrPermissionDenied, op, repo.Root)
+		}
+	}
+	return repo, rel, nil
+}
+
+// openGitRepo opens the repository whose worktree contains path. The git
+// tools expose the history of the whole worktree, so the config must grant
+// read on the worktree and everything below it, not just on path.
+func (a *App) openGitRepo(ctx context.Context, path string) (*GitRepo, string, error) {
+	path = cleanAbsPath(path)
+	if err := a.checkRead(ctx, path); err != nil {
+		return nil, "", err
+	}
+	repo, err := OpenGitRepo(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if !a.allowed(ctx, PermRead, repo.Root) || !a.allowed(ctx, PermRead, filepath.Join(repo.Root, ".git")) {
+		return nil, "", fmt.Errorf("%w: worktree %q is not readable", ErrPermissionDenied, repo.Root)
+	}
+	rel, err := repo.RelPath(path)
+	return repo, rel, err
+}
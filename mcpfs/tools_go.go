@@ -0,0 +1,136 @@
+package mcpfs
+
+import (
+	"context"
+	"os"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type ListSymbolsInput struct {
+	Path         string `json:"path" jsonschema:"absolute path of a Go file or package directory"`
+	ExportedOnly bool   `json:"exported_only,omitempty"`
+}
+
+type ListSymbolsOutput struct {
+	Symbols []GoSymbol `json:"symbols"`
+}
+
+type GoPositionInput struct {
+	Path   string `json:"path" jsonschema:"absolute path of a Go file"`
+	Line   int    `json:"line" jsonschema:"1-based line of the identifier"`
+	Column int    `json:"column" jsonschema:"1-based byte column of the identifier"`
+}
+
+type FindReferencesInput struct {
+	GoPositionInput
+	MaxResults int `json:"max_results,omitempty" jsonschema:"maximum number of references (default 200)"`
+}
+
+type FindReferencesOutput struct {
+	Definition GoDefinition `json:"definition"`
+	References []GoLocation `json:"references"`
+	Truncated  bool         `json:"truncated,omitempty"`
+}
+
+// defaultMaxReferences caps find_references when max_results is not set.
+const defaultMaxReferences = 200
+
+func (a *App) addGoTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name:        "list_symbols",
+		Description: "List the functions, methods, types, fields, variables and constants declared in a Go file or package directory, with positions and signatures.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in ListSymbolsInput) (*mcp.CallToolResult, ListSymbolsOutput, error) {
+		out := ListSymbolsOutput{Symbols: []GoSymbol{}}
+		g, err := a.goCode(ctx, in.Path)
+		if err != nil {
+			return nil, out, err
+		}
+		syms, err := g.Symbols(in.Path)
+		if err != nil {
+			return nil, out, err
+		}
+		red := a.newOutputRedaction("list_symbols")
+		for _, s := range syms {
+			if !in.ExportedOnly || s.Exported {
+				s.Signature, s.Doc = red.String(s.File, s.Signature), red.String(s.File, s.Doc)
+				out.Symbols = append(out.Symbols, s)
+			}
+		}
+		red.audit(ctx, req)
+		return nil, out, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "find_definition",
+		Description: "Find the declaration of the Go identifier at a position. Packages of the same module are type-checked from source; identifiers from other modules are reported by import path only.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in GoPositionInput) (*mcp.CallToolResult, GoDefinition, error) {
+		g, err := a.goCode(ctx, in.Path)
+		if err != nil {
+			return nil, GoDefinition{}, err
+		}
+		def, err := g.Definition(in.Path, in.Line, in.Column)
+		if err != nil {
+			return nil, GoDefinition{}, err
+		}
+		red := a.newOutputRedaction("find_definition")
+		red.definition(def)
+		red.audit(ctx, req)
+		return nil, *def, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "find_references",
+		Description: "Find the uses of the Go identifier at a position in all readable packages of its module, including tests.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in FindReferencesInput) (*mcp.CallToolResult, FindReferencesOutput, error) {
+		out := FindReferencesOutput{References: []GoLocation{}}
+		g, err := a.goCode(ctx, in.Path)
+		if err != nil {
+			return nil, out, err
+		}
+		limit := in.MaxResults
+		if limit <= 0 {
+			limit = defaultMaxReferences
+		}
+		ctx, cancel := context.WithTimeout(ctx, DefaultGoSearchTimeout)
+		defer cancel()
+		def, refs, truncated, err := g.References(ctx, in.Path, in.Line, in.Column, limit)
+		if err != nil {
+			return nil, out, err
+		}
+		red := a.newOutputRedaction("find_references")
+		red.definition(def)
+		for i := range refs {
+			refs[i].Text = red.String(refs[i].File, refs[i].Text)
+		}
+		red.audit(ctx, req)
+		out.Definition, out.Truncated = *def, truncated
+		if refs != nil {
+			out.References = refs
+		}
+		return nil, out, nil
+	})
+}
+
+// definition masks the secrets in the source of def.
+func (o *outputRedaction) definition(def *GoDefinition) {
+	if def.Location == nil {
+		return
+	}
+	def.Signature = o.String(def.Location.File, def.Signature)
+	def.Location.Text = o.String(def.Location.File, def.Location.Text)
+}
+
+// goCode prepares Go navigation for path, which must be readable. Other
+// files and directories of the module are only read where the config
+// grants read on them.
+func (a *App) goCode(ctx context.Context, path string) (*GoCode, error) {
+	path = cleanAbsPath(path)
+	if err := a.checkRead(ctx, path); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return NewGoCode(path, func(p string) bool { return a.allowed(ctx, PermRead, p) })
+}
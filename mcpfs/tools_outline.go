@@ -0,0 +1,88 @@
+package mcpfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type FileOutlineInput struct {
+	Path     string `json:"path" jsonschema:"absolute path of a Go, Markdown, YAML, JSON or TOML file"`
+	MaxDepth int    `json:"max_depth,omitempty" jsonschema:"maximum nesting depth of the outline (default unlimited)"`
+}
+
+func (a *App) addOutlineTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name:         "file_outline",
+		OutputSchema: outlineSchema(),
+		Description: "Return the hierarchical outline of a file (declarations of Go files, headings of Markdown, keys of YAML and JSON, " +
+			"tables and keys of TOML) with the line range of every entry, to find the parts of a large file worth reading.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in FileOutlineInput) (*mcp.CallToolResult, Outline, error) {
+		path := cleanAbsPath(in.Path)
+		if err := a.checkRead(ctx, path); err != nil {
+			return nil, Outline{}, err
+		}
+		file := resolvePath(path)
+		info, err := os.Lstat(file)
+		if err != nil {
+			return nil, Outline{}, err
+		}
+		if info.IsDir() {
+			return nil, Outline{}, fmt.Errorf("%q is a directory", path)
+		}
+		if info.Size() > DefaultOutlineMaxSize {
+			return nil, Outline{}, fmt.Errorf("%q is %s, larger than the outline limit of %s",
+				path, ByteSize(info.Size()), ByteSize(DefaultOutlineMaxSize))
+		}
+		data, err := readFileNoFollow(file)
+		if err != nil {
+			return nil, Outline{}, err
+		}
+		ctx, cancel := context.WithTimeout(ctx, DefaultOutlineTimeout)
+		defer cancel()
+		out, err := FileOutline(ctx, path, data, in.MaxDepth)
+		if err != nil {
+			return nil, Outline{}, err
+		}
+		// Details hold short values of data files, which may be secrets.
+		red := a.newOutputRedaction("file_outline")
+		red.Outline(path, out.Nodes)
+		red.audit(ctx, req)
+		return nil, *out, nil
+	})
+}
+
+// outlineSchema describes Outline. It is written by hand because schema
+// inference does not support the recursive OutlineNode.
+func outlineSchema() *jsonschema.Schema {
+	str := func(desc string) *jsonschema.Schema { return &jsonschema.Schema{Type: "string", Description: desc} }
+	nodes := func() *jsonschema.Schema {
+		return &jsonschema.Schema{Type: "array", Items: &jsonschema.Schema{Ref: "#/$defs/node"}}
+	}
+	return &jsonschema.Schema{
+		Type:     "object",
+		Required: []string{"language", "nodes"},
+		Properties: map[string]*jsonschema.Schema{
+			"language":  str(""),
+			"nodes":     nodes(),
+			"truncated": {Type: "boolean", Description: "whether nodes were dropped because of the depth or node limit"},
+		},
+		Defs: map[string]*jsonschema.Schema{
+			"node": {
+				Type:     "object",
+				Required: []string{"name", "kind", "line", "end_line"},
+				Properties: map[string]*jsonschema.Schema{
+					"name":     str(""),
+					"kind":     str("func, method, type, field, var, const, h1-h6, key, item, table or array_table"),
+					"detail":   str("signature or short value"),
+					"line":     {Type: "integer"},
+					"end_line": {Type: "integer"},
+					"children": nodes(),
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,411 @@
+package mcpfs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// newTestApp parses config and returns an app whose state lives in t's
+// temporary directory.
+func newTestApp(t *testing.T, config string) *mcpfs.App {
+	t.Helper()
+	cfg, err := mcpfs.ParseConfigData([]byte(config))
+	if err != nil {
+		t.Fatalf("ParseConfigData: %v", err)
+	}
+	home := t.TempDir()
+	env := std.NewTestEnv(home, "testuser")
+	env.Set("XDG_STATE_HOME", filepath.Join(home, "state"))
+	app, err := mcpfs.NewApp(cfg, nil, &mcpfs.Services{Env: env, Clock: &std.TestClock{}})
+	if err != nil {
+		t.Fatalf("NewApp: %v", err)
+	}
+	return app
+}
+
+// connect serves app over in-memory transports and returns the client side.
+func connect(t *testing.T, app *mcpfs.App) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	ct, st := mcp.NewInMemoryTransports()
+	if _, err := app.NewServer().Connect(ctx, st, nil); err != nil {
+		t.Fatalf("server connect: %v", err)
+	}
+	cs, err := mcp.NewClient(&mcp.Implementation{Name: "client"}, nil).Connect(ctx, ct, nil)
+	if err != nil {
+		t.Fatalf("client connect: %v", err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+// callTool calls name with args, fails the test on a tool error and decodes
+// the structured output into out.
+func callTool(t *testing.T, cs *mcp.ClientSession, name string, args any, out any) {
+	t.Helper()
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("CallTool %s: %v", name, err)
+	}
+	if res.IsError {
+		t.Fatalf("tool %s failed: %v", name, res.Content)
+	}
+	if out == nil {
+		return
+	}
+	data, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		t.Fatalf("decode %s output: %v", name, err)
+	}
+}
+
+func TestUndoTool(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+
+	target := filepath.Join(work, "a.txt")
+	os.WriteFile(target, []byte("before"), 0o644)
+	if _, err := app.Journal.Record(app.SessionID(nil), mcpfs.JournalWrite, target, ""); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(target, []byte("after"), 0o644)
+
+	var out mcpfs.UndoOutput
+	callTool(t, cs, "undo", map[string]any{}, &out)
+	if len(out.Undone) != 1 || out.Undone[0].Path != target {
+		t.Fatalf("unexpected undo output: %+v", out)
+	}
+	if got := readFile(t, target); got != "before" {
+		t.Fatalf("content after undo = %q", got)
+	}
+}
+
+func TestUndoTool_ChecksLikeCommit(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, `version: "2.0"
+paths:
+  - path: `+work+`/release
+    perms: [read, write]
+    approval: required
+  - path: `+work+`
+    perms: [read, write]
+    max_file_size: 8
+`)
+	cs := connect(t, app)
+	for name, before := range map[string]string{"release/a.txt": "v1", "big.txt": "0123456789"} {
+		target := filepath.Join(work, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(target), 0o755)
+		os.WriteFile(target, []byte(before), 0o644)
+		if _, err := app.Journal.Record(app.SessionID(nil), mcpfs.JournalWrite, target, ""); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(target, []byte("new"), 0o644)
+
+		// Undo asks for approval, which the test client cannot give, and
+		// restoring the ten bytes of big.txt exceeds max_file_size.
+		res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: "undo", Arguments: map[string]any{}})
+		if err != nil || !res.IsError {
+			t.Fatalf("undo of %s: res=%v err=%v, want tool error", name, res, err)
+		}
+		if got := readFile(t, target); got != "new" {
+			t.Errorf("%s after refused undo = %q", name, got)
+		}
+		entries, _ := app.Journal.Entries(app.SessionID(nil))
+		for _, e := range entries {
+			app.Journal.Discard(&e)
+		}
+	}
+}
+
+func TestWriteTool_PrunesJournal(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+	target := filepath.Join(work, "a.txt")
+
+	callTool(t, cs, "write_file", map[string]any{"path": target, "content": "one"}, nil)
+	app.Services.Clock.(*std.TestClock).Advance(mcpfs.DefaultJournalMaxAge + time.Hour)
+	callTool(t, cs, "write_file", map[string]any{"path": target, "content": "two"}, nil)
+
+	// The entry of the first write expired during the session.
+	entries, err := app.Journal.Entries(app.SessionID(nil))
+	if err != nil || len(entries) != 1 || !entries[0].Existed {
+		t.Fatalf("journal entries = %+v, %v", entries, err)
+	}
+}
+
+func TestDeleteAndRestoreTools(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+
+	dir := filepath.Join(work, "dir")
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "f.txt"), []byte("data"), 0o644)
+
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "delete_path",
+		Arguments: map[string]any{"path": dir},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("non-recursive delete of non-empty dir: res=%v err=%v, want tool error", res, err)
+	}
+	res, err = cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "delete_path",
+		Arguments: map[string]any{"path": "/etc/hostname"},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("delete outside rules: res=%v err=%v, want tool error", res, err)
+	}
+
+	var del mcpfs.DeletePathOutput
+	callTool(t, cs, "delete_path", map[string]any{"path": dir, "recursive": true}, &del)
+	if !del.Trashed || del.TrashName != "dir" {
+		t.Fatalf("delete_path = %+v", del)
+	}
+	if _, err := os.Lstat(dir); err == nil {
+		t.Fatal("dir still exists after delete_path")
+	}
+
+	var list mcpfs.ListTrashOutput
+	callTool(t, cs, "list_trash", map[string]any{}, &list)
+	if len(list.Items) != 1 || list.Items[0].OriginalPath != dir || !list.Items[0].IsDir {
+		t.Fatalf("list_trash = %+v", list)
+	}
+
+	var restored mcpfs.RestoreFromTrashOutput
+	callTool(t, cs, "restore_from_trash", map[string]any{"name": del.TrashName}, &restored)
+	if restored.Path != dir {
+		t.Fatalf("restored to %q, want %q", restored.Path, dir)
+	}
+	if got := readFile(t, filepath.Join(dir, "f.txt")); got != "data" {
+		t.Fatalf("restored content = %q", got)
+	}
+}
+
+func TestDeleteTool_UndoRestoresFromTrash(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+	dir := filepath.Join(work, "dir")
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "f.txt"), []byte("data"), 0o644)
+	l, err := net.Listen("unix", filepath.Join(dir, "sock"))
+	if err != nil {
+		t.Skipf("unix socket: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var del mcpfs.DeletePathOutput
+	callTool(t, cs, "delete_path", map[string]any{"path": dir, "recursive": true}, &del)
+	if !del.Trashed {
+		t.Fatalf("delete_path = %+v", del)
+	}
+	// The journal names the trash item rather than keeping a copy.
+	var copies []string
+	filepath.WalkDir(app.Journal.Dir(), func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.Name() == "f.txt" {
+			copies = append(copies, p)
+		}
+		return nil
+	})
+	if len(copies) != 0 {
+		t.Errorf("journal copied the deleted tree: %q", copies)
+	}
+
+	var out mcpfs.UndoOutput
+	callTool(t, cs, "undo", map[string]any{}, &out)
+	if got := readFile(t, filepath.Join(dir, "f.txt")); got != "data" {
+		t.Fatalf("content after undo = %q", got)
+	}
+	if info, err := os.Lstat(filepath.Join(dir, "sock")); err != nil || info.Mode()&fs.ModeSocket == 0 {
+		t.Fatalf("socket after undo: %v, %v", info, err)
+	}
+	var list mcpfs.ListTrashOutput
+	callTool(t, cs, "list_trash", map[string]any{}, &list)
+	if len(list.Items) != 0 {
+		t.Errorf("list_trash after undo = %+v", list)
+	}
+}
+
+func TestRestoreTool_Undo(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+	path := filepath.Join(work, "f.txt")
+	os.WriteFile(path, []byte("data"), 0o644)
+
+	var del mcpfs.DeletePathOutput
+	callTool(t, cs, "delete_path", map[string]any{"path": path}, &del)
+	var restored mcpfs.RestoreFromTrashOutput
+	callTool(t, cs, "restore_from_trash", map[string]any{"name": del.TrashName}, &restored)
+
+	var out mcpfs.UndoOutput
+	callTool(t, cs, "undo", map[string]any{"count": 1}, &out)
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("restored file after undo: %v", err)
+	}
+	var list mcpfs.ListTrashOutput
+	callTool(t, cs, "list_trash", map[string]any{}, &list)
+	if len(list.Items) != 1 || list.Items[0].OriginalPath != path {
+		t.Fatalf("list_trash after undo = %+v", list)
+	}
+}
+
+func TestTransactionTools(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+	a := filepath.Join(work, "a.txt")
+	os.WriteFile(a, []byte("hello world\n"), 0o644)
+
+	var begin mcpfs.BeginTransactionOutput
+	callTool(t, cs, "begin_transaction", map[string]any{}, &begin)
+
+	var write mcpfs.WriteFileOutput
+	callTool(t, cs, "write_file", map[string]any{"path": filepath.Join(work, "b.txt"), "content": "new", "transaction": begin.Transaction}, &write)
+	if !write.Staged {
+		t.Fatalf("write_file = %+v, want staged", write)
+	}
+	var edit mcpfs.EditFileOutput
+	callTool(t, cs, "edit_file", map[string]any{"path": a, "old_text": "world", "new_text": "there", "transaction": begin.Transaction}, &edit)
+	callTool(t, cs, "move_path", map[string]any{"source": a, "destination": filepath.Join(work, "c.txt"), "transaction": begin.Transaction}, nil)
+	if _, err := os.Lstat(filepath.Join(work, "b.txt")); err == nil {
+		t.Fatal("staged write was applied before commit")
+	}
+
+	var commit mcpfs.CommitOutput
+	callTool(t, cs, "commit", map[string]any{"transaction": begin.Transaction}, &commit)
+	if len(commit.Applied) != 3 {
+		t.Fatalf("commit applied %+v", commit.Applied)
+	}
+	if got := readFile(t, filepath.Join(work, "c.txt")); got != "hello there\n" {
+		t.Fatalf("c.txt = %q", got)
+	}
+	if got := readFile(t, filepath.Join(work, "b.txt")); got != "new" {
+		t.Fatalf("b.txt = %q", got)
+	}
+
+	callTool(t, cs, "begin_transaction", map[string]any{}, &begin)
+	callTool(t, cs, "delete_path", map[string]any{"path": filepath.Join(work, "b.txt"), "transaction": begin.Transaction}, nil)
+	var rollback mcpfs.RollbackOutput
+	callTool(t, cs, "rollback", map[string]any{"transaction": begin.Transaction}, &rollback)
+	if len(rollback.Discarded) != 1 {
+		t.Fatalf("rollback discarded %+v", rollback.Discarded)
+	}
+	if _, err := os.Lstat(filepath.Join(work, "b.txt")); err != nil {
+		t.Fatalf("rolled back delete was applied: %v", err)
+	}
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: "commit", Arguments: map[string]any{"transaction": begin.Transaction}})
+	if err != nil || !res.IsError {
+		t.Fatalf("commit after rollback: res=%v err=%v, want tool error", res, err)
+	}
+
+	// transactions left open expire with the session
+	callTool(t, cs, "begin_transaction", map[string]any{}, &begin)
+	cs.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := app.Transactions.Get(app.SessionID(nil), begin.Transaction); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("transaction still open after the session ended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEditFileRequiresRead(t *testing.T) {
+	work := t.TempDir()
+	a := filepath.Join(work, "a.txt")
+	os.WriteFile(a, []byte("secret\n"), 0o644)
+	cs := connect(t, newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [write]\n"))
+
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "edit_file",
+		Arguments: map[string]any{"path": a, "old_text": "secret", "new_text": "x"},
+	})
+	if err != nil || !res.IsError {
+		t.Fatalf("edit_file without read: res=%v err=%v, want tool error", res, err)
+	}
+	if got := readFile(t, a); got != "secret\n" {
+		t.Fatalf("a.txt = %q, want it untouched", got)
+	}
+}
+
+func TestFileTools_Symlinks(t *testing.T) {
+	work, outside := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(work, "data"), 0o755)
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("outside\n"), 0o644)
+	for link, target := range map[string]string{
+		"dir":      outside,
+		"file":     filepath.Join(outside, "secret"),
+		"dangling": filepath.Join(outside, "new.txt"),
+		"inner":    "data",
+	} {
+		if err := os.Symlink(target, filepath.Join(work, link)); err != nil {
+			t.Skipf("symlink: %v", err)
+		}
+	}
+	os.Symlink(filepath.Join(work, "data"), filepath.Join(outside, "back"))
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	cs := connect(t, app)
+
+	// A link in the granted directory grants nothing outside it.
+	write := func(p string) map[string]any { return map[string]any{"path": p, "content": "pwned\n"} }
+	for _, tc := range []struct {
+		tool string
+		args map[string]any
+	}{
+		{"write_file", write(filepath.Join(work, "dir", "secret"))},
+		{"write_file", write(filepath.Join(work, "dir", "created"))},
+		{"write_file", write(filepath.Join(work, "file"))},
+		{"write_file", write(filepath.Join(work, "dangling"))},
+		{"edit_file", map[string]any{"path": filepath.Join(work, "file"), "old_text": "outside", "new_text": "pwned"}},
+		{"query_data", map[string]any{"path": filepath.Join(work, "dir", "secret"), "query": "$"}},
+		// and a link outside it pointing in does not make its own path granted
+		{"delete_path", map[string]any{"path": filepath.Join(outside, "back")}},
+	} {
+		res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: tc.tool, Arguments: tc.args})
+		if err != nil || !res.IsError {
+			t.Errorf("%s %v: res=%v err=%v, want tool error", tc.tool, tc.args["path"], res, err)
+		}
+	}
+	if got := readFile(t, filepath.Join(outside, "secret")); got != "outside\n" {
+		t.Errorf("outside/secret = %q", got)
+	}
+	for _, name := range []string{"created", "new.txt"} {
+		if _, err := os.Lstat(filepath.Join(outside, name)); err == nil {
+			t.Errorf("outside/%s was created", name)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "back")); err != nil {
+		t.Errorf("outside/back was deleted: %v", err)
+	}
+
+	// Links within the granted directory work, and moves act on the link.
+	callTool(t, cs, "write_file", map[string]any{"path": filepath.Join(work, "inner", "x.txt"), "content": "x"}, nil)
+	if got := readFile(t, filepath.Join(work, "data", "x.txt")); got != "x" {
+		t.Errorf("data/x.txt = %q", got)
+	}
+	callTool(t, cs, "move_path", map[string]any{"source": filepath.Join(work, "inner"), "destination": filepath.Join(work, "inner2")}, nil)
+	if info, err := os.Lstat(filepath.Join(work, "inner2")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("inner2 = %v, %v, want the moved link", info, err)
+	}
+}
@@ -0,0 +1,69 @@
+package mcpfs
+
+import (
+	"context"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type BeginTransactionOutput struct {
+	Transaction string `json:"transaction" jsonschema:"id to pass to write_file, edit_file, move_path, delete_path, commit and rollback"`
+}
+
+type TransactionInput struct {
+	Transaction string `json:"transaction"`
+}
+
+type CommitOutput struct {
+	Applied []TxOp `json:"applied"`
+}
+
+type RollbackOutput struct {
+	Discarded []TxOp `json:"discarded"`
+}
+
+func (a *App) addTransactionTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name: "begin_transaction",
+		Description: "Start a transaction. Writes, edits, moves and deletes given its id are staged " +
+			"instead of applied, and take effect together on commit. Uncommitted transactions are " +
+			"discarded when the session ends.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, BeginTransactionOutput, error) {
+		tx, err := a.Transactions.Begin(a.SessionID(req.Session))
+		if err != nil {
+			return nil, BeginTransactionOutput{}, err
+		}
+		return nil, BeginTransactionOutput{Transaction: tx.ID}, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name: "commit",
+		Description: "Apply the operations staged in a transaction, in order. If one fails, those " +
+			"already applied are reverted and the transaction stays open.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in TransactionInput) (*mcp.CallToolResult, CommitOutput, error) {
+		out := CommitOutput{Applied: []TxOp{}}
+		tx, err := a.Transactions.Get(a.SessionID(req.Session), in.Transaction)
+		if err != nil {
+			return nil, out, err
+		}
+		applied, err := a.Commit(ctx, NewSessionApprover(a.Cfg, req.Session), tx)
+		if err != nil {
+			return nil, out, err
+		}
+		out.Applied = append(out.Applied, applied...)
+		return nil, out, a.Transactions.Close(tx)
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "rollback",
+		Description: "Discard a transaction and everything staged in it.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in TransactionInput) (*mcp.CallToolResult, RollbackOutput, error) {
+		out := RollbackOutput{Discarded: []TxOp{}}
+		tx, err := a.Transactions.Get(a.SessionID(req.Session), in.Transaction)
+		if err != nil {
+			return nil, out, err
+		}
+		out.Discarded = append(out.Discarded, tx.Ops()...)
+		return nil, out, a.Transactions.Close(tx)
+	})
+}
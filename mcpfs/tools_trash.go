@@ -0,0 +1,112 @@
+package mcpfs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type ListTrashOutput struct {
+	Items []TrashEntry `json:"items"`
+}
+
+// TrashEntry is a TrashItem as reported by the tools.
+type TrashEntry struct {
+	Name         string `json:"name"`
+	OriginalPath string `json:"original_path"`
+	DeletionDate string `json:"deletion_date" jsonschema:"when the item was deleted (RFC 3339)"`
+	IsDir        bool   `json:"is_dir"`
+	Size         int64  `json:"size"`
+}
+
+type RestoreFromTrashInput struct {
+	Name string `json:"name" jsonschema:"trash item name as reported by list_trash"`
+}
+
+type RestoreFromTrashOutput struct {
+	Path string `json:"path" jsonschema:"path the item was restored to"`
+}
+
+func newTrashEntry(item TrashItem) TrashEntry {
+	return TrashEntry{
+		Name:         item.Name,
+		OriginalPath: item.OriginalPath,
+		DeletionDate: item.DeletionDate.Format(time.RFC3339),
+		IsDir:        item.IsDir,
+		Size:         item.Size,
+	}
+}
+
+func (a *App) addTrashTools(server *mcp.Server) {
+	addTool(a, server, &mcp.Tool{
+		Name:        "list_trash",
+		Description: "List trashed items whose original path is readable, most recently deleted first.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, ListTrashOutput, error) {
+		out := ListTrashOutput{Items: []TrashEntry{}}
+		if a.Trash == nil {
+			return nil, out, nil
+		}
+		items, err := a.Trash.List()
+		if err != nil {
+			return nil, out, err
+		}
+		for _, item := range items {
+			if a.allowed(ctx, PermRead, item.OriginalPath) {
+				out.Items = append(out.Items, newTrashEntry(item))
+			}
+		}
+		return nil, out, nil
+	})
+
+	addTool(a, server, &mcp.Tool{
+		Name:        "restore_from_trash",
+		Description: "Move a trashed item back to its original path. Fails if something already exists there.",
+	}, func(ctx context.Context, req *mcp.CallToolRequest, in RestoreFromTrashInput) (*mcp.CallToolResult, RestoreFromTrashOutput, error) {
+		out, err := a.restoreFromTrash(ctx, req.Session, in)
+		return nil, out, err
+	})
+}
+
+func (a *App) restoreFromTrash(ctx context.Context, ss *mcp.ServerSession, in RestoreFromTrashInput) (RestoreFromTrashOutput, error) {
+	var out RestoreFromTrashOutput
+	if a.Trash == nil {
+		return out, fmt.Errorf("%w: trash is disabled", ErrTrash)
+	}
+	item, err := a.Trash.Get(in.Name)
+	if err != nil {
+		return out, err
+	}
+	if err := a.checkWrite(ctx, item.OriginalPath); err != nil {
+		return out, err
+	}
+	if err := a.Confirm(ctx, NewSessionApprover(a.Cfg, ss), ApprovalRequest{Op: PermWrite, Path: item.OriginalPath, Action: "restore from trash"}); err != nil {
+		return out, err
+	}
+	res, err := a.Quotas.ReserveUsage(item.OriginalPath, PathUsage(item.path()))
+	if err != nil {
+		return out, err
+	}
+	var entry *JournalEntry
+	if a.Journal != nil {
+		if entry, err = a.Journal.Record(a.SessionID(ss), JournalRestore, item.OriginalPath, ""); err != nil {
+			res.Cancel()
+			return out, err
+		}
+	}
+	restored, err := a.Trash.Restore(in.Name, "")
+	if err != nil {
+		a.discardEntry(entry)
+		res.Cancel()
+		return out, err
+	}
+	if a.Journal != nil {
+		if err := a.Journal.Prune(); err != nil {
+			a.Logger.LogAttrs(ctx, slog.LevelWarn, "journal prune failed", slog.Any("error", err))
+		}
+	}
+	out.Path = restored.OriginalPath
+	return out, nil
+}
@@ -0,0 +1,725 @@
+package mcpfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+)
+
+// DefaultTransactionMaxAge is how long shadow areas left behind by earlier
+// processes are kept before Prune removes them.
+const DefaultTransactionMaxAge = 24 * time.Hour
+
+// maxApprovalDiffSize bounds the files diffed for approval prompts.
+const maxApprovalDiffSize = 256 << 10
+
+// TxOp is an operation staged in a transaction.
+type TxOp struct {
+	Op        JournalOp `json:"op"`
+	Path      string    `json:"path"`
+	Dest      string    `json:"dest,omitempty"`
+	Size      int64     `json:"size,omitempty"`       // bytes staged by writes and edits
+	TrashName string    `json:"trash_name,omitempty"` // set on deletes once committed to the trash
+
+	mode     fs.FileMode
+	source   string // shadow copy of the new content of writes and edits
+	basePath string // file on disk an edit was based on
+	base     []byte // sha256 of the content of basePath when staged
+}
+
+// Transaction is a set of writes, edits, moves and deletes staged in a shadow
+// area and applied together by App.Commit. Reads through Read see the staged
+// state, so later operations can build on earlier ones.
+type Transaction struct {
+	ID      string
+	Session string
+	Created time.Time
+
+	dir    string
+	quotas *QuotaTracker
+
+	mu   sync.Mutex
+	ops  []TxOp
+	done bool
+}
+
+// Transactions tracks the open transactions of all sessions. Shadow areas
+// live in <dir>/<id>/.
+type Transactions struct {
+	dir    string
+	clock  std.Clock
+	quotas *QuotaTracker
+
+	mu  sync.Mutex
+	txs map[string]*Transaction
+}
+
+// DefaultTransactionDir returns the shadow area directory under the user's
+// state path.
+func DefaultTransactionDir(env std.Env) (string, error) {
+	dir, err := std.UserStatePath(AppName, env)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "transactions"), nil
+}
+
+// NewTransactions returns a transaction manager storing shadow areas in dir.
+func NewTransactions(dir string, clock std.Clock) *Transactions {
+	if clock == nil {
+		clock = std.OsClock{}
+	}
+	return &Transactions{dir: dir, clock: clock, txs: map[string]*Transaction{}}
+}
+
+// SetQuotas makes the transactions created from now on refuse to stage
+// writes that exceed the quotas of q, so that oversized content is not
+// copied into the shadow area only to be refused on commit.
+func (m *Transactions) SetQuotas(q *QuotaTracker) {
+	m.quotas = q
+}
+
+// Begin opens a transaction for session.
+func (m *Transactions) Begin(session string) (*Transaction, error) {
+	tx, err := m.create(session)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.txs[tx.ID] = tx
+	m.mu.Unlock()
+	return tx, nil
+}
+
+// create makes a transaction that is not registered, used to run a single
+// operation through the same staging and commit path.
+func (m *Transactions) create(session string) (*Transaction, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	id := "tx-" + hex.EncodeToString(b[:])
+	dir := filepath.Join(m.dir, id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTransaction, err)
+	}
+	return &Transaction{ID: id, Session: session, Created: m.clock.Now(), dir: dir, quotas: m.quotas}, nil
+}
+
+// Get returns the open transaction id of session.
+func (m *Transactions) Get(session, id string) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx, ok := m.txs[id]
+	if !ok || tx.Session != session {
+		return nil, fmt.Errorf("%w: no open transaction %q", ErrTransaction, id)
+	}
+	return tx, nil
+}
+
+// Close forgets tx and removes its shadow area. Staged operations that were
+// not committed are discarded.
+func (m *Transactions) Close(tx *Transaction) error {
+	m.mu.Lock()
+	delete(m.txs, tx.ID)
+	m.mu.Unlock()
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.done = true
+	return os.RemoveAll(tx.dir)
+}
+
+// EndSession discards the open transactions of session and returns them.
+func (m *Transactions) EndSession(session string) []*Transaction {
+	m.mu.Lock()
+	var ended []*Transaction
+	for _, tx := range m.txs {
+		if tx.Session == session {
+			ended = append(ended, tx)
+		}
+	}
+	m.mu.Unlock()
+	for _, tx := range ended {
+		m.Close(tx)
+	}
+	return ended
+}
+
+// Prune removes shadow areas that are not open in this process and were last
+// modified more than maxAge ago, such as those of a process that crashed.
+func (m *Transactions) Prune(maxAge time.Duration) error {
+	des, err := os.ReadDir(m.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cutoff := m.clock.Now().Add(-maxAge)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for _, de := range des {
+		if _, open := m.txs[de.Name()]; open {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(m.dir, de.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Ops returns the staged operations in order.
+func (tx *Transaction) Ops() []TxOp {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return append([]TxOp(nil), tx.ops...)
+}
+
+// resolve returns the file holding the staged content of p: the shadow copy
+// of the staged write that produced it, the path on disk (possibly moved), or
+// "" when an operation removed it.
+func (tx *Transaction) resolve(p string) (file string, staged *TxOp) {
+	for i := len(tx.ops) - 1; i >= 0; i-- {
+		op := &tx.ops[i]
+		switch op.Op {
+		case JournalWrite, JournalEdit:
+			if p == op.Path {
+				return op.source, op
+			}
+		case JournalDelete:
+			if withinPath(p, op.Path) {
+				return "", nil
+			}
+		case JournalMove:
+			if withinPath(p, op.Path) {
+				return "", nil
+			}
+			if withinPath(p, op.Dest) {
+				rel, _ := filepath.Rel(op.Dest, p)
+				p = filepath.Join(op.Path, rel)
+			}
+		}
+	}
+	return p, nil
+}
+
+// stat returns the staged file info of p.
+func (tx *Transaction) stat(p string) (fs.FileInfo, error) {
+	file, _ := tx.resolve(p)
+	if file == "" {
+		return nil, &fs.PathError{Op: "stat", Path: p, Err: fs.ErrNotExist}
+	}
+	return os.Lstat(file)
+}
+
+// Read returns the staged content and mode of the file at path, following
+// symlinks as resolvePath does.
+func (tx *Transaction) Read(path string) ([]byte, fs.FileMode, error) {
+	path = resolvePath(path)
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	file, staged := tx.resolve(path)
+	if file == "" {
+		return nil, 0, &fs.PathError{Op: "read", Path: path, Err: fs.ErrNotExist}
+	}
+	if staged != nil {
+		data, err := os.ReadFile(file)
+		return data, staged.mode, err
+	}
+	info, err := os.Lstat(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := readFileNoFollow(file)
+	return data, info.Mode().Perm(), err
+}
+
+// StageWrite stages replacing the file at path with data. op is JournalWrite
+// or JournalEdit; an edit of a file on disk fails to commit if the file has
+// changed since it was staged. A zero mode keeps the current mode, or uses
+// 0644 for new files. Content that exceeds the quotas of the rule granting
+// write on path is refused; Commit reserves the usage when it applies the
+// write. A symlink at path is followed: its target is written.
+func (tx *Transaction) StageWrite(op JournalOp, path string, data []byte, mode fs.FileMode) error {
+	path = resolvePath(path)
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return fmt.Errorf("%w: %s is closed", ErrTransaction, tx.ID)
+	}
+	if tx.quotas != nil {
+		if err := tx.quotas.Check(path, int64(len(data))); err != nil {
+			return err
+		}
+	}
+	staged := TxOp{Op: op, Path: path, Size: int64(len(data)), mode: mode}
+	file, prev := tx.resolve(path)
+	switch {
+	case prev != nil:
+		if staged.mode == 0 {
+			staged.mode = prev.mode
+		}
+	case file != "":
+		info, err := os.Lstat(file)
+		if err == nil && info.IsDir() {
+			return fmt.Errorf("%w: %q is a directory", ErrTransaction, path)
+		}
+		if err == nil && staged.mode == 0 {
+			staged.mode = info.Mode().Perm()
+		}
+		if err == nil && op == JournalEdit {
+			if staged.base, err = fileSum(file); err != nil {
+				return err
+			}
+			staged.basePath = file
+		}
+	}
+	if staged.mode == 0 {
+		staged.mode = 0o644
+	}
+	staged.source = filepath.Join(tx.dir, strconv.Itoa(len(tx.ops)))
+	if err := os.WriteFile(staged.source, data, 0o600); err != nil {
+		return fmt.Errorf("%w: %v", ErrTransaction, err)
+	}
+	tx.ops = append(tx.ops, staged)
+	return nil
+}
+
+// StageMove stages renaming src to dest. dest must not exist. A symlink at
+// src is moved itself, not what it points to.
+func (tx *Transaction) StageMove(src, dest string) error {
+	src, dest = resolveParent(src), resolveParent(dest)
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return fmt.Errorf("%w: %s is closed", ErrTransaction, tx.ID)
+	}
+	if _, err := tx.stat(src); err != nil {
+		return err
+	}
+	if _, err := tx.stat(dest); err == nil {
+		return fmt.Errorf("%w: %q already exists", ErrTransaction, dest)
+	}
+	if withinPath(dest, src) {
+		return fmt.Errorf("%w: cannot move %q into itself", ErrTransaction, src)
+	}
+	tx.ops = append(tx.ops, TxOp{Op: JournalMove, Path: src, Dest: dest})
+	return nil
+}
+
+// StageDelete stages removing path. A non-empty directory is only removed if
+// recursive is set. A symlink at path is removed itself, not what it points
+// to.
+func (tx *Transaction) StageDelete(path string, recursive bool) error {
+	path = resolveParent(path)
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return fmt.Errorf("%w: %s is closed", ErrTransaction, tx.ID)
+	}
+	info, err := tx.stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && !recursive {
+		file, _ := tx.resolve(path)
+		empty, err := isEmptyDir(file)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return fmt.Errorf("%q is a non-empty directory; set recursive to delete it", path)
+		}
+	}
+	tx.ops = append(tx.ops, TxOp{Op: JournalDelete, Path: path})
+	return nil
+}
+
+// appliedOp is an operation performed by Commit and what is needed to revert
+// it.
+type appliedOp struct {
+	op      *TxOp
+	entry   *JournalEntry
+	quota   *QuotaReservation
+	freed   QuotaUsage // usage leaving the rule of op.Path
+	trashed bool
+}
+
+// Commit applies the staged operations of tx in order. Permissions are
+// checked again and approvals requested before anything is changed. If an
+// operation fails, the ones already applied are reverted from their
+// before-images and tx stays open. Each applied operation is recorded in the
+// undo journal, which is then pruned. On success tx is marked done and the
+// applied operations are returned; Close it to remove its shadow area.
+func (a *App) Commit(ctx context.Context, approver Approver, tx *Transaction) ([]TxOp, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return nil, fmt.Errorf("%w: %s is closed", ErrTransaction, tx.ID)
+	}
+
+	for _, op := range tx.ops {
+		if err := a.checkOp(ctx, op); err != nil {
+			return nil, err
+		}
+		if op.base != nil {
+			sum, err := fileSum(op.basePath)
+			if err != nil || !bytes.Equal(sum, op.base) {
+				return nil, fmt.Errorf("%w: %q changed since the edit was staged", ErrTransaction, op.Path)
+			}
+		}
+	}
+	for _, op := range tx.ops {
+		if err := a.confirmOp(ctx, approver, op, op.action()); err != nil {
+			return nil, err
+		}
+	}
+
+	applied := make([]appliedOp, 0, len(tx.ops))
+	for i := range tx.ops {
+		ap, err := a.applyOp(tx, i)
+		if err != nil {
+			for j := len(applied) - 1; j >= 0; j-- {
+				if rerr := a.revertOp(applied[j]); rerr != nil {
+					a.Logger.LogAttrs(ctx, slog.LevelError, "transaction rollback failed",
+						slog.String("transaction", tx.ID), slog.String("path", applied[j].op.Path), slog.Any("error", rerr))
+				}
+			}
+			op := tx.ops[i]
+			return nil, fmt.Errorf("%w: %s %q: %v (applied operations were rolled back)", ErrTransaction, op.Op, op.Path, err)
+		}
+		applied = append(applied, ap)
+	}
+	for _, ap := range applied {
+		a.Quotas.Remove(ap.op.Path, ap.freed)
+	}
+	tx.done = true
+	if a.Journal != nil {
+		// Keep the journal within max_age and max_size during long sessions.
+		if err := a.Journal.Prune(); err != nil {
+			a.Logger.LogAttrs(ctx, slog.LevelWarn, "journal prune failed", slog.Any("error", err))
+		}
+	}
+	return append([]TxOp(nil), tx.ops...), nil
+}
+
+// confirmOp asks approver to approve op where a rule requires approval,
+// with the diff of a write. action describes op to the user.
+func (a *App) confirmOp(ctx context.Context, approver Approver, op TxOp, action string) error {
+	req := ApprovalRequest{Op: PermWrite, Path: op.Path, Action: action}
+	if a.Cfg.RequiresApproval(PermWrite, op.Path) {
+		req.Diff = op.diff()
+	}
+	if err := a.Confirm(ctx, approver, req); err != nil {
+		return err
+	}
+	if op.Dest != "" {
+		return a.Confirm(ctx, approver, ApprovalRequest{Op: PermWrite, Path: op.Dest, Action: action + " " + op.Path + " to"})
+	}
+	return nil
+}
+
+// reserveOp reserves the quota usage op adds and returns the usage it frees
+// at op.Path, which the caller removes once op is done.
+func (a *App) reserveOp(op TxOp) (*QuotaReservation, QuotaUsage, error) {
+	switch op.Op {
+	case JournalWrite, JournalEdit:
+		if info, err := os.Lstat(op.source); err == nil && info.IsDir() {
+			res, err := a.Quotas.ReserveUsage(op.Path, PathUsage(op.source))
+			return res, QuotaUsage{}, err
+		}
+		res, err := a.Quotas.Reserve(op.Path, op.Size)
+		return res, QuotaUsage{}, err
+	case JournalMove:
+		if a.Cfg.MatchRule(PermWrite, op.Path) != a.Cfg.MatchRule(PermWrite, op.Dest) {
+			freed := PathUsage(op.Path)
+			res, err := a.Quotas.ReserveUsage(op.Dest, freed)
+			if err != nil {
+				return nil, QuotaUsage{}, err
+			}
+			return res, freed, nil
+		}
+	case JournalDelete:
+		return nil, PathUsage(op.Path), nil
+	}
+	return nil, QuotaUsage{}, nil
+}
+
+// applyOp performs the i-th operation of tx after capturing its before-image.
+func (a *App) applyOp(tx *Transaction, i int) (appliedOp, error) {
+	op := &tx.ops[i]
+	ap := appliedOp{op: op}
+	if op.Op == JournalDelete && a.Trash != nil {
+		return a.trashOp(tx, ap)
+	}
+	var err error
+	if a.Journal != nil {
+		ap.entry, err = a.Journal.Record(tx.Session, op.Op, op.Path, op.Dest)
+	}
+	if err == nil && ap.entry == nil {
+		ap.entry, err = newJournalEntry(filepath.Join(tx.dir, "undo", strconv.Itoa(i)), JournalEntry{
+			Session: tx.Session, Op: op.Op, Path: op.Path, Dest: op.Dest, Time: a.Services.Clock.Now(),
+		})
+	}
+	if err != nil {
+		return ap, err
+	}
+
+	if ap.quota, ap.freed, err = a.reserveOp(*op); err == nil {
+		switch op.Op {
+		case JournalWrite, JournalEdit:
+			_, err = copyFile(op.source, op.Path, op.mode)
+		case JournalMove:
+			err = moveTree(op.Path, op.Dest)
+		case JournalDelete:
+			err = os.RemoveAll(op.Path)
+		}
+	}
+	if err != nil {
+		ap.quota.Cancel()
+		ap.freed = QuotaUsage{}
+		a.discardEntry(ap.entry)
+	}
+	return ap, err
+}
+
+// trashOp moves the path of a delete operation to the trash and records the
+// trash item in the journal. Undo and rollback restore the item, so the
+// path is not copied.
+func (a *App) trashOp(tx *Transaction, ap appliedOp) (appliedOp, error) {
+	op := ap.op
+	freed := PathUsage(op.Path)
+	item, err := a.Trash.Put(op.Path)
+	if err != nil {
+		return ap, err
+	}
+	if a.Journal != nil {
+		if ap.entry, err = a.Journal.RecordTrashed(tx.Session, op.Path, item); err != nil {
+			a.Trash.Restore(item.Name, "")
+			return ap, err
+		}
+	}
+	op.TrashName, ap.trashed, ap.freed = item.Name, true, freed
+	return ap, nil
+}
+
+// revertOp undoes an operation applied by applyOp.
+func (a *App) revertOp(ap appliedOp) error {
+	var err error
+	if ap.trashed {
+		_, err = a.Trash.Restore(ap.op.TrashName, "")
+		ap.op.TrashName = ""
+	} else {
+		err = ap.entry.revert(nil)
+	}
+	ap.quota.Cancel()
+	a.discardEntry(ap.entry)
+	return err
+}
+
+// Undo reverts the last n operations of session in the journal, newest
+// first. Each revert is checked as Commit checks the operations of a
+// transaction: the config must grant writing what it restores, approver
+// must approve it where a rule requires approval, and it must fit the
+// quotas.
+func (a *App) Undo(ctx context.Context, approver Approver, session string, n int) ([]JournalEntry, error) {
+	type revert struct {
+		quotas []*QuotaReservation
+		freed  map[string]QuotaUsage
+	}
+	reverts := map[int64]*revert{}
+	done, err := a.Journal.Undo(session, n, func(e JournalEntry) error {
+		r := &revert{freed: map[string]QuotaUsage{}}
+		reverts[e.Seq] = r
+		ops := a.undoOps(e)
+		for _, op := range ops {
+			if err := a.checkOp(ctx, op); err != nil {
+				return err
+			}
+		}
+		for _, op := range ops {
+			if err := a.confirmOp(ctx, approver, op, "undo "+string(e.Op)); err != nil {
+				return err
+			}
+		}
+		for _, op := range ops {
+			res, freed, err := a.reserveOp(op)
+			if err != nil {
+				return err
+			}
+			r.quotas = append(r.quotas, res)
+			r.freed[op.Path] = freed
+		}
+		return nil
+	})
+	for _, e := range done {
+		for path, freed := range reverts[e.Seq].freed {
+			a.Quotas.Remove(path, freed)
+		}
+		delete(reverts, e.Seq)
+	}
+	// Return what was reserved for the entries that were not reverted.
+	for _, r := range reverts {
+		for _, res := range r.quotas {
+			res.Cancel()
+		}
+	}
+	return done, err
+}
+
+// undoOps returns the operations reverting e performs, as a transaction
+// would stage them.
+func (a *App) undoOps(e JournalEntry) []TxOp {
+	if e.Op == JournalMove {
+		ops := []TxOp{{Op: JournalMove, Path: e.Dest, Dest: e.Path}}
+		if prior := e.destBefore(); prior != "" {
+			ops = append(ops, TxOp{Op: JournalWrite, Path: e.Dest, source: prior, Size: treeSize(prior)})
+		}
+		return ops
+	}
+	if src := e.restored(a.Trash); src != "" {
+		return []TxOp{{Op: JournalWrite, Path: e.Path, source: src, Size: treeSize(src)}}
+	}
+	return []TxOp{{Op: JournalDelete, Path: e.Path}}
+}
+
+func (a *App) discardEntry(e *JournalEntry) {
+	if a.Journal != nil {
+		a.Journal.Discard(e)
+	} else if e != nil {
+		os.RemoveAll(e.dir)
+	}
+}
+
+// checkWrite reports whether the config grants write on every non-empty path.
+func (a *App) checkWrite(ctx context.Context, paths ...string) error {
+	for _, p := range paths {
+		if p != "" {
+			if err := a.checkAccess(ctx, Access{Op: PermWrite, Path: p}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRead reports whether the config grants read on path.
+func (a *App) checkRead(ctx context.Context, path string) error {
+	return a.checkAccess(ctx, Access{Op: PermRead, Path: path})
+}
+
+// checkAccess reports whether the config grants acc to the principal of
+// ctx, with the reason if not.
+func (a *App) checkAccess(ctx context.Context, acc Access) error {
+	if acc.Principal == "" {
+		acc.Principal = principalFrom(ctx)
+	}
+	return a.Cfg.Evaluate(acc).Err()
+}
+
+// allowed reports whether the config grants op on path to the principal of
+// ctx.
+func (a *App) allowed(ctx context.Context, op Permission, path string) bool {
+	return a.checkAccess(ctx, Access{Op: op, Path: path}) == nil
+}
+
+// checkOp reports whether the config grants the staged operation op, judging
+// writes by the content they produce.
+func (a *App) checkOp(ctx context.Context, op TxOp) error {
+	switch op.Op {
+	case JournalWrite, JournalEdit:
+		return a.checkAccess(ctx, Access{Op: PermWrite, Path: op.Path, ContentPath: op.source})
+	case JournalMove:
+		if err := a.checkWrite(ctx, op.Path); err != nil {
+			return err
+		}
+		return a.checkAccess(ctx, Access{Op: PermWrite, Path: op.Dest, ContentPath: op.Path})
+	default:
+		return a.checkWrite(ctx, op.Path, op.Dest)
+	}
+}
+
+func (op TxOp) action() string {
+	switch op.Op {
+	case JournalWrite:
+		return "write file"
+	case JournalEdit:
+		return "edit file"
+	case JournalMove:
+		return "move"
+	default:
+		return string(op.Op)
+	}
+}
+
+// diff returns the unified diff of a staged write against the file on disk,
+// or "" if either side is too large or not text.
+func (op TxOp) diff() string {
+	if op.source == "" || op.Size > maxApprovalDiffSize {
+		return ""
+	}
+	var old []byte
+	if info, err := os.Stat(op.Path); err == nil {
+		if !info.Mode().IsRegular() || info.Size() > maxApprovalDiffSize {
+			return ""
+		}
+		old, _ = os.ReadFile(op.Path)
+	}
+	data, err := os.ReadFile(op.source)
+	if err != nil || bytes.IndexByte(old, 0) >= 0 || bytes.IndexByte(data, 0) >= 0 {
+		return ""
+	}
+	return UnifiedDiff(op.Path, op.Path, string(old), string(data))
+}
+
+// withinPath reports whether p is base or below it. Both must be clean.
+func withinPath(p, base string) bool {
+	if p == base {
+		return true
+	}
+	rel, err := filepath.Rel(base, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isEmptyDir(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	if errors.Is(err, io.EOF) {
+		return true, nil
+	}
+	return false, err
+}
+
+func fileSum(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
@@ -0,0 +1,143 @@
+package mcpfs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestTransaction_StagedView(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	src := filepath.Join(work, "src")
+	os.MkdirAll(src, 0o755)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("one"), 0o600)
+
+	tx, err := app.Transactions.Begin("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(work, "dst")
+	if err := tx.StageMove(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	data, mode, err := tx.Read(filepath.Join(dst, "a.txt"))
+	if err != nil || string(data) != "one" || mode != 0o600 {
+		t.Fatalf("Read moved file = %q, %v, %v", data, mode, err)
+	}
+	if _, _, err := tx.Read(filepath.Join(src, "a.txt")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Read moved-away file: err = %v, want ErrNotExist", err)
+	}
+	if err := tx.StageWrite(mcpfs.JournalEdit, filepath.Join(dst, "a.txt"), []byte("two"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if data, mode, _ := tx.Read(filepath.Join(dst, "a.txt")); string(data) != "two" || mode != 0o600 {
+		t.Fatalf("Read edited file = %q, %v", data, mode)
+	}
+	if err := tx.StageDelete(dst, false); err == nil {
+		t.Fatal("StageDelete of non-empty directory without recursive succeeded")
+	}
+
+	// nothing touches the real tree before commit
+	if got := readFile(t, filepath.Join(src, "a.txt")); got != "one" {
+		t.Fatalf("source changed before commit: %q", got)
+	}
+
+	if _, err := app.Commit(context.Background(), nil, tx); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dst, "a.txt")); got != "two" {
+		t.Fatalf("committed content = %q", got)
+	}
+	if info, _ := os.Stat(filepath.Join(dst, "a.txt")); info.Mode().Perm() != 0o600 {
+		t.Fatalf("committed mode = %v", info.Mode())
+	}
+	if _, err := os.Lstat(src); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("source still exists after commit")
+	}
+	if err := app.Transactions.Close(tx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.Transactions.Get("s1", tx.ID); !errors.Is(err, mcpfs.ErrTransaction) {
+		t.Fatalf("Get after Close: err = %v", err)
+	}
+}
+
+func TestTransaction_CommitRollsBackOnFailure(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	a := filepath.Join(work, "a.txt")
+	os.WriteFile(a, []byte("original"), 0o644)
+
+	tx, _ := app.Transactions.Begin("s1")
+	if err := tx.StageWrite(mcpfs.JournalWrite, a, []byte("changed"), 0); err != nil {
+		t.Fatal(err)
+	}
+	// b is removed behind the transaction's back so its move fails at commit
+	b := filepath.Join(work, "b.txt")
+	os.WriteFile(b, []byte("b"), 0o644)
+	if err := tx.StageMove(b, filepath.Join(work, "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(b)
+
+	if _, err := app.Commit(context.Background(), nil, tx); !errors.Is(err, mcpfs.ErrTransaction) {
+		t.Fatalf("Commit: err = %v, want ErrTransaction", err)
+	}
+	if got := readFile(t, a); got != "original" {
+		t.Fatalf("a.txt = %q after rollback, want original", got)
+	}
+	if entries, _ := app.Journal.Entries("s1"); len(entries) != 0 {
+		t.Fatalf("journal kept %d entries of a rolled back commit", len(entries))
+	}
+}
+
+func TestTransaction_CommitRechecks(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n")
+	a := filepath.Join(work, "a.txt")
+	os.WriteFile(a, []byte("v1"), 0o644)
+
+	tx, _ := app.Transactions.Begin("s1")
+	if err := tx.StageWrite(mcpfs.JournalEdit, a, []byte("v2"), 0); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(a, []byte("concurrent"), 0o644)
+	if _, err := app.Commit(context.Background(), nil, tx); !errors.Is(err, mcpfs.ErrTransaction) {
+		t.Fatalf("Commit after concurrent change: err = %v, want ErrTransaction", err)
+	}
+
+	tx2, _ := app.Transactions.Begin("s1")
+	if err := tx2.StageWrite(mcpfs.JournalWrite, a, []byte("v3"), 0); err != nil {
+		t.Fatal(err)
+	}
+	// the config changed between staging and commit
+	restricted := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read]\n")
+	if _, err := restricted.Commit(context.Background(), nil, tx2); !errors.Is(err, mcpfs.ErrPermissionDenied) {
+		t.Fatalf("Commit without write permission: err = %v, want ErrPermissionDenied", err)
+	}
+	if got := readFile(t, a); got != "concurrent" {
+		t.Fatalf("a.txt = %q, want it untouched", got)
+	}
+}
+
+func TestTransaction_StageWriteChecksQuotas(t *testing.T) {
+	work := t.TempDir()
+	app := newTestApp(t, "paths:\n  - path: "+work+"\n    perms: [read, write]\n    max_file_size: 8\n")
+	tx, _ := app.Transactions.Begin("s1")
+	err := tx.StageWrite(mcpfs.JournalWrite, filepath.Join(work, "a.txt"), []byte("more than eight bytes"), 0)
+	if !errors.Is(err, mcpfs.ErrQuotaExceeded) {
+		t.Fatalf("StageWrite over max_file_size: err = %v, want ErrQuotaExceeded", err)
+	}
+	if len(tx.Ops()) != 0 {
+		t.Fatalf("oversized write was staged: %+v", tx.Ops())
+	}
+	if err := tx.StageWrite(mcpfs.JournalWrite, filepath.Join(work, "a.txt"), []byte("small"), 0); err != nil {
+		t.Fatalf("StageWrite within max_file_size: %v", err)
+	}
+}
@@ -0,0 +1,443 @@
+package mcpfs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+)
+
+// TrashConfig controls where delete_path moves deleted items.
+// YAML schema:
+//
+//	trash:
+//	  dir: ~/.local/share/Trash  # trash directory (default: the freedesktop home trash)
+//	  disabled: false            # delete permanently instead of trashing
+type TrashConfig struct {
+	Dir      string `yaml:"dir,omitempty" json:"dir,omitempty"`
+	Disabled bool   `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+}
+
+const (
+	trashFilesDir  = "files"
+	trashInfoDir   = "info"
+	trashInfoExt   = ".trashinfo"
+	trashDateFmt   = "2006-01-02T15:04:05"
+	trashInfoGroup = "[Trash Info]"
+)
+
+// TrashItem is an entry of the trash.
+type TrashItem struct {
+	Name         string    `json:"name"`
+	OriginalPath string    `json:"original_path"`
+	DeletionDate time.Time `json:"deletion_date"`
+	IsDir        bool      `json:"is_dir"`
+	Size         int64     `json:"size"`
+
+	dir string // trash directory holding the item
+}
+
+func (item *TrashItem) path() string {
+	return filepath.Join(item.dir, trashFilesDir, item.Name)
+}
+
+func (item *TrashItem) infoPath() string {
+	return filepath.Join(item.dir, trashInfoDir, item.Name+trashInfoExt)
+}
+
+// Trash is a trash directory laid out as described by the freedesktop.org
+// Trash specification: deleted items live in files/ and a matching
+// info/<name>.trashinfo records their original path and deletion date, so
+// desktop file managers can list and restore them too.
+//
+// Paths on another filesystem than the trash directory go to the trash of
+// their own filesystem, $topdir/.Trash-$uid, so that trashing them is a
+// rename rather than a copy. The home trash is used if that one cannot be
+// created. Item names are unique across the trash directories.
+type Trash struct {
+	dir   string
+	clock std.Clock
+
+	mu      sync.Mutex
+	volumes map[string]bool // trash directories of other filesystems used
+}
+
+// DefaultTrashDir returns the freedesktop home trash, $XDG_DATA_HOME/Trash,
+// falling back to ~/.local/share/Trash.
+func DefaultTrashDir(env std.Env) (string, error) {
+	if x := env.Get("XDG_DATA_HOME"); x != "" {
+		return filepath.Join(x, "Trash"), nil
+	}
+	home, err := env.GetHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
+
+// NewTrash returns the trash rooted at dir. Directories are created when the
+// first item is trashed.
+func NewTrash(dir string, clock std.Clock) *Trash {
+	if clock == nil {
+		clock = std.OsClock{}
+	}
+	return &Trash{dir: dir, clock: clock, volumes: map[string]bool{}}
+}
+
+// Dir returns the trash directory.
+func (t *Trash) Dir() string { return t.dir }
+
+// Put moves path into the trash and records its metadata.
+func (t *Trash) Put(path string) (*TrashItem, error) {
+	path = cleanAbsPath(path)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dir := t.volumeTrash(path)
+	if dir == "" {
+		dir = t.dir
+		for _, d := range []string{trashFilesDir, trashInfoDir} {
+			if err := os.MkdirAll(filepath.Join(dir, d), 0o700); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrTrash, err)
+			}
+		}
+	}
+
+	item := &TrashItem{
+		OriginalPath: path,
+		DeletionDate: t.clock.Now(),
+		IsDir:        info.IsDir(),
+		Size:         treeSize(path),
+		dir:          dir,
+	}
+	// Reserve a unique name by creating the info file exclusively, as the
+	// spec requires, then move the item.
+	infoPath, err := t.reserveName(filepath.Base(path), item)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTrash, err)
+	}
+	if err := moveTree(path, item.path()); err != nil {
+		os.Remove(infoPath)
+		return nil, fmt.Errorf("%w: move %q to trash: %v", ErrTrash, path, err)
+	}
+	return item, nil
+}
+
+// volumeTrash returns the trash directory of the filesystem holding path,
+// with its files and info directories created, or "" if path is on the
+// filesystem of the home trash or that trash cannot be used.
+func (t *Trash) volumeTrash(path string) string {
+	uid := os.Getuid()
+	if uid < 0 {
+		return ""
+	}
+	top, ok := filesystemTop(resolvePath(filepath.Dir(path)), t.dir)
+	if !ok {
+		return ""
+	}
+	dir := filepath.Join(top, fmt.Sprintf(".Trash-%d", uid))
+	// The spec requires the directory to be the user's own, not a link.
+	if info, err := os.Lstat(dir); err == nil && !info.IsDir() {
+		return ""
+	}
+	for _, d := range []string{trashFilesDir, trashInfoDir} {
+		if err := os.MkdirAll(filepath.Join(dir, d), 0o700); err != nil {
+			return ""
+		}
+	}
+	t.volumes[dir] = true
+	return dir
+}
+
+// dirs returns the trash directories: the home trash first, then those of
+// other filesystems used so far or found at the top of a mount point.
+func (t *Trash) dirs() []string {
+	dirs := []string{t.dir}
+	seen := map[string]bool{t.dir: true}
+	add := func(dir string) {
+		if seen[dir] {
+			return
+		}
+		seen[dir] = true
+		if info, err := os.Lstat(filepath.Join(dir, trashInfoDir)); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, dir := range slices.Sorted(maps.Keys(t.volumes)) {
+		add(dir)
+	}
+	if uid := os.Getuid(); uid >= 0 {
+		for _, mnt := range mountPoints() {
+			add(filepath.Join(mnt, fmt.Sprintf(".Trash-%d", uid)))
+		}
+	}
+	return dirs
+}
+
+// mountPoints returns the mount points listed in /proc/self/mounts, or none
+// where there is no such file.
+func mountPoints() []string {
+	data, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	// the kernel escapes these characters of mount points in octal
+	unescape := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+	var mnts []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 2 {
+			mnts = append(mnts, unescape.Replace(fields[1]))
+		}
+	}
+	return mnts
+}
+
+// taken reports whether a trash directory other than dir has an item called
+// name.
+func (t *Trash) taken(name, dir string) bool {
+	for _, d := range t.dirs() {
+		if d == dir {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(d, trashInfoDir, name+trashInfoExt)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Trash) reserveName(base string, item *TrashItem) (string, error) {
+	// The trash of another filesystem records paths relative to its top.
+	original := item.OriginalPath
+	if item.dir != t.dir {
+		if rel, err := filepath.Rel(filepath.Dir(item.dir), original); err == nil {
+			original = rel
+		}
+	}
+	content := fmt.Sprintf("%s\nPath=%s\nDeletionDate=%s\n",
+		trashInfoGroup, escapeTrashPath(original), item.DeletionDate.Local().Format(trashDateFmt))
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			ext := filepath.Ext(base)
+			name = fmt.Sprintf("%s.%d%s", strings.TrimSuffix(base, ext), i, ext)
+		}
+		if t.taken(name, item.dir) {
+			continue
+		}
+		infoPath := filepath.Join(item.dir, trashInfoDir, name+trashInfoExt)
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, statErr := os.Lstat(filepath.Join(item.dir, trashFilesDir, name)); statErr == nil {
+			// orphaned file without info; keep looking
+			f.Close()
+			os.Remove(infoPath)
+			continue
+		}
+		_, err = f.WriteString(content)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(infoPath)
+			return "", err
+		}
+		item.Name = name
+		return infoPath, nil
+	}
+}
+
+// List returns the items in the trash directories, most recently deleted
+// first. Info files that cannot be parsed or have no matching item are
+// skipped.
+func (t *Trash) List() ([]TrashItem, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var items []TrashItem
+	for _, dir := range t.dirs() {
+		des, err := os.ReadDir(filepath.Join(dir, trashInfoDir))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, de := range des {
+			if !strings.HasSuffix(de.Name(), trashInfoExt) {
+				continue
+			}
+			item, err := t.load(dir, strings.TrimSuffix(de.Name(), trashInfoExt))
+			if err != nil {
+				continue
+			}
+			items = append(items, *item)
+		}
+	}
+	sort.Slice(items, func(a, b int) bool { return items[a].DeletionDate.After(items[b].DeletionDate) })
+	return items, nil
+}
+
+// Get returns the item called name.
+func (t *Trash) Get(name string) (*TrashItem, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.find(name)
+}
+
+// find loads the item called name from the first trash directory that has
+// it.
+func (t *Trash) find(name string) (*TrashItem, error) {
+	if !strings.ContainsAny(name, `/\`) {
+		for _, dir := range t.dirs()[1:] {
+			if _, err := os.Lstat(filepath.Join(dir, trashInfoDir, name+trashInfoExt)); err == nil {
+				return t.load(dir, name)
+			}
+		}
+	}
+	return t.load(t.dir, name)
+}
+
+func (t *Trash) load(dir, name string) (*TrashItem, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, fmt.Errorf("%w: invalid item name %q", ErrTrash, name)
+	}
+	f, err := os.Open(filepath.Join(dir, trashInfoDir, name+trashInfoExt))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTrash, err)
+	}
+	defer f.Close()
+
+	item := &TrashItem{Name: name, dir: dir}
+	sc := bufio.NewScanner(f)
+	inGroup := false
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") {
+			inGroup = line == trashInfoGroup
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !inGroup || !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			p, err := url.PathUnescape(val)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: bad Path: %v", ErrTrash, name, err)
+			}
+			item.OriginalPath = p
+		case "DeletionDate":
+			if d, err := time.ParseInLocation(trashDateFmt, val, time.Local); err == nil {
+				item.DeletionDate = d
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if item.OriginalPath == "" {
+		return nil, fmt.Errorf("%w: %s: missing Path", ErrTrash, name)
+	}
+	if !filepath.IsAbs(item.OriginalPath) {
+		// relative paths are relative to the directory holding the trash
+		item.OriginalPath = filepath.Join(filepath.Dir(dir), item.OriginalPath)
+	}
+	info, err := os.Lstat(item.path())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTrash, err)
+	}
+	item.IsDir = info.IsDir()
+	item.Size = treeSize(item.path())
+	return item, nil
+}
+
+// itemPath returns where the item called name is kept.
+func (t *Trash) itemPath(name string) string {
+	if item, err := t.Get(name); err == nil {
+		return item.path()
+	}
+	return filepath.Join(t.dir, trashFilesDir, name)
+}
+
+// Restore moves the item called name back to its original path, or to dest
+// if it is not empty. It refuses to overwrite an existing path.
+func (t *Trash) Restore(name, dest string) (*TrashItem, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	item, err := t.find(name)
+	if err != nil {
+		return nil, err
+	}
+	if dest == "" {
+		dest = item.OriginalPath
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return nil, fmt.Errorf("%w: %q already exists", ErrTrash, dest)
+	}
+	if err := moveTree(item.path(), dest); err != nil {
+		return nil, fmt.Errorf("%w: restore %q: %v", ErrTrash, name, err)
+	}
+	os.Remove(item.infoPath())
+	item.OriginalPath = dest
+	return item, nil
+}
+
+// Purge permanently removes the items for which keep returns false. A nil
+// keep purges everything. It returns the purged items.
+func (t *Trash) Purge(keep func(TrashItem) bool) ([]TrashItem, error) {
+	items, err := t.List()
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var (
+		purged []TrashItem
+		errs   []error
+	)
+	for _, item := range items {
+		if keep != nil && keep(item) {
+			continue
+		}
+		if err := os.RemoveAll(item.path()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Remove(item.infoPath()); err != nil {
+			errs = append(errs, err)
+		}
+		purged = append(purged, item)
+	}
+	return purged, errors.Join(errs...)
+}
+
+// escapeTrashPath percent-encodes a path for the Path key, keeping the
+// separators readable.
+func escapeTrashPath(p string) string {
+	parts := strings.Split(filepath.ToSlash(p), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
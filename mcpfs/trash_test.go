@@ -0,0 +1,162 @@
+package mcpfs_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	std "github.com/jlrickert/go-std/pkg"
+	"github.com/jlrickert/mcp-filesystem/mcpfs"
+)
+
+func TestTrash_PutListRestore(t *testing.T) {
+	work := t.TempDir()
+	trashDir := filepath.Join(t.TempDir(), "Trash")
+	clock := std.NewTestClock(time.Date(2025, 3, 4, 5, 6, 7, 0, time.Local))
+	trash := mcpfs.NewTrash(trashDir, clock)
+
+	a := filepath.Join(work, "dir one", "a.txt")
+	b := filepath.Join(work, "b", "a.txt")
+	for _, p := range []string{a, b} {
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(p), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := trash.Put(a)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	clock.Advance(time.Minute)
+	second, err := trash.Put(b)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if first.Name != "a.txt" || second.Name != "a.2.txt" {
+		t.Fatalf("names = %q, %q; want a.txt, a.2.txt", first.Name, second.Name)
+	}
+	if _, err := os.Lstat(a); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("%s still exists", a)
+	}
+
+	info := readFile(t, filepath.Join(trashDir, "info", "a.txt.trashinfo"))
+	want := "[Trash Info]\nPath=" + strings.ReplaceAll(a, " ", "%20") + "\nDeletionDate=2025-03-04T05:06:07\n"
+	if info != want {
+		t.Fatalf("trashinfo = %q, want %q", info, want)
+	}
+
+	items, err := trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Name != "a.2.txt" || items[1].OriginalPath != a {
+		t.Fatalf("List = %+v", items)
+	}
+
+	if _, err := trash.Restore("a.txt", ""); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := readFile(t, a); got != a {
+		t.Fatalf("restored content = %q", got)
+	}
+
+	// b's original path is occupied again, so restoring must not clobber it.
+	os.WriteFile(b, []byte("new"), 0o644)
+	if _, err := trash.Restore("a.2.txt", ""); !errors.Is(err, mcpfs.ErrTrash) {
+		t.Fatalf("Restore over existing path: err = %v, want ErrTrash", err)
+	}
+	if _, err := trash.Get("../escape"); !errors.Is(err, mcpfs.ErrTrash) {
+		t.Fatalf("Get with path separator: err = %v, want ErrTrash", err)
+	}
+}
+
+func TestTrash_PutOnOtherFilesystem(t *testing.T) {
+	uid := os.Getuid()
+	vol, err := os.MkdirTemp("/dev/shm", "mcpfs-trash")
+	if uid < 0 || err != nil {
+		t.Skip("no /dev/shm to delete from")
+	}
+	t.Cleanup(func() { os.RemoveAll(vol) })
+	home := t.TempDir()
+	probe := filepath.Join(vol, "probe")
+	if err := os.WriteFile(probe, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(probe, filepath.Join(home, "probe")); !errors.Is(err, syscall.EXDEV) {
+		t.Skip("/dev/shm is on the filesystem of the temporary directory")
+	}
+	volTrash := filepath.Join("/dev/shm", fmt.Sprintf(".Trash-%d", uid))
+	if _, err := os.Lstat(volTrash); errors.Is(err, os.ErrNotExist) {
+		t.Cleanup(func() { os.RemoveAll(volTrash) })
+	}
+
+	trash := mcpfs.NewTrash(filepath.Join(home, "Trash"), nil)
+	path := filepath.Join(vol, "f.txt")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	item, err := trash.Put(path)
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	t.Cleanup(func() { trash.Purge(func(i mcpfs.TrashItem) bool { return i.Name != item.Name }) })
+
+	if got := readFile(t, filepath.Join(volTrash, "files", item.Name)); got != "data" {
+		t.Fatalf("item in the trash of /dev/shm = %q", got)
+	}
+	info := readFile(t, filepath.Join(volTrash, "info", item.Name+".trashinfo"))
+	if !strings.Contains(info, "\nPath="+filepath.Base(vol)+"/f.txt\n") {
+		t.Fatalf("trashinfo does not record the path relative to /dev/shm:\n%s", info)
+	}
+	if _, err := os.Stat(filepath.Join(home, "Trash", "files", item.Name)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("item copied into the home trash: %v", err)
+	}
+
+	got, err := trash.Get(item.Name)
+	if err != nil || got.OriginalPath != path {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+	if _, err := trash.Restore(item.Name, ""); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if got := readFile(t, path); got != "data" {
+		t.Fatalf("restored content = %q", got)
+	}
+}
+
+func TestTrash_Purge(t *testing.T) {
+	work := t.TempDir()
+	clock := std.NewTestClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local))
+	trash := mcpfs.NewTrash(filepath.Join(t.TempDir(), "Trash"), clock)
+
+	for _, name := range []string{"old", "new"} {
+		p := filepath.Join(work, name)
+		os.MkdirAll(filepath.Join(p, "sub"), 0o755)
+		os.WriteFile(filepath.Join(p, "sub", "f"), []byte(name), 0o644)
+		if _, err := trash.Put(p); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(48 * time.Hour)
+	}
+
+	cutoff := clock.Now().Add(-72 * time.Hour)
+	purged, err := trash.Purge(func(item mcpfs.TrashItem) bool { return item.DeletionDate.After(cutoff) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].Name != "old" || !purged[0].IsDir {
+		t.Fatalf("purged = %+v", purged)
+	}
+	items, _ := trash.List()
+	if len(items) != 1 || items[0].Name != "new" {
+		t.Fatalf("remaining = %+v", items)
+	}
+}